@@ -46,6 +46,42 @@ type ( // PUT /mcp/roots
 )
 
 type ( // POST /mcp/complete
+	// CompleteRequest is passed in the body of POST /mcp/complete to get suggested values for an argument
+	CompleteRequest struct {
+		Ref      CompleteReference `json:"ref"`
+		Argument CompleteArgument  `json:"argument"`
+		Context  *CompleteContext  `json:"context,omitempty"`
+	}
+
+	// CompleteReference identifies the prompt (by name) or resource template (by URI template) whose argument is
+	// being completed
+	CompleteReference struct {
+		Type string  `json:"type"` // RefTypePrompt or RefTypeResource
+		Name *string `json:"name,omitempty"`
+		URI  *string `json:"uri,omitempty"`
+	}
+
+	// CompleteArgument is the argument being completed and its partial value
+	CompleteArgument struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	// CompleteContext has the values of previously-entered arguments
+	CompleteContext struct {
+		Arguments map[string]string `json:"arguments,omitempty"`
+	}
+
+	// CompleteResult is returned in the body of POST /mcp/complete
+	CompleteResult struct {
+		Completion Completion `json:"completion"`
+	}
+
+	Completion struct {
+		Values  []string `json:"values"`            // At most MaxCompletionValues
+		Total   *int     `json:"total,omitempty"`   // Total number of matching values (may exceed len(Values))
+		HasMore *bool    `json:"hasMore,omitempty"` // True if there are more matching values than returned
+	}
 )
 
 type ( // GET /mcp/prompts
@@ -182,12 +218,33 @@ type (
 		SamplingRequest    *SamplingRequest    `json:"samplingRequest,omitempty"`
 		ElicitationRequest *ElicitationRequest `json:"elicitationRequest,omitempty"`
 		ServerData         *string             `json:"serverData,omitempty"` // Opaque ToolCall-specific state for round-tripping; allows some servers to avoid a durable state store
-		Progress           jsontext.Value      `json:"progress,omitempty"`
+		Progress           *Progress           `json:"progress,omitempty"`
 		Result             jsontext.Value      `json:"result,omitempty"`
 		Error              jsontext.Value      `json:"error,omitempty"`
 	}
 
 	Status string
+
+	// Progress is a running tool call's progress toward completion
+	Progress struct {
+		Progress float64    `json:"progress"`          // Increases as the tool call progresses, even if Total is unknown
+		Total    *float64   `json:"total,omitempty"`   // Progress's value when the tool call completes; nil if unknown
+		Message  *string    `json:"message,omitempty"` // Describes the tool call's current work
+		ETA      *time.Time `json:"eta,omitempty"`     // Estimated completion time; nil if unknown
+	}
+
+	// ListToolCallsResult is returned from GET /mcp/tools/{toolName}/calls
+	ListToolCallsResult struct {
+		ToolCalls         []ToolCallSummary `json:"toolCalls"`
+		ContinuationToken *string           `json:"continuationToken,omitempty"` // Pass as ?continuationToken= to get the next page
+	}
+
+	// ToolCallSummary is the subset of a ToolCall's fields returned when listing tool calls
+	ToolCallSummary struct {
+		ToolName *string `json:"toolname"`
+		ID       *string `json:"id"`
+		Status   *Status `json:"status"`
+	}
 )
 
 type ( // Sampling
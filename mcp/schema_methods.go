@@ -35,6 +35,25 @@ func (s Status) Terminated() bool {
 	return s == StatusSuccess || s == StatusFailed || s == StatusCanceled
 }
 
+// Fraction returns the portion (0-1) of the work completed; ok is false if Total is unknown
+func (p Progress) Fraction() (fraction float64, ok bool) {
+	if p.Total == nil || *p.Total <= 0 {
+		return 0, false
+	}
+	return min(max(p.Progress / *p.Total, 0), 1), true
+}
+
+// Done returns true if Progress has reached Total
+func (p Progress) Done() bool { return p.Total != nil && p.Progress >= *p.Total }
+
+const (
+	RefTypePrompt   = "ref/prompt"
+	RefTypeResource = "ref/resource"
+)
+
+// MaxCompletionValues is the maximum number of values in a Completion
+const MaxCompletionValues = 100
+
 const (
 	RoleUser      Role = "user"
 	RoleAssistant Role = "assistant"
@@ -159,3 +178,102 @@ func (er *ElicitationRequest) UnmarshalJSON(data []byte) error {
 
 	return nil
 }
+
+// UnmarshalContentBlock unmarshals data into the ContentBlock type identified by its "type" field.
+func UnmarshalContentBlock(data []byte) (ContentBlock, error) {
+	var temp struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &temp); aids.IsError(err) {
+		return nil, err
+	}
+	switch temp.Type {
+	case "text":
+		return unmarshalContentBlock[TextContent](data)
+	case "image":
+		return unmarshalContentBlock[ImageContent](data)
+	case "audio":
+		return unmarshalContentBlock[AudioContent](data)
+	case "resource_link":
+		return unmarshalContentBlock[ResourceLink](data)
+	case "resource":
+		return unmarshalContentBlock[EmbeddedResource](data)
+	default:
+		return nil, fmt.Errorf("unknown content block type: %q", temp.Type)
+	}
+}
+
+func unmarshalContentBlock[T ContentBlock](data []byte) (ContentBlock, error) {
+	var cb T
+	if err := json.Unmarshal(data, &cb); aids.IsError(err) {
+		return nil, err
+	}
+	return cb, nil
+}
+
+func (sm *SamplingMessage) UnmarshalJSON(data []byte) error {
+	var temp struct {
+		Role    Role            `json:"role"`
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &temp); aids.IsError(err) {
+		return err
+	}
+	content, err := UnmarshalContentBlock(temp.Content)
+	if aids.IsError(err) {
+		return err
+	}
+	sm.Role, sm.Content = temp.Role, content
+	return nil
+}
+
+func (pm *PromptMessage) UnmarshalJSON(data []byte) error {
+	var temp struct {
+		Role    Role            `json:"role"`
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &temp); aids.IsError(err) {
+		return err
+	}
+	content, err := UnmarshalContentBlock(temp.Content)
+	if aids.IsError(err) {
+		return err
+	}
+	pm.Role, pm.Content = temp.Role, content
+	return nil
+}
+
+// UnmarshalJSON sets Resource to a BlobResourceContents if the resource has a "blob" field; else TextResourceContents.
+func (e *EmbeddedResource) UnmarshalJSON(data []byte) error {
+	var temp struct {
+		Type        string          `json:"type"`
+		Resource    json.RawMessage `json:"resource"`
+		Annotations *Annotations    `json:"annotations,omitempty"`
+		Meta        Meta            `json:"_meta,omitempty"`
+	}
+	if err := json.Unmarshal(data, &temp); aids.IsError(err) {
+		return err
+	}
+	var blob struct {
+		Blob *string `json:"blob"`
+	}
+	if err := json.Unmarshal(temp.Resource, &blob); aids.IsError(err) {
+		return err
+	}
+	resource := any(nil)
+	if blob.Blob != nil {
+		brc := BlobResourceContents{}
+		if err := json.Unmarshal(temp.Resource, &brc); aids.IsError(err) {
+			return err
+		}
+		resource = brc
+	} else {
+		trc := TextResourceContents{}
+		if err := json.Unmarshal(temp.Resource, &trc); aids.IsError(err) {
+			return err
+		}
+		resource = trc
+	}
+	*e = EmbeddedResource{Type: temp.Type, Resource: resource, Annotations: temp.Annotations, Meta: temp.Meta}
+	return nil
+}
@@ -34,21 +34,48 @@ type mcpClient struct {
 	sharedKey string
 	urlPrefix string
 	dump      bool
+	oauth     *OAuthConfig // If not nil, tokens are requested when the server challenges the client
+	token     string       // The access token sent as "Authorization: Bearer"; empty if none
 }
 
 func (c *mcpClient) appendPath(path string) string { return c.urlPrefix + path }
 
 func (c *mcpClient) Do(method string, pathSuffix string, header http.Header, body any) (*http.Response, error) {
 	aids.Assert(header != nil, "header must be non-nil")
-	var bodyReader io.Reader = nil
+	var bodyBytes []byte = nil
 	if body != nil {
-		bodyReader = strings.NewReader(string(aids.MustMarshal(body)))
+		bodyBytes = aids.MustMarshal(body)
+	}
+	for attempt := 0; ; attempt++ {
+		response, err := c.do(method, pathSuffix, header, bodyBytes)
+		if aids.IsError(err) || c.oauth == nil || attempt > 0 {
+			return response, err
+		}
+		challenge, ok := bearerChallenge(response)
+		if !ok {
+			return response, err
+		}
+		// The server wants a (new) token; get one & retry the request once
+		response.Body.Close()
+		if err := c.authorize(challenge); aids.IsError(err) {
+			return nil, err
+		}
+	}
+}
+
+func (c *mcpClient) do(method string, pathSuffix string, header http.Header, bodyBytes []byte) (*http.Response, error) {
+	var bodyReader io.Reader = nil
+	if bodyBytes != nil {
+		bodyReader = bytes.NewReader(bodyBytes)
 	}
 	request := aids.Must(http.NewRequest(method, c.appendPath(pathSuffix), bodyReader))
-	request.Header = header
+	request.Header = header.Clone()
 	if c.sharedKey != "" {
 		request.Header.Add("SharedKey", c.sharedKey)
 	}
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
 
 	dumpBody := func(b *io.ReadCloser) string {
 		if *b == nil {
@@ -101,47 +128,109 @@ func (c *mcpClient) runToolCall(toolName, toolCallID string, tcp ToolCallProcess
 		response = get()
 	}
 	aids.AssertHttpStatus(response, http.StatusOK, http.StatusCreated)
-	tc := unmarshalBody[mcp.ToolCall](response.Body)
+	tc, etag := unmarshalBody[mcp.ToolCall](response.Body), response.Header.Get("ETag")
 
 	for !tc.Status.Terminated() {
 		tcp.ShowProgress(tc)
 		tcp.ShowPartialResults(tc)
 		switch *tc.Status {
 		case "awaitingSamplingResult":
-			result := tcp.Sample(tc)
+			result := withServerData(tcp.Sample(tc), tc.ServerData)
 			response = aids.Must(c.Do("POST", toolCallIDURL+"/advance", http.Header{
 				"Content-Type": []string{"application/json"},
 				"Accept":       []string{"application/json"},
-				"If-Match":     []string{*tc.ETag},
+				"If-Match":     []string{etag},
 			}, result))
 			if response.StatusCode == 412 {
 				response = get()
 			}
-			tc = unmarshalBody[mcp.ToolCall](response.Body)
+			tc, etag = unmarshalBody[mcp.ToolCall](response.Body), response.Header.Get("ETag")
 
 		case "awaitingElicitationResult":
-			result := tcp.Elicit(tc)
+			result := withServerData(tcp.Elicit(tc), tc.ServerData)
 			response = aids.Must(c.Do("POST", toolCallIDURL+"/advance", http.Header{
 				"Content-Type": []string{"application/json"},
 				"Accept":       []string{"application/json"},
-				"If-Match":     []string{*tc.ETag},
+				"If-Match":     []string{etag},
 			}, result))
 			if response.StatusCode == 412 {
 				response = get()
 			}
-			tc = unmarshalBody[mcp.ToolCall](response.Body)
+			tc, etag = unmarshalBody[mcp.ToolCall](response.Body), response.Header.Get("ETag")
 
 		case "submitted", "running":
-			// Optional: Pause execution
-			time.Sleep(200 * time.Millisecond)
-			response = get()
-			tc = unmarshalBody[mcp.ToolCall](response.Body)
+			// Stream the tool call's changes as Server-Sent Events until it needs client input or terminates
+			if events, eventID, ok := c.toolCallEvents(toolCallIDURL, tcp); ok {
+				tc, etag = events, eventID // An event's ID is the tool call's ETag
+				continue
+			}
+			// Events not available; long-poll: the server holds the GET until the tool call's ETag changes or the wait elapses (304)
+			response = aids.Must(c.Do("GET", toolCallIDURL, http.Header{
+				"Accept":        []string{"application/json"},
+				"If-None-Match": []string{etag},
+				"Prefer":        []string{"wait=20"},
+			}, nil))
+			if response.StatusCode == http.StatusNotModified {
+				response.Body.Close()
+				continue // Still running
+			}
+			aids.AssertHttpStatus(response, http.StatusOK)
+			tc, etag = unmarshalBody[mcp.ToolCall](response.Body), response.Header.Get("ETag")
 		}
 	}
 	tcp.Terminated(tc)
 	return tc
 }
 
+// withServerData returns result with the tool call's serverData (if any) so a stateless server can reconstruct
+// the tool call from it
+func withServerData(result any, serverData *string) any {
+	switch r := result.(type) {
+	case mcp.SamplingResult:
+		r.ServerData = serverData
+		return r
+	case mcp.ElicitationResult:
+		r.ServerData = serverData
+		return r
+	}
+	return result
+}
+
+// toolCallEvents reads the tool call's Server-Sent Events showing progress & partial results for each one.
+// It returns the last tool call received (& its event ID) once it is no longer server processing (or the
+// stream ends) and false if the server doesn't support events for this tool call.
+func (c *mcpClient) toolCallEvents(toolCallIDURL string, tcp ToolCallProcessor) (mcp.ToolCall, string, bool) {
+	response := aids.Must(c.Do("GET", toolCallIDURL+"/events", http.Header{"Accept": []string{"text/event-stream"}}, nil))
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return mcp.ToolCall{}, "", false
+	}
+	tc, received, id, eventID, data := mcp.ToolCall{}, false, "", "", strings.Builder{}
+	for scanner := bufio.NewScanner(response.Body); scanner.Scan(); {
+		line := scanner.Text()
+		if v, ok := strings.CutPrefix(line, "data:"); ok {
+			data.WriteString(strings.TrimPrefix(v, " "))
+			continue
+		}
+		if v, ok := strings.CutPrefix(line, "id:"); ok {
+			id = strings.TrimPrefix(v, " ")
+			continue
+		}
+		if line != "" || data.Len() == 0 {
+			continue // Ignore comments (keep-alives) & other fields; a blank line dispatches the event
+		}
+		printJson(jsontext.Value(data.String()))
+		tc, eventID, received = aids.MustUnmarshal[mcp.ToolCall](jsontext.Value(data.String())), id, true
+		data.Reset()
+		if !(*tc.Status).Processing() {
+			break
+		}
+		tcp.ShowProgress(tc)
+		tcp.ShowPartialResults(tc)
+	}
+	return tc, eventID, received
+}
+
 func unmarshalBody[T any](body io.ReadCloser) T {
 	defer body.Close()
 	jsonBody := aids.Must(io.ReadAll(body))
@@ -9,8 +9,8 @@ import (
 )
 
 var (
-	tcp    = NewAppToolCallProcessor(false)
-	stream = NewAppToolCallProcessor(true)
+	tcp    = NewAppToolCallProcessor(false, nil)
+	stream = NewAppToolCallProcessor(true, nil)
 )
 
 func main() {
@@ -27,6 +27,7 @@ Which demo do you want to do (1, 2, or 3):`)
 	switch demo {
 	case "1":
 		client := NewMCPClient("http://localhost:8080/mcp", "")
+		client.oauth = oauthConfigFromEnv()
 
 		// ***** Listing tools *****
 		/*client.dump = true
@@ -40,12 +41,16 @@ Which demo do you want to do (1, 2, or 3):`)
 		client.runToolCall("add", "ID-1", tcp, true, map[string]any{"x": 1, "y": 2})
 
 		// ***** Long-running Server processing tool call *****
-		client.runToolCall("count", "ID-1", tcp, true, map[string]any{"countto": 5})
+		client.runToolCall("count", "ID-1", tcp, true, map[string]any{"increments": 5})
 		client.dump = false
 
 		// ***** Streaming tool call  *****
 		client.runToolCall("stream", "ID-1", stream, true, nil)
 
+		// ***** Sampling tool call (a canned LLM answers each turn) *****
+		client.runToolCall("summarize", "ID-1", NewAppToolCallProcessor(false, NewCannedSampler()), true,
+			map[string]any{"text": "First paragraph.\n\nSecond paragraph."})
+
 		// ***** No Server state tool call  *****
 		*/
 		// ***** Long-running elicitation tool call *****
@@ -53,6 +58,7 @@ Which demo do you want to do (1, 2, or 3):`)
 
 	case "2":
 		client := NewMCPClient("http://localhost:8080/mcp", "")
+		client.oauth = oauthConfigFromEnv()
 
 		// ***** Crash recovery tool call *****
 		client.runToolCall("welcome", "ID-1", tcp, false, nil)
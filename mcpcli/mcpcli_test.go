@@ -27,19 +27,19 @@ func TestToolsList(t *testing.T) {
 }
 
 func TestToolCallEphemeral(t *testing.T) {
-	tcp := NewAppToolCallProcessor(false)
+	tcp := NewAppToolCallProcessor(false, nil)
 	tc := client.runToolCall("add", "ID-1", tcp, true, map[string]any{"x": 1, "y": 2})
 	_ = tc
 }
 
 func TestToolCallServerProcessing(t *testing.T) {
-	tcp := NewAppToolCallProcessor(false)
-	tc := client.runToolCall("count", "ID-1", tcp, true, map[string]any{"countto": 5})
+	tcp := NewAppToolCallProcessor(false, nil)
+	tc := client.runToolCall("count", "ID-1", tcp, true, map[string]any{"increments": 5})
 	_ = tc
 }
 
 func TestToolCallServerProcessingAfterCrash(t *testing.T) {
-	tcp := NewAppToolCallProcessor(false)
+	tcp := NewAppToolCallProcessor(false, nil)
 	tc := client.runToolCall("count", "ID-1", tcp, false, nil)
 	_ = tc
 }
@@ -51,14 +51,35 @@ func TestToolCallServerProcessingCancel(t *testing.T) {
 }
 
 func TestToolCallElicitation(t *testing.T) {
-	tcp := NewAppToolCallProcessor(false)
+	tcp := NewAppToolCallProcessor(false, nil)
 	tc := client.runToolCall("welcome", "ID-1", tcp, true, nil)
 	_ = tc
 }
 
 func TestToolCallStreaming(t *testing.T) {
 	// Steaming output is the DEBUG CONSOLE
-	tcp := NewAppToolCallProcessor(true)
+	tcp := NewAppToolCallProcessor(true, nil)
 	tc := client.runToolCall("stream", "ID-1", tcp, true, nil)
 	_ = tc
 }
+
+func TestToolCallSampling(t *testing.T) {
+	tcp := NewAppToolCallProcessor(false, NewCannedSampler("Summary one.", "Summary two.", "The whole summary."))
+	tc := client.runToolCall("summarize", "ID-1", tcp, true, map[string]any{"text": "First paragraph.\n\nSecond paragraph."})
+	if *tc.Status != mcp.StatusSuccess {
+		t.Fatalf("expected status %q, got %q", mcp.StatusSuccess, *tc.Status)
+	}
+}
+
+func TestCannedSampler(t *testing.T) {
+	sr := &mcp.SamplingRequest{Messages: []mcp.SamplingMessage{{Role: mcp.RoleUser, Content: mcp.TextContent{Type: "text", Text: "Echo me"}}}}
+	if text := NewCannedSampler().CreateMessage(sr).SamplingMessage.Content.(mcp.TextContent).Text; text != "Echo me" {
+		t.Fatalf("expected the prompt to be echoed, got %q", text)
+	}
+	s := NewCannedSampler("one", "two")
+	for _, want := range []string{"one", "two", "two"} {
+		if result := s.CreateMessage(sr); result.SamplingMessage.Content.(mcp.TextContent).Text != want || result.SamplingMessage.Role != mcp.RoleAssistant {
+			t.Fatalf("expected assistant response %q, got %+v", want, result.SamplingMessage)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// OAuthConfig configures how an mcpClient gets an access token when the MCP server challenges it with
+// "WWW-Authenticate: Bearer" (MCP authorization). Tokens are requested with the OAuth 2.1 client credentials grant.
+type OAuthConfig struct {
+	TokenEndpoint string   // The token endpoint; if empty, it's discovered via the resource's authorization server metadata
+	ClientID      string   // The client's ID at the authorization server
+	ClientSecret  string   // The client's secret at the authorization server
+	Scopes        []string // Scopes always requested; the challenge's scope (or the resource's scopes_supported) is added
+
+	// Issuers are the authorization servers whose discovered token endpoint may be used; the resource's metadata
+	// can't be trusted with ClientSecret so, if ClientSecret is set, TokenEndpoint or Issuers is required.
+	Issuers []string
+}
+
+// oauthConfigFromEnv returns an OAuthConfig from the MCPCLI_TOKEN_ENDPOINT, MCPCLI_CLIENT_ID, MCPCLI_CLIENT_SECRET,
+// MCPCLI_SCOPES & MCPCLI_ISSUERS (space-separated) environment variables; it returns nil if MCPCLI_CLIENT_ID isn't set.
+func oauthConfigFromEnv() *OAuthConfig {
+	if os.Getenv("MCPCLI_CLIENT_ID") == "" {
+		return nil
+	}
+	return &OAuthConfig{
+		TokenEndpoint: os.Getenv("MCPCLI_TOKEN_ENDPOINT"),
+		ClientID:      os.Getenv("MCPCLI_CLIENT_ID"),
+		ClientSecret:  os.Getenv("MCPCLI_CLIENT_SECRET"),
+		Scopes:        strings.Fields(os.Getenv("MCPCLI_SCOPES")),
+		Issuers:       strings.Fields(os.Getenv("MCPCLI_ISSUERS")),
+	}
+}
+
+// bearerChallenge returns the auth-params of response's "WWW-Authenticate: Bearer" challenge (RFC 6750) if the
+// client should get a (new) token & retry: the response is a 401 or a 403 with error="insufficient_scope".
+func bearerChallenge(response *http.Response) (map[string]string, bool) {
+	if response.StatusCode != http.StatusUnauthorized && response.StatusCode != http.StatusForbidden {
+		return nil, false
+	}
+	scheme, rest, _ := strings.Cut(response.Header.Get("WWW-Authenticate"), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return nil, false
+	}
+	params := map[string]string{}
+	for rest = strings.TrimSpace(rest); rest != ""; {
+		name, value, _ := strings.Cut(rest, "=")
+		name, value = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(value)
+		if v, ok := strings.CutPrefix(value, `"`); ok { // quoted-string
+			value, rest, _ = strings.Cut(v, `"`)
+		} else { // token
+			value, rest, _ = strings.Cut(value, ",")
+		}
+		params[name] = value
+		rest = strings.TrimLeft(rest, ", ")
+	}
+	if response.StatusCode == http.StatusForbidden && params["error"] != "insufficient_scope" {
+		return nil, false
+	}
+	return params, true
+}
+
+// authorize gets an access token for the challenge's resource: it discovers the resource's metadata (RFC 9728),
+// which must be for c.urlPrefix's origin, & (unless c.oauth.TokenEndpoint is set) the token endpoint (RFC 8414) of
+// its 1st authorization server in c.oauth.Issuers, & then requests a token for the resource (RFC 8707) with the
+// client credentials grant.
+func (c *mcpClient) authorize(challenge map[string]string) error {
+	scopes, resource, tokenEndpoint := slices.Clone(c.oauth.Scopes), "", c.oauth.TokenEndpoint
+	scopes = append(scopes, strings.Fields(challenge["scope"])...)
+	if metadataURL := challenge["resource_metadata"]; metadataURL != "" {
+		var md struct {
+			Resource             string   `json:"resource"`
+			AuthorizationServers []string `json:"authorization_servers"`
+			ScopesSupported      []string `json:"scopes_supported"`
+		}
+		if err := c.getJSON(metadataURL, &md); err != nil {
+			return fmt.Errorf("getting protected resource metadata: %w", err)
+		}
+		if !sameOrigin(md.Resource, c.urlPrefix) { // RFC 9728 §3.3: the metadata must be for the resource being accessed
+			return fmt.Errorf("protected resource metadata is for '%s', not '%s'", md.Resource, c.urlPrefix)
+		}
+		resource = md.Resource
+		if challenge["scope"] == "" {
+			scopes = append(scopes, md.ScopesSupported...)
+		}
+		if tokenEndpoint == "" {
+			if c.oauth.ClientSecret != "" && len(c.oauth.Issuers) == 0 {
+				return fmt.Errorf("a token endpoint or trusted issuers must be configured to send the client secret")
+			}
+			issuer, ok := "", false
+			for _, issuer = range md.AuthorizationServers {
+				if ok = len(c.oauth.Issuers) == 0 || slices.Contains(c.oauth.Issuers, issuer); ok {
+					break
+				}
+			}
+			if !ok {
+				return fmt.Errorf("none of the authorization servers %v is a trusted issuer", md.AuthorizationServers)
+			}
+			var asm struct {
+				Issuer        string `json:"issuer"`
+				TokenEndpoint string `json:"token_endpoint"`
+			}
+			if err := c.getJSON(wellKnownURL(issuer, "/.well-known/oauth-authorization-server"), &asm); err != nil {
+				return fmt.Errorf("getting authorization server metadata: %w", err)
+			}
+			if asm.Issuer != issuer { // RFC 8414 §3.3
+				return fmt.Errorf("authorization server metadata is for issuer '%s', not '%s'", asm.Issuer, issuer)
+			}
+			tokenEndpoint = asm.TokenEndpoint
+		}
+	}
+	if tokenEndpoint == "" {
+		return fmt.Errorf("no token endpoint configured or discovered")
+	}
+
+	slices.Sort(scopes)
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {c.oauth.ClientID}, "client_secret": {c.oauth.ClientSecret}}
+	if scopes = slices.Compact(scopes); len(scopes) > 0 {
+		form.Set("scope", strings.Join(scopes, " "))
+	}
+	if resource != "" {
+		form.Set("resource", resource)
+	}
+	response, err := c.Client.PostForm(tokenEndpoint, form)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return fmt.Errorf("token endpoint returned %s: %w", response.Status, err)
+	}
+	if response.StatusCode != http.StatusOK || token.AccessToken == "" || !strings.EqualFold(token.TokenType, "Bearer") {
+		return fmt.Errorf("token endpoint returned %s: %s %s", response.Status, token.Error, token.ErrorDescription)
+	}
+	c.token = token.AccessToken
+	return nil
+}
+
+// getJSON GETs url's JSON body into v
+func (c *mcpClient) getJSON(url string, v any) error {
+	response, err := c.Client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("GET %s returned %s: %s", url, response.Status, body)
+	}
+	return json.NewDecoder(response.Body).Decode(v)
+}
+
+// sameOrigin returns true if URLs a & b have the same scheme & host (including port)
+func sameOrigin(a, b string) bool {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	return errA == nil && errB == nil && ua.Host != "" && strings.EqualFold(ua.Scheme, ub.Scheme) && strings.EqualFold(ua.Host, ub.Host)
+}
+
+// wellKnownURL returns issuer's metadata URL: the well-known path inserted between issuer's host & path (RFC 8414 §3.1)
+func wellKnownURL(issuer, wellKnownPath string) string {
+	u, err := url.Parse(issuer)
+	if err != nil || u.Host == "" {
+		return strings.TrimSuffix(issuer, "/") + wellKnownPath
+	}
+	return u.Scheme + "://" + u.Host + wellKnownPath + strings.TrimSuffix(u.EscapedPath(), "/")
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JeffreyRichter/internal/aids"
+)
+
+// newTestAuthServer returns a stand-in MCP server & authorization server: /mcp/* requires the token it issues
+// at /token to the "cli" client for the resource, which it advertises via its protected resource metadata.
+func newTestAuthServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	writeJSON := func(w http.ResponseWriter, status int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		aids.Must0(json.NewEncoder(w).Encode(v))
+	}
+	mux.HandleFunc("GET /.well-known/oauth-protected-resource/mcp", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"resource": srv.URL + "/mcp", "authorization_servers": []string{srv.URL + "/as"}, "scopes_supported": []string{"tools:read"}})
+	})
+	mux.HandleFunc("GET /.well-known/oauth-authorization-server/as", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"issuer": srv.URL + "/as", "token_endpoint": srv.URL + "/as/token"})
+	})
+	mux.HandleFunc("POST /as/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_id") != "cli" || r.FormValue("client_secret") != "secret" {
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid_client"})
+			return
+		}
+		if r.FormValue("resource") != srv.URL+"/mcp" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_target"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"access_token": "token:" + r.FormValue("scope"), "token_type": "Bearer", "expires_in": 3600})
+	})
+	mux.HandleFunc("GET /mcp/tools", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer token:tools:read":
+			writeJSON(w, http.StatusOK, map[string]any{"tools": []any{}})
+		case "":
+			w.Header().Set("WWW-Authenticate", `Bearer resource_metadata="`+srv.URL+`/.well-known/oauth-protected-resource/mcp"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": map[string]any{"code": "Unauthorized"}})
+		default:
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": map[string]any{"code": "InvalidToken"}})
+		}
+	})
+	return srv
+}
+
+func TestOAuthChallenge(t *testing.T) {
+	srv := newTestAuthServer(t)
+	for name, oauth := range map[string]*OAuthConfig{
+		"TrustedIssuer":      {ClientID: "cli", ClientSecret: "secret", Issuers: []string{srv.URL + "/as"}},
+		"ConfiguredEndpoint": {ClientID: "cli", ClientSecret: "secret", TokenEndpoint: srv.URL + "/as/token"},
+	} {
+		c := NewMCPClient(srv.URL+"/mcp", "")
+		c.oauth = oauth
+		response := aids.Must(c.Do("GET", "/tools", http.Header{"Accept": []string{"application/json"}}, nil))
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK || c.token != "token:tools:read" {
+			t.Fatalf("%s: expected the client to get a token & retry, got %d with token %q", name, response.StatusCode, c.token)
+		}
+	}
+
+	// Without OAuthConfig, the challenge is returned to the caller
+	response := aids.Must(NewMCPClient(srv.URL+"/mcp", "").Do("GET", "/tools", http.Header{}, nil))
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", response.StatusCode)
+	}
+
+	// A rejected client gets an error instead of retrying forever
+	c := NewMCPClient(srv.URL+"/mcp", "")
+	c.oauth = &OAuthConfig{ClientID: "cli", ClientSecret: "wrong", Issuers: []string{srv.URL + "/as"}}
+	if _, err := c.Do("GET", "/tools", http.Header{}, nil); err == nil {
+		t.Fatal("expected an error for invalid client credentials")
+	}
+
+	// The secret is never sent to a token endpoint discovered via untrusted metadata
+	for name, tc := range map[string]struct {
+		urlPrefix string
+		oauth     *OAuthConfig
+	}{
+		"NoTrustedIssuers":    {urlPrefix: srv.URL + "/mcp", oauth: &OAuthConfig{ClientID: "cli", ClientSecret: "secret"}},
+		"UntrustedIssuer":     {urlPrefix: srv.URL + "/mcp", oauth: &OAuthConfig{ClientID: "cli", ClientSecret: "secret", Issuers: []string{"https://login.example.com"}}},
+		"OtherResourceOrigin": {urlPrefix: strings.Replace(srv.URL, "127.0.0.1", "localhost", 1) + "/mcp", oauth: &OAuthConfig{ClientID: "cli", ClientSecret: "secret", Issuers: []string{srv.URL + "/as"}}},
+	} {
+		c := NewMCPClient(tc.urlPrefix, "")
+		c.oauth = tc.oauth
+		if _, err := c.Do("GET", "/tools", http.Header{}, nil); err == nil || c.token != "" {
+			t.Fatalf("%s: expected an error without getting a token, got %v with token %q", name, err, c.token)
+		}
+	}
+}
+
+func TestBearerChallenge(t *testing.T) {
+	for header, expected := range map[string]map[string]string{
+		`Bearer`: {},
+		`Bearer resource_metadata="https://x/.well-known/oauth-protected-resource", scope="a b"`: {"resource_metadata": "https://x/.well-known/oauth-protected-resource", "scope": "a b"},
+		`bearer error=invalid_token,realm="mcp"`:                                                 {"error": "invalid_token", "realm": "mcp"},
+	} {
+		params, ok := bearerChallenge(&http.Response{StatusCode: http.StatusUnauthorized, Header: http.Header{"Www-Authenticate": []string{header}}})
+		if !ok || len(params) != len(expected) {
+			t.Errorf("%s: expected %v, got %v", header, expected, params)
+			continue
+		}
+		for k, v := range expected {
+			if params[k] != v {
+				t.Errorf("%s: expected %s=%q, got %q", header, k, v, params[k])
+			}
+		}
+	}
+	for status, header := range map[int]string{
+		http.StatusForbidden:  `Bearer error="invalid_request"`, // Only insufficient_scope warrants a new token
+		http.StatusBadRequest: `Bearer error="invalid_request"`,
+		http.StatusOK:         `Basic realm="x"`,
+	} {
+		if _, ok := bearerChallenge(&http.Response{StatusCode: status, Header: http.Header{"Www-Authenticate": []string{header}}}); ok {
+			t.Errorf("%d %s: expected no challenge", status, header)
+		}
+	}
+	if params, ok := bearerChallenge(&http.Response{StatusCode: http.StatusForbidden,
+		Header: http.Header{"Www-Authenticate": []string{`Bearer error="insufficient_scope", scope="tools:write"`}}}); !ok || params["scope"] != "tools:write" {
+		t.Errorf("expected an insufficient_scope challenge, got %v", params)
+	}
+}
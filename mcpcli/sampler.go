@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+)
+
+// Sampler generates an LLM response for a tool call's sampling request.
+// https://modelcontextprotocol.io/specification/2025-06-18/client/sampling
+type Sampler interface {
+	// CreateMessage returns the LLM's response to the sampling request's messages.
+	CreateMessage(sr *mcp.SamplingRequest) mcp.SamplingResult
+}
+
+// cannedSampler is a deterministic, offline Sampler: it returns its canned responses in order, repeating the
+// last one when they run out. With no canned responses, it echoes the last user message's text.
+type cannedSampler struct {
+	mu        sync.Mutex
+	responses []string
+	next      int
+}
+
+// NewCannedSampler creates a Sampler returning the canned responses in order
+func NewCannedSampler(responses ...string) Sampler { return &cannedSampler{responses: responses} }
+
+func (s *cannedSampler) CreateMessage(sr *mcp.SamplingRequest) mcp.SamplingResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	text := ""
+	switch {
+	case len(s.responses) > 0:
+		text = s.responses[min(s.next, len(s.responses)-1)]
+		s.next++
+	case len(sr.Messages) > 0:
+		if tc, ok := sr.Messages[len(sr.Messages)-1].Content.(mcp.TextContent); ok {
+			text = strings.TrimSpace(tc.Text)
+		}
+	}
+	return mcp.SamplingResult{
+		SamplingMessage: mcp.SamplingMessage{Role: mcp.RoleAssistant, Content: mcp.TextContent{Type: "text", Text: text}},
+		Model:           "canned",
+		StopReason:      aids.New("endTurn"),
+	}
+}
@@ -11,21 +11,48 @@ import (
 	"github.com/JeffreyRichter/mcp"
 )
 
-func NewAppToolCallProcessor(stream bool) *appToolCallProcessor {
-	return &appToolCallProcessor{stream: stream}
+// NewAppToolCallProcessor creates a ToolCallProcessor; sampler answers sampling requests (nil echoes the prompt)
+func NewAppToolCallProcessor(stream bool, sampler Sampler) *appToolCallProcessor {
+	if sampler == nil {
+		sampler = NewCannedSampler()
+	}
+	return &appToolCallProcessor{stream: stream, sampler: sampler}
 }
 
 type appToolCallProcessor struct {
-	stream      bool
-	streamIndex int
+	stream       bool
+	streamIndex  int
+	sampler      Sampler
+	lastProgress string // The last progress line shown; unchanged progress isn't shown again
 }
 
 func (tcp *appToolCallProcessor) ShowProgress(tc mcp.ToolCall) {
-	if tc.Progress != nil {
-		FgYellow.Printf("Progress: %v\n", tc.Progress)
+	if tc.Progress == nil {
+		return
+	}
+	if line := progressLine(*tc.Progress, 30); line != tcp.lastProgress {
+		FgYellow.Printf("Progress: %s\n", line)
+		tcp.lastProgress = line
 	}
 }
 
+// progressLine renders p as a progress bar width characters wide (or a count if the total is unknown)
+// followed by its message & ETA.
+func progressLine(p mcp.Progress, width int) string {
+	line := fmt.Sprintf("%v", p.Progress)
+	if fraction, ok := p.Fraction(); ok {
+		filled := int(math.Round(fraction * float64(width)))
+		line = fmt.Sprintf("[%s%s] %3.0f%%", strings.Repeat("█", filled), strings.Repeat("░", width-filled), fraction*100)
+	}
+	if p.Message != nil {
+		line += " " + *p.Message
+	}
+	if p.ETA != nil {
+		line += fmt.Sprintf(" (ETA %v)", max(time.Until(*p.ETA).Round(time.Second), 0))
+	}
+	return line
+}
+
 func (tcp *appToolCallProcessor) ShowPartialResults(tc mcp.ToolCall) {
 	if tc.Result != nil {
 		if !tcp.stream {
@@ -51,9 +78,18 @@ func (tcp *appToolCallProcessor) StreamText(text string, charsPerSecond int) {
 	}
 }
 
+// Sample handles this part of the MCP spec: https://modelcontextprotocol.io/specification/2025-06-18/client/sampling
 func (tcp *appToolCallProcessor) Sample(tc mcp.ToolCall) any {
-	FgHiGreen.Printf("SamplingRequest: %v\n", tc.SamplingRequest)
-	return nil
+	if m := tc.SamplingRequest.Messages; len(m) > 0 {
+		if text, ok := m[len(m)-1].Content.(mcp.TextContent); ok {
+			FgHiGreen.Printf("Sampling request: %s\n", text.Text)
+		}
+	}
+	result := tcp.sampler.CreateMessage(tc.SamplingRequest)
+	if text, ok := result.SamplingMessage.Content.(mcp.TextContent); ok {
+		FgHiCyan.Printf("Sampling result (%s): %s\n", result.Model, text.Text)
+	}
+	return result
 }
 
 var actions = map[string]string{"a": "accept", "d": "decline", "c": "cancel"}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/mcpsvr/toolcall"
+	"github.com/JeffreyRichter/svrcore"
+	"github.com/JeffreyRichter/svrcore/stages"
+)
+
+var testJWTKey = []byte("test-jwt-signing-key")
+
+// testAuthStage authenticates requests with HS256 JWTs signed by testJWTKey
+var testAuthStage = stages.NewAuthStage(stages.AuthConfig{Keys: stages.JWTKeys{"test": testJWTKey}})
+
+// testToken returns an "Authorization: Bearer" header for tenant's subject granted scopes
+func testToken(tenant, subject string, scopes ...string) http.Header {
+	b64 := func(v any) string { return base64.RawURLEncoding.EncodeToString(aids.MustMarshal(v)) }
+	signed := b64(map[string]any{"alg": "HS256", "kid": "test"}) + "." +
+		b64(map[string]any{"sub": subject, "tid": tenant, "scope": strings.Join(scopes, " "), "exp": time.Now().Add(time.Hour).Unix()})
+	mac := hmac.New(sha256.New, testJWTKey)
+	mac.Write([]byte(signed))
+	return http.Header{"Authorization": []string{"Bearer " + signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))}}
+}
+
+// withHeader returns h with the headers in more added
+func withHeader(h http.Header, more http.Header) http.Header {
+	for k, v := range more {
+		h[k] = v
+	}
+	return h
+}
+
+func TestToolCallTenantIsolation(t *testing.T) {
+	client := &testClient{t: t, url: testServerFor(t, testSvr, testAuthStage).URL}
+	urlPath := "/mcp/tools/welcome/calls/" + t.Name()
+
+	resp := client.Put(urlPath, http.Header{"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)}}, strings.NewReader(`{}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || !strings.HasPrefix(resp.Header.Get("WWW-Authenticate"), "Bearer") {
+		t.Fatalf("expected 401 with a Bearer challenge, got %d %q", resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+	}
+
+	resp = client.Put(urlPath, withHeader(testToken("tenant-a", "alice", scopeToolsRead), http.Header{"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)}}), strings.NewReader(`{}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected tenant-a's PUT to succeed, got %d", resp.StatusCode)
+	}
+
+	for tenant, status := range map[string]int{"tenant-a": http.StatusOK, "tenant-b": http.StatusNotFound} {
+		resp = client.Get(urlPath, testToken(tenant, "someone", scopeToolsRead))
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("%s: expected GET status %d, got %d", tenant, status, resp.StatusCode)
+		}
+	}
+
+	// Tenant B's tool call with the same ID is distinct from tenant A's
+	resp = client.Put(urlPath, withHeader(testToken("tenant-b", "bob", scopeToolsRead), http.Header{"Idempotency-Key": []string{"other"}}), strings.NewReader(`{}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected tenant-b's PUT to succeed, got %d", resp.StatusCode)
+	}
+
+	resp = client.Get(urlPath, testToken("Not_Valid", "mallory"))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for an invalid tenant, got %d", resp.StatusCode)
+	}
+}
+
+func TestAnonymousPaths(t *testing.T) {
+	jwks := filepath.Join(t.TempDir(), "jwks.json")
+	aids.Must0(os.WriteFile(jwks, aids.MustMarshal(map[string]any{"keys": []any{
+		map[string]any{"kty": "oct", "kid": "test", "k": base64.RawURLEncoding.EncodeToString(testJWTKey)},
+	}}), 0o600))
+	healthProbes := stages.NewHealthProbes(stages.HealthProbesConfig{})
+	srv := httptest.NewServer(svrcore.BuildHandler(svrcore.BuildHandlerConfig{
+		Stages: []svrcore.Stage{newApiVersionSimulatorStage(), newAuthStage(Configuration{AuthJWKSFile: jwks}, nil)},
+		ApiVersionInfos: []*svrcore.ApiVersionInfo{{GetRoutes: func(baseRoutes svrcore.ApiVersionRoutes) svrcore.ApiVersionRoutes {
+			return noApiVersionRoutes(baseRoutes, healthProbes)
+		}}},
+		ApiVersionKeyName:     "Api-Version",
+		ApiVersionKeyLocation: svrcore.ApiVersionKeyLocationHeader,
+		Logger:                slog.Default(),
+	}))
+	t.Cleanup(srv.Close)
+	client := &testClient{t: t, url: srv.URL}
+
+	for _, path := range []string{"/health/live", "/health/ready", "/debug/health"} {
+		resp := client.Get(path, http.Header{})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: expected the health probe to be anonymous, got %d", path, resp.StatusCode)
+		}
+	}
+	// The debug URLs expose the process's internals; the handlers never run without a token so profiles aren't taken
+	for _, path := range []string{"/debug/metrics", "/debug/pprof", "/debug/cmdline", "/debug/profile", "/debug/symbol", "/debug/trace", "/health/other"} {
+		resp := client.Get(path, http.Header{})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401 without a token, got %d", path, resp.StatusCode)
+		}
+	}
+	resp := client.Get("/debug/metrics", testToken("tenant-a", "alice"))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected /debug/metrics to succeed with a token, got %d", resp.StatusCode)
+	}
+}
+
+func TestRequiredScopes(t *testing.T) {
+	for name, c := range map[string]struct {
+		annotations *mcp.ToolAnnotations
+		declared    []string
+		expected    []string
+	}{
+		"no annotations":     {nil, nil, []string{scopeToolsWrite, scopeToolsDestructive}},
+		"read-only":          {&mcp.ToolAnnotations{ReadOnlyHint: aids.New(true)}, nil, []string{scopeToolsRead}},
+		"no destructiveHint": {&mcp.ToolAnnotations{ReadOnlyHint: aids.New(false)}, nil, []string{scopeToolsWrite, scopeToolsDestructive}},
+		"not destructive":    {&mcp.ToolAnnotations{DestructiveHint: aids.New(false)}, nil, []string{scopeToolsWrite}},
+		"declared":           {&mcp.ToolAnnotations{ReadOnlyHint: aids.New(true)}, []string{"billing"}, []string{"billing"}},
+	} {
+		ti := toolcall.Define(toolcall.Definition[struct{}, struct{}]{
+			Tool:      mcp.Tool{BaseMetadata: mcp.BaseMetadata{Name: "test"}, Annotations: c.annotations},
+			Scopes:    c.declared,
+			Ephemeral: true,
+			Phase: func(context.Context, toolcall.PhaseInput[struct{}, struct{}]) (toolcall.Step[struct{}], *svrcore.ServerError) {
+				return toolcall.Step[struct{}]{Status: mcp.StatusSuccess}, nil
+			},
+		})
+		if actual := requiredScopes(ti); !slices.Equal(actual, c.expected) {
+			t.Errorf("%s: expected %v, got %v", name, c.expected, actual)
+		}
+	}
+}
+
+func TestToolScopes(t *testing.T) {
+	client := &testClient{t: t, url: testServerFor(t, testSvr, testAuthStage).URL}
+
+	listTools := func(h http.Header) []string {
+		resp := client.Get("/mcp/tools", h)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 listing tools, got %d", resp.StatusCode)
+		}
+		var result mcp.ListToolsResult
+		aids.Must0(json.NewDecoder(resp.Body).Decode(&result))
+		names := []string{}
+		for _, tool := range result.Tools {
+			names = append(names, tool.Name)
+		}
+		return names
+	}
+	for _, c := range []struct {
+		scopes   []string
+		expected []string
+	}{
+		{nil, []string{}},
+		{[]string{scopeToolsRead}, []string{"stream", "summarize", "welcome"}},
+		{[]string{scopeToolsWrite}, []string{"add", "count"}},
+		{[]string{scopeToolsRead, scopeToolsWrite}, []string{"add", "count", "stream", "summarize", "welcome"}},
+	} {
+		if actual := listTools(testToken("tenant-a", "alice", c.scopes...)); !slices.Equal(actual, c.expected) {
+			t.Errorf("scopes %v: expected tools %v, got %v", c.scopes, c.expected, actual)
+		}
+	}
+
+	// A read-only caller can't create a tool call that may modify its environment
+	putAdd := func(h http.Header) *http.Response {
+		return client.Put("/mcp/tools/add/calls/"+t.Name(), withHeader(h, http.Header{"Idempotency-Key": []string{t.Name()}}), strings.NewReader(`{"x":1,"y":2}`))
+	}
+	resp := putAdd(testToken("tenant-a", "alice", scopeToolsRead))
+	resp.Body.Close()
+	if challenge := resp.Header.Get("WWW-Authenticate"); resp.StatusCode != http.StatusForbidden ||
+		challenge != `Bearer error="insufficient_scope", scope="tools:write"` {
+		t.Fatalf("expected 403 with an insufficient_scope challenge, got %d %q", resp.StatusCode, challenge)
+	}
+	resp = putAdd(testToken("tenant-a", "alice", scopeToolsWrite))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a caller with tools:write to create an add tool call, got %d", resp.StatusCode)
+	}
+
+	// Advancing & canceling a tool call require the same scopes as creating it
+	urlPath := "/mcp/tools/welcome/calls/" + t.Name()
+	resp = client.Put(urlPath, withHeader(testToken("tenant-a", "alice", scopeToolsRead), http.Header{"Idempotency-Key": []string{t.Name()}}), strings.NewReader(`{}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a caller with tools:read to create a welcome tool call, got %d", resp.StatusCode)
+	}
+	for op, body := range map[string]io.Reader{"/advance": strings.NewReader(`{"action":"decline"}`), "/cancel": nil} {
+		resp = client.Post(urlPath+op, testToken("tenant-a", "alice", scopeToolsWrite), body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden || !strings.Contains(resp.Header.Get("WWW-Authenticate"), `error="insufficient_scope"`) {
+			t.Errorf("%s: expected 403 with an insufficient_scope challenge, got %d", op, resp.StatusCode)
+		}
+	}
+}
+
+func TestProtectedResourceMetadata(t *testing.T) {
+	prc := newProtectedResourceConfig(Configuration{AuthJWKSFile: "jwks.json", AuthIssuer: "https://login.example.com"}, testSvr)
+	srv := testServerFor(t, testSvr, stages.NewProtectedResourceStage(*prc),
+		stages.NewAuthStage(stages.AuthConfig{Keys: stages.JWTKeys{"test": testJWTKey}, ProtectedResource: prc}))
+	client := &testClient{t: t, url: srv.URL}
+
+	resp := client.Get("/mcp/tools", http.Header{})
+	resp.Body.Close()
+	if challenge := resp.Header.Get("WWW-Authenticate"); resp.StatusCode != http.StatusUnauthorized ||
+		challenge != `Bearer resource_metadata="`+srv.URL+stages.ProtectedResourceMetadataPath+`"` {
+		t.Fatalf("expected 401 with a resource_metadata challenge, got %d %q", resp.StatusCode, challenge)
+	}
+
+	resp = client.Get(stages.ProtectedResourceMetadataPath, http.Header{})
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for the metadata, got %d", resp.StatusCode)
+	}
+	var md stages.ProtectedResourceMetadata
+	aids.Must0(json.NewDecoder(resp.Body).Decode(&md))
+	if md.Resource != srv.URL || !slices.Equal(md.AuthorizationServers, []string{"https://login.example.com"}) ||
+		!slices.Equal(md.ScopesSupported, []string{scopeToolsRead, scopeToolsWrite}) {
+		t.Fatalf("unexpected metadata %+v", md)
+	}
+}
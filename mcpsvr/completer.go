@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+// Completer is optionally implemented by a PromptInfo or ResourceProvider to suggest values for a prompt argument
+// or resource template variable as the user enters it.
+type Completer interface {
+	// Complete returns suggested values for ref's argument given the argument's partial value; args has the values
+	// of ref's previously-entered arguments. argument.Name is always one of ref's arguments.
+	Complete(ctx context.Context, ref mcp.CompleteReference, argument mcp.CompleteArgument, args map[string]string) ([]string, *svrcore.ServerError)
+}
+
+// completeFrom returns the candidates starting with prefix (case-insensitively) preserving the candidates' order.
+func completeFrom(candidates []string, prefix string) []string {
+	values := []string{}
+	for _, c := range candidates {
+		if strings.HasPrefix(strings.ToLower(c), strings.ToLower(prefix)) {
+			values = append(values, c)
+		}
+	}
+	return values
+}
+
+// newCompletion returns a Completion with at most mcp.MaxCompletionValues of values.
+func newCompletion(values []string) mcp.Completion {
+	c := mcp.Completion{Values: values, Total: aids.New(len(values)), HasMore: aids.New(false)}
+	if len(values) > mcp.MaxCompletionValues {
+		c.Values, c.HasMore = values[:mcp.MaxCompletionValues], aids.New(true)
+	}
+	return c
+}
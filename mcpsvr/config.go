@@ -8,11 +8,25 @@ import (
 )
 
 type Configuration struct {
-	AzureBlobURL   string `env:"AZURE_BLOB_URL"`
-	AzureQueueURL  string `env:"AZURE_QUEUE_URL"`
-	AzuriteAccount string `env:"AZURITE_ACCOUNT"`
-	AzuriteKey     string `env:"AZURITE_KEY"`
-	Local          bool   `env:"LOCAL"`
+	AzureBlobURL     string `env:"AZURE_BLOB_URL"`
+	AzureQueueURL    string `env:"AZURE_QUEUE_URL"`
+	AzuriteAccount   string `env:"AZURITE_ACCOUNT"`
+	AzuriteKey       string `env:"AZURITE_KEY"`
+	Local            bool   `env:"LOCAL"`
+	Stateless        bool   `env:"STATELESS"`         // Tool calls are round-tripped via clients' serverData instead of stored
+	ServerDataKeys   string `env:"SERVER_DATA_KEYS"`  // Comma-separated keyID:hexKey pairs (newest first) encrypting serverData
+	AuthJWKSFile     string `env:"AUTH_JWKS_FILE"`    // JWKS verifying Authorization: Bearer JWTs; empty disables authentication
+	AuthIssuer       string `env:"AUTH_ISSUER"`       // If set, JWTs' "iss" claim must match
+	AuthAudience     string `env:"AUTH_AUDIENCE"`     // If set, JWTs' "aud" claim must contain it
+	AuthTenantClaim  string `env:"AUTH_TENANT_CLAIM"` // JWT claim holding the caller's tenant; default "tid"
+	AuthServers      string `env:"AUTH_SERVERS"`      // Comma-separated authorization server issuer URLs; default AUTH_ISSUER
+	AuthResource     string `env:"AUTH_RESOURCE"`     // This server's canonical URI (ex: https://mcp.example.com/mcp); default the request's host
+	RateLimitPut     string `env:"RATE_LIMIT_PUT"`    // Each caller's PUT (new tool call) budget as limit/window; default 60/1m
+	RateLimitGet     string `env:"RATE_LIMIT_GET"`    // Each caller's GET (polling) budget as limit/window; default 1200/1m
+	Webhooks         bool   `env:"WEBHOOKS"`          // Accept Notification-Url; each tool call's notifications are signed with its own secret
+	WebhookHosts     string `env:"WEBHOOK_HOSTS"`     // Comma-separated hosts Notification-Url may use; empty allows any public host
+	OutputValidation string `env:"OUTPUT_VALIDATION"` // off (default), debug, or strict; see OutputValidation
+	OTLPEndpoint     string `env:"OTLP_ENDPOINT"`     // OTLP/HTTP collector receiving trace spans (ex: http://localhost:4318); empty disables exporting
 }
 
 func (c *Configuration) Load() {
@@ -33,6 +47,34 @@ func (c *Configuration) Load() {
 			c.AzuriteKey = tokens[1]
 		case "LOCAL":
 			c.Local = tokens[1] == "true"
+		case "STATELESS":
+			c.Stateless = tokens[1] == "true"
+		case "SERVER_DATA_KEYS":
+			c.ServerDataKeys = tokens[1]
+		case "AUTH_JWKS_FILE":
+			c.AuthJWKSFile = tokens[1]
+		case "AUTH_ISSUER":
+			c.AuthIssuer = tokens[1]
+		case "AUTH_AUDIENCE":
+			c.AuthAudience = tokens[1]
+		case "AUTH_TENANT_CLAIM":
+			c.AuthTenantClaim = tokens[1]
+		case "AUTH_SERVERS":
+			c.AuthServers = tokens[1]
+		case "AUTH_RESOURCE":
+			c.AuthResource = tokens[1]
+		case "RATE_LIMIT_PUT":
+			c.RateLimitPut = tokens[1]
+		case "RATE_LIMIT_GET":
+			c.RateLimitGet = tokens[1]
+		case "WEBHOOKS":
+			c.Webhooks = tokens[1] == "true"
+		case "WEBHOOK_HOSTS":
+			c.WebhookHosts = tokens[1]
+		case "OUTPUT_VALIDATION":
+			c.OutputValidation = tokens[1]
+		case "OTLP_ENDPOINT":
+			c.OTLPEndpoint = tokens[1]
 		default:
 			panic("unknown env var: " + tokens[0])
 		}
@@ -0,0 +1,205 @@
+// Package jsonschema validates JSON values against the subset of JSON Schema (https://json-schema.org/draft/2020-12)
+// used by MCP tool input & output schemas: type, enum, const, properties, required, additionalProperties, items,
+// minItems/maxItems, minimum/maximum, exclusiveMinimum/exclusiveMaximum, minLength/maxLength & pattern.
+// Unrecognized keywords (like description) are ignored.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JeffreyRichter/internal/aids"
+)
+
+// Violation describes a value that doesn't satisfy its schema
+type Violation struct {
+	Pointer string // RFC 6901 JSON Pointer to the violating value; "" is the whole instance
+	Message string
+}
+
+func (v Violation) String() string { return fmt.Sprintf("%q: %s", v.Pointer, v.Message) }
+
+// Validate returns the violations of instance (JSON) against schema, a JSON Schema object or any value (like
+// mcp.JSONSchema) that marshals to one. Validate returns no violations if instance satisfies schema.
+func Validate(schema any, instance []byte) ([]Violation, error) {
+	s, ok := schema.(map[string]any)
+	if !ok {
+		b, err := json.Marshal(schema)
+		if aids.IsError(err) {
+			return nil, fmt.Errorf("invalid schema: %w", err)
+		}
+		if err = json.Unmarshal(b, &s); aids.IsError(err) {
+			return nil, fmt.Errorf("schema must be a JSON object: %w", err)
+		}
+	}
+	var v any
+	if err := json.Unmarshal(instance, &v); aids.IsError(err) {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return ValidateValue(s, v), nil
+}
+
+// ValidateValue returns the violations of v (a value unmarshaled from JSON into an any) against schema.
+func ValidateValue(schema map[string]any, v any) []Violation {
+	vs := &validator{}
+	vs.validate(schema, v, "")
+	return vs.violations
+}
+
+type validator struct {
+	violations []Violation
+}
+
+func (vs *validator) fail(ptr, format string, a ...any) {
+	vs.violations = append(vs.violations, Violation{Pointer: ptr, Message: fmt.Sprintf(format, a...)})
+}
+
+func (vs *validator) validate(schema map[string]any, v any, ptr string) {
+	if !vs.validateType(schema["type"], v, ptr) {
+		return // The remaining keywords assume the value has the right type
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.ContainsFunc(enum, func(e any) bool { return reflect.DeepEqual(e, v) }) {
+		vs.fail(ptr, "must be one of %s", aids.MustMarshal(enum))
+	}
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(c, v) {
+		vs.fail(ptr, "must be %s", aids.MustMarshal(c))
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		vs.validateObject(schema, v, ptr)
+	case []any:
+		vs.validateArray(schema, v, ptr)
+	case string:
+		n := utf8.RuneCountInString(v)
+		if limit, ok := number(schema["minLength"]); ok && float64(n) < limit {
+			vs.fail(ptr, "must be at least %v characters", limit)
+		}
+		if limit, ok := number(schema["maxLength"]); ok && float64(n) > limit {
+			vs.fail(ptr, "must be at most %v characters", limit)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); !aids.IsError(err) && !re.MatchString(v) {
+				vs.fail(ptr, "must match pattern %q", pattern)
+			}
+		}
+	case float64:
+		if limit, ok := number(schema["minimum"]); ok && v < limit {
+			vs.fail(ptr, "must be >= %v", limit)
+		}
+		if limit, ok := number(schema["maximum"]); ok && v > limit {
+			vs.fail(ptr, "must be <= %v", limit)
+		}
+		if limit, ok := number(schema["exclusiveMinimum"]); ok && v <= limit {
+			vs.fail(ptr, "must be > %v", limit)
+		}
+		if limit, ok := number(schema["exclusiveMaximum"]); ok && v >= limit {
+			vs.fail(ptr, "must be < %v", limit)
+		}
+	}
+}
+
+// validateType returns true if v is one of the types allowed by t (a type name or array of type names); a missing
+// or empty type allows any type.
+func (vs *validator) validateType(t any, v any, ptr string) bool {
+	types := []string{}
+	switch t := t.(type) {
+	case string:
+		if t != "" {
+			types = append(types, t)
+		}
+	case []any:
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				types = append(types, s)
+			}
+		}
+	}
+	if len(types) == 0 || slices.ContainsFunc(types, func(t string) bool { return hasType(t, v) }) {
+		return true
+	}
+	vs.fail(ptr, "must be of type %s", strings.Join(types, " or "))
+	return false
+}
+
+func hasType(t string, v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case float64:
+		return t == "number" || (t == "integer" && v == math.Trunc(v))
+	case []any:
+		return t == "array"
+	case map[string]any:
+		return t == "object"
+	}
+	return false
+}
+
+func (vs *validator) validateObject(schema map[string]any, v map[string]any, ptr string) {
+	if required, ok := schema["required"].([]any); ok {
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				if _, ok := v[name]; !ok {
+					vs.fail(ptr+"/"+escape(name), "is required")
+				}
+			}
+		}
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	slices.Sort(names) // Violations are reported in a deterministic order
+	for _, name := range names {
+		if ps, ok := properties[name].(map[string]any); ok {
+			vs.validate(ps, v[name], ptr+"/"+escape(name))
+			continue
+		}
+		switch ap := schema["additionalProperties"].(type) {
+		case bool:
+			if !ap {
+				vs.fail(ptr+"/"+escape(name), "is not allowed")
+			}
+		case map[string]any:
+			vs.validate(ap, v[name], ptr+"/"+escape(name))
+		}
+	}
+}
+
+func (vs *validator) validateArray(schema map[string]any, v []any, ptr string) {
+	if limit, ok := number(schema["minItems"]); ok && float64(len(v)) < limit {
+		vs.fail(ptr, "must have at least %v items", limit)
+	}
+	if limit, ok := number(schema["maxItems"]); ok && float64(len(v)) > limit {
+		vs.fail(ptr, "must have at most %v items", limit)
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		for i, item := range v {
+			vs.validate(items, item, ptr+"/"+strconv.Itoa(i))
+		}
+	}
+}
+
+// number returns v as a float64 if v is a JSON number
+func number(v any) (float64, bool) {
+	f, ok := v.(float64)
+	return f, ok
+}
+
+// escape escapes a property name for use as a JSON Pointer reference token (RFC 6901 section 3)
+func escape(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
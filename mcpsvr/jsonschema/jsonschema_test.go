@@ -0,0 +1,72 @@
+package jsonschema
+
+import (
+	"slices"
+	"testing"
+)
+
+var schema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"name":  map[string]any{"type": "string", "minLength": 1.0, "maxLength": 5.0},
+		"count": map[string]any{"type": "integer", "minimum": 0.0, "maximum": 10.0},
+		"ratio": map[string]any{"type": "number", "exclusiveMinimum": 0.0, "exclusiveMaximum": 1.0},
+		"color": map[string]any{"enum": []any{"red", "green"}},
+		"tags":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "maxItems": 2.0},
+		"owner": map[string]any{
+			"type":                 "object",
+			"properties":           map[string]any{"id": map[string]any{"type": "string", "pattern": "^[a-z]+$"}},
+			"required":             []any{"id"},
+			"additionalProperties": false,
+		},
+		"a/b~c": map[string]any{"type": []any{"boolean", "null"}},
+	},
+	"required": []any{"name"},
+}
+
+func TestValidate(t *testing.T) {
+	for instance, want := range map[string][]string{
+		`{"name":"x"}`: {},
+		`{"name":"abc","count":10,"ratio":0.5,"color":"red","tags":["a","b"],"owner":{"id":"jeff"},"a/b~c":null}`: {},
+		`{}`:                            {"/name"},
+		`[]`:                            {""},
+		`{"name":""}`:                   {"/name"},
+		`{"name":"toolong"}`:            {"/name"},
+		`{"name":"x","count":1.5}`:      {"/count"},
+		`{"name":"x","count":11}`:       {"/count"},
+		`{"name":"x","count":"1"}`:      {"/count"},
+		`{"name":"x","ratio":1}`:        {"/ratio"},
+		`{"name":"x","color":"blue"}`:   {"/color"},
+		`{"name":"x","tags":["a",1,2]}`: {"/tags", "/tags/1", "/tags/2"},
+		`{"name":"x","owner":{"id":"J","extra":true}}`: {"/owner/extra", "/owner/id"},
+		`{"name":"x","owner":{}}`:                      {"/owner/id"},
+		`{"name":"x","a/b~c":1}`:                       {"/a~1b~0c"},
+		`{"name":1,"count":-1,"unknown":"is allowed"}`: {"/count", "/name"},
+	} {
+		violations, err := Validate(schema, []byte(instance))
+		if err != nil {
+			t.Fatalf("%s: %v", instance, err)
+		}
+		got := []string{}
+		for _, v := range violations {
+			got = append(got, v.Pointer)
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("%s: wanted violations at %q, got %v", instance, want, violations)
+		}
+	}
+}
+
+func TestValidateEmptySchema(t *testing.T) {
+	for _, instance := range []string{`{}`, `{"any":"thing"}`, `[1,"2"]`, `null`} {
+		if violations, err := Validate(map[string]any{}, []byte(instance)); err != nil || len(violations) > 0 {
+			t.Fatalf("%s: expected no violations, got %v, %v", instance, violations, err)
+		}
+	}
+}
+
+func TestValidateInvalidJSON(t *testing.T) {
+	if _, err := Validate(schema, []byte(`{"name":`)); err == nil {
+		t.Fatal("expected an error for invalid JSON")
+	}
+}
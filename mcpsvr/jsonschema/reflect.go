@@ -0,0 +1,101 @@
+package jsonschema
+
+import (
+	"encoding/json/jsontext"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+)
+
+// For returns the JSON Schema of T's JSON representation. T is typically a struct whose exported fields are
+// properties named by their json tags; a property is required unless its json tag has omitempty or omitzero.
+// These struct tags add constraints to a field's schema:
+//
+//	description:"The first number"  enum:"red,green,blue"  minimum:"0"  maximum:"100"  minLength:"1"  maxLength:"50"
+func For[T any]() map[string]any { return forType(reflect.TypeFor[T]()) }
+
+func forType(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case reflect.TypeFor[time.Time]():
+		return map[string]any{"type": "string", "format": "date-time"}
+	case reflect.TypeFor[jsontext.Value]():
+		return map[string]any{} // Any JSON value
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string"} // []byte marshals as base64
+		}
+		return map[string]any{"type": "array", "items": forType(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": forType(t.Elem())}
+	case reflect.Struct:
+		properties, required := map[string]any{}, []any{}
+		addStructProperties(t, properties, &required)
+		s := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		return s
+	}
+	return map[string]any{} // ex: any
+}
+
+// addStructProperties adds the schema of each of t's JSON-marshaled fields to properties; embedded structs'
+// fields are inlined.
+func addStructProperties(t reflect.Type, properties map[string]any, required *[]any) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct { // Even if the struct type is unexported
+			addStructProperties(f.Type, properties, required)
+			continue
+		}
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s := forType(f.Type)
+		if d, ok := f.Tag.Lookup("description"); ok {
+			s["description"] = d
+		}
+		if e, ok := f.Tag.Lookup("enum"); ok {
+			enum := []any{}
+			for v := range strings.SplitSeq(e, ",") {
+				enum = append(enum, v)
+			}
+			s["enum"] = enum
+		}
+		for _, keyword := range []string{"minimum", "maximum", "minLength", "maxLength"} {
+			if v, ok := f.Tag.Lookup(keyword); ok {
+				n, err := strconv.ParseFloat(v, 64)
+				aids.Assert(!aids.IsError(err), fmt.Errorf("%s.%s: %s tag must be a number", t.Name(), f.Name, keyword))
+				s[keyword] = n
+			}
+		}
+		properties[name] = s
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") {
+			*required = append(*required, name)
+		}
+	}
+}
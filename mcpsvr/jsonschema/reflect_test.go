@@ -0,0 +1,54 @@
+package jsonschema
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+)
+
+type (
+	reflectBase struct {
+		ID string `json:"id" description:"The ID"`
+	}
+
+	reflectRequest struct {
+		reflectBase
+		Color   string          `json:"color,omitempty" enum:"red,green"`
+		Count   int             `json:"count" minimum:"0" maximum:"10"`
+		Ratio   *float64        `json:"ratio,omitzero"`
+		Tags    []string        `json:"tags"`
+		Labels  map[string]bool `json:"labels,omitempty"`
+		When    time.Time       `json:"when,omitzero"`
+		Ignored string          `json:"-"`
+		hidden  string
+		Code    string `json:"code,omitempty" minLength:"1" maxLength:"3"`
+	}
+)
+
+func TestFor(t *testing.T) {
+	want := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"id":     map[string]any{"type": "string", "description": "The ID"},
+			"color":  map[string]any{"type": "string", "enum": []any{"red", "green"}},
+			"count":  map[string]any{"type": "integer", "minimum": 0.0, "maximum": 10.0},
+			"ratio":  map[string]any{"type": "number"},
+			"tags":   map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+			"labels": map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "boolean"}},
+			"when":   map[string]any{"type": "string", "format": "date-time"},
+			"code":   map[string]any{"type": "string", "minLength": 1.0, "maxLength": 3.0},
+		},
+		"required": []any{"id", "count", "tags"},
+	}
+	if got := For[reflectRequest](); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %s, got %s", aids.MustMarshal(want), aids.MustMarshal(got))
+	}
+
+	// A derived schema validates the type's own JSON
+	violations := ValidateValue(For[reflectRequest](), aids.MustUnmarshal[any](aids.MustMarshal(reflectRequest{Tags: []string{}, hidden: "x"})))
+	if len(violations) != 0 {
+		t.Fatalf("expected no violations, got %v", violations)
+	}
+}
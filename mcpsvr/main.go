@@ -20,16 +20,22 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azqueue"
 	"github.com/JeffreyRichter/internal/aids"
+	rootsazure "github.com/JeffreyRichter/mcpsvr/roots/azure"
+	rootslocal "github.com/JeffreyRichter/mcpsvr/roots/local"
+	"github.com/JeffreyRichter/mcpsvr/toolcall"
 	"github.com/JeffreyRichter/mcpsvr/toolcall/azure"
 	"github.com/JeffreyRichter/mcpsvr/toolcall/local"
+	"github.com/JeffreyRichter/mcpsvr/toolcall/stateless"
 	"github.com/JeffreyRichter/svrcore"
+	"github.com/JeffreyRichter/svrcore/metrics"
 	"github.com/JeffreyRichter/svrcore/stages"
+	"github.com/JeffreyRichter/svrcore/tracing"
 )
 
 var (
-	errorLogger   = slog.New(slog.NewJSONHandler(os.Stderr, nil))
-	metricsLogger = slog.New(slog.NewTextHandler(os.Stderr, nil))
-	shutdownMgr   = stages.NewShutdownMgr(stages.ShutdownMgrConfig{ErrorLogger: errorLogger, HealthProbeDelay: time.Second * 2, CancellationDelay: time.Second * 3})
+	errorLogger     = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	metricsRegistry = metrics.NewRegistry()
+	shutdownMgr     = stages.NewShutdownMgr(stages.ShutdownMgrConfig{ErrorLogger: errorLogger, HealthProbeDelay: time.Second * 2, CancellationDelay: time.Second * 3})
 )
 
 func main() {
@@ -37,6 +43,7 @@ func main() {
 	c.Load()
 
 	var routes *mcpStages
+	nc := newNotifierConfig(c)
 	port, sharedKey := "0", "" // Default to OS-port & no sharedKey
 	switch {
 	case c.Local:
@@ -50,29 +57,41 @@ func main() {
 		} else {
 			port, sharedKey = "8080", "" //"ForDebuggingOnly"
 		}
-		routes = newLocalMcpStages(shutdownMgr.Context, errorLogger)
+		routes = newLocalMcpStages(shutdownMgr.Context, errorLogger, nc)
+
+	case c.Stateless:
+		keys := aids.Must(toolcall.ParseServerDataKeys(c.ServerDataKeys))
+		routes = newStatelessMcpStages(shutdownMgr.Context, errorLogger, nc, toolcall.NewServerDataEncoder(keys...))
 
 	case c.AzuriteAccount != "":
 		blobCred := aids.Must(azblob.NewSharedKeyCredential(c.AzuriteAccount, c.AzuriteKey))
 		blobClient := aids.Must(azblob.NewClientWithSharedKeyCredential(c.AzureBlobURL, blobCred, nil))
 		queueCred := aids.Must(azqueue.NewSharedKeyCredential(c.AzuriteAccount, c.AzuriteKey))
 		queueClient := aids.Must(azqueue.NewQueueClientWithSharedKeyCredential(c.AzureQueueURL, queueCred, nil))
-		routes = newAzureMcpStages(shutdownMgr.Context, errorLogger, blobClient, queueClient)
+		routes = newAzureMcpStages(shutdownMgr.Context, errorLogger, nc, blobClient, queueClient)
 
 	default:
 		cred := aids.Must(azidentity.NewDefaultAzureCredential(nil))
 		blobClient := aids.Must(azblob.NewClient(c.AzureBlobURL, cred, nil))
 		queueClient := aids.Must(azqueue.NewQueueClient(c.AzureQueueURL, cred, nil))
-		routes = newAzureMcpStages(shutdownMgr.Context, errorLogger, blobClient, queueClient)
+		routes = newAzureMcpStages(shutdownMgr.Context, errorLogger, nc, blobClient, queueClient)
 	}
+	routes.enableOutputValidation(aids.Must(ParseOutputValidation(c.OutputValidation)))
+	routes.enableMetrics(metricsRegistry)
+	tracer := newTracer(c)
+	routes.tracer = tracer
+	prc := newProtectedResourceConfig(c, routes)
+	healthProbes := stages.NewHealthProbes(stages.HealthProbesConfig{Checkers: routes.healthCheckers, ShuttingDown: shutdownMgr.ShuttingDown})
 
 	stages := []svrcore.Stage{
 		shutdownMgr.NewStage(),
-		stages.NewMetricsStage(metricsLogger),
+		stages.NewDistributedTracingStage(tracer),
+		stages.NewMetricsStage(metricsRegistry),
 		newApiVersionSimulatorStage(),
 		stages.NewSharedKeyStage(sharedKey),
-		stages.NewThrottlingStage(100),
-		stages.NewDistributedTracingStage(),
+		newProtectedResourceStage(prc),
+		newAuthStage(c, prc),
+		newRateLimitStage(c),
 	}
 
 	// Supported scenarios:
@@ -80,7 +99,9 @@ func main() {
 	// 2. New preview/GA version based on existing preview/GA version
 	// 3. Retire old preview/GA version
 	avis := []*svrcore.ApiVersionInfo{
-		{ApiVersion: "", BaseApiVersion: "", GetRoutes: noApiVersionRoutes},
+		{ApiVersion: "", BaseApiVersion: "", GetRoutes: func(baseRoutes svrcore.ApiVersionRoutes) svrcore.ApiVersionRoutes {
+			return noApiVersionRoutes(baseRoutes, healthProbes)
+		}},
 		{ApiVersion: "2025-08-08", BaseApiVersion: "", GetRoutes: routes.Routes20250808},
 	}
 
@@ -104,6 +125,10 @@ func main() {
 		WriteTimeout:                 30 * time.Second,
 	}
 
+	// At shutdown, stop accepting connections & wait for in-flight requests & running phases to stop
+	shutdownMgr.OnShutdown("http server", s.Shutdown)
+	shutdownMgr.OnShutdown("phases", routes.pm.Drain)
+
 	ln := aids.Must(net.Listen("tcp", net.JoinHostPort("", port)))
 	var err error
 	if _, port, err = net.SplitHostPort(ln.Addr().String()); aids.IsError(err) {
@@ -119,25 +144,88 @@ func main() {
 	if err := s.Serve(ln); aids.IsError(err) && !errors.Is(err, http.ErrServerClosed) {
 		panic(err)
 	}
+	select {} // Serve returns as soon as shutdown starts; shutdownMgr exits the process once everything drains
 }
 
-func newLocalMcpStages(shutdownCtx context.Context, errorLogger *slog.Logger) *mcpStages {
-	ops := &mcpStages{errorLogger: errorLogger, store: local.NewToolCallStore(shutdownCtx)}
-	ops.pm = local.NewPhaseMgr(shutdownCtx, local.PhaseMgrConfig{ErrorLogger: errorLogger, ToolNameToProcessPhaseFunc: ops.toolNameToProcessPhaseFunc})
+func newLocalMcpStages(shutdownCtx context.Context, errorLogger *slog.Logger, nc *toolcall.NotifierConfig) *mcpStages {
+	ops := &mcpStages{errorLogger: errorLogger, store: local.NewToolCallStore(shutdownCtx), rootsStore: rootslocal.NewRootsStore()}
+	ops.addHealthChecker("store", ops.store)
+	ops.enableNotifications(shutdownCtx, nc)
+	ops.pm = local.NewPhaseMgr(shutdownCtx, ops.store, local.PhaseMgrConfig{ErrorLogger: errorLogger, ToolNameToProcessPhaseFunc: ops.toolNameToProcessPhaseFunc, Metrics: ops.phaseMgrMetrics()})
+	ops.addHealthChecker("phases", ops.pm)
 	ops.buildToolInfos()
+	ops.buildResourceProviders()
+	ops.buildPromptInfos()
 	return ops
 }
 
-func newAzureMcpStages(shutdownCtx context.Context, errorLogger *slog.Logger, blobClient *azblob.Client, queueClient *azqueue.QueueClient) *mcpStages {
-	ops := &mcpStages{errorLogger: errorLogger, store: azure.NewToolCallStore(blobClient)}
-	pm, err := azure.NewPhaseMgr(shutdownCtx, queueClient, ops.store, azure.PhaseMgrConfig{ErrorLogger: errorLogger, ToolNameToProcessPhaseFunc: ops.toolNameToProcessPhaseFunc})
+func newAzureMcpStages(shutdownCtx context.Context, errorLogger *slog.Logger, nc *toolcall.NotifierConfig, blobClient *azblob.Client, queueClient *azqueue.QueueClient) *mcpStages {
+	ops := &mcpStages{errorLogger: errorLogger, store: azure.NewToolCallStore(blobClient), rootsStore: rootsazure.NewRootsStore(blobClient)}
+	ops.addHealthChecker("store", ops.store)
+	ops.enableNotifications(shutdownCtx, nc)
+	pm, err := azure.NewPhaseMgr(shutdownCtx, queueClient, ops.store, azure.PhaseMgrConfig{ErrorLogger: errorLogger, ToolNameToProcessPhaseFunc: ops.toolNameToProcessPhaseFunc, Metrics: ops.phaseMgrMetrics()})
 	aids.Must0(err)
 	ops.pm = pm
+	ops.addHealthChecker("phases", ops.pm)
+	ops.buildToolInfos()
+	ops.buildResourceProviders()
+	ops.buildPromptInfos()
+	return ops
+}
+
+// newStatelessMcpStages creates mcpStages that don't store tool calls; each tool call is encrypted by sde into the
+// serverData returned to the client which sends it back to advance or cancel the tool call. Roots are kept in memory.
+// A retried PUT can't return the tool call it created so it fails with 409-Conflict (instead of running the tool again)
+// if it reaches the same process; the client must then create the tool call with a new ID.
+func newStatelessMcpStages(shutdownCtx context.Context, errorLogger *slog.Logger, nc *toolcall.NotifierConfig, sde *toolcall.ServerDataEncoder) *mcpStages {
+	ops := &mcpStages{errorLogger: errorLogger, store: stateless.NewToolCallStore(sde), serverData: sde, rootsStore: rootslocal.NewRootsStore()}
+	ops.addHealthChecker("store", ops.store)
+	ops.enableNotifications(shutdownCtx, nc)
+	ops.pm = stateless.NewPhaseMgr(ops.store, stateless.PhaseMgrConfig{ErrorLogger: errorLogger, ToolNameToProcessPhaseFunc: ops.toolNameToProcessPhaseFunc, Metrics: ops.phaseMgrMetrics()})
+	ops.addHealthChecker("phases", ops.pm)
 	ops.buildToolInfos()
+	ops.buildResourceProviders()
+	ops.buildPromptInfos()
 	return ops
 }
 
-func noApiVersionRoutes(baseRoutes svrcore.ApiVersionRoutes) svrcore.ApiVersionRoutes {
+// enableNotifications wraps ops' store so tool call status changes are POSTed to clients' webhooks
+// using nc; a nil nc leaves webhook notifications disabled.
+func (ops *mcpStages) enableNotifications(shutdownCtx context.Context, nc *toolcall.NotifierConfig) {
+	if nc == nil {
+		return
+	}
+	ops.notifier = toolcall.NewNotifier(shutdownCtx, *nc)
+	ops.store = toolcall.NewNotifyingStore(ops.store, ops.notifier)
+}
+
+// addHealthChecker adds dependency (ex: a toolcall.Store or PhaseMgr) to the readiness probe's checks if it implements
+// stages.HealthChecker; it must be called before the dependency is wrapped (ex: by enableNotifications).
+func (ops *mcpStages) addHealthChecker(name string, dependency any) {
+	if hc, ok := dependency.(stages.HealthChecker); ok {
+		if ops.healthCheckers == nil {
+			ops.healthCheckers = map[string]stages.HealthChecker{}
+		}
+		ops.healthCheckers[name] = hc
+	}
+}
+
+// isInfrastructurePath returns true for the debug & health probe URLs which have no api-version
+func isInfrastructurePath(path string) bool {
+	return strings.HasPrefix(path, "/debug/") || strings.HasPrefix(path, "/health/")
+}
+
+// isAnonymousPath returns true for the health probe URLs which load balancers & orchestrators call without a JWT;
+// the other debug URLs (pprof, metrics, ...) expose the process's internals so they require one
+func isAnonymousPath(path string) bool {
+	switch path {
+	case "/health/live", "/health/ready", "/debug/health":
+		return true
+	}
+	return false
+}
+
+func noApiVersionRoutes(baseRoutes svrcore.ApiVersionRoutes, healthProbes *stages.HealthProbes) svrcore.ApiVersionRoutes {
 	// If no base api-version, baseRoutes == nil; build routes from scratch
 
 	// Use the patterns below to MODIFY the base's routes (or ignore baseRoutes to build routes from scratch):
@@ -148,6 +236,18 @@ func noApiVersionRoutes(baseRoutes svrcore.ApiVersionRoutes) svrcore.ApiVersionR
 		"/debug/health": map[string]*svrcore.MethodInfo{
 			"GET": {Stage: shutdownMgr.HealthProbe},
 		},
+		"/health/live": map[string]*svrcore.MethodInfo{
+			"GET": {Stage: healthProbes.LiveProbe},
+		},
+		"/health/ready": map[string]*svrcore.MethodInfo{
+			"GET": {Stage: healthProbes.ReadyProbe},
+		},
+		"/debug/metrics": map[string]*svrcore.MethodInfo{
+			"GET": {Stage: func(ctx context.Context, rr *svrcore.ReqRes) bool {
+				metricsRegistry.ServeHTTP(rr.RW, rr.R)
+				return false
+			}},
+		},
 		"/debug/pprof": map[string]*svrcore.MethodInfo{
 			"GET": {Stage: func(ctx context.Context, rr *svrcore.ReqRes) bool { pprof.Index(rr.RW, rr.R); return false }},
 		},
@@ -166,9 +266,93 @@ func noApiVersionRoutes(baseRoutes svrcore.ApiVersionRoutes) svrcore.ApiVersionR
 	}
 }
 
+// newTracer returns a Tracer exporting spans to c.OTLPEndpoint's collector; if it's empty, spans aren't exported but
+// trace contexts are still propagated & stored with tool calls.
+func newTracer(c Configuration) *tracing.Tracer {
+	var exporter tracing.Exporter
+	if c.OTLPEndpoint != "" {
+		exporter = tracing.NewOTLPExporter(shutdownMgr.Context, tracing.OTLPConfig{Endpoint: c.OTLPEndpoint, ServiceName: "mcpsvr", ErrorLogger: errorLogger})
+	}
+	return tracing.NewTracer(exporter, errorLogger)
+}
+
+// newNotifierConfig returns the webhook notifications' configuration; it returns nil if c.Webhooks is false
+// (webhook notifications are disabled).
+func newNotifierConfig(c Configuration) *toolcall.NotifierConfig {
+	if !c.Webhooks {
+		return nil
+	}
+	hosts := []string{}
+	for h := range strings.SplitSeq(c.WebhookHosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return &toolcall.NotifierConfig{ErrorLogger: errorLogger, AllowedHosts: hosts}
+}
+
+// newProtectedResourceConfig returns the OAuth protected resource metadata (RFC 9728) clients use to discover
+// where to get tokens for this server; it returns nil if c.AuthJWKSFile is empty (authentication is disabled).
+func newProtectedResourceConfig(c Configuration, routes *mcpStages) *stages.ProtectedResourceConfig {
+	if c.AuthJWKSFile == "" {
+		return nil
+	}
+	servers := []string{}
+	for s := range strings.SplitSeq(aids.Iif(c.AuthServers == "", c.AuthIssuer, c.AuthServers), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			servers = append(servers, s)
+		}
+	}
+	return &stages.ProtectedResourceConfig{
+		Resource:             c.AuthResource,
+		AuthorizationServers: servers,
+		ScopesSupported:      supportedScopes(routes.toolInfos),
+		ResourceName:         "mcpsvr",
+	}
+}
+
+// newProtectedResourceStage returns a stage serving prc's metadata at /.well-known/oauth-protected-resource;
+// if prc is nil, the stage does nothing.
+func newProtectedResourceStage(prc *stages.ProtectedResourceConfig) svrcore.Stage {
+	if prc == nil {
+		return func(ctx context.Context, r *svrcore.ReqRes) bool { return r.Next(ctx) }
+	}
+	return stages.NewProtectedResourceStage(*prc)
+}
+
+// newAuthStage returns a stage authenticating requests with JWTs verified by c.AuthJWKSFile's keys; the stage puts
+// the caller's principal (& tenant) on the context & its 401 challenges point clients at prc's metadata. If
+// c.AuthJWKSFile is empty, requests aren't authenticated & all tool calls & roots belong to defaultTenant.
+// Only the health probes don't require authentication.
+func newAuthStage(c Configuration, prc *stages.ProtectedResourceConfig) svrcore.Stage {
+	if c.AuthJWKSFile == "" {
+		return func(ctx context.Context, r *svrcore.ReqRes) bool { return r.Next(ctx) }
+	}
+	return stages.NewAuthStage(stages.AuthConfig{
+		Keys:              aids.Must(stages.LoadJWKS(c.AuthJWKSFile)),
+		Issuer:            c.AuthIssuer,
+		Audience:          c.AuthAudience,
+		TenantClaim:       c.AuthTenantClaim,
+		Anonymous:         func(r *svrcore.ReqRes) bool { return isAnonymousPath(r.R.URL.Path) },
+		ProtectedResource: prc,
+	})
+}
+
+// newRateLimitStage returns a stage limiting each caller's (tenant & subject or, if authentication is disabled,
+// remote address) PUTs & GETs to separate budgets.
+func newRateLimitStage(c Configuration) svrcore.Stage {
+	return stages.NewRateLimitStage(stages.RateLimitConfig{
+		Limits: map[string]stages.RateLimit{
+			http.MethodPut: aids.Must(stages.ParseRateLimit(aids.Iif(c.RateLimitPut == "", "60/1m", c.RateLimitPut))),
+			http.MethodGet: aids.Must(stages.ParseRateLimit(aids.Iif(c.RateLimitGet == "", "1200/1m", c.RateLimitGet))),
+		},
+		ErrorLogger: errorLogger,
+	})
+}
+
 func newApiVersionSimulatorStage() svrcore.Stage {
 	return func(ctx context.Context, r *svrcore.ReqRes) bool {
-		if !strings.HasPrefix(r.R.URL.Path, "/debug/") {
+		if !isInfrastructurePath(r.R.URL.Path) {
 			r.R.Header.Set("api-version", "2025-08-08")
 		}
 		return r.Next(ctx)
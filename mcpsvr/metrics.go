@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/mcpsvr/toolcall"
+	"github.com/JeffreyRichter/svrcore"
+	"github.com/JeffreyRichter/svrcore/metrics"
+)
+
+// toolCallMetrics are the tool call metrics recorded by enableMetrics
+type toolCallMetrics struct {
+	created        *metrics.Counter   // Tool calls created per tool
+	status         *metrics.Counter   // Tool calls entering each status per tool
+	phaseDuration  *metrics.Histogram // Time to process a phase per tool
+	queueDepth     *metrics.Gauge     // Tool call phases waiting for or being processed by the PhaseMgr
+	poisonMessages *metrics.Counter   // Tool call phases abandoned by the PhaseMgr
+}
+
+// enableMetrics registers tool call metrics in reg & wraps ops' ToolInfos so they record them.
+func (ops *mcpStages) enableMetrics(reg *metrics.Registry) {
+	m := &toolCallMetrics{
+		created:        reg.NewCounter("mcp_tool_calls_created_total", "Tool calls created.", "tool"),
+		status:         reg.NewCounter("mcp_tool_call_status_total", "Tool calls entering each status.", "tool", "status"),
+		phaseDuration:  reg.NewHistogram("mcp_tool_call_phase_duration_seconds", "Time to process a tool call phase in seconds.", nil, "tool"),
+		queueDepth:     reg.NewGauge("mcp_phase_queue_depth", "Tool call phases waiting for or being processed by the phase manager."),
+		poisonMessages: reg.NewCounter("mcp_phase_poison_messages_total", "Tool call phases abandoned by the phase manager after failing repeatedly."),
+	}
+	m.queueDepth.Set(0)
+	m.poisonMessages.Add(0)
+	for name, ti := range ops.toolInfos {
+		ops.toolInfos[name] = &meteredToolInfo{ToolInfo: ti, m: m}
+	}
+	ops.metrics.Store(m)
+}
+
+// phaseMgrMetrics returns the toolcall.PhaseMgrMetrics a PhaseMgr reports to; they're ignored until enableMetrics
+// is called since the PhaseMgr is created first.
+func (ops *mcpStages) phaseMgrMetrics() toolcall.PhaseMgrMetrics {
+	return toolcall.PhaseMgrMetrics{
+		QueueDepth: func(depth int) {
+			if m := ops.metrics.Load(); m != nil {
+				m.queueDepth.Set(float64(depth))
+			}
+		},
+		PoisonMessage: func() {
+			if m := ops.metrics.Load(); m != nil {
+				m.poisonMessages.Inc()
+			}
+		},
+	}
+}
+
+// meteredToolInfo wraps a ToolInfo counting its tool calls' creations & status changes & timing its phases
+type meteredToolInfo struct {
+	ToolInfo
+	m *toolCallMetrics
+}
+
+func (mti *meteredToolInfo) Create(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes, pm toolcall.PhaseMgr) bool {
+	before, spm := statusOf(tc), &startedPhaseMgr{PhaseMgr: pm}
+	stop := mti.ToolInfo.Create(ctx, tc, r, spm)
+	if r.RW.StatusCode == http.StatusOK {
+		mti.m.created.Inc(mti.Tool().Name)
+		mti.countStatus(before, spm.statusOf(tc))
+	}
+	return stop
+}
+
+func (mti *meteredToolInfo) Advance(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes, pm toolcall.PhaseMgr) bool {
+	before, spm := statusOf(tc), &startedPhaseMgr{PhaseMgr: pm}
+	stop := mti.ToolInfo.Advance(ctx, tc, r, spm)
+	if r.RW.StatusCode == http.StatusOK {
+		mti.countStatus(before, spm.statusOf(tc))
+	}
+	return stop
+}
+
+func (mti *meteredToolInfo) Cancel(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes, pm toolcall.PhaseMgr) bool {
+	before := statusOf(tc)
+	stop := mti.ToolInfo.Cancel(ctx, tc, r, pm)
+	if r.RW.StatusCode == http.StatusOK {
+		mti.countStatus(before, statusOf(tc))
+	}
+	return stop
+}
+
+func (mti *meteredToolInfo) ProcessPhase(ctx context.Context, pp toolcall.PhaseProcessor, tc *toolcall.Resource) {
+	before, start := statusOf(tc), time.Now()
+	mti.ToolInfo.ProcessPhase(ctx, pp, tc)
+	mti.m.phaseDuration.Observe(time.Since(start).Seconds(), mti.Tool().Name)
+	if after := statusOf(tc); after != mcp.StatusCanceled { // A discarded phase has the status set (& counted) by Cancel
+		mti.countStatus(before, after)
+	}
+}
+
+// countStatus counts the tool call entering the after status if it differs from before
+func (mti *meteredToolInfo) countStatus(before, after mcp.Status) {
+	if after != "" && after != before {
+		mti.m.status.Inc(mti.Tool().Name, string(after))
+	}
+}
+
+func statusOf(tc *toolcall.Resource) mcp.Status {
+	if tc.Status == nil {
+		return ""
+	}
+	return *tc.Status
+}
+
+// startedPhaseMgr remembers a tool call's status when its phases were started; phases count their own status
+// changes so only the status reached before them is counted (a stateless PhaseMgr runs them before returning)
+type startedPhaseMgr struct {
+	toolcall.PhaseMgr
+	started *mcp.Status
+}
+
+func (spm *startedPhaseMgr) StartPhase(ctx context.Context, tc *toolcall.Resource) *svrcore.ServerError {
+	spm.started = aids.New(statusOf(tc))
+	return spm.PhaseMgr.StartPhase(ctx, tc)
+}
+
+// statusOf returns tc's status when its phases were started or, if they weren't, its current status
+func (spm *startedPhaseMgr) statusOf(tc *toolcall.Resource) mcp.Status {
+	if spm.started != nil {
+		return *spm.started
+	}
+	return statusOf(tc)
+}
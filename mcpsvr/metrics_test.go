@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/svrcore/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolCallMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	ops := newLocalMcpStages(context.Background(), slog.Default(), nil)
+	ops.enableMetrics(reg)
+	client := newTestClientFor(t, ops)
+	put := func(path, body string) mcp.ToolCall {
+		resp := client.Put(path, http.Header{
+			"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)},
+			"Content-Type":    []string{"application/json"},
+			"Accept":          []string{"application/json"},
+		}, strings.NewReader(body))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var tc mcp.ToolCall
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&tc))
+		resp.Body.Close()
+		return tc
+	}
+
+	put("/mcp/tools/add/calls/"+t.Name(), `{"x":1,"y":2}`)
+	countPath := "/mcp/tools/count/calls/" + t.Name()
+	for tc := put(countPath, `{"increments":2}`); *tc.Status != mcp.StatusSuccess; {
+		time.Sleep(50 * time.Millisecond)
+		resp := client.Get(countPath, http.Header{"Accept": []string{"application/json"}})
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&tc))
+		resp.Body.Close()
+	}
+	cancelPath := "/mcp/tools/count/calls/" + t.Name() + "-canceled"
+	put(cancelPath, `{"increments":100}`)
+	resp := client.Post(cancelPath+"/cancel", http.Header{}, nil)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.Eventually(t, func() bool { // The canceled call's phase stops asynchronously
+		b := &strings.Builder{}
+		return reg.WriteText(b) == nil && strings.Contains(b.String(), "mcp_phase_queue_depth 0\n")
+	}, 5*time.Second, 50*time.Millisecond)
+	b := &strings.Builder{}
+	require.NoError(t, reg.WriteText(b))
+	for _, expected := range []string{
+		`mcp_tool_calls_created_total{tool="add"} 1`,
+		`mcp_tool_calls_created_total{tool="count"} 2`,
+		`mcp_tool_call_status_total{tool="add",status="success"} 1`,
+		`mcp_tool_call_status_total{tool="count",status="running"} 2`,
+		`mcp_tool_call_status_total{tool="count",status="success"} 1`,
+		`mcp_tool_call_status_total{tool="count",status="canceled"} 1`,
+		`mcp_tool_call_phase_duration_seconds_count{tool="count"}`,
+		`mcp_phase_poison_messages_total 0`,
+	} {
+		assert.Contains(t, b.String(), expected)
+	}
+}
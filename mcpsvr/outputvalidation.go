@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/mcpsvr/jsonschema"
+	"github.com/JeffreyRichter/mcpsvr/toolcall"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+// OutputValidation controls whether successful tool call results are validated against their tool's OutputSchema
+type OutputValidation int
+
+const (
+	OutputValidationOff    OutputValidation = iota // Results are not validated
+	OutputValidationDebug                          // Violations are logged
+	OutputValidationStrict                         // Violations are logged & the tool call fails with a 500 error
+)
+
+// ParseOutputValidation converts "", "off", "debug", or "strict" to an OutputValidation
+func ParseOutputValidation(s string) (OutputValidation, error) {
+	switch strings.ToLower(s) {
+	case "", "off":
+		return OutputValidationOff, nil
+	case "debug":
+		return OutputValidationDebug, nil
+	case "strict":
+		return OutputValidationStrict, nil
+	}
+	return OutputValidationOff, fmt.Errorf("output validation must be off, debug, or strict; not %q", s)
+}
+
+// enableOutputValidation wraps ops' store so every Put of a successful tool call validates its result; tools
+// not putting their tool calls (like add) must call validateToolCallResult before writing their response.
+func (ops *mcpStages) enableOutputValidation(ov OutputValidation) {
+	ops.outputValidation = ov
+	if ov != OutputValidationOff {
+		ops.store = &validatingStore{Store: ops.store, ops: ops}
+	}
+}
+
+// validateToolCallResult validates a successful tc's result against its tool's OutputSchema logging any violations.
+// In strict mode, a 500 [svrcore.ServerError] is returned if the result is invalid.
+func (ops *mcpStages) validateToolCallResult(tc *toolcall.Resource) *svrcore.ServerError {
+	if ops.outputValidation == OutputValidationOff || tc.Status == nil || *tc.Status != mcp.StatusSuccess {
+		return nil
+	}
+	ti, ok := ops.toolInfos[*tc.ToolName]
+	if !ok || ti.Tool().OutputSchema == nil {
+		return nil
+	}
+	result := tc.Result
+	if len(result) == 0 {
+		result = []byte("null") // A missing result fails an object schema
+	}
+	violations, err := jsonschema.Validate(ti.Tool().OutputSchema, result)
+	if aids.IsError(err) {
+		violations = []jsonschema.Violation{{Message: err.Error()}}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	ops.errorLogger.Error("Tool call result doesn't match output schema", "tool", *tc.ToolName, "id", *tc.ID, "violations", fmt.Sprint(violations))
+	if ops.outputValidation != OutputValidationStrict {
+		return nil
+	}
+	se := svrcore.NewServerError(http.StatusInternalServerError, "InvalidToolOutput", "Tool '%s' result doesn't match its output schema", *tc.ToolName)
+	for _, v := range violations {
+		se.Details = append(se.Details, &svrcore.ServerError{ErrorCode: "InvalidValue", Message: v.Message, Target: v.Pointer})
+	}
+	return se
+}
+
+// validatingStore wraps a Store validating the result of every successful tool call it Puts. In strict mode, an
+// invalid tool call is Put as failed (with the validation error) instead.
+type validatingStore struct {
+	toolcall.Store
+	ops *mcpStages
+}
+
+func (s *validatingStore) Put(ctx context.Context, tc *toolcall.Resource, ac svrcore.AccessConditions) *svrcore.ServerError {
+	if se := s.ops.validateToolCallResult(tc); se != nil {
+		tc.Status, tc.Result, tc.Error = aids.New(mcp.StatusFailed), nil, aids.MustMarshal(se)
+	}
+	return s.Store.Put(ctx, tc, ac)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/mcpsvr/jsonschema"
+	"github.com/JeffreyRichter/mcpsvr/toolcall"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+func TestToolResultsMatchOutputSchemas(t *testing.T) {
+	streamSecond = time.Millisecond
+	t.Cleanup(func() { streamSecond = time.Second })
+
+	// Each driver runs its tool through the test server (in strict mode) to a successful tool call; a result not
+	// matching its OutputSchema fails the tool call
+	drivers := map[string]func(t *testing.T, client *testClient, urlPath string) mcp.ToolCall{
+		"add": func(t *testing.T, client *testClient, urlPath string) mcp.ToolCall {
+			tc, _ := createToolCall(t, client, urlPath, `{"x":5,"y":3}`)
+			return tc
+		},
+		"count": func(t *testing.T, client *testClient, urlPath string) mcp.ToolCall {
+			tc, _ := createToolCall(t, client, urlPath, `{"increments":2}`)
+			return pollToolCall(t, client, urlPath, tc)
+		},
+		"welcome": func(t *testing.T, client *testClient, urlPath string) mcp.ToolCall {
+			createToolCall(t, client, urlPath, `{"key":"test"}`)
+			tc, _ := readToolCall(t, client.Post(urlPath+"/advance", http.Header{}, strings.NewReader(`{"action":"accept","content":{"name":"Jeffrey"}}`)), http.StatusOK)
+			return tc
+		},
+		"stream": func(t *testing.T, client *testClient, urlPath string) mcp.ToolCall {
+			tc, _ := createToolCall(t, client, urlPath, `{}`)
+			return pollToolCall(t, client, urlPath, tc)
+		},
+		"summarize": func(t *testing.T, client *testClient, urlPath string) mcp.ToolCall {
+			tc, etag := createToolCall(t, client, urlPath, `{"text":"First paragraph.\n\nSecond paragraph."}`)
+			for *tc.Status == mcp.StatusAwaitingSamplingResult {
+				resp := client.Post(urlPath+"/advance", http.Header{"If-Match": []string{etag}}, strings.NewReader(samplingResult(mcp.RoleAssistant, "A summary.")))
+				tc, etag = readToolCall(t, resp, http.StatusOK)
+			}
+			return tc
+		},
+	}
+	client := newTestClient(t)
+	for name, ti := range testSvr.toolInfos {
+		tool := ti.Tool()
+		if tool.OutputSchema == nil {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			drive, ok := drivers[name]
+			if !ok {
+				t.Fatalf("tool '%s' has an OutputSchema but no driver producing a result to validate", name)
+			}
+			tc := drive(t, client, "/mcp/tools/"+name+"/calls/"+strings.ReplaceAll(t.Name(), "/", "-"))
+			if tc.Status == nil || *tc.Status != mcp.StatusSuccess || tc.Result == nil {
+				t.Fatalf("expected a successful tool call with a result, got %s", aids.MustMarshal(tc))
+			}
+			violations, err := jsonschema.Validate(tool.OutputSchema, tc.Result)
+			if aids.IsError(err) {
+				t.Fatal(err)
+			}
+			if len(violations) > 0 {
+				t.Fatalf("tool '%s' result doesn't match its OutputSchema: %v", name, violations)
+			}
+		})
+	}
+}
+
+// createToolCall PUTs a new tool call with body & returns it (& its ETag) after checking for a 200
+func createToolCall(t *testing.T, client *testClient, urlPath, body string) (mcp.ToolCall, string) {
+	t.Helper()
+	return readToolCall(t, client.Put(urlPath, http.Header{"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)}}, strings.NewReader(body)), http.StatusOK)
+}
+
+// pollToolCall GETs the running tool call tc until it stops running
+func pollToolCall(t *testing.T, client *testClient, urlPath string, tc mcp.ToolCall) mcp.ToolCall {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); *tc.Status == mcp.StatusRunning; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("tool call still running after 10 seconds: %s", aids.MustMarshal(tc))
+		}
+		tc, _ = readToolCall(t, client.Get(urlPath, http.Header{"Accept": []string{"application/json"}}), http.StatusOK)
+	}
+	return tc
+}
+
+func TestOutputValidationStrictFailsToolCall(t *testing.T) {
+	tc := toolcall.New(defaultTenant, "count", t.Name())
+	tc.Status, tc.Result = aids.New(mcp.StatusSuccess), aids.MustMarshal(map[string]any{"count": 1})
+	if se := testSvr.store.Put(context.Background(), tc, svrcore.AccessConditions{}); se != nil {
+		t.Fatal(se)
+	}
+	if *tc.Status != mcp.StatusFailed || tc.Result != nil {
+		t.Fatalf("expected a failed tool call without a result, got status %s", *tc.Status)
+	}
+	se := aids.MustUnmarshal[svrcore.ServerError](tc.Error)
+	if se.ErrorCode != "InvalidToolOutput" || len(se.Details) != 2 {
+		t.Fatalf("expected InvalidToolOutput with 2 details, got %s", tc.Error)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+type codeReviewPromptInfo struct{}
+
+func (pi *codeReviewPromptInfo) Prompt() *mcp.Prompt {
+	return &mcp.Prompt{
+		BaseMetadata: mcp.BaseMetadata{
+			Name:  "code_review",
+			Title: aids.New("Request a code review"),
+		},
+		Description: aids.New("Asks the LLM to review code for bugs, readability & performance"),
+		Arguments: []mcp.PromptArgument{
+			{BaseMetadata: mcp.BaseMetadata{Name: "code"}, Description: aids.New("The code to review"), Required: aids.New(true)},
+			{BaseMetadata: mcp.BaseMetadata{Name: "language"}, Description: aids.New("The code's programming language")},
+			{BaseMetadata: mcp.BaseMetadata{Name: "focus"}, Description: aids.New("What the review should focus on (ex: security)")},
+		},
+	}
+}
+
+func (pi *codeReviewPromptInfo) Render(ctx context.Context, args map[string]string) (*mcp.PromptResponse, *svrcore.ServerError) {
+	sb := strings.Builder{}
+	sb.WriteString("Please review the following ")
+	if language := args["language"]; language != "" {
+		sb.WriteString(language + " ")
+	}
+	sb.WriteString("code for bugs, readability & performance")
+	if focus := args["focus"]; focus != "" {
+		sb.WriteString(", focusing especially on " + focus)
+	}
+	sb.WriteString(":\n\n" + args["code"])
+	return &mcp.PromptResponse{
+		Description: aids.New("Code review request"),
+		Messages: []mcp.PromptMessage{
+			{Role: mcp.RoleUser, Content: mcp.TextContent{Type: "text", Text: sb.String()}},
+		},
+	}, nil
+}
+
+func (pi *codeReviewPromptInfo) Complete(ctx context.Context, ref mcp.CompleteReference, argument mcp.CompleteArgument, args map[string]string) ([]string, *svrcore.ServerError) {
+	switch argument.Name {
+	case "language":
+		return completeFrom([]string{"C", "C#", "C++", "Go", "Java", "JavaScript", "Kotlin", "Python", "Ruby", "Rust", "Swift", "TypeScript"}, argument.Value), nil
+	case "focus":
+		return completeFrom([]string{"concurrency", "error handling", "performance", "readability", "security", "testability"}, argument.Value), nil
+	}
+	return []string{}, nil // Arbitrary code can't be completed
+}
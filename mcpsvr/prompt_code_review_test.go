@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+)
+
+func TestPromptCodeReview(t *testing.T) {
+	client := newTestClient(t)
+
+	resp := client.Post("/mcp/prompts/code_review", http.Header{},
+		strings.NewReader(`{"name":"code_review","arguments":{"code":"x := 1","language":"Go","focus":"naming"}}`))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if aids.IsError(err) {
+		t.Fatal(err)
+	}
+
+	pr := mcp.PromptResponse{}
+	err = json.Unmarshal(b, &pr)
+	if aids.IsError(err) {
+		t.Fatal(err)
+	}
+	if actual := len(pr.Messages); actual != 1 {
+		t.Fatalf("expected 1 message, got %d", actual)
+	}
+	text, ok := pr.Messages[0].Content.(mcp.TextContent)
+	if !ok {
+		t.Fatalf("expected text content, got %T", pr.Messages[0].Content)
+	}
+	for _, s := range []string{"Go code", "focusing especially on naming", "x := 1"} {
+		if !strings.Contains(text.Text, s) {
+			t.Fatalf("expected %q in prompt text %q", s, text.Text)
+		}
+	}
+}
+
+func TestPromptCodeReviewInvalidArguments(t *testing.T) {
+	for name, body := range map[string]string{
+		"missing required": `{"arguments":{"language":"Go"}}`,
+		"no arguments":     `{}`,
+		"unknown argument": `{"arguments":{"code":"x := 1","style":"terse"}}`,
+		"mismatched name":  `{"name":"describe_server","arguments":{"code":"x := 1"}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := newTestClient(t)
+			resp := client.Post("/mcp/prompts/code_review", http.Header{}, strings.NewReader(body))
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestPromptNotFound(t *testing.T) {
+	client := newTestClient(t)
+	resp := client.Post("/mcp/prompts/nosuchprompt", http.Header{}, strings.NewReader(`{}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected status %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+// describeServerPromptInfo renders a prompt embedding this server's readme & logo resources
+type describeServerPromptInfo struct {
+	ops *mcpStages
+}
+
+func (pi *describeServerPromptInfo) Prompt() *mcp.Prompt {
+	return &mcp.Prompt{
+		BaseMetadata: mcp.BaseMetadata{
+			Name:  "describe_server",
+			Title: aids.New("Describe this server"),
+		},
+		Description: aids.New("Asks the LLM to describe this server using its readme & logo resources"),
+		Arguments: []mcp.PromptArgument{
+			{BaseMetadata: mcp.BaseMetadata{Name: "audience"}, Description: aids.New("Who the description is for (ex: developers)"), Required: aids.New(true)},
+		},
+	}
+}
+
+func (pi *describeServerPromptInfo) Render(ctx context.Context, args map[string]string) (*mcp.PromptResponse, *svrcore.ServerError) {
+	readme, mimeType, se := pi.read(ctx, "readme")
+	if se != nil {
+		return nil, se
+	}
+	logo, logoMimeType, se := pi.read(ctx, "logo")
+	if se != nil {
+		return nil, se
+	}
+	return &mcp.PromptResponse{
+		Description: aids.New("Describe this server for " + args["audience"]),
+		Messages: []mcp.PromptMessage{
+			{Role: mcp.RoleUser, Content: mcp.TextContent{Type: "text", Text: "Using the readme & logo below, describe this server for " + args["audience"] + "."}},
+			{Role: mcp.RoleUser, Content: mcp.EmbeddedResource{Type: "resource", Resource: mcp.TextResourceContents{
+				ResourceContents: mcp.ResourceContents{URI: pi.ops.resources["readme"].resource.URI, MimeType: aids.New(mimeType)},
+				Text:             string(readme),
+			}}},
+			{Role: mcp.RoleUser, Content: mcp.ImageContent{Type: "image", Data: base64.StdEncoding.EncodeToString(logo), MimeType: logoMimeType}},
+		},
+	}, nil
+}
+
+func (pi *describeServerPromptInfo) Complete(ctx context.Context, ref mcp.CompleteReference, argument mcp.CompleteArgument, args map[string]string) ([]string, *svrcore.ServerError) {
+	return completeFrom([]string{"developers", "executives", "operators", "students"}, argument.Value), nil
+}
+
+// read returns the content & MIME type of the named (non-template) resource
+func (pi *describeServerPromptInfo) read(ctx context.Context, name string) ([]byte, string, *svrcore.ServerError) {
+	re := pi.ops.resources[name]
+	rc, se := re.rp.Read(ctx, re.resource.URI, map[string]string{})
+	if se != nil {
+		return nil, "", se
+	}
+	return aids.Must(io.ReadAll(rc.Content)), rc.MimeType, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+)
+
+func TestPromptDescribeServer(t *testing.T) {
+	client := newTestClient(t)
+
+	resp := client.Post("/mcp/prompts/describe_server", http.Header{}, strings.NewReader(`{"arguments":{"audience":"developers"}}`))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if aids.IsError(err) {
+		t.Fatal(err)
+	}
+
+	pr := mcp.PromptResponse{}
+	err = json.Unmarshal(b, &pr)
+	if aids.IsError(err) {
+		t.Fatal(err)
+	}
+	if actual := len(pr.Messages); actual != 3 {
+		t.Fatalf("expected 3 messages, got %d", actual)
+	}
+	if text, ok := pr.Messages[0].Content.(mcp.TextContent); !ok || !strings.Contains(text.Text, "developers") {
+		t.Fatalf("expected text content mentioning the audience, got %#v", pr.Messages[0].Content)
+	}
+
+	er, ok := pr.Messages[1].Content.(mcp.EmbeddedResource)
+	if !ok {
+		t.Fatalf("expected embedded resource content, got %T", pr.Messages[1].Content)
+	}
+	trc, ok := er.Resource.(mcp.TextResourceContents)
+	if !ok {
+		t.Fatalf("expected text resource contents, got %T", er.Resource)
+	}
+	if trc.URI != readmeURI || trc.Text != readmeText {
+		t.Fatalf("unexpected embedded resource %q", trc.URI)
+	}
+
+	image, ok := pr.Messages[2].Content.(mcp.ImageContent)
+	if !ok {
+		t.Fatalf("expected image content, got %T", pr.Messages[2].Content)
+	}
+	if image.MimeType != "image/png" {
+		t.Fatalf("unexpected image MIME type %q", image.MimeType)
+	}
+	if _, err := png.Decode(bytes.NewReader(aids.Must(base64.StdEncoding.DecodeString(image.Data)))); aids.IsError(err) {
+		t.Fatal(err)
+	}
+}
+
+func TestPromptDescribeServerMissingAudience(t *testing.T) {
+	client := newTestClient(t)
+
+	resp := client.Post("/mcp/prompts/describe_server", http.Header{}, strings.NewReader(`{}`))
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if aids.IsError(err) {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "audience") {
+		t.Fatalf("expected error to name the missing argument, got %s", b)
+	}
+}
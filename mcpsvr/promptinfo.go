@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+// PromptInfo defines the interface for prompt-specific operations
+type PromptInfo interface {
+	// Prompt returns the prompt metadata including the arguments the prompt accepts.
+	Prompt() *mcp.Prompt
+
+	// Render returns the prompt's messages for args. args contains only arguments declared by Prompt and
+	// contains every required argument.
+	Render(ctx context.Context, args map[string]string) (*mcp.PromptResponse, *svrcore.ServerError)
+}
+
+// validatePromptArguments returns a 400 [svrcore.ServerError] if args contains an argument not declared by
+// prompt or is missing any of prompt's required arguments.
+func validatePromptArguments(prompt *mcp.Prompt, args map[string]string) *svrcore.ServerError {
+	declared, missing := []string{}, []string{}
+	for _, a := range prompt.Arguments {
+		declared = append(declared, a.Name)
+		if _, ok := args[a.Name]; !ok && a.Required != nil && *a.Required {
+			missing = append(missing, a.Name)
+		}
+	}
+	unknown := []string{}
+	for name := range args {
+		if !slices.Contains(declared, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	slices.Sort(unknown)
+	switch {
+	case len(missing) > 0:
+		return svrcore.NewServerError(http.StatusBadRequest, "BadRequest", "Prompt '%s' missing required arguments: %s", prompt.Name, strings.Join(missing, ", "))
+	case len(unknown) > 0:
+		return svrcore.NewServerError(http.StatusBadRequest, "BadRequest", "Prompt '%s' has no arguments named: %s", prompt.Name, strings.Join(unknown, ", "))
+	}
+	return nil
+}
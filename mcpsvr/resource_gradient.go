@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/mcpsvr/uritemplate"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+// gradientResourceProvider serves binary PNG images: a fixed-size logo resource & a resource template
+// whose width & height variables produce a gradient image of any (bounded) size.
+type gradientResourceProvider struct {
+	defaultResourceProvider
+	lastModified time.Time
+}
+
+const (
+	gradientURITemplate = "image://gradient/{width}x{height}.png"
+	gradientMaxSize     = 1024 // Maximum width/height in pixels
+)
+
+// gradientLogoURI is the logo resource's URI; it's the template expanded with the logo's size
+var gradientLogoURI = aids.Must(uritemplate.Expand(gradientURITemplate, map[string]string{"width": "64", "height": "64"}))
+
+func newGradientResourceProvider() *gradientResourceProvider {
+	return &gradientResourceProvider{lastModified: time.Now().Truncate(time.Second)}
+}
+
+func (rp *gradientResourceProvider) Resources() []mcp.Resource {
+	return []mcp.Resource{{
+		BaseMetadata: mcp.BaseMetadata{Name: "logo", Title: aids.New("Server logo")},
+		URI:          gradientLogoURI,
+		Description:  aids.New("A 64x64 PNG image"),
+		MimeType:     aids.New("image/png"),
+	}}
+}
+
+func (rp *gradientResourceProvider) ResourceTemplates() []mcp.ResourceTemplate {
+	return []mcp.ResourceTemplate{{
+		BaseMetadata: mcp.BaseMetadata{Name: "gradient", Title: aids.New("Gradient image")},
+		URITemplate:  gradientURITemplate,
+		Description:  aids.New("A PNG image of the specified width & height (1-" + strconv.Itoa(gradientMaxSize) + " pixels) filled with a gradient"),
+		MimeType:     aids.New("image/png"),
+	}}
+}
+
+func (rp *gradientResourceProvider) Read(ctx context.Context, uri string, vars map[string]string) (*ResourceContents, *svrcore.ServerError) {
+	if uri == gradientLogoURI && len(vars) == 0 { // The logo resource; the template's variables must all be specified
+		vars = map[string]string{"width": "64", "height": "64"}
+	}
+	size := [2]int{}
+	for i, name := range []string{"width", "height"} {
+		n, err := strconv.Atoi(vars[name])
+		if aids.IsError(err) || n < 1 || n > gradientMaxSize {
+			return nil, svrcore.NewServerError(http.StatusBadRequest, "BadRequest", "%s must be an integer from 1 to %d", name, gradientMaxSize)
+		}
+		size[i] = n
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, size[0], size[1]))
+	for y := range size[1] {
+		for x := range size[0] {
+			img.Set(x, y, color.RGBA{R: uint8(255 * x / size[0]), G: uint8(255 * y / size[1]), B: 128, A: 255})
+		}
+	}
+	b := &bytes.Buffer{}
+	aids.Must0(png.Encode(b, img))
+	return &ResourceContents{
+		MimeType:     "image/png",
+		ETag:         contentETag(b.Bytes()),
+		LastModified: aids.New(rp.lastModified),
+		Content:      bytes.NewReader(b.Bytes()),
+	}, nil
+}
+
+// Complete suggests power-of-2 sizes for the gradient template's width & height; the other dimension's value
+// (if entered) is suggested 1st so square images are easy to request.
+func (rp *gradientResourceProvider) Complete(ctx context.Context, ref mcp.CompleteReference, argument mcp.CompleteArgument, args map[string]string) ([]string, *svrcore.ServerError) {
+	sizes := []string{}
+	if other := args[aids.Iif(argument.Name == "width", "height", "width")]; other != "" {
+		sizes = append(sizes, other)
+	}
+	for n := 16; n <= gradientMaxSize; n *= 2 {
+		if s := strconv.Itoa(n); !slices.Contains(sizes, s) {
+			sizes = append(sizes, s)
+		}
+	}
+	return completeFrom(sizes, argument.Value), nil
+}
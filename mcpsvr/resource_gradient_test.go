@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"testing"
+
+	"github.com/JeffreyRichter/internal/aids"
+)
+
+func TestGradientResource(t *testing.T) {
+	client := newTestClient(t)
+	resp, b := getResource(t, client, "/mcp/resources/gradient?width=40&height=30", http.Header{})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+	}
+	if actual := resp.Header.Get("Content-Type"); actual != "image/png" {
+		t.Fatalf("unexpected Content-Type %q", actual)
+	}
+	if actual := resp.Header.Get("Mcp-Resource-Uri"); actual != "image://gradient/40x30.png" {
+		t.Fatalf("unexpected Mcp-Resource-Uri %q", actual)
+	}
+	img := aids.Must(png.Decode(bytes.NewReader(b))) // The body is the raw PNG, not base64
+	if size := img.Bounds().Size(); size.X != 40 || size.Y != 30 {
+		t.Fatalf("expected a 40x30 image, got %dx%d", size.X, size.Y)
+	}
+
+	t.Run("range", func(t *testing.T) {
+		resp, part := getResource(t, client, "/mcp/resources/gradient?width=40&height=30", http.Header{"Range": []string{"bytes=0-7"}})
+		if resp.StatusCode != http.StatusPartialContent {
+			t.Fatalf("expected 206 Partial Content, got %d", resp.StatusCode)
+		}
+		if !bytes.Equal(part, b[:8]) {
+			t.Fatalf("expected the PNG signature, got %v", part)
+		}
+	})
+	t.Run("stale if-range", func(t *testing.T) {
+		resp, all := getResource(t, client, "/mcp/resources/gradient?width=40&height=30", http.Header{"Range": []string{"bytes=0-7"}, "If-Range": []string{"stale"}})
+		if resp.StatusCode != http.StatusOK || !bytes.Equal(all, b) {
+			t.Fatalf("expected 200 OK with the whole image, got %d with %d bytes", resp.StatusCode, len(all))
+		}
+	})
+	t.Run("logo", func(t *testing.T) {
+		resp, b := getResource(t, client, "/mcp/resources/logo", http.Header{})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+		}
+		if size := aids.Must(png.Decode(bytes.NewReader(b))).Bounds().Size(); size.X != 64 || size.Y != 64 {
+			t.Fatalf("expected a 64x64 image, got %dx%d", size.X, size.Y)
+		}
+	})
+	for name, query := range map[string]string{"no variables": "", "missing variable": "?width=40", "invalid size": "?width=40&height=5000", "unrecognized variable": "?width=40&height=30&depth=2"} {
+		t.Run(name, func(t *testing.T) {
+			resp, _ := getResource(t, client, "/mcp/resources/gradient"+query, http.Header{})
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("expected 400 Bad Request, got %d", resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+// readmeResourceProvider serves a single text resource describing this server
+type readmeResourceProvider struct {
+	defaultResourceProvider
+	lastModified time.Time
+}
+
+const readmeURI = "docs://readme.md"
+
+const readmeText = `# MCP over HTTP sample server
+
+This server implements the MCP-over-HTTP SEP: every MCP operation is a plain HTTP request.
+
+* Tools: GET /mcp/tools; tool calls are durable resources at /mcp/tools/{toolName}/calls/{toolCallID}
+* Resources: GET /mcp/resources & /mcp/resources-templates; read a resource with GET /mcp/resources/{name}
+* Resource bodies are raw bytes (never base64-encoded) & support ETags and byte ranges
+`
+
+func newReadmeResourceProvider() *readmeResourceProvider {
+	return &readmeResourceProvider{lastModified: time.Now().Truncate(time.Second)}
+}
+
+func (rp *readmeResourceProvider) Resources() []mcp.Resource {
+	return []mcp.Resource{{
+		BaseMetadata: mcp.BaseMetadata{Name: "readme", Title: aids.New("About this server")},
+		URI:          readmeURI,
+		Description:  aids.New("Describes this server & the MCP operations it supports"),
+		MimeType:     aids.New("text/markdown"),
+		Size:         aids.New(int64(len(readmeText))),
+		Annotations:  &mcp.Annotations{Audience: []mcp.Role{mcp.RoleUser, mcp.RoleAssistant}},
+	}}
+}
+
+func (rp *readmeResourceProvider) Read(ctx context.Context, uri string, vars map[string]string) (*ResourceContents, *svrcore.ServerError) {
+	if uri != readmeURI {
+		return nil, svrcore.NewServerError(http.StatusNotFound, "NotFound", "Resource '%s' not found", uri)
+	}
+	return &ResourceContents{
+		MimeType:     "text/markdown; charset=utf-8",
+		ETag:         contentETag([]byte(readmeText)),
+		LastModified: aids.New(rp.lastModified),
+		Content:      strings.NewReader(readmeText),
+	}, nil
+}
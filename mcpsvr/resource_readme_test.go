@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/JeffreyRichter/internal/aids"
+)
+
+// getResource reads the resource at urlPath; client.Get cancels before a large body can be read
+func getResource(t *testing.T, client *testClient, urlPath string, headers http.Header) (*http.Response, []byte) {
+	req, err := http.NewRequest(http.MethodGet, client.url+urlPath, nil)
+	if aids.IsError(err) {
+		t.Fatal(err)
+	}
+	req.Header = headers
+	resp, err := http.DefaultClient.Do(req)
+	if aids.IsError(err) {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if aids.IsError(err) {
+		t.Fatal(err)
+	}
+	return resp, b
+}
+
+func TestReadmeResource(t *testing.T) {
+	client := newTestClient(t)
+	resp, b := getResource(t, client, "/mcp/resources/readme", http.Header{})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+	}
+	if actual := string(b); actual != readmeText {
+		t.Fatalf("unexpected body %q", actual)
+	}
+	if actual := resp.Header.Get("Content-Type"); actual != "text/markdown; charset=utf-8" {
+		t.Fatalf("unexpected Content-Type %q", actual)
+	}
+	if actual := resp.Header.Get("Mcp-Resource-Uri"); actual != readmeURI {
+		t.Fatalf("unexpected Mcp-Resource-Uri %q", actual)
+	}
+
+	t.Run("if-none-match", func(t *testing.T) {
+		resp, _ := getResource(t, client, "/mcp/resources/readme", http.Header{"If-None-Match": []string{resp.Header.Get("ETag")}})
+		if resp.StatusCode != http.StatusNotModified {
+			t.Fatalf("expected 304 Not Modified, got %d", resp.StatusCode)
+		}
+	})
+	t.Run("if-modified-since", func(t *testing.T) {
+		resp, _ := getResource(t, client, "/mcp/resources/readme", http.Header{"If-Modified-Since": []string{resp.Header.Get("Last-Modified")}})
+		if resp.StatusCode != http.StatusNotModified {
+			t.Fatalf("expected 304 Not Modified, got %d", resp.StatusCode)
+		}
+	})
+	t.Run("unrecognized query parameter", func(t *testing.T) {
+		resp, _ := getResource(t, client, "/mcp/resources/readme?width=1", http.Header{})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 Bad Request, got %d", resp.StatusCode)
+		}
+	})
+}
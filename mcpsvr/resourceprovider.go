@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+// ResourceProvider defines the interface for resource-specific operations
+type ResourceProvider interface {
+	// Resources returns the metadata of the concrete resources this provider serves.
+	Resources() []mcp.Resource
+
+	// ResourceTemplates returns the metadata of the resource templates (RFC 6570) this provider serves.
+	ResourceTemplates() []mcp.ResourceTemplate
+
+	// Read returns the contents of the resource identified by uri. For a resource template, uri is the template
+	// expanded with vars (the request's query parameters); for a concrete resource, vars is empty.
+	Read(ctx context.Context, uri string, vars map[string]string) (*ResourceContents, *svrcore.ServerError)
+}
+
+// ResourceContents is a resource's raw (never base64-encoded) content returned by [ResourceProvider.Read]
+type ResourceContents struct {
+	MimeType     string        // The HTTP response's Content-Type
+	ETag         *svrcore.ETag // Optional; used for If-None-Match/If-Match & If-Range
+	LastModified *time.Time    // Optional; used for If-(Un)Modified-Since & If-Range
+	Content      io.ReadSeeker // Seekable so clients can request byte ranges
+}
+
+// defaultResourceProvider provides default implementations of ResourceProvider methods; it serves no resources.
+type defaultResourceProvider struct{}
+
+func (*defaultResourceProvider) Resources() []mcp.Resource                 { return nil }
+func (*defaultResourceProvider) ResourceTemplates() []mcp.ResourceTemplate { return nil }
+func (*defaultResourceProvider) Read(ctx context.Context, uri string, vars map[string]string) (*ResourceContents, *svrcore.ServerError) {
+	return nil, svrcore.NewServerError(http.StatusNotFound, "NotFound", "Resource '%s' not found", uri)
+}
+
+// contentETag returns a strong ETag derived from content's SHA-256 hash
+func contentETag(content []byte) *svrcore.ETag {
+	h := sha256.Sum256(content)
+	return aids.New(svrcore.ETag(hex.EncodeToString(h[:16])))
+}
@@ -0,0 +1,93 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcpsvr/roots"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+// store maintains the state required to manage all operations for the roots resource type.
+type store struct {
+	client *azblob.Client // Client to access the Azure Blob Storage service
+}
+
+// NewRootsStore creates a new [roots.Store]
+func NewRootsStore(client *azblob.Client) roots.Store {
+	return &store{client: client}
+}
+
+// toBlobInfo returns the container and blob name for the specified client's roots. The tenant's container is
+// shared with its tool calls (whose blob names start with a tool name) so roots blobs use a "$roots/" prefix.
+func (s *store) toBlobInfo(r *roots.Resource) (containerName, blobName string) {
+	return *r.Tenant, "$roots/" + *r.ClientID
+}
+
+// accessConditions converts svrcore.AccessConditions to azblob.AccessConditions
+func (*store) accessConditions(ac svrcore.AccessConditions) *azblob.AccessConditions {
+	return &azblob.AccessConditions{
+		ModifiedAccessConditions: &blob.ModifiedAccessConditions{
+			IfMatch:     (*azcore.ETag)(ac.IfMatch),
+			IfNoneMatch: (*azcore.ETag)(ac.IfNoneMatch)},
+	}
+}
+
+// Get retrieves the specified client's roots from storage into the passed-in Resource struct or a
+// [svrcore.ServerError] if an error occurs.
+func (s *store) Get(ctx context.Context, r *roots.Resource, ac svrcore.AccessConditions) *svrcore.ServerError {
+	containerName, blobName := s.toBlobInfo(r)
+	response, err := s.client.DownloadStream(ctx, containerName, blobName,
+		&azblob.DownloadStreamOptions{AccessConditions: s.accessConditions(ac)})
+	if aids.IsError(err) {
+		if rerr := (*azcore.ResponseError)(nil); errors.As(err, &rerr) { // Blob not found or precondition failed
+			return svrcore.NewServerError(rerr.StatusCode, "", "Failed to get roots")
+		}
+		return svrcore.NewServerError(http.StatusInternalServerError, "InternalServerError", "failed to get roots")
+	}
+
+	defer response.Body.Close()
+	const MaxRootsResourceSizeInBytes = 1024 * 1024 // 1MB
+	buffer, err := io.ReadAll(io.LimitReader(response.Body, MaxRootsResourceSizeInBytes))
+	if aids.IsError(err) {
+		return svrcore.NewServerError(http.StatusInternalServerError, "InternalServerError", "failed to read roots")
+	}
+	*r = aids.MustUnmarshal[roots.Resource](buffer)
+	r.ETag = (*svrcore.ETag)(response.ETag) // Set the ETag from the response
+	return nil
+}
+
+// Put creates or replaces the specified client's roots in storage from the passed-in Resource struct.
+// On success, the Resource.ETag field is updated from the response ETag. Returns a
+// [svrcore.ServerError] if an error occurs.
+func (s *store) Put(ctx context.Context, r *roots.Resource, ac svrcore.AccessConditions) *svrcore.ServerError {
+	buffer := aids.MustMarshal(r)
+	containerName, blobName := s.toBlobInfo(r)
+	for {
+		response, err := s.client.UploadBuffer(ctx, containerName, blobName, buffer,
+			&azblob.UploadBufferOptions{AccessConditions: s.accessConditions(ac)})
+		if !aids.IsError(err) {
+			r.ETag = (*svrcore.ETag)(response.ETag) // Update the passed-in Resource's ETag from the response ETag
+			return nil
+		}
+
+		// An error occured; if not related to missing container, return the error
+		if bloberror.HasCode(err, bloberror.ConditionNotMet, bloberror.BlobNotFound) {
+			return svrcore.NewServerError(http.StatusPreconditionFailed, "PreconditionFailed", "roots were modified")
+		}
+		if !bloberror.HasCode(err, bloberror.ContainerNotFound) {
+			return svrcore.NewServerError(http.StatusInternalServerError, "", "failed to upload roots")
+		}
+		if _, err := s.client.CreateContainer(ctx, containerName, nil); aids.IsError(err) { // Attempt to create the missing tenant container
+			return svrcore.NewServerError(http.StatusInternalServerError, "", "failed to create container")
+		}
+		// Successfully created the container, retry uploading the roots blob
+	}
+}
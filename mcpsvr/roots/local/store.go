@@ -0,0 +1,57 @@
+package local
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcpsvr/roots"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+// localRootsStore is an in-memory [roots.Store] having the same semantics as the Azure blob roots store
+type localRootsStore struct {
+	data map[string]*roots.Resource
+	mu   *sync.RWMutex
+}
+
+// NewRootsStore creates a [roots.Store]
+func NewRootsStore() roots.Store {
+	return &localRootsStore{data: map[string]*roots.Resource{}, mu: &sync.RWMutex{}}
+}
+
+func (s *localRootsStore) Get(_ context.Context, r *roots.Resource, ac svrcore.AccessConditions) *svrcore.ServerError {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, ok := s.data[s.key(r)]
+	if !ok {
+		return svrcore.NewServerError(http.StatusNotFound, "NotFound", "Roots not found")
+	}
+	*r = stored.Copy() // copying prevents the caller mutating stored data
+	return svrcore.CheckPreconditions(svrcore.ResourceValues{AllowedConditionals: svrcore.AllowedConditionalsMatch, ETag: stored.ETag}, http.MethodGet, ac)
+}
+
+func (s *localRootsStore) Put(_ context.Context, r *roots.Resource, ac svrcore.AccessConditions) *svrcore.ServerError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.key(r)
+	rv := svrcore.ResourceValues{AllowedConditionals: svrcore.AllowedConditionalsMatch}
+	if stored, ok := s.data[key]; ok {
+		rv.ETag = stored.ETag
+	}
+	if se := svrcore.CheckPreconditions(rv, http.MethodPut, ac); se != nil {
+		r.ETag = rv.ETag // return the current ETag to the caller
+		return se
+	}
+	cp := r.Copy() // storing a copy prevents mutating the caller's data
+	cp.ETag, cp.Updated = aids.New(svrcore.ETag(time.Now().Format("20060102150405.000000"))), aids.New(time.Now())
+	s.data[key] = &cp
+	*r = cp.Copy() // except we want the caller to have the actual ETag
+	return nil
+}
+
+func (*localRootsStore) key(r *roots.Resource) string { return *r.Tenant + "/" + *r.ClientID }
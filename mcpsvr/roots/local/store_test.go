@@ -0,0 +1,94 @@
+package local
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/mcpsvr/roots"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+var ctx = context.Background()
+
+func TestLocalRootsStore_Get_NotFound(t *testing.T) {
+	store := NewRootsStore()
+	se := store.Get(ctx, roots.New("test-tenant", "test-client"), svrcore.AccessConditions{})
+	if se == nil || se.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected status code 404, got %v", se)
+	}
+}
+
+func TestLocalRootsStore_Put_and_Get(t *testing.T) {
+	store := NewRootsStore()
+	r := roots.New("test-tenant", "test-client")
+	r.Roots = []mcp.Root{{URI: "file:///home/user/project", Name: aids.New("project")}}
+	if se := store.Put(ctx, r, svrcore.AccessConditions{}); se != nil {
+		t.Fatalf("Put failed: %v", se)
+	}
+	if r.ETag == nil || r.Updated == nil {
+		t.Fatal("Expected ETag & Updated to be set on put result")
+	}
+
+	got := roots.New("test-tenant", "test-client")
+	if se := store.Get(ctx, got, svrcore.AccessConditions{}); se != nil {
+		t.Fatalf("Get failed: %v", se)
+	}
+	if !got.ETag.Equals(*r.ETag) || len(got.Roots) != 1 || got.Roots[0].URI != "file:///home/user/project" {
+		t.Fatalf("Get returned unexpected roots %+v", got)
+	}
+
+	other := roots.New("test-tenant", "other-client") // Roots are scoped per client
+	if se := store.Get(ctx, other, svrcore.AccessConditions{}); se == nil || se.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected other client's roots to be not found, got %v", se)
+	}
+}
+
+func TestLocalRootsStore_Put_AccessConditions(t *testing.T) {
+	store := NewRootsStore()
+	r := roots.New("test-tenant", "test-client")
+	if se := store.Put(ctx, r, svrcore.AccessConditions{IfMatch: aids.New(svrcore.ETag("nonexistent"))}); se == nil || se.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("Expected If-Match on nonexistent roots to fail with 412, got %v", se)
+	}
+	if se := store.Put(ctx, r, svrcore.AccessConditions{IfNoneMatch: svrcore.ETagAnyPtr}); se != nil {
+		t.Fatalf("Put failed: %v", se)
+	}
+	etag := *r.ETag
+	if se := store.Put(ctx, r, svrcore.AccessConditions{IfNoneMatch: svrcore.ETagAnyPtr}); se == nil || se.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("Expected If-None-Match:* on existing roots to fail with 412, got %v", se)
+	}
+	if se := store.Put(ctx, r, svrcore.AccessConditions{IfMatch: &etag}); se != nil {
+		t.Fatalf("Put with matching If-Match failed: %v", se)
+	}
+	if se := store.Put(ctx, r, svrcore.AccessConditions{IfMatch: &etag}); se == nil || se.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("Expected stale If-Match to fail with 412, got %v", se)
+	}
+}
+
+func TestRootsContext(t *testing.T) {
+	store := NewRootsStore()
+	if rs, se := roots.FromContext(roots.NewContext(ctx, store, "test-tenant", "test-client")); se != nil || len(rs) != 0 {
+		t.Fatalf("Expected no roots before any are put, got %v, %v", rs, se)
+	}
+
+	r := roots.New("test-tenant", "test-client")
+	r.Roots = []mcp.Root{{URI: "file:///home/user/project"}}
+	if se := store.Put(ctx, r, svrcore.AccessConditions{}); se != nil {
+		t.Fatalf("Put failed: %v", se)
+	}
+	rs, se := roots.FromContext(roots.NewContext(ctx, store, "test-tenant", "test-client"))
+	if se != nil || len(rs) != 1 {
+		t.Fatalf("Expected 1 root, got %v, %v", rs, se)
+	}
+	if !roots.Allows(rs, "file:///home/user/project/main.go") {
+		t.Fatal("Expected file beneath root to be allowed")
+	}
+	if roots.Allows(rs, "file:///home/user/projectX/main.go") || roots.Allows(rs, "file:///etc/passwd") {
+		t.Fatal("Expected file outside root to be disallowed")
+	}
+	if rs, se := roots.FromContext(ctx); se != nil || len(rs) != 0 {
+		t.Fatalf("Expected no roots from a context without roots, got %v, %v", rs, se)
+	}
+}
@@ -0,0 +1,132 @@
+package roots
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+type (
+	// Identity is the identity of a client's roots which includes Tenant and ClientID
+	Identity struct {
+		Tenant   *string `json:"tenant"`
+		ClientID *string `json:"clientId"` // Scoped within tenant; all of a client's devices share its roots
+	}
+
+	// Resource is the data model for the version-agnostic roots resource type.
+	Resource struct {
+		Identity `json:",inline"`
+		ETag     *svrcore.ETag `json:"etag"`
+		Updated  *time.Time    `json:"updated,omitempty"`
+		Roots    []mcp.Root    `json:"roots"`
+	}
+
+	// Store manages persistent storage of clients' roots
+	Store interface {
+		// Put creates or replaces the specified client's roots in storage from the passed-in Resource struct.
+		// On success, the Resource.ETag field is updated from the response ETag. Returns a
+		// [svrcore.ServerError] if an error occurs.
+		Put(ctx context.Context, r *Resource, ac svrcore.AccessConditions) *svrcore.ServerError
+
+		// Get retrieves the specified client's roots from storage into the passed-in Resource struct or a
+		// [svrcore.ServerError] (like 404) if an error occurs.
+		Get(ctx context.Context, r *Resource, ac svrcore.AccessConditions) *svrcore.ServerError
+	}
+)
+
+// MaxRoots is the maximum number of roots a client may declare
+const MaxRoots = 100
+
+// New creates a new roots Resource (with no roots) for the specified tenant and client ID.
+func New(tenant, clientID string) *Resource {
+	return &Resource{Identity: Identity{Tenant: aids.New(tenant), ClientID: aids.New(clientID)}, Roots: []mcp.Root{}}
+}
+
+// Copy returns a deep copy of r
+func (r *Resource) Copy() Resource {
+	aids.Assert(r != nil, "roots.Resource.Copy: r is nil")
+	b := aids.Must(json.Marshal(r))
+	cp := Resource{}
+	aids.Must0(json.Unmarshal(b, &cp))
+	return cp
+}
+
+// ToMCP converts the roots Resource to the public-facing MCP RootsList returned to clients.
+func (r *Resource) ToMCP() mcp.RootsList { return mcp.RootsList{Roots: r.Roots} }
+
+// Validate returns an error if rl has too many roots, a root whose URI isn't an absolute file:// URI, or
+// duplicate root URIs.
+func Validate(rl mcp.RootsList) error {
+	if len(rl.Roots) > MaxRoots {
+		return fmt.Errorf("at most %d roots are allowed", MaxRoots)
+	}
+	seen := map[string]bool{}
+	for i, root := range rl.Roots {
+		u, err := url.Parse(root.URI)
+		if aids.IsError(err) || u.Scheme != "file" || !strings.HasPrefix(u.Path, "/") {
+			return fmt.Errorf("roots[%d]: uri %q must be an absolute file:// URI", i, root.URI)
+		}
+		if seen[root.URI] {
+			return fmt.Errorf("roots[%d]: duplicate uri %q", i, root.URI)
+		}
+		seen[root.URI] = true
+	}
+	return nil
+}
+
+// Allows returns true if uri is a file:// URI equal to or beneath one of roots; tools use this to restrict
+// themselves to the roots the client declared.
+func Allows(roots []mcp.Root, uri string) bool {
+	u, err := url.Parse(uri)
+	if aids.IsError(err) || u.Scheme != "file" {
+		return false
+	}
+	for _, root := range roots {
+		r, err := url.Parse(root.URI)
+		if aids.IsError(err) || r.Host != u.Host {
+			continue
+		}
+		dir := strings.TrimSuffix(r.Path, "/")
+		if u.Path == dir || strings.HasPrefix(u.Path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// loaderKey is the context key for the function that loads the request's roots
+type loaderKey struct{}
+
+// NewContext returns a copy of ctx from which FromContext gets tenant's client's roots. The roots are loaded
+// from s on the 1st call to FromContext so requests not needing roots never access storage.
+func NewContext(ctx context.Context, s Store, tenant, clientID string) context.Context {
+	return context.WithValue(ctx, loaderKey{}, sync.OnceValues(func() ([]mcp.Root, *svrcore.ServerError) {
+		r := New(tenant, clientID)
+		if se := s.Get(ctx, r, svrcore.AccessConditions{}); se != nil {
+			if se.StatusCode == http.StatusNotFound {
+				return []mcp.Root{}, nil // The client never declared roots
+			}
+			return nil, se
+		}
+		return r.Roots, nil
+	}))
+}
+
+// FromContext returns the roots the client declared via PUT /mcp/roots. It returns no roots if the client
+// never declared any or ctx didn't come from NewContext; it returns a [svrcore.ServerError] if loading fails.
+func FromContext(ctx context.Context) ([]mcp.Root, *svrcore.ServerError) {
+	load, ok := ctx.Value(loaderKey{}).(func() ([]mcp.Root, *svrcore.ServerError))
+	if !ok {
+		return []mcp.Root{}, nil
+	}
+	return load()
+}
@@ -0,0 +1,41 @@
+package roots
+
+import (
+	"testing"
+
+	"github.com/JeffreyRichter/mcp"
+)
+
+func TestValidate(t *testing.T) {
+	valid := mcp.RootsList{Roots: []mcp.Root{{URI: "file:///home/user/project"}, {URI: "file:///C:/src"}}}
+	if err := Validate(valid); err != nil {
+		t.Fatal(err)
+	}
+	for name, rl := range map[string]mcp.RootsList{
+		"not file scheme": {Roots: []mcp.Root{{URI: "https://example.com/project"}}},
+		"relative":        {Roots: []mcp.Root{{URI: "project/src"}}},
+		"duplicate":       {Roots: []mcp.Root{{URI: "file:///src"}, {URI: "file:///src"}}},
+		"too many":        {Roots: make([]mcp.Root, MaxRoots+1)},
+	} {
+		if err := Validate(rl); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	rs := []mcp.Root{{URI: "file:///home/user/project/"}, {URI: "file:///tmp"}}
+	for uri, want := range map[string]bool{
+		"file:///home/user/project":          true,
+		"file:///home/user/project/a/b.go":   true,
+		"file:///home/user/project2/b.go":    false,
+		"file:///tmp/x":                      true,
+		"file:///etc/passwd":                 false,
+		"https://example.com/tmp/x":          false,
+		"file://otherhost/home/user/project": false,
+	} {
+		if got := Allows(rs, uri); got != want {
+			t.Fatalf("Allows(%q): wanted %t, got %t", uri, want, got)
+		}
+	}
+}
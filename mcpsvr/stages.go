@@ -3,15 +3,28 @@ package main
 // For timeouts/cancellation, see https://ieftimov.com/posts/make-resilient-golang-net-http-servers-using-timeouts-deadlines-context-cancellation/
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"hash/fnv"
+	"io"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/JeffreyRichter/internal/aids"
 	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/mcpsvr/jsonschema"
+	"github.com/JeffreyRichter/mcpsvr/roots"
 	"github.com/JeffreyRichter/mcpsvr/toolcall"
+	"github.com/JeffreyRichter/mcpsvr/uritemplate"
 	"github.com/JeffreyRichter/svrcore"
+	"github.com/JeffreyRichter/svrcore/stages"
+	"github.com/JeffreyRichter/svrcore/tracing"
 )
 
 // Resource type & operations pattern:
@@ -22,19 +35,41 @@ import (
 
 // mcpStages wraps the version-agnostic resources (ToolCalls) with this specific api-version's HTTP operations: behavior wrapping state
 type mcpStages struct {
-	errorLogger *slog.Logger
-	store       toolcall.Store
-	pm          toolcall.PhaseMgr
-	toolInfos   map[string]ToolInfo // ToolName to ToolCaller implementation
+	errorLogger      *slog.Logger
+	store            toolcall.Store
+	serverData       *toolcall.ServerDataEncoder // nil unless tool calls are stateless (reconstructed from serverData)
+	pm               toolcall.PhaseMgr
+	rootsStore       roots.Store
+	notifier         *toolcall.Notifier // nil if webhook notifications are disabled
+	outputValidation OutputValidation
+	toolInfos        map[string]ToolInfo // ToolName to ToolCaller implementation
+
+	resourceProviders []ResourceProvider       // In registration order so resource lists (& their ETags) are stable
+	resources         map[string]resourceEntry // Resource or resource template name to its provider
+
+	promptInfos map[string]PromptInfo // Prompt name to PromptInfo implementation
+
+	metrics atomic.Pointer[toolCallMetrics] // nil until enableMetrics is called
+	tracer  *tracing.Tracer                 // nil if tool call phases aren't traced
+
+	healthCheckers map[string]stages.HealthChecker // The store & phase manager dependencies checked by the readiness probe
+}
+
+// resourceEntry identifies the ResourceProvider serving a named resource or resource template
+type resourceEntry struct {
+	rp       ResourceProvider
+	resource *mcp.Resource         // nil if the entry is a resource template
+	template *mcp.ResourceTemplate // nil if the entry is a resource
 }
 
 func (p *mcpStages) buildToolInfos() {
 	p.toolInfos = map[string]ToolInfo{}
 	for _, tc := range []ToolInfo{
-		&addToolInfo{ops: p},
-		&countToolInfo{ops: p},
-		&welcomeToolInfo{ops: p},
-		&streamToolInfo{ops: p},
+		newAddToolInfo(p),
+		newCountToolInfo(p),
+		newWelcomeToolInfo(p),
+		newStreamToolInfo(p),
+		newSummarizeToolInfo(p),
 	} {
 		if t := tc.Tool(); t != nil {
 			p.toolInfos[t.Name] = tc
@@ -42,13 +77,49 @@ func (p *mcpStages) buildToolInfos() {
 	}
 }
 
+func (p *mcpStages) buildResourceProviders() {
+	p.resourceProviders = []ResourceProvider{
+		newReadmeResourceProvider(),
+		newGradientResourceProvider(),
+	}
+	p.resources = map[string]resourceEntry{}
+	for _, rp := range p.resourceProviders {
+		for _, res := range rp.Resources() {
+			_, exists := p.resources[res.Name]
+			aids.Assert(!exists, fmt.Errorf("duplicate resource name '%s'", res.Name))
+			p.resources[res.Name] = resourceEntry{rp: rp, resource: &res}
+		}
+		for _, rt := range rp.ResourceTemplates() {
+			_, exists := p.resources[rt.Name]
+			aids.Assert(!exists, fmt.Errorf("duplicate resource template name '%s'", rt.Name))
+			aids.Must(uritemplate.Variables(rt.URITemplate)) // Panic at startup if the template is malformed
+			p.resources[rt.Name] = resourceEntry{rp: rp, template: &rt}
+		}
+	}
+}
+
+func (p *mcpStages) buildPromptInfos() {
+	p.promptInfos = map[string]PromptInfo{}
+	for _, pi := range []PromptInfo{
+		&codeReviewPromptInfo{},
+		&describeServerPromptInfo{ops: p},
+	} {
+		if prompt := pi.Prompt(); prompt != nil {
+			p.promptInfos[prompt.Name] = pi
+		}
+	}
+}
+
 // etag returns the ETag for this version's HTTP operations
 func (p *mcpStages) etag() *svrcore.ETag { return aids.New(svrcore.ETag("v20250808")) }
 
 // lookupToolCall retrieves the ToolInfo and ToolCall from the given request URL (and authentication for tenant).
 // Writes an HTTP error response and returns a *ServerError if the tool name or tool call ID is missing or invalid.
-func (p *mcpStages) lookupToolCall(r *svrcore.ReqRes) (ToolInfo, *toolcall.Resource, bool) {
-	tenant := "sometenant"
+func (p *mcpStages) lookupToolCall(ctx context.Context, r *svrcore.ReqRes) (ToolInfo, *toolcall.Resource, bool) {
+	tenant, stop := p.tenant(ctx, r)
+	if stop {
+		return nil, nil, stop
+	}
 	toolName, toolCallID := r.R.PathValue("toolName"), r.R.PathValue("toolCallID")
 	if toolName == "" {
 		return nil, nil, r.WriteError(http.StatusBadRequest, nil, nil, "BadRequest", "Tool name required")
@@ -63,20 +134,114 @@ func (p *mcpStages) lookupToolCall(r *svrcore.ReqRes) (ToolInfo, *toolcall.Resou
 	return ti, toolcall.New(tenant, toolName, toolCallID), false
 }
 
-// toolNameToProcessPhaseFunc converts a toolname to a function that knows how to advance the tool call's phase/state
+// authorizeTool writes a 403-Forbidden response with an "insufficient_scope" challenge (RFC 6750) listing ti's
+// required scopes and returns true if the caller isn't granted all of them.
+func (p *mcpStages) authorizeTool(ctx context.Context, r *svrcore.ReqRes, ti ToolInfo) bool {
+	missing := missingScopes(ctx, ti)
+	if len(missing) == 0 {
+		return false
+	}
+	challenge := fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, strings.Join(requiredScopes(ti), " "))
+	return r.WriteError(http.StatusForbidden, &svrcore.ResponseHeader{WWWAuthenticate: &challenge}, nil,
+		"InsufficientScope", "Tool '%s' requires scope(s): %s", ti.Tool().Name, strings.Join(missing, ", "))
+}
+
+// defaultTenant is the tenant of unauthenticated requests (authentication is disabled; ex: local mode)
+const defaultTenant = "default"
+
+// tenant returns the tenant (from authentication) that the request's tool calls & roots are scoped to so one
+// tenant can never access another's. Writes an HTTP error response and returns true if the tenant is invalid.
+func (p *mcpStages) tenant(ctx context.Context, r *svrcore.ReqRes) (string, bool) {
+	principal, ok := stages.PrincipalFromContext(ctx)
+	if !ok {
+		return defaultTenant, false
+	}
+	// Tenants name Azure blob containers: 3-63 lowercase letters, digits or non-consecutive/non-edge '-'
+	t := principal.Tenant
+	valid := 3 <= len(t) && len(t) <= 63 && t[0] != '-' && t[len(t)-1] != '-' && !strings.Contains(t, "--")
+	for _, c := range t {
+		valid = valid && (c == '-' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z'))
+	}
+	if !valid {
+		return "", r.WriteError(http.StatusForbidden, nil, nil, "InvalidTenant", "Tenant %q must be 3-63 lowercase letters, digits or '-'", t)
+	}
+	return t, false
+}
+
+// clientIDHeader is the request header identifying the MCP client (app) within the tenant; all of a
+// client's devices share the client's roots
+const clientIDHeader = "Mcp-Client-Id"
+
+// clientID returns the request's client ID ("default" if the client didn't send one). Writes an HTTP error
+// response and returns true if the client ID is invalid.
+func (p *mcpStages) clientID(r *svrcore.ReqRes) (string, bool) {
+	clientID := r.R.Header.Get(clientIDHeader)
+	if clientID == "" {
+		return "default", false
+	}
+	valid := len(clientID) <= 128
+	for _, c := range clientID {
+		valid = valid && (c == '-' || c == '_' || c == '.' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z'))
+	}
+	if !valid {
+		return "", r.WriteError(http.StatusBadRequest, nil, nil, "BadRequest", "%s must be 1-128 letters, digits, '-', '_' or '.'", clientIDHeader)
+	}
+	return clientID, false
+}
+
+// rootsContext returns a copy of ctx from which tools get the client's roots via [roots.FromContext].
+// Writes an HTTP error response and returns true if the client ID is invalid.
+func (p *mcpStages) rootsContext(ctx context.Context, r *svrcore.ReqRes) (context.Context, bool) {
+	tenant, stop := p.tenant(ctx, r)
+	if stop {
+		return ctx, stop
+	}
+	clientID, stop := p.clientID(r)
+	if stop {
+		return ctx, stop
+	}
+	return roots.NewContext(ctx, p.rootsStore, tenant, clientID), false
+}
+
+// toolNameToProcessPhaseFunc converts a toolname to a function that knows how to advance the tool call's phase/state.
+// Each phase gets a span continuing the trace of the request that started the phases (or else the one that created
+// the tool call) & linked to the tool call's creation. Since phases run outside any request, tools get the roots of
+// the client that created the tool call via [roots.FromContext].
 func (p *mcpStages) toolNameToProcessPhaseFunc(toolName string) toolcall.ProcessPhaseFunc {
 	ti, ok := p.toolInfos[toolName]
 	aids.Assert(ok, fmt.Errorf("tool '%s' not found", toolName))
-	return ti.ProcessPhase
+	return func(ctx context.Context, pp toolcall.PhaseProcessor, tc *toolcall.Resource) {
+		if !tracing.SpanContextFromContext(ctx).IsValid() {
+			ctx = tracing.ContextWithSpanContext(ctx, tc.TraceContext())
+		}
+		if tc.ClientID != nil {
+			ctx = roots.NewContext(ctx, p.rootsStore, *tc.Tenant, *tc.ClientID)
+		}
+		ctx, span := p.tracer.Start(ctx, "ProcessPhase "+toolName, tracing.SpanKindInternal, tc.TraceContext())
+		defer span.End()
+		span.SetAttributes(slog.String("mcp.tool.name", toolName), slog.String("mcp.tool_call.id", *tc.ID))
+		ti.ProcessPhase(ctx, pp, tc)
+		if tc.Status != nil {
+			span.SetAttributes(slog.String("mcp.tool_call.status", string(*tc.Status)))
+		}
+	}
 }
 
-// putToolCallResource creates a new tool call resource (idempotently if a retry occurs).
+// putToolCallResource creates a new tool call resource (idempotently if a retry occurs). If tool calls are stateless,
+// a retry can't return the original tool call (only its client has the serverData) so it fails with 409-Conflict.
 // Writes an HTTP error response and returns a *ServerError if the tool name or tool call ID is missing or invalid.
 func (p *mcpStages) putToolCallResource(ctx context.Context, r *svrcore.ReqRes) bool {
-	ti, tc, stop := p.lookupToolCall(r)
+	ctx, stop := p.rootsContext(ctx, r)
 	if stop {
 		return stop
 	}
+	ti, tc, stop := p.lookupToolCall(ctx, r)
+	if stop {
+		return stop
+	}
+	if stop := p.authorizeTool(ctx, r, ti); stop {
+		return stop
+	}
 	// PUT does not support any conditional headers; return error if client specifies them
 	if stop := r.CheckPreconditions(svrcore.ResourceValues{AllowedConditionals: svrcore.AllowedConditionalsNone}); stop {
 		return stop
@@ -97,120 +262,554 @@ func (p *mcpStages) putToolCallResource(ctx context.Context, r *svrcore.ReqRes)
 
 	if !toolCallIDFound { // If tool call ID doesn't already exist, create it
 		tc.IdempotencyKey = r.H.IdempotencyKey
+		clientID, _ := p.clientID(r) // Validated by rootsContext
+		tc.ClientID = aids.New(clientID)
+		tc.SetTraceContext(tracing.SpanContextFromContext(ctx)) // Later spans for the tool call link to this request's
+		if stop := p.validateToolCallRequest(ti, r); stop {
+			return stop
+		}
+		if stop := p.notificationURL(r, tc); stop {
+			return stop
+		}
 		return ti.Create(ctx, tc, r, p.pm) // Create method must use "if-none-match: *"
 	}
 
 	// Tool call already exists
 	if *tc.IdempotencyKey == *r.H.IdempotencyKey { // This is a retry, return existing resource & 200-OK via GET
+		if tc.NotificationSecret != nil { // The client may not have received the original PUT's response
+			r.RW.Header().Set(toolcall.NotificationSecretHeader, *tc.NotificationSecret)
+		}
 		return ti.Get(ctx, tc, r)
 	}
 	return r.WriteError(http.StatusConflict, nil, nil, "Conflict", "Tool call ID already exists with different IdempotencyKey")
 }
 
+// validateToolCallRequest validates the request body against the tool's InputSchema. Writes a 400 error whose
+// details target every violating JSON Pointer and returns true if the body is invalid; otherwise, the body is
+// left unread for the tool's Create method.
+func (p *mcpStages) validateToolCallRequest(ti ToolInfo, r *svrcore.ReqRes) bool {
+	body, err := io.ReadAll(r.R.Body)
+	r.R.Body.Close()
+	if aids.IsError(err) {
+		return r.WriteError(http.StatusBadRequest, nil, nil, "Unable to read full body", "%s", err.Error())
+	}
+	r.R.Body = io.NopCloser(bytes.NewReader(body))
+	if len(bytes.TrimSpace(body)) == 0 {
+		body = []byte("{}") // No body is the same as no arguments
+	}
+	tool := ti.Tool()
+	violations, err := jsonschema.Validate(tool.InputSchema, body)
+	if aids.IsError(err) {
+		return r.WriteError(http.StatusBadRequest, nil, nil, "Invalid JSON body", "%s", err.Error())
+	}
+	if len(violations) == 0 {
+		return false
+	}
+	se := svrcore.NewServerError(http.StatusBadRequest, "InvalidToolInput", "Request body doesn't match tool '%s' input schema", tool.Name)
+	for _, v := range violations {
+		se.Details = append(se.Details, &svrcore.ServerError{ErrorCode: "InvalidValue", Message: v.Message, Target: v.Pointer})
+	}
+	return r.WriteServerError(se, nil, nil)
+}
+
+// notificationURL sets tc's NotificationURL from the request's optional Notification-Url header & a new
+// NotificationSecret that signs its notifications; ToolInfo.Create returns the secret once the tool call is created.
+// Writes an HTTP error response and returns true if the URL is invalid or webhooks are disabled.
+func (p *mcpStages) notificationURL(r *svrcore.ReqRes, tc *toolcall.Resource) bool {
+	v := r.R.Header.Get("Notification-Url")
+	if v == "" {
+		return false
+	}
+	if p.notifier == nil {
+		return r.WriteError(http.StatusBadRequest, nil, nil, "BadRequest", "Notification-Url not supported; webhook notifications are disabled")
+	}
+	u, err := p.notifier.ValidateURL(v)
+	if aids.IsError(err) {
+		return r.WriteError(http.StatusBadRequest, nil, nil, "BadRequest", "Notification-Url %s", err.Error())
+	}
+	tc.NotificationURL, tc.NotificationSecret = aids.New(u.String()), aids.New(toolcall.NewNotificationSecret())
+	return false
+}
+
 // preambleToolCallResource retrieves the ToolInfo and ToolCall from the given request URL (and authentication for tenant),
-// then retrieves the ToolCall resource from storage and validates preconditions.
+// then retrieves the ToolCall resource from storage (or the request's serverData if stateless) and validates preconditions.
 // Writes an HTTP error response and returns a *ServerError if the tool name or tool call ID is missing or invalid,
 // the ToolCall resource is not found, or preconditions are not met.
 // This method is used is called by GET & POST (not PUT) because it assumes the resource must already exist.
 func (p *mcpStages) preambleToolCallResource(ctx context.Context, r *svrcore.ReqRes) (ToolInfo, *toolcall.Resource, bool) {
-	ti, tc, stop := p.lookupToolCall(r)
+	ti, tc, stop := p.lookupToolCall(ctx, r)
 	if stop {
 		return nil, nil, stop
 	}
-	se := p.store.Get(ctx, tc, svrcore.AccessConditions{IfMatch: r.H.IfMatch, IfNoneMatch: r.H.IfNoneMatch})
-	if se != nil {
+	// Preconditions are checked below (not by the store) so a matching if-none-match returns 304, not 404
+	if p.serverData != nil {
+		if tc, stop = p.toolCallFromServerData(r, tc); stop {
+			return nil, nil, stop
+		}
+	} else if se := p.store.Get(ctx, tc, svrcore.AccessConditions{}); se != nil {
+		if se.StatusCode != http.StatusNotFound {
+			return nil, nil, r.WriteServerError(se, nil, nil)
+		}
 		return nil, nil, r.WriteError(http.StatusNotFound, nil, nil, "NotFound", "Tool call not found")
 	}
 	if stop := r.CheckPreconditions(svrcore.ResourceValues{AllowedConditionals: svrcore.AllowedConditionalsMatch, ETag: tc.ETag}); stop {
 		return nil, nil, stop
 	}
+	tracing.SpanFromContext(ctx).AddLink(tc.TraceContext()) // Relate this request to the tool call's creation
 	return ti, tc, false
 }
 
+// maxToolCallWait is the longest a GET waits for a tool call to change; it must be less than the http.Server's WriteTimeout
+const maxToolCallWait = 25 * time.Second
+
 // getToolCallResource retrieves the ToolCall resource from the request.
+// If the request has an if-none-match ETag & a "Prefer: wait=N" header or "waitSeconds=N" query parameter,
+// the response is delayed until the tool call's ETag changes or N seconds (capped) elapse (304-Not Modified).
 func (p *mcpStages) getToolCallResource(ctx context.Context, r *svrcore.ReqRes) bool {
-	ti, tc, stop := p.preambleToolCallResource(ctx, r)
+	ctx, stop := p.rootsContext(ctx, r)
+	if stop {
+		return stop
+	}
+	wait, stop := p.toolCallWait(r)
+	if stop {
+		return stop
+	}
+	if wait == 0 || r.H.IfNoneMatch == nil || *r.H.IfNoneMatch == svrcore.ETagAny {
+		ti, tc, stop := p.preambleToolCallResource(ctx, r)
+		if stop {
+			return stop
+		}
+		return ti.Get(ctx, tc, r)
+	}
+
+	ti, tc, stop := p.lookupToolCall(ctx, r)
 	if stop {
 		return stop
 	}
-	return ti.Get(ctx, tc, r)
+	if r.H.IfMatch != nil || r.H.IfModifiedSince != nil || r.H.IfUnmodifiedSince != nil {
+		return r.WriteError(http.StatusBadRequest, nil, nil, "BadRequest", "Waiting for a tool call change supports only the if-none-match header")
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	se := p.store.Wait(waitCtx, tc, *r.H.IfNoneMatch)
+	switch {
+	case se == nil:
+		tracing.SpanFromContext(ctx).AddLink(tc.TraceContext())
+		return ti.Get(ctx, tc, r)
+	case se.StatusCode == http.StatusNotModified:
+		return r.WriteSuccess(http.StatusNotModified, &svrcore.ResponseHeader{ETag: r.H.IfNoneMatch}, nil, nil)
+	case se.StatusCode == http.StatusNotFound:
+		return r.WriteError(http.StatusNotFound, nil, nil, "NotFound", "Tool call not found")
+	default:
+		return r.WriteServerError(se, nil, nil)
+	}
+}
+
+// toolCallWait returns how long a GET should wait for a tool call to change from the request's "Prefer: wait=N"
+// header or "waitSeconds=N" query parameter; 0 means don't wait. Writes an HTTP error response if N is invalid.
+func (p *mcpStages) toolCallWait(r *svrcore.ReqRes) (time.Duration, bool) {
+	var query struct {
+		Unknown     svrcore.Unknown `json:"-"`
+		WaitSeconds *int            `json:"waitSeconds" minval:"0"`
+	}
+	if stop := r.UnmarshalQuery(&query); stop {
+		return 0, stop
+	}
+	seconds := 0
+	if query.WaitSeconds != nil {
+		seconds = *query.WaitSeconds
+	} else if v, ok := r.H.Preference("wait"); ok {
+		n, err := strconv.Atoi(v)
+		if aids.IsError(err) || n < 0 {
+			return 0, r.WriteError(http.StatusBadRequest, nil, nil, "BadRequest", "Prefer wait must be a non-negative integer number of seconds")
+		}
+		seconds = n
+	}
+	return min(time.Duration(seconds)*time.Second, maxToolCallWait), false
+}
+
+// toolCallEventsKeepAlive is how often an idle tool call event stream sends a comment to keep the connection open
+const toolCallEventsKeepAlive = 15 * time.Second
+
+// getToolCallEvents streams the ToolCall resource as Server-Sent Events (text/event-stream). An event (with id set to
+// the tool call's ETag) is sent each time the tool call's status, progress, or result changes; the stream ends when
+// the tool call terminates. A reconnecting client's Last-Event-ID header resumes the stream after that ETag.
+func (p *mcpStages) getToolCallEvents(ctx context.Context, r *svrcore.ReqRes) bool {
+	_, tc, stop := p.lookupToolCall(ctx, r)
+	if stop {
+		return stop
+	}
+	if se := p.store.Get(ctx, tc, svrcore.AccessConditions{}); se != nil {
+		if se.StatusCode != http.StatusNotFound {
+			return r.WriteServerError(se, nil, nil)
+		}
+		return r.WriteError(http.StatusNotFound, nil, nil, "NotFound", "Tool call not found")
+	}
+
+	rc := http.NewResponseController(r.RW)
+	_ = rc.SetWriteDeadline(time.Time{}) // The stream outlives the http.Server's WriteTimeout
+	r.RW.Header().Set("Content-Type", "text/event-stream")
+	r.RW.Header().Set("Cache-Control", "no-cache")
+	r.RW.WriteHeader(http.StatusOK)
+
+	sent := (*toolcall.Resource)(nil) // The last tool call sent as an event
+	if r.H.LastEventID != nil && tc.ETag != nil && tc.ETag.Equals(svrcore.ETag(*r.H.LastEventID)) {
+		sent = aids.New(tc.Copy()) // The client already has this version
+	}
+	for {
+		if sent == nil || *sent.Status != *tc.Status || !bytes.Equal(aids.MustMarshal(sent.Progress), aids.MustMarshal(tc.Progress)) || !bytes.Equal(sent.Result, tc.Result) {
+			if _, err := fmt.Fprintf(r.RW, "id: %s\ndata: %s\n\n", *tc.ETag, aids.MustMarshal(tc.ToMCP())); aids.IsError(err) {
+				return false // Client went away
+			}
+			sent = aids.New(tc.Copy())
+		}
+		if tc.Status.Terminated() {
+			return false
+		}
+		if err := rc.Flush(); aids.IsError(err) {
+			return false
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, toolCallEventsKeepAlive)
+		se := p.store.Wait(waitCtx, tc, *tc.ETag)
+		cancel()
+		switch {
+		case se == nil: // tc changed; loop around to send it
+		case ctx.Err() != nil: // Client went away or server is shutting down
+			return false
+		case se.StatusCode == http.StatusNotModified:
+			if _, err := fmt.Fprint(r.RW, ": keep-alive\n\n"); aids.IsError(err) {
+				return false
+			}
+		default: // Tool call deleted/expired or a store error; nothing more to send
+			return false
+		}
+	}
 }
 
 // postToolCallAdvance advances the state of a tool call using r's body (CreateMessageResult or ElicitResult)
 func (p *mcpStages) postToolCallResourceAdvance(ctx context.Context, r *svrcore.ReqRes) bool {
+	ctx, stop := p.rootsContext(ctx, r)
+	if stop {
+		return stop
+	}
 	ti, tc, stop := p.preambleToolCallResource(ctx, r)
 	if stop {
 		return stop
 	}
-	return ti.Advance(ctx, tc, r)
+	if stop := p.authorizeTool(ctx, r, ti); stop {
+		return stop
+	}
+	return ti.Advance(ctx, tc, r, p.pm)
 }
 
 // postToolCallCancelResource cancels a tool call.
 func (p *mcpStages) postToolCallCancelResource(ctx context.Context, r *svrcore.ReqRes) bool {
+	ctx, stop := p.rootsContext(ctx, r)
+	if stop {
+		return stop
+	}
 	ti, tc, stop := p.preambleToolCallResource(ctx, r)
 	if stop {
 		return stop
 	}
-	return ti.Cancel(ctx, tc, r)
+	if stop := p.authorizeTool(ctx, r, ti); stop {
+		return stop
+	}
+	return ti.Cancel(ctx, tc, r, p.pm)
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////
 
-// getToolList retrieves the list of tools.
+// getToolList retrieves the list of tools the caller is authorized to use.
 func (p *mcpStages) getToolList(ctx context.Context, r *svrcore.ReqRes) bool {
-	if stop := r.CheckPreconditions(svrcore.ResourceValues{AllowedConditionals: svrcore.AllowedConditionalsMatch, ETag: p.etag()}); stop {
-		return true
-	}
 	result := mcp.ListToolsResult{Tools: make([]mcp.Tool, 0, len(p.toolInfos))}
 	for _, ti := range p.toolInfos {
-		result.Tools = append(result.Tools, *ti.Tool())
+		if len(missingScopes(ctx, ti)) == 0 {
+			result.Tools = append(result.Tools, *ti.Tool())
+		}
 	}
-	return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{ETag: p.etag()}, nil, result)
+	slices.SortFunc(result.Tools, func(a, b mcp.Tool) int { return strings.Compare(a.Name, b.Name) })
+
+	// Callers with different scopes get different lists so a partial list's ETag identifies the tools in it
+	etag := p.etag()
+	if len(result.Tools) < len(p.toolInfos) {
+		h := fnv.New64a()
+		for _, t := range result.Tools {
+			h.Write([]byte(t.Name + "\n"))
+		}
+		etag = aids.New(svrcore.ETag(fmt.Sprintf("%s-%x", *etag, h.Sum64())))
+	}
+	if stop := r.CheckPreconditions(svrcore.ResourceValues{AllowedConditionals: svrcore.AllowedConditionalsMatch, ETag: etag}); stop {
+		return true
+	}
+	return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{ETag: etag}, nil, result)
 }
 
-// listToolCalls retrieves the list of tool calls.
+// listToolCalls retrieves the list of the tenant's durable tool calls for the tool name in the request URL.
+// Supported query parameters: status, createdAfter (RFC 3339 or YYYY-MM-DD), maxResults & continuationToken.
 func (p *mcpStages) listToolCalls(ctx context.Context, r *svrcore.ReqRes) bool {
-	body := any(nil)
-	return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{ETag: p.etag()}, nil, body)
+	toolName := r.R.PathValue("toolName")
+	if _, ok := p.toolInfos[toolName]; !ok {
+		return r.WriteError(http.StatusBadRequest, nil, nil, "BadRequest", "Tool '%s' not found", toolName)
+	}
+	var query struct {
+		Unknown           svrcore.Unknown `json:"-"`
+		Status            *string         `json:"status" enums:"submitted,running,awaitingSamplingResult,awaitingElicitationResult,success,failed,canceled"`
+		CreatedAfter      *string         `json:"createdAfter"`
+		MaxResults        *int            `json:"maxResults" minval:"1" maxval:"1000"`
+		ContinuationToken *string         `json:"continuationToken"`
+	}
+	if stop := r.UnmarshalQuery(&query); stop {
+		return stop
+	}
+	o := toolcall.ListOptions{Status: (*mcp.Status)(query.Status), ContinuationToken: query.ContinuationToken}
+	if query.MaxResults != nil {
+		o.MaxResults = *query.MaxResults
+	}
+	if query.CreatedAfter != nil {
+		createdAfter, err := time.Parse(time.RFC3339, *query.CreatedAfter)
+		if aids.IsError(err) {
+			if createdAfter, err = time.Parse(time.DateOnly, *query.CreatedAfter); aids.IsError(err) {
+				return r.WriteError(http.StatusBadRequest, nil, nil, "BadRequest", "createdAfter must be an RFC 3339 date-time or YYYY-MM-DD date")
+			}
+		}
+		o.CreatedAfter = &createdAfter
+	}
+
+	tenant, stop := p.tenant(ctx, r)
+	if stop {
+		return stop
+	}
+	page, se := p.store.List(ctx, tenant, toolName, o)
+	if se != nil {
+		return r.WriteServerError(se, nil, nil)
+	}
+	result := mcp.ListToolCallsResult{ToolCalls: make([]mcp.ToolCallSummary, 0, len(page.ToolCalls)), ContinuationToken: page.ContinuationToken}
+	for _, tc := range page.ToolCalls {
+		result.ToolCalls = append(result.ToolCalls, tc.ToSummary())
+	}
+	return r.WriteSuccess(http.StatusOK, nil, nil, result)
 }
 
 // getResources retrieves the list of resources.
 func (p *mcpStages) getResources(ctx context.Context, r *svrcore.ReqRes) bool {
-	return r.WriteSuccess(http.StatusNoContent, &svrcore.ResponseHeader{}, nil, nil)
+	result := mcp.ListResources{Resources: []mcp.Resource{}}
+	for _, rp := range p.resourceProviders {
+		result.Resources = append(result.Resources, rp.Resources()...)
+	}
+	return p.writeResourceList(r, result)
 }
 
 // getResourcesTemplates retrieves the list of resource templates.
 func (p *mcpStages) getResourcesTemplates(ctx context.Context, r *svrcore.ReqRes) bool {
-	return r.WriteSuccess(http.StatusNoContent, &svrcore.ResponseHeader{}, nil, nil)
+	result := mcp.ListResourceTemplates{ResourceTemplates: []mcp.ResourceTemplate{}}
+	for _, rp := range p.resourceProviders {
+		result.ResourceTemplates = append(result.ResourceTemplates, rp.ResourceTemplates()...)
+	}
+	return p.writeResourceList(r, result)
 }
 
-// getResource retrieves a specific resource by name.
+// writeResourceList writes list with an ETag computed from its content; 304-Not Modified if it matches If-None-Match.
+func (p *mcpStages) writeResourceList(r *svrcore.ReqRes, list any) bool {
+	etag := contentETag(aids.MustMarshal(list))
+	if stop := r.CheckPreconditions(svrcore.ResourceValues{AllowedConditionals: svrcore.AllowedConditionalsMatch, ETag: etag}); stop {
+		return true
+	}
+	return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{ETag: etag}, nil, list)
+}
+
+// resourceHeader is the custom response header returned when reading a resource
+type resourceHeader struct {
+	URI *string `json:"mcp-resource-uri"` // The resource's URI (expanded from the template for a resource template)
+}
+
+// getResource reads a specific resource (or resource template) by name writing its raw content as the response
+// body. For a resource template, the query parameters are the template's variables.
 func (p *mcpStages) getResource(ctx context.Context, r *svrcore.ReqRes) bool {
 	resourceName := r.R.PathValue("name")
-	if resourceName == "" {
-		return r.WriteError(http.StatusBadRequest, nil, nil, "BadRequest", "Resource name is required")
+	re, ok := p.resources[resourceName]
+	if !ok {
+		return r.WriteError(http.StatusNotFound, nil, nil, "NotFound", "Resource '%s' not found", resourceName)
+	}
+
+	query, vars := r.R.URL.Query(), map[string]string{}
+	names := []string{}
+	if re.template != nil {
+		names = aids.Must(uritemplate.Variables(re.template.URITemplate))
+	}
+	for name, values := range query {
+		if !slices.Contains(names, name) {
+			return r.WriteError(http.StatusBadRequest, nil, nil, "BadRequest", "Unrecognized query parameter '%s' for resource '%s'", name, resourceName)
+		}
+		if len(values) > 1 {
+			return r.WriteError(http.StatusBadRequest, nil, nil, "BadRequest", "Query parameter '%s' must be specified once", name)
+		}
+		vars[name] = values[0]
 	}
-	return r.WriteSuccess(http.StatusNoContent, &svrcore.ResponseHeader{}, nil, nil)
+	uri := ""
+	if re.resource != nil {
+		uri = re.resource.URI
+	} else {
+		uri = aids.Must(uritemplate.Expand(re.template.URITemplate, vars))
+	}
+
+	rc, se := re.rp.Read(ctx, uri, vars)
+	if se != nil {
+		return r.WriteServerError(se, nil, nil)
+	}
+	rv := svrcore.ResourceValues{AllowedConditionals: svrcore.AllowedConditionalsMatch | svrcore.AllowedConditionalsModified, ETag: rc.ETag, LastModified: rc.LastModified}
+	if stop := r.CheckPreconditions(rv); stop {
+		return true
+	}
+	return r.WriteContent(&svrcore.ResponseHeader{ETag: rc.ETag, LastModified: rc.LastModified, ContentType: aids.New(rc.MimeType)},
+		&resourceHeader{URI: aids.New(uri)}, rc.Content)
 }
 
 // getPrompts retrieves the list of prompts.
 func (p *mcpStages) getPrompts(ctx context.Context, r *svrcore.ReqRes) bool {
-	return r.WriteSuccess(http.StatusNoContent, &svrcore.ResponseHeader{}, nil, nil)
+	if stop := r.CheckPreconditions(svrcore.ResourceValues{AllowedConditionals: svrcore.AllowedConditionalsMatch, ETag: p.etag()}); stop {
+		return true
+	}
+	result := mcp.PromptList{Prompts: make([]mcp.Prompt, 0, len(p.promptInfos))}
+	for _, pi := range p.promptInfos {
+		result.Prompts = append(result.Prompts, *pi.Prompt())
+	}
+	slices.SortFunc(result.Prompts, func(a, b mcp.Prompt) int { return strings.Compare(a.Name, b.Name) })
+	return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{ETag: p.etag()}, nil, result)
 }
 
-// getPrompt retrieves a specific prompt by name.
+// getPrompt renders a specific prompt by name with the arguments in the request body.
 func (p *mcpStages) getPrompt(ctx context.Context, r *svrcore.ReqRes) bool {
-	return r.WriteSuccess(http.StatusNoContent, &svrcore.ResponseHeader{}, nil, nil)
+	promptName := r.R.PathValue("name")
+	pi, ok := p.promptInfos[promptName]
+	if !ok {
+		return r.WriteError(http.StatusNotFound, nil, nil, "NotFound", "Prompt '%s' not found", promptName)
+	}
+	var request mcp.PromptRequest
+	if stop := r.UnmarshalBody(&request); stop {
+		return stop
+	}
+	if request.Name != "" && request.Name != promptName {
+		return r.WriteError(http.StatusBadRequest, nil, nil, "BadRequest", "Body's prompt name '%s' doesn't match URL's '%s'", request.Name, promptName)
+	}
+	args := map[string]string{}
+	if request.Arguments != nil {
+		args = *request.Arguments
+	}
+	if se := validatePromptArguments(pi.Prompt(), args); se != nil {
+		return r.WriteServerError(se, nil, nil)
+	}
+	result, se := pi.Render(ctx, args)
+	if se != nil {
+		return r.WriteServerError(se, nil, nil)
+	}
+	return r.WriteSuccess(http.StatusOK, nil, nil, result)
 }
 
-// putRoots updates the list of root resources.
+// putRoots replaces the client's list of roots; If-Match/If-None-Match:* make the replacement conditional.
 func (p *mcpStages) putRoots(ctx context.Context, r *svrcore.ReqRes) bool {
-	return r.WriteSuccess(http.StatusNoContent, &svrcore.ResponseHeader{}, nil, nil)
+	tenant, stop := p.tenant(ctx, r)
+	if stop {
+		return stop
+	}
+	clientID, stop := p.clientID(r)
+	if stop {
+		return stop
+	}
+	var rl mcp.RootsList
+	if stop := r.UnmarshalBody(&rl); stop {
+		return stop
+	}
+	if err := roots.Validate(rl); aids.IsError(err) {
+		return r.WriteError(http.StatusBadRequest, nil, nil, "BadRequest", "%s", err.Error())
+	}
+	rr := roots.New(tenant, clientID)
+	rr.Roots = rl.Roots
+	if rr.Roots == nil {
+		rr.Roots = []mcp.Root{}
+	}
+	if se := p.rootsStore.Put(ctx, rr, svrcore.AccessConditions{IfMatch: r.H.IfMatch, IfNoneMatch: r.H.IfNoneMatch}); se != nil {
+		return r.WriteServerError(se, &svrcore.ResponseHeader{ETag: rr.ETag}, nil)
+	}
+	return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{ETag: rr.ETag}, nil, rr.ToMCP())
 }
 
-// postCompletion returns a text completion.
+// getRoots retrieves the client's list of roots (as put by any of the client's devices).
+func (p *mcpStages) getRoots(ctx context.Context, r *svrcore.ReqRes) bool {
+	tenant, stop := p.tenant(ctx, r)
+	if stop {
+		return stop
+	}
+	clientID, stop := p.clientID(r)
+	if stop {
+		return stop
+	}
+	rr := roots.New(tenant, clientID)
+	if se := p.rootsStore.Get(ctx, rr, svrcore.AccessConditions{}); se != nil {
+		return r.WriteServerError(se, nil, nil)
+	}
+	if stop := r.CheckPreconditions(svrcore.ResourceValues{AllowedConditionals: svrcore.AllowedConditionalsMatch, ETag: rr.ETag}); stop {
+		return true
+	}
+	return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{ETag: rr.ETag}, nil, rr.ToMCP())
+}
+
+// postCompletion returns suggested values for a prompt's argument or a resource template's variable. Prompts
+// and resource providers not implementing Completer suggest no values.
 func (p *mcpStages) postCompletion(ctx context.Context, r *svrcore.ReqRes) bool {
-	return r.WriteSuccess(http.StatusNoContent, &svrcore.ResponseHeader{}, nil, nil)
+	var request mcp.CompleteRequest
+	if stop := r.UnmarshalBody(&request); stop {
+		return stop
+	}
+	var completer any
+	names := []string{}
+	switch ref := request.Ref; ref.Type {
+	case mcp.RefTypePrompt:
+		if ref.Name == nil {
+			return r.WriteError(http.StatusBadRequest, nil, nil, "BadRequest", "A '%s' ref requires a name", ref.Type)
+		}
+		pi, ok := p.promptInfos[*ref.Name]
+		if !ok {
+			return r.WriteError(http.StatusNotFound, nil, nil, "NotFound", "Prompt '%s' not found", *ref.Name)
+		}
+		for _, a := range pi.Prompt().Arguments {
+			names = append(names, a.Name)
+		}
+		completer = pi
+
+	case mcp.RefTypeResource:
+		if ref.URI == nil {
+			return r.WriteError(http.StatusBadRequest, nil, nil, "BadRequest", "A '%s' ref requires a uri", ref.Type)
+		}
+		for _, re := range p.resources {
+			if re.template != nil && re.template.URITemplate == *ref.URI {
+				names, completer = aids.Must(uritemplate.Variables(re.template.URITemplate)), re.rp
+			}
+		}
+		if completer == nil {
+			return r.WriteError(http.StatusNotFound, nil, nil, "NotFound", "Resource template '%s' not found", *ref.URI)
+		}
+
+	default:
+		return r.WriteError(http.StatusBadRequest, nil, nil, "BadRequest", "ref type must be '%s' or '%s'", mcp.RefTypePrompt, mcp.RefTypeResource)
+	}
+	if !slices.Contains(names, request.Argument.Name) {
+		return r.WriteError(http.StatusBadRequest, nil, nil, "BadRequest", "No argument named '%s'", request.Argument.Name)
+	}
+
+	values := []string{}
+	if c, ok := completer.(Completer); ok {
+		args := map[string]string{}
+		if request.Context != nil && request.Context.Arguments != nil {
+			args = request.Context.Arguments
+		}
+		var se *svrcore.ServerError
+		if values, se = c.Complete(ctx, request.Ref, request.Argument, args); se != nil {
+			return r.WriteServerError(se, nil, nil)
+		}
+	}
+	return r.WriteSuccess(http.StatusOK, nil, nil, mcp.CompleteResult{Completion: newCompletion(values)})
 }
 
 /*
@@ -233,5 +832,5 @@ a.	Delete resource’s ToolCallNotification queue (idempotent)
 b.	Delete the resource
 
 NOTES:
--	Whenever an event is queued to the ToolCallNotification queue, a webhook could also be called.
+-	Whenever a Put changes a tool call's status, toolcall.NewNotifyingStore POSTs it to the client's Notification-Url webhook.
 */
@@ -1,12 +1,22 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/mcpsvr/roots"
+	"github.com/JeffreyRichter/mcpsvr/toolcall"
+	"github.com/JeffreyRichter/svrcore"
 )
 
 func TestListTools(t *testing.T) {
@@ -26,8 +36,8 @@ func TestListTools(t *testing.T) {
 	if aids.IsError(err) {
 		t.Fatal(err)
 	}
-	if actual := len(actual.Tools); actual != 4 {
-		t.Fatalf("expected 4 tools, got %d", actual)
+	if actual := len(actual.Tools); actual != 5 {
+		t.Fatalf("expected 5 tools, got %d", actual)
 	}
 
 	etag, has := resp.Header[http.CanonicalHeaderKey("etag")]
@@ -58,3 +68,472 @@ func TestListToolsETag(t *testing.T) {
 		}
 	})
 }
+
+func TestListPrompts(t *testing.T) {
+	client := newTestClient(t)
+	resp := client.Get("/mcp/prompts", http.Header{})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if aids.IsError(err) {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	result := aids.MustUnmarshal[mcp.PromptList](b)
+	names := []string{}
+	for _, p := range result.Prompts {
+		names = append(names, p.Name)
+	}
+	if want := []string{"code_review", "describe_server"}; !slices.Equal(names, want) {
+		t.Fatalf("wanted prompts %v, got %v", want, names)
+	}
+
+	resp = client.Get("/mcp/prompts", http.Header{"If-None-Match": []string{resp.Header.Get("ETag")}})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected 304 Not Modified, got %d", resp.StatusCode)
+	}
+}
+
+func TestPutGetRoots(t *testing.T) {
+	client, clientID := newTestClient(t), http.Header{"Mcp-Client-Id": []string{t.Name()}}
+	resp := client.Get("/mcp/roots", clientID)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 Not Found before roots are put, got %d", resp.StatusCode)
+	}
+
+	resp = client.Put("/mcp/roots", clientID, strings.NewReader(`{"roots":[{"uri":"file:///home/user/project","name":"project"}]}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+	}
+	etag := resp.Header.Get("ETag")
+
+	// Another device using the same client ID sees the roots
+	resp = client.Get("/mcp/roots", clientID)
+	b, err := io.ReadAll(resp.Body)
+	if aids.IsError(err) {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != etag {
+		t.Fatalf("expected 200 OK with ETag %q, got %d with %q", etag, resp.StatusCode, resp.Header.Get("ETag"))
+	}
+	if rl := aids.MustUnmarshal[mcp.RootsList](b); len(rl.Roots) != 1 || rl.Roots[0].URI != "file:///home/user/project" {
+		t.Fatalf("unexpected roots %s", b)
+	}
+
+	resp = client.Get("/mcp/roots", http.Header{"Mcp-Client-Id": []string{clientID.Get("Mcp-Client-Id")}, "If-None-Match": []string{etag}})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected 304 Not Modified, got %d", resp.StatusCode)
+	}
+
+	resp = client.Get("/mcp/roots", http.Header{"Mcp-Client-Id": []string{t.Name() + "-other"}})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected another client's roots to be 404 Not Found, got %d", resp.StatusCode)
+	}
+
+	t.Run("stale if-match", func(t *testing.T) {
+		resp := client.Put("/mcp/roots", http.Header{"Mcp-Client-Id": clientID["Mcp-Client-Id"], "If-Match": []string{"stale"}}, strings.NewReader(`{"roots":[]}`))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusPreconditionFailed {
+			t.Fatalf("expected 412 Precondition Failed, got %d", resp.StatusCode)
+		}
+	})
+	t.Run("invalid uri", func(t *testing.T) {
+		resp := client.Put("/mcp/roots", clientID, strings.NewReader(`{"roots":[{"uri":"https://example.com"}]}`))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 Bad Request, got %d", resp.StatusCode)
+		}
+	})
+	t.Run("invalid client id", func(t *testing.T) {
+		resp := client.Get("/mcp/roots", http.Header{"Mcp-Client-Id": []string{"not valid!"}})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 Bad Request, got %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestRootsInBackgroundPhase(t *testing.T) {
+	type rootsResult struct {
+		Roots []mcp.Root `json:"roots"`
+	}
+	ops := newLocalMcpStages(context.Background(), slog.Default(), nil)
+	ops.toolInfos["roots"] = toolcall.Define(toolcall.Definition[struct{}, rootsResult]{
+		Tool:  mcp.Tool{BaseMetadata: mcp.BaseMetadata{Name: "roots"}},
+		Store: func() toolcall.Store { return ops.store },
+		Phase: func(ctx context.Context, in toolcall.PhaseInput[struct{}, rootsResult]) (toolcall.Step[rootsResult], *svrcore.ServerError) {
+			if in.Phase == 0 { // Phase 1 runs in the background (outside any request) via the PhaseMgr
+				return toolcall.Step[rootsResult]{Status: mcp.StatusRunning}, nil
+			}
+			rs, se := roots.FromContext(ctx)
+			if se != nil {
+				return toolcall.Step[rootsResult]{}, se
+			}
+			return toolcall.Step[rootsResult]{Status: mcp.StatusSuccess, Result: &rootsResult{Roots: rs}}, nil
+		},
+	})
+	client, clientID := newTestClientFor(t, ops), http.Header{"Mcp-Client-Id": []string{t.Name()}}
+	resp := client.Put("/mcp/roots", clientID, strings.NewReader(`{"roots":[{"uri":"file:///home/user/project","name":"project"}]}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+	}
+
+	urlPath := "/mcp/tools/roots/calls/" + t.Name()
+	resp = client.Put(urlPath, withHeader(http.Header{"Idempotency-Key": []string{"1"}}, clientID), strings.NewReader(`{}`))
+	tc, _ := readToolCall(t, resp, http.StatusOK)
+	for deadline := time.Now().Add(5 * time.Second); *tc.Status == mcp.StatusRunning && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		tc, _ = readToolCall(t, client.Get(urlPath, http.Header{}), http.StatusOK) // Another device needn't send the client ID
+	}
+	if *tc.Status != mcp.StatusSuccess {
+		t.Fatalf("expected the tool call to succeed, got %s", aids.MustMarshal(tc))
+	}
+	if rs := aids.MustUnmarshal[rootsResult](tc.Result).Roots; len(rs) != 1 || rs[0].URI != "file:///home/user/project" {
+		t.Fatalf("expected the background phase to get the client's roots, got %s", tc.Result)
+	}
+}
+func TestListResources(t *testing.T) {
+	client := newTestClient(t)
+	resp := client.Get("/mcp/resources", http.Header{})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if aids.IsError(err) {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	result := aids.MustUnmarshal[mcp.ListResources](b)
+	names := []string{}
+	for _, r := range result.Resources {
+		names = append(names, r.Name)
+	}
+	if want := []string{"readme", "logo"}; !slices.Equal(names, want) {
+		t.Fatalf("wanted resources %v, got %v", want, names)
+	}
+
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("no etag")
+	}
+	resp = client.Get("/mcp/resources", http.Header{"If-None-Match": []string{etag}})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected 304 Not Modified, got %d", resp.StatusCode)
+	}
+}
+
+func TestListResourceTemplates(t *testing.T) {
+	client := newTestClient(t)
+	resp := client.Get("/mcp/resources-templates", http.Header{})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if aids.IsError(err) {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	result := aids.MustUnmarshal[mcp.ListResourceTemplates](b)
+	if actual := len(result.ResourceTemplates); actual != 1 {
+		t.Fatalf("expected 1 resource template, got %d", actual)
+	}
+	if actual := result.ResourceTemplates[0].URITemplate; actual != gradientURITemplate {
+		t.Fatalf("unexpected uriTemplate %q", actual)
+	}
+
+	resp = client.Get("/mcp/resources-templates", http.Header{"If-None-Match": []string{resp.Header.Get("ETag")}})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected 304 Not Modified, got %d", resp.StatusCode)
+	}
+}
+
+func TestGetResourceNotFound(t *testing.T) {
+	client := newTestClient(t)
+	resp := client.Get("/mcp/resources/nosuchresource", http.Header{})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 Not Found, got %d", resp.StatusCode)
+	}
+}
+
+func TestPostCompletion(t *testing.T) {
+	client := newTestClient(t)
+	for name, tc := range map[string]struct {
+		body string
+		want []string
+	}{
+		"prompt argument":     {`{"ref":{"type":"ref/prompt","name":"code_review"},"argument":{"name":"language","value":"ja"}}`, []string{"Java", "JavaScript"}},
+		"uncompletable":       {`{"ref":{"type":"ref/prompt","name":"code_review"},"argument":{"name":"code","value":"x"}}`, []string{}},
+		"template variable":   {`{"ref":{"type":"ref/resource","uri":"image://gradient/{width}x{height}.png"},"argument":{"name":"width","value":"1"}}`, []string{"16", "128", "1024"}},
+		"template w/ context": {`{"ref":{"type":"ref/resource","uri":"image://gradient/{width}x{height}.png"},"argument":{"name":"height","value":""},"context":{"arguments":{"width":"100"}}}`, []string{"100", "16", "32", "64", "128", "256", "512", "1024"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := client.Post("/mcp/complete", http.Header{}, strings.NewReader(tc.body))
+			b, err := io.ReadAll(resp.Body)
+			if aids.IsError(err) {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected 200 OK, got %d: %s", resp.StatusCode, b)
+			}
+			result := aids.MustUnmarshal[mcp.CompleteResult](b)
+			if !slices.Equal(result.Completion.Values, tc.want) {
+				t.Fatalf("wanted values %v, got %v", tc.want, result.Completion.Values)
+			}
+			if result.Completion.HasMore == nil || *result.Completion.HasMore {
+				t.Fatalf("expected hasMore=false, got %v", result.Completion.HasMore)
+			}
+		})
+	}
+}
+
+func TestPostCompletionInvalid(t *testing.T) {
+	client := newTestClient(t)
+	for name, tc := range map[string]struct {
+		body   string
+		status int
+	}{
+		"unknown ref type":  {`{"ref":{"type":"ref/tool","name":"add"},"argument":{"name":"x","value":""}}`, http.StatusBadRequest},
+		"missing name":      {`{"ref":{"type":"ref/prompt"},"argument":{"name":"code","value":""}}`, http.StatusBadRequest},
+		"unknown argument":  {`{"ref":{"type":"ref/prompt","name":"code_review"},"argument":{"name":"style","value":""}}`, http.StatusBadRequest},
+		"unknown variable":  {`{"ref":{"type":"ref/resource","uri":"image://gradient/{width}x{height}.png"},"argument":{"name":"depth","value":""}}`, http.StatusBadRequest},
+		"unknown prompt":    {`{"ref":{"type":"ref/prompt","name":"nosuchprompt"},"argument":{"name":"code","value":""}}`, http.StatusNotFound},
+		"unknown template":  {`{"ref":{"type":"ref/resource","uri":"docs://{name}"},"argument":{"name":"name","value":""}}`, http.StatusNotFound},
+		"concrete resource": {`{"ref":{"type":"ref/resource","uri":"docs://readme.md"},"argument":{"name":"name","value":""}}`, http.StatusNotFound},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := client.Post("/mcp/complete", http.Header{}, strings.NewReader(tc.body))
+			resp.Body.Close()
+			if resp.StatusCode != tc.status {
+				t.Fatalf("expected %d, got %d", tc.status, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestListToolCalls(t *testing.T) {
+	client, name := newTestClient(t), t.Name()
+	for _, id := range []string{name + "-1", name + "-2"} {
+		resp := client.Put("/mcp/tools/welcome/calls/"+id,
+			http.Header{"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)}}, strings.NewReader(`{}`))
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Create failed with status %d", resp.StatusCode)
+		}
+		resp.Body.Close()
+	}
+	list := func(query string) mcp.ListToolCallsResult {
+		resp := client.Get("/mcp/tools/welcome/calls"+query, http.Header{})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+		}
+		b, err := io.ReadAll(resp.Body)
+		if aids.IsError(err) {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		var result mcp.ListToolCallsResult
+		if err := json.Unmarshal(b, &result); aids.IsError(err) {
+			t.Fatal(err)
+		}
+		return result
+	}
+	contains := func(result mcp.ListToolCallsResult, id string) bool {
+		return slices.ContainsFunc(result.ToolCalls, func(s mcp.ToolCallSummary) bool { return *s.ID == id })
+	}
+
+	t.Run("status", func(t *testing.T) {
+		result := list("?status=awaitingElicitationResult")
+		if !contains(result, name+"-1") || !contains(result, name+"-2") {
+			t.Fatalf("expected tool call to be listed, got %v", result.ToolCalls)
+		}
+		for _, tc := range result.ToolCalls {
+			if *tc.ToolName != "welcome" || *tc.Status != mcp.StatusAwaitingElicitationResult {
+				t.Fatalf("unexpected tool call summary: %v", tc)
+			}
+		}
+		if result := list("?status=failed"); len(result.ToolCalls) != 0 {
+			t.Fatalf("expected no failed tool calls, got %d", len(result.ToolCalls))
+		}
+	})
+	t.Run("createdAfter", func(t *testing.T) {
+		if result := list("?createdAfter=" + time.Now().Add(time.Hour).Format(time.RFC3339)); len(result.ToolCalls) != 0 {
+			t.Fatalf("expected no tool calls, got %d", len(result.ToolCalls))
+		}
+		if result := list("?createdAfter=2025-09-20"); len(result.ToolCalls) < 2 {
+			t.Fatalf("expected at least 2 tool calls, got %d", len(result.ToolCalls))
+		}
+	})
+	t.Run("paging", func(t *testing.T) {
+		result := list("?maxResults=1")
+		if len(result.ToolCalls) != 1 || result.ContinuationToken == nil {
+			t.Fatalf("expected 1 tool call & a continuation token, got %d", len(result.ToolCalls))
+		}
+		next := list("?maxResults=1&continuationToken=" + url.QueryEscape(*result.ContinuationToken))
+		if len(next.ToolCalls) != 1 || *next.ToolCalls[0].ID == *result.ToolCalls[0].ID {
+			t.Fatalf("expected the next tool call, got %v", next.ToolCalls)
+		}
+	})
+	t.Run("bad query", func(t *testing.T) {
+		for _, query := range []string{"?status=bogus", "?createdAfter=yesterday", "?unknown=1"} {
+			resp := client.Get("/mcp/tools/welcome/calls"+query, http.Header{})
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("%s: expected 400 Bad Request, got %d", query, resp.StatusCode)
+			}
+		}
+	})
+}
+
+func TestGetToolCallWait(t *testing.T) {
+	client, name := newTestClient(t), t.Name()
+	put := func(toolName, body string) (string, string) {
+		urlPath := "/mcp/tools/" + toolName + "/calls/" + name
+		resp := client.Put(urlPath, http.Header{"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)}}, strings.NewReader(body))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Create failed with status %d", resp.StatusCode)
+		}
+		return urlPath, resp.Header.Get("ETag")
+	}
+
+	t.Run("changed", func(t *testing.T) {
+		urlPath, etag := put("count", `{"increments":2}`)
+		start := time.Now()
+		resp := client.Get(urlPath, http.Header{"If-None-Match": []string{etag}, "Prefer": []string{"wait=10"}})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+		}
+		if resp.Header.Get("ETag") == etag {
+			t.Fatal("expected a changed ETag")
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Fatalf("expected the GET to return when the tool call changed, took %v", elapsed)
+		}
+	})
+	t.Run("not modified", func(t *testing.T) {
+		urlPath, etag := put("welcome", `{}`) // Awaits elicitation; doesn't change on its own
+		start := time.Now()
+		resp := client.Get(urlPath+"?waitSeconds=1", http.Header{"If-None-Match": []string{etag}})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotModified {
+			t.Fatalf("expected 304 Not Modified, got %d", resp.StatusCode)
+		}
+		if elapsed := time.Since(start); elapsed < time.Second {
+			t.Fatalf("expected the GET to wait 1 second, took %v", elapsed)
+		}
+		resp = client.Get(urlPath, http.Header{"If-None-Match": []string{etag}}) // No wait
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotModified {
+			t.Fatalf("expected 304 Not Modified, got %d", resp.StatusCode)
+		}
+	})
+	t.Run("bad wait", func(t *testing.T) {
+		for _, h := range []http.Header{{"Prefer": []string{"wait=soon"}}, {"Prefer": []string{"wait=-1"}}} {
+			resp := client.Get("/mcp/tools/welcome/calls/"+name, h)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("%v: expected 400 Bad Request, got %d", h, resp.StatusCode)
+			}
+		}
+		resp := client.Get("/mcp/tools/welcome/calls/"+name+"?waitSeconds=-1", http.Header{})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 Bad Request, got %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestGetToolCallEvents(t *testing.T) {
+	client, urlPath := newTestClient(t), "/mcp/tools/count/calls/"+t.Name()
+	resp := client.Put(urlPath, http.Header{"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)}}, strings.NewReader(`{"increments":3}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Create failed with status %d", resp.StatusCode)
+	}
+
+	type event struct {
+		id string
+		tc mcp.ToolCall
+	}
+	events := func(headers http.Header) []event {
+		req, err := http.NewRequest(http.MethodGet, client.url+urlPath+"/events", nil) // client.Get cancels before the stream can be read
+		if aids.IsError(err) {
+			t.Fatal(err)
+		}
+		req.Header = headers
+		resp, err := http.DefaultClient.Do(req)
+		if aids.IsError(err) {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("expected text/event-stream, got %q", ct)
+		}
+		b, err := io.ReadAll(resp.Body) // The stream ends when the tool call terminates
+		if aids.IsError(err) {
+			t.Fatal(err)
+		}
+		result := []event{}
+		for block := range strings.SplitSeq(strings.TrimSpace(string(b)), "\n\n") {
+			if block == "" {
+				continue
+			}
+			e := event{}
+			for line := range strings.SplitSeq(block, "\n") {
+				if v, ok := strings.CutPrefix(line, "id: "); ok {
+					e.id = v
+				} else if v, ok := strings.CutPrefix(line, "data: "); ok {
+					if err := json.Unmarshal([]byte(v), &e.tc); aids.IsError(err) {
+						t.Fatal(err)
+					}
+				}
+			}
+			result = append(result, e)
+		}
+		return result
+	}
+
+	all := events(http.Header{})
+	if len(all) < 2 {
+		t.Fatalf("expected at least 2 events, got %d", len(all))
+	}
+	for i, e := range all {
+		if e.id == "" || (i > 0 && e.id == all[i-1].id) {
+			t.Fatalf("event %d: expected a new, non-empty id; got %q", i, e.id)
+		}
+	}
+	if last := all[len(all)-1]; *last.tc.Status != mcp.StatusSuccess {
+		t.Fatalf("expected the last event's status to be success, got %s", *last.tc.Status)
+	}
+
+	t.Run("resume", func(t *testing.T) {
+		if resumed := events(http.Header{"Last-Event-ID": []string{all[len(all)-1].id}}); len(resumed) != 0 {
+			t.Fatalf("expected no events after the last event ID, got %d", len(resumed))
+		}
+		if resumed := events(http.Header{"Last-Event-ID": []string{all[0].id}}); len(resumed) != 1 || resumed[0].id != all[len(all)-1].id {
+			t.Fatalf("expected only the latest event, got %v", resumed)
+		}
+	})
+	t.Run("not found", func(t *testing.T) {
+		resp := client.Get("/mcp/tools/count/calls/missing/events", http.Header{})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected 404 Not Found, got %d", resp.StatusCode)
+		}
+	})
+}
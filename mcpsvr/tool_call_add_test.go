@@ -5,11 +5,13 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/svrcore"
 )
 
 var ctx = context.Background()
@@ -56,3 +58,26 @@ func TestToolCallAdd(t *testing.T) {
 		t.Fatalf("expected sum: 8, got %d", got)
 	}
 }
+
+func TestToolCallAddInvalidInput(t *testing.T) {
+	client := newTestClient(t)
+	resp := client.Put("/mcp/tools/add/calls/"+t.Name(),
+		http.Header{"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)}},
+		strings.NewReader(`{"x":"5"}`))
+	b, err := io.ReadAll(resp.Body)
+	if aids.IsError(err) {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	se := aids.MustUnmarshal[struct{ Error svrcore.ServerError }](b).Error
+	targets := []string{}
+	for _, d := range se.Details {
+		targets = append(targets, d.Target)
+	}
+	if want := []string{"/y", "/x"}; se.ErrorCode != "InvalidToolInput" || !slices.Equal(targets, want) {
+		t.Fatalf("expected InvalidToolInput with details targeting %v, got %s", want, b)
+	}
+}
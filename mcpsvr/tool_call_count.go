@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"time"
 
 	"github.com/JeffreyRichter/internal/aids"
@@ -12,126 +11,76 @@ import (
 	"github.com/JeffreyRichter/svrcore"
 )
 
-type countToolInfo struct {
-	defaultToolInfo
-	ops *mcpStages
-}
-
-func (c *countToolInfo) Tool() *mcp.Tool {
-	return &mcp.Tool{
-		BaseMetadata: mcp.BaseMetadata{
-			Name:  "count",
-			Title: aids.New("Count up from an integer"),
-		},
-		Description: aids.New("Count from a starting value, adding 1 to it for the specified number of increments"),
-		InputSchema: mcp.JSONSchema{
-			Type: "object",
-			Properties: &map[string]any{
-				"start": map[string]any{
-					"type":        "integer",
-					"Description": aids.New("The starting value"),
-				},
-				"increments": map[string]any{
-					"type":        "integer",
-					"Description": aids.New("The number of increments to perform"),
-				},
-			},
-			Required: []string{},
-		},
-		OutputSchema: &mcp.JSONSchema{
-			Type: "object",
-			Properties: &map[string]any{
-				"n": map[string]any{
-					"type":        "integer",
-					"Description": aids.New("The final count"),
-				},
-				"text": map[string]any{
-					"type": "array",
-					"items": map[string]any{
-						"type": "string",
-					},
-					"Description": aids.New("The text output array"),
-				},
-			},
-			Required: []string{"n", "text"},
-		},
-		Annotations: &mcp.ToolAnnotations{
-			Title:           aids.New("Count a specified number of increments"),
-			ReadOnlyHint:    aids.New(false),
-			DestructiveHint: aids.New(false),
-			IdempotentHint:  aids.New(true),
-			OpenWorldHint:   aids.New(true),
-		},
-	}
-}
-
 // This type block defines the tool-specific tool call resource types
 type (
 	countToolCallRequest struct {
-		CountTo int `json:"countto,omitempty"`
+		Start      int `json:"start,omitempty" description:"The starting value"`
+		Increments int `json:"increments,omitempty" description:"The number of increments to perform" minimum:"0"`
 	}
 
 	countToolCallResult struct {
-		Count   int      `json:"count"`
-		Updates []string `json:"updates"`
+		Count   int      `json:"n" description:"The final count"`
+		Updates []string `json:"text" description:"The text output array"`
 	}
 )
 
-func (c *countToolInfo) Create(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes, pm toolcall.PhaseMgr) bool {
-	var request countToolCallRequest
-	if stop := r.UnmarshalBody(&request); stop {
-		return stop
-	}
-	tc.Request = aids.MustMarshal(request)
-	tc.Status = aids.New(mcp.StatusRunning)
-	result := countToolCallResult{
-		Count:   0,
-		Updates: []string{fmt.Sprintf("Started: %s", time.Now().Format(time.DateTime))},
-	}
-	tc.Phase = aids.New(fmt.Sprintf("Phase-%c", 'A'+result.Count))
-	tc.Result = aids.MustMarshal(result)
-	se := c.ops.store.Put(ctx, tc, svrcore.AccessConditions{IfNoneMatch: svrcore.ETagAnyPtr})
-	if se != nil {
-		return r.WriteServerError(se, nil, nil)
-	}
-	if se := pm.StartPhase(ctx, tc); se != nil {
-		return r.WriteServerError(se, nil, nil)
-	}
-	return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{ETag: tc.ETag}, nil, tc.ToMCP())
+func newCountToolInfo(ops *mcpStages) ToolInfo {
+	return toolcall.Define(toolcall.Definition[countToolCallRequest, countToolCallResult]{
+		Tool: mcp.Tool{
+			BaseMetadata: mcp.BaseMetadata{
+				Name:  "count",
+				Title: aids.New("Count up from an integer"),
+			},
+			Description: aids.New("Count from a starting value, adding 1 to it for the specified number of increments"),
+			Annotations: &mcp.ToolAnnotations{
+				Title:           aids.New("Count a specified number of increments"),
+				ReadOnlyHint:    aids.New(false),
+				DestructiveHint: aids.New(false),
+				IdempotentHint:  aids.New(true),
+				OpenWorldHint:   aids.New(true),
+			},
+		},
+		Store: func() toolcall.Store { return ops.store },
+		Phase: countPhase,
+	})
 }
 
-func (c *countToolInfo) Get(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes) bool {
-	return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{ETag: tc.ETag}, nil, tc.ToMCP())
-}
+// countIncrementTime is the simulated work to perform each increment
+const countIncrementTime = 150 * time.Millisecond
 
-// Cancel the tool call if it is running; otherwise, do nothing
-func (c *countToolInfo) Cancel(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes) bool {
-	switch *tc.Status {
-	case mcp.StatusSuccess, mcp.StatusFailed, mcp.StatusCanceled:
-		return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{ETag: tc.ETag}, nil, tc.ToMCP())
+// countPhase starts counting in phase 0 (which completes the tool call if there are no increments); each subsequent
+// phase adds 1 to the count until all increments are done.
+func countPhase(ctx context.Context, in toolcall.PhaseInput[countToolCallRequest, countToolCallResult]) (toolcall.Step[countToolCallResult], *svrcore.ServerError) {
+	if in.Phase == 0 {
+		return toolcall.Step[countToolCallResult]{
+			Status: aids.Iif(in.Request.Increments == 0, mcp.StatusSuccess, mcp.StatusRunning),
+			Result: &countToolCallResult{
+				Count:   in.Request.Start,
+				Updates: []string{fmt.Sprintf("Started: %s", time.Now().Format(time.DateTime))},
+			},
+			Progress: countProgress(in.Request, 0),
+		}, nil
 	}
 
-	tc.Status, tc.Phase, tc.Error, tc.Result, tc.ElicitationRequest = aids.New(mcp.StatusCanceled), nil, nil, nil, nil
-	if se := c.ops.store.Put(ctx, tc, svrcore.AccessConditions{IfMatch: r.H.IfMatch, IfNoneMatch: r.H.IfNoneMatch}); se != nil {
-		return r.WriteServerError(se, nil, nil)
+	if !toolcall.Sleep(ctx, countIncrementTime) { // Simulate doing work
+		return toolcall.Step[countToolCallResult]{}, nil // Canceled; the step is discarded
 	}
-	return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{ETag: tc.ETag}, nil, tc.ToMCP())
-}
-
-// ProcessPhase advanced the tool call's current phase to its next phase.
-// Return nil to have the updated tc persisted to the tool call Store.
-func (c *countToolInfo) ProcessPhase(_ context.Context, _ toolcall.PhaseProcessor, tc *toolcall.Resource) {
-	time.Sleep(150 * time.Millisecond) // Simulate doing work
-
-	request := aids.MustUnmarshal[countToolCallRequest](tc.Request)
-	result := aids.MustUnmarshal[countToolCallResult](tc.Result) // Update the result
+	result := in.Result
 	result.Count++
 	result.Updates = append(result.Updates, fmt.Sprintf("Incremented: %s", time.Now().Format(time.DateTime)))
-	tc.Result = aids.MustMarshal(result)
-	tc.Phase = aids.New(fmt.Sprintf("Phase-%c", 'A'+result.Count))
-	if result.Count >= request.CountTo {
-		tc.Status, tc.Phase = aids.New(mcp.StatusSuccess), nil
+	return toolcall.Step[countToolCallResult]{
+		Status:   aids.Iif(result.Count-in.Request.Start >= in.Request.Increments, mcp.StatusSuccess, mcp.StatusRunning),
+		Result:   &result,
+		Progress: countProgress(in.Request, result.Count-in.Request.Start),
+	}, nil
+}
+
+// countProgress returns the progress after the specified number of increments
+func countProgress(request countToolCallRequest, increments int) *mcp.Progress {
+	return &mcp.Progress{
+		Progress: float64(increments),
+		Total:    aids.New(float64(request.Increments)),
+		Message:  aids.New(fmt.Sprintf("Counted to %d", request.Start+increments)),
+		ETA:      aids.New(time.Now().Add(time.Duration(request.Increments-increments) * countIncrementTime)),
 	}
-	se := c.ops.store.Put(context.TODO(), tc, svrcore.AccessConditions{IfMatch: tc.ETag})
-	aids.Assert(se == nil, fmt.Errorf("failed to put tool call resource: %w", se))
 }
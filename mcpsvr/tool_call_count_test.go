@@ -4,12 +4,14 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/JeffreyRichter/mcp"
 	"github.com/JeffreyRichter/mcpsvr/toolcall"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -22,7 +24,7 @@ func TestToolCallCount(t *testing.T) {
 			"Content-Type":    []string{"application/json"},
 			"Accept":          []string{"application/json"},
 		},
-		strings.NewReader(`{"countto":40}`))
+		strings.NewReader(`{"increments":40}`))
 	require.Equal(t, http.StatusOK, resp.StatusCode)
 
 	var tc toolcall.Resource
@@ -41,4 +43,138 @@ func TestToolCallCount(t *testing.T) {
 		time.Sleep(50 * time.Millisecond)
 	}
 	require.Equal(t, mcp.StatusSuccess, *tc.Status)
+	require.NotNil(t, tc.Progress)
+	fraction, ok := tc.Progress.Fraction()
+	assert.True(t, ok && fraction == 1, "expected completed progress, got %+v", *tc.Progress)
+}
+
+func TestToolCallCountNoIncrements(t *testing.T) {
+	client := newTestClient(t)
+	for name, body := range map[string]string{"omitted": `{"start":5}`, "zero": `{"start":5,"increments":0}`} {
+		resp := client.Put("/mcp/tools/count/calls/"+t.Name()+"-"+name,
+			http.Header{"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)}}, strings.NewReader(body))
+		tc, _ := readToolCall(t, resp, http.StatusOK)
+		require.Equal(t, mcp.StatusSuccess, *tc.Status, name)
+		var result countToolCallResult
+		require.NoError(t, json.Unmarshal(tc.Result, &result), name)
+		require.Equal(t, 5, result.Count, name)
+		require.Len(t, result.Updates, 1, name)
+		require.NotNil(t, tc.Progress, name)
+		assert.Zero(t, tc.Progress.Progress, name)
+	}
+}
+
+func TestToolCallCountNotifications(t *testing.T) {
+	type notification struct {
+		body      []byte
+		signature string
+	}
+	notifications := make(chan notification, 10)
+	webhook := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err) // require must not be called from the handler's goroutine
+		notifications <- notification{body: body, signature: r.Header.Get(toolcall.NotificationSignatureHeader)}
+	}))
+	t.Cleanup(webhook.Close)
+
+	client := newTestClient(t)
+	urlPath := "/mcp/tools/count/calls/" + t.Name()
+	resp := client.Put(urlPath,
+		http.Header{
+			"Idempotency-Key":  []string{time.Now().Format(time.RFC3339Nano)},
+			"Notification-Url": []string{strings.Replace(webhook.URL, "127.0.0.1", "localhost", 1)}, // IP literals must be public
+		},
+		strings.NewReader(`{"increments":3}`))
+	readToolCall(t, resp, http.StatusOK)
+	secret := resp.Header.Get(toolcall.NotificationSecretHeader)
+	require.NotEmpty(t, secret, "the PUT must return the tool call's notification secret")
+
+	// The secret is returned only by the PUT
+	resp = client.Get(urlPath, http.Header{"Accept": []string{"application/json"}})
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Empty(t, resp.Header.Get(toolcall.NotificationSecretHeader))
+	require.NotContains(t, string(body), secret)
+
+	// Only status changes are notified: running (on create) & success; increments while running are not
+	statuses := []mcp.Status{}
+	for len(statuses) < 2 {
+		select {
+		case n := <-notifications:
+			require.NoError(t, toolcall.VerifyNotification([]byte(secret), n.signature, n.body, time.Minute))
+			var tc mcp.ToolCall
+			require.NoError(t, json.Unmarshal(n.body, &tc))
+			require.Equal(t, t.Name(), *tc.ID)
+			statuses = append(statuses, *tc.Status)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for notifications; got %v", statuses)
+		}
+	}
+	require.ElementsMatch(t, []mcp.Status{mcp.StatusRunning, mcp.StatusSuccess}, statuses)
+	select {
+	case n := <-notifications:
+		t.Fatalf("unexpected notification %s", n.body)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestToolCallNotificationUrlInvalid(t *testing.T) {
+	client := newTestClient(t)
+	for _, u := range []string{"not a url", "ftp://example.com/hook", "/relative/hook", "http://example.com/hook",
+		"https://127.0.0.1/hook", "https://[::1]/hook", "https://10.0.0.1/hook", "https://169.254.169.254/latest/meta-data"} {
+		resp := client.Put("/mcp/tools/count/calls/"+t.Name(),
+			http.Header{"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)}, "Notification-Url": []string{u}},
+			strings.NewReader(`{"increments":1}`))
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, u)
+	}
+}
+
+func TestToolCallNotificationSecretOnlyWhenCreated(t *testing.T) {
+	client := newTestClient(t)
+	for name, c := range map[string]struct{ tool, body string }{
+		"invalid input": {"count", `{"increments":-1}`},
+		"phase 0 error": {"summarize", `{"text":" "}`},
+	} {
+		resp := client.Put("/mcp/tools/"+c.tool+"/calls/"+t.Name(),
+			http.Header{"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)}, "Notification-Url": []string{"https://example.com/hook"}},
+			strings.NewReader(c.body))
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, name)
+		require.Empty(t, resp.Header.Get(toolcall.NotificationSecretHeader), name)
+	}
+}
+
+func TestToolCallCountInvalidInput(t *testing.T) {
+	client := newTestClient(t)
+	resp := client.Put("/mcp/tools/count/calls/"+t.Name(),
+		http.Header{"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)}},
+		strings.NewReader(`{"increments":-1}`))
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestToolCallCountCancel(t *testing.T) {
+	client := newTestClient(t)
+	urlPath := "/mcp/tools/count/calls/" + t.Name()
+	resp := client.Put(urlPath,
+		http.Header{"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)}},
+		strings.NewReader(`{"increments":40}`))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	time.Sleep(400 * time.Millisecond) // Let a few phases run
+
+	resp = client.Post(urlPath+"/cancel", http.Header{}, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var tc toolcall.Resource
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&tc))
+	require.Equal(t, mcp.StatusCanceled, *tc.Status)
+
+	// The running phase stops without overwriting the canceled tool call
+	time.Sleep(400 * time.Millisecond)
+	resp = client.Get(urlPath, http.Header{})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&tc))
+	require.Equal(t, mcp.StatusCanceled, *tc.Status)
+	assert.Nil(t, tc.Result)
 }
@@ -1 +1,42 @@
 package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcpsvr/toolcall"
+	"github.com/JeffreyRichter/mcpsvr/toolcall/stateless"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+// toolCallFromServerData reconstructs the tool call identified by tc from the serverData member of the request's
+// JSON body (ex: an ElicitationResult or SamplingResult); the body is left unread for the tool's method.
+// Writes an HTTP error response and returns true if serverData is missing, invalid, or for another tool call.
+func (p *mcpStages) toolCallFromServerData(r *svrcore.ReqRes, tc *toolcall.Resource) (*toolcall.Resource, bool) {
+	body, err := io.ReadAll(r.R.Body)
+	r.R.Body.Close()
+	if aids.IsError(err) {
+		return nil, r.WriteError(http.StatusBadRequest, nil, nil, "Unable to read full body", "%s", err.Error())
+	}
+	r.R.Body = io.NopCloser(bytes.NewReader(body))
+
+	var sd struct {
+		ServerData *string `json:"serverData"`
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &sd); aids.IsError(err) {
+			return nil, r.WriteError(http.StatusBadRequest, nil, nil, "Invalid JSON body", "%s", err.Error())
+		}
+	}
+	if sd.ServerData == nil {
+		return nil, r.WriteError(http.StatusBadRequest, nil, nil, "ServerDataRequired", "This server is stateless; the body must contain the tool call's most recent serverData")
+	}
+	tc, se := stateless.Decode(p.serverData, tc.Identity, *sd.ServerData)
+	if se != nil {
+		return nil, r.WriteServerError(se, nil, nil)
+	}
+	return tc, false
+}
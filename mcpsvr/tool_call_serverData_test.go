@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/mcpsvr/toolcall"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testStatelessSvr = func() *mcpStages {
+	keys := aids.Must(toolcall.ParseServerDataKeys("test:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"))
+	ops := newStatelessMcpStages(context.Background(), slog.Default(), testNotifierConfig, toolcall.NewServerDataEncoder(keys...))
+	ops.enableOutputValidation(OutputValidationStrict)
+	return ops
+}()
+
+// readToolCall reads resp's body as an MCP ToolCall (& its ETag header) after checking resp's status code
+func readToolCall(t *testing.T, resp *http.Response, statusCode int) (mcp.ToolCall, string) {
+	t.Helper()
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, statusCode, resp.StatusCode, string(body))
+	var tc mcp.ToolCall
+	require.NoError(t, json.Unmarshal(body, &tc))
+	return tc, resp.Header.Get("ETag")
+}
+
+func TestStatelessToolCallWelcome(t *testing.T) {
+	client := newTestClientFor(t, testStatelessSvr)
+	urlPath := "/mcp/tools/welcome/calls/" + t.Name()
+
+	tc, etag := readToolCall(t, client.Put(urlPath, http.Header{"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)}}, strings.NewReader(`{}`)), http.StatusOK)
+	require.Equal(t, mcp.StatusAwaitingElicitationResult, *tc.Status)
+	require.NotNil(t, tc.ServerData)
+
+	// The tool call isn't stored; it can only be advanced with its serverData
+	resp := client.Post(urlPath+"/advance", http.Header{}, strings.NewReader(`{"action":"accept","content":{"name":"Jeffrey"}}`))
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	body := fmt.Sprintf(`{"action":"accept","content":{"name":"Jeffrey"},"serverData":%q}`, *tc.ServerData)
+	tc, _ = readToolCall(t, client.Post(urlPath+"/advance", http.Header{"If-Match": []string{etag}}, strings.NewReader(body)), http.StatusOK)
+	require.Equal(t, mcp.StatusSuccess, *tc.Status)
+	require.NotNil(t, tc.ServerData)
+	var result welcomeToolCallResult
+	require.NoError(t, json.Unmarshal(tc.Result, &result))
+	assert.True(t, strings.HasPrefix(result.Welcome, "Hello Jeffrey"), result.Welcome)
+
+	// Replaying stale serverData with a stale ETag fails the precondition
+	resp = client.Post(urlPath+"/advance", http.Header{"If-Match": []string{`"stale"`}}, strings.NewReader(body))
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp.Body.Close()
+}
+
+func TestStatelessToolCallCount(t *testing.T) {
+	client := newTestClientFor(t, testStatelessSvr)
+
+	// The phases run before the response so the tool call has completed
+	tc, _ := readToolCall(t, client.Put("/mcp/tools/count/calls/"+t.Name(), http.Header{"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)}},
+		strings.NewReader(`{"increments":3}`)), http.StatusOK)
+	require.Equal(t, mcp.StatusSuccess, *tc.Status)
+	require.NotNil(t, tc.ServerData)
+	require.NotNil(t, tc.Progress)
+	fraction, ok := tc.Progress.Fraction()
+	assert.True(t, ok && fraction == 1, "expected completed progress, got %+v", *tc.Progress)
+}
+
+func TestStatelessToolCallPutRetry(t *testing.T) {
+	client := newTestClientFor(t, testStatelessSvr)
+	urlPath, h := "/mcp/tools/count/calls/"+t.Name(), http.Header{"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)}}
+	tc, _ := readToolCall(t, client.Put(urlPath, h, strings.NewReader(`{"increments":3}`)), http.StatusOK)
+	require.Equal(t, mcp.StatusSuccess, *tc.Status)
+
+	// The tool call isn't stored so a retry can't return it; it fails instead of counting again
+	resp := client.Put(urlPath, h, strings.NewReader(`{"increments":3}`))
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestStatelessToolCallInvalidServerData(t *testing.T) {
+	client := newTestClientFor(t, testStatelessSvr)
+	tc, _ := readToolCall(t, client.Put("/mcp/tools/welcome/calls/"+t.Name(), http.Header{"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)}}, strings.NewReader(`{}`)), http.StatusOK)
+	sd := *tc.ServerData
+	retired := toolcall.NewServerDataEncoder(toolcall.ServerDataKey{ID: "retired", Key: make([]byte, 32)})
+
+	for name, tt := range map[string]struct {
+		urlPath    string
+		serverData string
+	}{
+		"tampered":       {urlPath: "/mcp/tools/welcome/calls/" + t.Name(), serverData: sd[:len(sd)-4] + aids.Iif(sd[len(sd)-4] == 'A', "B", "A") + sd[len(sd)-3:]},
+		"other call":     {urlPath: "/mcp/tools/welcome/calls/other", serverData: sd},
+		"retired key":    {urlPath: "/mcp/tools/welcome/calls/" + t.Name(), serverData: retired.Encode(aids.MustMarshal(tc), time.Minute)},
+		"not serverData": {urlPath: "/mcp/tools/welcome/calls/" + t.Name(), serverData: "garbage"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := client.Post(tt.urlPath+"/cancel", http.Header{}, strings.NewReader(fmt.Sprintf(`{"serverData":%q}`, tt.serverData)))
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+	}
+
+	// Canceling with valid serverData succeeds
+	tc, _ = readToolCall(t, client.Post("/mcp/tools/welcome/calls/"+t.Name()+"/cancel", http.Header{}, strings.NewReader(fmt.Sprintf(`{"serverData":%q}`, sd))), http.StatusOK)
+	assert.Equal(t, mcp.StatusCanceled, *tc.Status)
+}
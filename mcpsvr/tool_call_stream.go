@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"time"
 
 	"github.com/JeffreyRichter/internal/aids"
@@ -12,75 +11,76 @@ import (
 	"github.com/JeffreyRichter/svrcore"
 )
 
-type streamToolInfo struct {
-	defaultToolInfo
-	ops *mcpStages
-}
-
-func (c *streamToolInfo) Tool() *mcp.Tool {
-	return &mcp.Tool{
-		BaseMetadata: mcp.BaseMetadata{
-			Name:  "stream",
-			Title: aids.New("Get Stream text"),
-		},
-		Description: aids.New("Get Stream text"),
-		OutputSchema: &mcp.JSONSchema{
-			Type: "object",
-			Properties: &map[string]any{
-				"text": map[string]any{
-					"type":        "array",
-					"items":       map[string]any{"type": "string"},
-					"Description": aids.New("The stream text"),
-				},
-			},
-			Required: []string{"text"},
-		},
-		Annotations: &mcp.ToolAnnotations{
-			Title:           aids.New("Get Stream text"),
-			ReadOnlyHint:    aids.New(true),
-			DestructiveHint: aids.New(false),
-			IdempotentHint:  aids.New(true),
-			OpenWorldHint:   aids.New(false),
-		},
-		Meta: mcp.Meta{"sensitive": "true"},
-	}
-}
-
 // This type block defines the tool-specific tool call resource types
 type (
 	streamToolCallResult struct {
-		Text []string `json:"text"`
+		Text []string `json:"text" description:"The stream text"`
 	}
 )
 
-func (c *streamToolInfo) Create(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes, pm toolcall.PhaseMgr) bool {
-	tc.Result = aids.MustMarshal(streamToolCallResult{Text: []string{}})
-	tc.Status = aids.New(mcp.StatusRunning)
-	if se := c.ops.store.Put(ctx, tc, svrcore.AccessConditions{IfNoneMatch: svrcore.ETagAnyPtr}); se != nil {
-		return r.WriteServerError(se, nil, nil)
+func newStreamToolInfo(ops *mcpStages) ToolInfo {
+	return toolcall.Define(toolcall.Definition[struct{}, streamToolCallResult]{ // No input parameters
+		Tool: mcp.Tool{
+			BaseMetadata: mcp.BaseMetadata{
+				Name:  "stream",
+				Title: aids.New("Get Stream text"),
+			},
+			Description: aids.New("Get Stream text"),
+			Annotations: &mcp.ToolAnnotations{
+				Title:           aids.New("Get Stream text"),
+				ReadOnlyHint:    aids.New(true),
+				DestructiveHint: aids.New(false),
+				IdempotentHint:  aids.New(true),
+				OpenWorldHint:   aids.New(false),
+			},
+			Meta: mcp.Meta{"sensitive": "true"},
+		},
+		Store: func() toolcall.Store { return ops.store },
+		Phase: streamPhase,
+	})
+}
+
+// streamPhase appends the next paragraph of text to the result in each phase after phase 0
+func streamPhase(ctx context.Context, in toolcall.PhaseInput[struct{}, streamToolCallResult]) (toolcall.Step[streamToolCallResult], *svrcore.ServerError) {
+	if in.Phase == 0 {
+		return toolcall.Step[streamToolCallResult]{
+			Status:   mcp.StatusRunning,
+			Result:   &streamToolCallResult{Text: []string{}},
+			Progress: aids.New(streamProgress(0, 0)),
+		}, nil
 	}
-	if se := pm.StartPhase(ctx, tc); se != nil {
-		return r.WriteServerError(se, nil, nil)
+	result := in.Result
+	for second := range aids.Iif(len(result.Text) > 0, streamSecondsPerParagraph, 0) { // Simulate doing work
+		if se := in.ReportProgress(ctx, streamProgress(len(result.Text), second)); se != nil {
+			return toolcall.Step[streamToolCallResult]{}, se
+		}
+		if !toolcall.Sleep(ctx, streamSecond) {
+			return toolcall.Step[streamToolCallResult]{}, nil // Canceled; the step is discarded
+		}
 	}
-	return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{ETag: tc.ETag}, nil, tc.ToMCP())
+	result.Text = append(result.Text, text[len(result.Text)])
+	return toolcall.Step[streamToolCallResult]{
+		Status:   aids.Iif(len(result.Text) == len(text), mcp.StatusSuccess, mcp.StatusRunning),
+		Result:   &result,
+		Progress: aids.New(streamProgress(len(result.Text), 0)),
+	}, nil
 }
 
-func (c *streamToolInfo) Get(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes) bool {
-	return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{ETag: tc.ETag}, nil, tc.ToMCP())
-}
+// streamSecondsPerParagraph is the simulated work to produce each paragraph after the 1st
+const streamSecondsPerParagraph = 10
 
-// ProcessPhase advanced the tool call's current phase to its next phase.
-// Return nil to have the updated tc persisted to the tool call Store.
-func (c *streamToolInfo) ProcessPhase(_ context.Context, _ toolcall.PhaseProcessor, tc *toolcall.Resource) {
-	result := aids.MustUnmarshal[streamToolCallResult](tc.Result) // Update the result
-	result.Text = append(result.Text, text[len(result.Text)])
-	tc.Result = aids.MustMarshal(result)
-	if len(result.Text) == len(text) {
-		tc.Status, tc.Phase = aids.New(mcp.StatusSuccess), nil
+// streamSecond is how long each second of simulated work takes; tests shorten it
+var streamSecond = time.Second
+
+// streamProgress returns the progress after producing paragraphs & working seconds on the next paragraph
+func streamProgress(paragraphs, seconds int) mcp.Progress {
+	remaining := (len(text)-paragraphs)*streamSecondsPerParagraph - seconds
+	return mcp.Progress{
+		Progress: float64(paragraphs) + float64(seconds)/streamSecondsPerParagraph,
+		Total:    aids.New(float64(len(text))),
+		Message:  aids.New(fmt.Sprintf("Streamed %d of %d paragraphs", paragraphs, len(text))),
+		ETA:      aids.New(time.Now().Add(time.Duration(remaining) * streamSecond)),
 	}
-	se := c.ops.store.Put(context.TODO(), tc, svrcore.AccessConditions{IfMatch: tc.ETag})
-	aids.Assert(se == nil, fmt.Errorf("failed to put tool call resource: %w", se))
-	time.Sleep(10 * time.Second) // Simulate doing work
 }
 
 var text = []string{`
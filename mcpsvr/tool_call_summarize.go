@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/mcpsvr/toolcall"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+// This type block defines the tool-specific tool call resource types
+type (
+	summarizeToolCallRequest struct {
+		Text string `json:"text" description:"The text to summarize; paragraphs are separated by blank lines"`
+	}
+
+	summarizeToolCallResult struct {
+		Summary string `json:"summary" description:"The summary of the text"`
+		Turns   int    `json:"turns" description:"The number of sampling turns used to produce the summary"`
+	}
+
+	// summarizeToolCallInternal is the tool call's state between sampling turns
+	summarizeToolCallInternal struct {
+		Paragraphs []string `json:"paragraphs"`
+		Summaries  []string `json:"summaries"` // One per paragraph summarized so far
+	}
+)
+
+// summarizeMaxTokens is the maximum number of tokens requested for each sampling turn
+const summarizeMaxTokens = 200
+
+// TODO: client must specify sampling capability
+func newSummarizeToolInfo(ops *mcpStages) ToolInfo {
+	return toolcall.Define(toolcall.Definition[summarizeToolCallRequest, summarizeToolCallResult]{
+		Tool: mcp.Tool{
+			BaseMetadata: mcp.BaseMetadata{
+				Name:  "summarize",
+				Title: aids.New("Summarize text"),
+			},
+			Description: aids.New("Summarizes text using the client's LLM (sampling); each paragraph is summarized in its own turn & then the paragraph summaries are combined"),
+			Annotations: &mcp.ToolAnnotations{
+				Title:           aids.New("Summarize text"),
+				ReadOnlyHint:    aids.New(true),
+				DestructiveHint: aids.New(false),
+				IdempotentHint:  aids.New(false), // LLM responses vary
+				OpenWorldHint:   aids.New(false),
+			},
+		},
+		Store: func() toolcall.Store { return ops.store },
+		Phase: summarizePhase,
+	})
+}
+
+// summarizePhase splits the text into paragraphs in phase 0; each subsequent phase accepts the client LLM's
+// SamplingResult for the current turn & asks to summarize the next paragraph (or combine the summaries).
+func summarizePhase(_ context.Context, in toolcall.PhaseInput[summarizeToolCallRequest, summarizeToolCallResult]) (toolcall.Step[summarizeToolCallResult], *svrcore.ServerError) {
+	if in.Phase == 0 {
+		internal := summarizeToolCallInternal{Summaries: []string{}}
+		for p := range strings.SplitSeq(strings.ReplaceAll(in.Request.Text, "\r\n", "\n"), "\n\n") {
+			if p = strings.TrimSpace(p); p != "" {
+				internal.Paragraphs = append(internal.Paragraphs, p)
+			}
+		}
+		if len(internal.Paragraphs) == 0 {
+			return toolcall.Step[summarizeToolCallResult]{}, svrcore.NewServerError(http.StatusBadRequest, "BadRequest", "text must not be empty")
+		}
+		return toolcall.Step[summarizeToolCallResult]{
+			Status:          mcp.StatusAwaitingSamplingResult,
+			Internal:        internal,
+			SamplingRequest: summarizeSamplingRequest(nil, internal),
+		}, nil
+	}
+
+	sr := in.SamplingResult
+	if sr.SamplingMessage.Role != mcp.RoleAssistant {
+		return toolcall.Step[summarizeToolCallResult]{}, svrcore.NewServerError(http.StatusBadRequest, "BadRequest", "sampling result: role must be %q", mcp.RoleAssistant)
+	}
+	text, ok := sr.SamplingMessage.Content.(mcp.TextContent)
+	if !ok || strings.TrimSpace(text.Text) == "" {
+		return toolcall.Step[summarizeToolCallResult]{}, svrcore.NewServerError(http.StatusBadRequest, "BadRequest", "sampling result: content must be non-empty text")
+	}
+	if sr.Model == "" {
+		return toolcall.Step[summarizeToolCallResult]{}, svrcore.NewServerError(http.StatusBadRequest, "BadRequest", "sampling result: missing model")
+	}
+
+	internal, summary := aids.MustUnmarshal[summarizeToolCallInternal](in.Internal), strings.TrimSpace(text.Text)
+	switch {
+	case len(internal.Summaries) == len(internal.Paragraphs): // This was the turn combining the paragraph summaries
+		return toolcall.Step[summarizeToolCallResult]{
+			Status: mcp.StatusSuccess,
+			Result: &summarizeToolCallResult{Summary: summary, Turns: len(internal.Paragraphs) + 1},
+		}, nil
+
+	case len(internal.Paragraphs) == 1: // The only paragraph's summary is the whole text's summary
+		return toolcall.Step[summarizeToolCallResult]{
+			Status: mcp.StatusSuccess,
+			Result: &summarizeToolCallResult{Summary: summary, Turns: 1},
+		}, nil
+	}
+	// Ask to summarize the next paragraph (or combine the summaries)
+	internal.Summaries = append(internal.Summaries, summary)
+	return toolcall.Step[summarizeToolCallResult]{
+		Status:          mcp.StatusAwaitingSamplingResult,
+		Internal:        internal,
+		SamplingRequest: summarizeSamplingRequest(in.SamplingRequest, internal),
+	}, nil
+}
+
+// summarizeSamplingRequest returns the next turn's SamplingRequest: the previous request's messages plus the LLM's
+// last response (if any) plus a new user message asking to summarize the next paragraph or combine the summaries.
+func summarizeSamplingRequest(prev *mcp.SamplingRequest, internal summarizeToolCallInternal) *mcp.SamplingRequest {
+	messages := []mcp.SamplingMessage{}
+	if prev != nil {
+		messages = append(prev.Messages, mcp.SamplingMessage{
+			Role:    mcp.RoleAssistant,
+			Content: mcp.TextContent{Type: "text", Text: internal.Summaries[len(internal.Summaries)-1]},
+		})
+	}
+	prompt := "Combine the paragraph summaries above into a single concise summary of the whole text."
+	if len(internal.Summaries) < len(internal.Paragraphs) {
+		prompt = "Summarize the following paragraph in one sentence:\n\n" + internal.Paragraphs[len(internal.Summaries)]
+	}
+	return &mcp.SamplingRequest{
+		Messages:     append(messages, mcp.SamplingMessage{Role: mcp.RoleUser, Content: mcp.TextContent{Type: "text", Text: prompt}}),
+		SystemPrompt: aids.New("You are a helpful assistant that writes accurate, concise summaries."),
+		MaxTokens:    aids.New(int64(summarizeMaxTokens)),
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+)
+
+// summarizeAdvance POSTs a sampling result to the summarize tool call & returns the response & its tool call
+func summarizeAdvance(t *testing.T, client *testClient, id string, headers http.Header, body string) (*http.Response, mcp.ToolCall) {
+	resp := client.Post("/mcp/tools/summarize/calls/"+id+"/advance", headers, strings.NewReader(body))
+	b, err := io.ReadAll(resp.Body)
+	if aids.IsError(err) {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	tc := mcp.ToolCall{}
+	if resp.StatusCode == http.StatusOK {
+		if err := json.Unmarshal(b, &tc); aids.IsError(err) {
+			t.Fatal(err)
+		}
+	}
+	return resp, tc
+}
+
+func samplingResult(role mcp.Role, text string) string {
+	return string(aids.MustMarshal(mcp.SamplingResult{
+		SamplingMessage: mcp.SamplingMessage{Role: role, Content: mcp.TextContent{Type: "text", Text: text}},
+		Model:           "canned",
+	}))
+}
+
+func TestToolCallSummarize(t *testing.T) {
+	client, id := newTestClient(t), t.Name()
+	resp := client.Put("/mcp/tools/summarize/calls/"+id,
+		http.Header{"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)}},
+		strings.NewReader(`{"text":"First paragraph.\n\nSecond paragraph."}`))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Create failed with status %d", resp.StatusCode)
+	}
+	tc := mcp.ToolCall{}
+	if err := json.Unmarshal(aids.Must(io.ReadAll(resp.Body)), &tc); aids.IsError(err) {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// A canned "LLM" answering each turn; 2 paragraph summaries & then the combined summary
+	canned := []string{"Summary one.", "Summary two.", "The whole summary."}
+	etag := resp.Header.Get("ETag")
+	for turn, response := range canned {
+		if *tc.Status != mcp.StatusAwaitingSamplingResult || tc.SamplingRequest == nil {
+			t.Fatalf("turn %d: expected a sampling request, got status %s", turn, *tc.Status)
+		}
+		if actual := len(tc.SamplingRequest.Messages); actual != 2*turn+1 { // The conversation grows each turn
+			t.Fatalf("turn %d: expected %d messages, got %d", turn, 2*turn+1, actual)
+		}
+		if turn > 0 {
+			if text := tc.SamplingRequest.Messages[2*turn-1].Content.(mcp.TextContent).Text; text != canned[turn-1] {
+				t.Fatalf("turn %d: expected the previous response %q in the conversation, got %q", turn, canned[turn-1], text)
+			}
+		}
+		resp, tc = summarizeAdvance(t, client, id, http.Header{"If-Match": []string{etag}}, samplingResult(mcp.RoleAssistant, response))
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("turn %d: expected 200 OK, got %d", turn, resp.StatusCode)
+		}
+		etag = resp.Header.Get("ETag")
+	}
+	if *tc.Status != mcp.StatusSuccess {
+		t.Fatalf("expected status %q, got %q", mcp.StatusSuccess, *tc.Status)
+	}
+	result := aids.MustUnmarshal[summarizeToolCallResult](tc.Result)
+	if result.Summary != "The whole summary." || result.Turns != 3 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestToolCallSummarizeAdvanceInvalid(t *testing.T) {
+	client, id := newTestClient(t), t.Name()
+	resp := client.Put("/mcp/tools/summarize/calls/"+id,
+		http.Header{"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)}},
+		strings.NewReader(`{"text":"Only paragraph."}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Create failed with status %d", resp.StatusCode)
+	}
+	etag := resp.Header.Get("ETag")
+
+	for name, tt := range map[string]struct {
+		headers http.Header
+		body    string
+		want    int
+	}{
+		"no if-match":    {http.Header{}, samplingResult(mcp.RoleAssistant, "Summary."), http.StatusPreconditionRequired},
+		"stale if-match": {http.Header{"If-Match": []string{"stale"}}, samplingResult(mcp.RoleAssistant, "Summary."), http.StatusPreconditionFailed},
+		"user role":      {http.Header{"If-Match": []string{etag}}, samplingResult(mcp.RoleUser, "Summary."), http.StatusBadRequest},
+		"empty text":     {http.Header{"If-Match": []string{etag}}, samplingResult(mcp.RoleAssistant, " "), http.StatusBadRequest},
+		"not text":       {http.Header{"If-Match": []string{etag}}, `{"samplingMessage":{"role":"assistant","content":{"type":"image","data":"","mimeType":"image/png"}},"model":"canned"}`, http.StatusBadRequest},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if resp, _ := summarizeAdvance(t, client, id, tt.headers, tt.body); resp.StatusCode != tt.want {
+				t.Fatalf("expected %d, got %d", tt.want, resp.StatusCode)
+			}
+		})
+	}
+
+	resp, tc := summarizeAdvance(t, client, id, http.Header{"If-Match": []string{etag}}, samplingResult(mcp.RoleAssistant, "Summary."))
+	if resp.StatusCode != http.StatusOK || *tc.Status != mcp.StatusSuccess {
+		t.Fatalf("expected 200 OK & success, got %d", resp.StatusCode)
+	}
+	if result := aids.MustUnmarshal[summarizeToolCallResult](tc.Result); result.Summary != "Summary." || result.Turns != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
@@ -10,17 +10,20 @@ a specific day/time, the completion of a task from another service, a stock pric
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azqueue"
 	"github.com/JeffreyRichter/internal/aids"
 	"github.com/JeffreyRichter/mcpsvr/toolcall"
 	"github.com/JeffreyRichter/svrcore"
+	"github.com/JeffreyRichter/svrcore/tracing"
 )
 
 type PhaseMgrConfig struct {
@@ -32,12 +35,26 @@ type PhaseMgrConfig struct {
 
 	// PhaseExecutionTime is the initial duration for which a phase is allowed to run.
 	PhaseExecutionTime time.Duration
+
+	// CancelPollInterval is how often a running phase checks the Store for its tool call having been canceled
+	// by another process; the default is 5 seconds.
+	CancelPollInterval time.Duration
+
+	// Metrics receives the queue's approximate message count (the queue depth, sampled every QueueDepthInterval)
+	// & poison messages.
+	Metrics toolcall.PhaseMgrMetrics
+
+	// QueueDepthInterval is how often the queue's depth is sampled if Metrics.QueueDepth isn't nil; the default
+	// is 15 seconds.
+	QueueDepthInterval time.Duration
 }
 
 type PhaseMgr struct {
 	queueClient *azqueue.QueueClient
 	tcs         toolcall.Store
 	config      PhaseMgrConfig
+	running     toolcall.RunningPhases
+	lastPoll    atomic.Int64 // When (Unix nanoseconds) the processor goroutine last polled the queue; 0 if it's not running
 }
 
 // NewPhaseMgr creates a new Mgr.
@@ -46,6 +63,12 @@ func NewPhaseMgr(ctx context.Context, queueClient *azqueue.QueueClient, tcs tool
 	if _, err := queueClient.Create(ctx, nil); aids.IsError(err) { // Make sure the queue exists
 		return nil, svrcore.NewServerError(http.StatusInternalServerError, "", "Failed to create phase manager queue")
 	}
+	if o.CancelPollInterval == 0 {
+		o.CancelPollInterval = 5 * time.Second
+	}
+	if o.QueueDepthInterval == 0 {
+		o.QueueDepthInterval = 15 * time.Second
+	}
 	pm := &PhaseMgr{queueClient: queueClient, tcs: tcs, config: o}
 	go func() {
 		for { // If the goroutine dies, create a new one
@@ -81,43 +104,70 @@ func (pm *PhaseMgr) processor(ctx context.Context) {
 		NumberOfMessages:  aids.New(int32(10)),
 		VisibilityTimeout: aids.New(int32(pm.config.PhaseExecutionTime.Seconds())),
 	}
+	lastQueueDepth := time.Time{}
+	defer pm.lastPoll.Store(0)
 	for ctx.Err() == nil {
+		pm.lastPoll.Store(time.Now().UnixNano())
 		time.Sleep(time.Millisecond * 200)
+		if pm.config.Metrics.QueueDepth != nil && time.Since(lastQueueDepth) >= pm.config.QueueDepthInterval {
+			lastQueueDepth = time.Now()
+			if props, err := pm.queueClient.GetProperties(ctx, nil); !aids.IsError(err) && props.ApproximateMessagesCount != nil {
+				pm.config.Metrics.ReportQueueDepth(int(*props.ApproximateMessagesCount))
+			}
+		}
 		// TODO: If CPU Usage > 90%, continue
 		resp, err := pm.queueClient.DequeueMessages(ctx, o)
-		aids.Assert(!aids.IsError(err), "DequeueMessages: "+err.Error()) // Maybe exponential delay for time.Sleep if service is down?
+		if ctx.Err() != nil {
+			return // The server is shutting down; stop dequeuing
+		}
+		aids.Assert(!aids.IsError(err), err) // Maybe exponential delay for time.Sleep if service is down?
 
 		for _, m := range resp.Messages {
 			if *m.DequeueCount > 3 { // Poison Message
 				pm.config.ErrorLogger.Error("PoisonMessage", slog.String("messageID", *m.MessageID))
+				pm.config.Metrics.ReportPoisonMessage()
 				pm.queueClient.DeleteMessage(ctx, *m.MessageID, *m.PopReceipt, nil) // Ignore any failure
 				continue
 			}
+			var msg phaseMessage
+			if err := json.Unmarshal(([]byte)(*m.MessageText), &msg); aids.IsError(err) {
+				pm.config.ErrorLogger.Error("UnexpectedMessageFormat", slog.String("messageID", *m.MessageID), slog.String("error", err.Error()))
+				continue
+			}
+			pp := pm.newPhaseProcessor(*m.MessageID, *m.PopReceipt, *m.MessageText)
+			sc, _ := tracing.ParseTraceParent(msg.TraceParent, msg.TraceState) // If invalid (ex: an older message), the tool call's trace is continued
+			// Start tracking the phase before its goroutine so Drain can't miss it
+			phaseCtx, stop := pm.running.Start(tracing.ContextWithSpanContext(ctx, sc), &toolcall.Resource{Identity: msg.Identity})
 			go func() { // Each tool call runs in a separate goroutine for parallelism
-				// TODO: Add defer & recover here
-				var tc toolcall.Resource
-				if err := json.Unmarshal(([]byte)(*m.MessageText), &tc); aids.IsError(err) {
-					pm.config.ErrorLogger.Error("UnexpectedMessageFormat", slog.String("messageID", *m.MessageID), slog.String("error", err.Error()))
-					return
-				}
-				se := pm.tcs.Get(ctx, &tc, svrcore.AccessConditions{})
-				if se != nil { // ToolCallID not expired/not found
-					// No more phases to execute; delete the queue message (or let it become a poison message)
-					// continuePhaseProcessing will delete the message if Status != toolcall.ToolCallStatusRunning
-					// TODO: log; maybe not
-					return
-				}
-				pp := pm.newPhaseProcessor(*m.MessageID, *m.PopReceipt)
-				pm.continuePhaseProcessing(ctx, pp, &tc)
+				defer stop()
+				defer func() {
+					if v := recover(); v != nil { // Panic: Capture error & stack trace
+						stack := &strings.Builder{}
+						stack.WriteString(fmt.Sprintf("Error: %v\n", v))
+						aids.WriteStack(stack, aids.ParseStack(2))
+						fmt.Fprint(os.Stderr, stack.String()) // Also write stack to stdout so it shows up in container logs
+						pm.config.ErrorLogger.LogAttrs(phaseCtx, slog.LevelError, "ContinuePhase error", slog.String("stack", stack.String()))
+					}
+				}()
+				pm.continuePhaseProcessing(ctx, phaseCtx, pp, msg.Identity)
 			}()
 		}
 	}
 }
 
-// StartPhaseProcessing: enqueues a new tool call phase with tool name & tool call id.
+// phaseMessage is a queue message identifying a tool call whose phase must be processed; the trace context is
+// the one of the request that started the phase so the phase's spans continue its trace.
+type phaseMessage struct {
+	toolcall.Identity `json:",inline"`
+	TraceParent       string `json:"traceparent,omitempty"`
+	TraceState        string `json:"tracestate,omitempty"`
+}
+
+// StartPhaseProcessing: enqueues a new tool call phase with tool name, tool call id & ctx's trace context.
 // It must succeed or panic due to internal server error
 func (pm *PhaseMgr) StartPhase(ctx context.Context, tc *toolcall.Resource) *svrcore.ServerError {
-	data := aids.MustMarshal(tc.Identity)
+	sc := tracing.SpanContextFromContext(ctx)
+	data := aids.MustMarshal(phaseMessage{Identity: tc.Identity, TraceParent: sc.TraceParent(), TraceState: sc.TraceState})
 	_, err := pm.queueClient.EnqueueMessage(ctx, string(data), nil)
 	if aids.IsError(err) {
 		return svrcore.NewServerError(http.StatusInternalServerError, "", "Failed to enqueue tool call phase")
@@ -125,32 +175,107 @@ func (pm *PhaseMgr) StartPhase(ctx context.Context, tc *toolcall.Resource) *svrc
 	return nil
 }
 
-func (pm *PhaseMgr) continuePhaseProcessing(ctx context.Context, pp *phaseProcessor, tc *toolcall.Resource) {
+// CancelPhase cancels the context of the tool call's phase if it's running in this process; phases running in
+// other processes are canceled when they next poll the Store.
+func (pm *PhaseMgr) CancelPhase(_ context.Context, tc *toolcall.Resource) { pm.running.Cancel(tc) }
+
+// Drain waits for the phases running in this process to stop; they stop when the context passed to NewPhaseMgr is
+// canceled & their queue messages are made visible again so other processes resume them.
+func (pm *PhaseMgr) Drain(ctx context.Context) error { return pm.running.Wait(ctx) }
+
+// processorStallTimeout is how long the processor goroutine may go without polling the queue before CheckHealth
+// reports it unhealthy
+const processorStallTimeout = time.Minute
+
+// CheckHealth returns nil if the queue is reachable & the processor goroutine is alive & polling it; it implements
+// the readiness probe's HealthChecker.
+func (pm *PhaseMgr) CheckHealth(ctx context.Context) error {
+	lastPoll := pm.lastPoll.Load()
+	if lastPoll == 0 {
+		return errors.New("phase processor isn't running")
+	}
+	if stalled := time.Since(time.Unix(0, lastPoll)); stalled > processorStallTimeout {
+		return fmt.Errorf("phase processor hasn't polled the queue for %s", stalled.Round(time.Second))
+	}
+	_, err := pm.queueClient.GetProperties(ctx, nil)
+	return err
+}
+
+// continuePhaseProcessing processes the tool call's phases with phaseCtx; ctx is canceled when the server shuts down.
+func (pm *PhaseMgr) continuePhaseProcessing(ctx, phaseCtx context.Context, pp *phaseProcessor, id toolcall.Identity) {
+	tc := &toolcall.Resource{Identity: id}
+	if se := pm.tcs.Get(phaseCtx, tc, svrcore.AccessConditions{}); se != nil { // ToolCallID not expired/not found
+		// No more phases to execute; let the queue message become a poison message (unless shutting down)
+		if ctx.Err() != nil {
+			pp.release(context.WithoutCancel(ctx))
+		}
+		return
+	}
+	// The poller only cancels phaseCtx; the phase is still running until this method returns (& its caller calls stop)
+	phaseCtx, cancel := context.WithCancel(phaseCtx)
+	defer cancel()
+	go pm.pollForCancel(phaseCtx, tc.Identity, cancel)
+
 	// Lookup PhaseProcessor for this ToolName
 	tnpp := pm.config.ToolNameToProcessPhaseFunc(*tc.ToolName) // panics if tool name unrecgnized
-	for (*tc.Status).Processing() {                            // Loop while tool call is running
-		tnpp(ctx, pp, tc) // Transition tool call from current phase to next phase
-		// Persists new state of tool call resource (etag must match)
-		aids.Must0(pm.tcs.Put(ctx, tc, svrcore.AccessConditions{IfMatch: tc.ETag}))
+	for (*tc.Status).Processing() && phaseCtx.Err() == nil {   // Loop while tool call is running & not canceled
+		tnpp(phaseCtx, pp, tc) // Transition tool call from current phase to next phase & persist its new state
 	}
 
+	if (*tc.Status).Processing() {
+		if ctx.Err() != nil { // The server is shutting down; another process resumes the phase from its persisted state
+			pp.release(context.WithoutCancel(ctx))
+		}
+		return
+	}
 	// When no longer "running", phase processing is complete, so delete the queue message
-	pm.queueClient.DeleteMessage(ctx, pp.messageID, pp.popReceipt, nil) // Ignore any failure
+	pm.queueClient.DeleteMessage(context.WithoutCancel(ctx), pp.messageID, pp.popReceipt, nil) // Ignore any failure
 }
 
-func (pm *PhaseMgr) newPhaseProcessor(messageID, popReceipt string) *phaseProcessor {
-	return &phaseProcessor{mgr: pm, messageID: messageID, popReceipt: popReceipt}
+// pollForCancel calls cancel if the tool call is no longer processing in the Store (ex: a client canceled it via
+// another process) or no longer exists; it returns when ctx is done.
+func (pm *PhaseMgr) pollForCancel(ctx context.Context, id toolcall.Identity, cancel context.CancelFunc) {
+	ticker := time.NewTicker(pm.config.CancelPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		stored := toolcall.Resource{Identity: id}
+		if se := pm.tcs.Get(ctx, &stored, svrcore.AccessConditions{}); (se != nil && ctx.Err() == nil) || (se == nil && !(*stored.Status).Processing()) {
+			cancel()
+			return
+		}
+	}
+}
+
+func (pm *PhaseMgr) newPhaseProcessor(messageID, popReceipt, messageText string) *phaseProcessor {
+	return &phaseProcessor{ProgressThrottle: toolcall.ProgressThrottle{Store: pm.tcs}, mgr: pm, messageID: messageID, popReceipt: popReceipt, messageText: messageText}
 }
 
 type phaseProcessor struct {
-	mgr        *PhaseMgr
-	messageID  string
-	popReceipt string
+	toolcall.ProgressThrottle
+	mgr         *PhaseMgr
+	messageID   string
+	popReceipt  string
+	messageText string // Updating a message replaces its text so updates must pass it back
 }
 
 func (pp *phaseProcessor) ExtendTime(ctx context.Context, phaseExecutionTime time.Duration) {
-	resp, err := pp.mgr.queueClient.UpdateMessage(ctx, pp.messageID, pp.popReceipt, "",
+	resp, err := pp.mgr.queueClient.UpdateMessage(ctx, pp.messageID, pp.popReceipt, pp.messageText,
 		&azqueue.UpdateMessageOptions{VisibilityTimeout: aids.New(int32(phaseExecutionTime.Seconds()))})
 	aids.Assert(!aids.IsError(err), err)
 	pp.popReceipt = *resp.PopReceipt
 }
+
+// release makes the phase's queue message visible again immediately (rather than when its visibility timeout
+// expires) so another process resumes the phase; any failure is logged & the message becomes visible later.
+func (pp *phaseProcessor) release(ctx context.Context) {
+	_, err := pp.mgr.queueClient.UpdateMessage(ctx, pp.messageID, pp.popReceipt, pp.messageText,
+		&azqueue.UpdateMessageOptions{VisibilityTimeout: aids.New(int32(0))})
+	if aids.IsError(err) {
+		pp.mgr.config.ErrorLogger.Error("Phase message release failed", slog.String("messageID", pp.messageID), slog.String("error", err.Error()))
+	}
+}
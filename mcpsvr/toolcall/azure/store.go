@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
@@ -12,7 +13,9 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
 	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
 	"github.com/JeffreyRichter/mcpsvr/toolcall"
 	"github.com/JeffreyRichter/svrcore"
 )
@@ -68,7 +71,7 @@ func (s *store) Get(ctx context.Context, tc *toolcall.Resource, ac svrcore.Acces
 	if aids.IsError(err) {
 		return svrcore.NewServerError(http.StatusInternalServerError, "InternalServerError", "failed to read tool call")
 	}
-	aids.MustUnmarshal[toolcall.Resource](buffer)
+	*tc = aids.MustUnmarshal[toolcall.Resource](buffer)
 	tc.ETag = (*svrcore.ETag)(response.ETag) // Set the ETag from the response
 	return nil
 }
@@ -79,10 +82,14 @@ func (s *store) Get(ctx context.Context, tc *toolcall.Resource, ac svrcore.Acces
 func (s *store) Put(ctx context.Context, tc *toolcall.Resource, ac svrcore.AccessConditions) *svrcore.ServerError {
 	buffer := aids.MustMarshal(tc)
 	containerName, blobName := s.toBlobInfo(tc)
+	metadata := map[string]*string{} // Status is in metadata so List can filter without downloading each blob
+	if tc.Status != nil {
+		metadata[statusMetadataKey] = (*string)(tc.Status)
+	}
 	for {
 		// Attempt to upload the Tool Call blob
 		response, err := s.client.UploadBuffer(ctx, containerName, blobName, buffer,
-			&azblob.UploadBufferOptions{AccessConditions: s.accessConditions(ac)})
+			&azblob.UploadBufferOptions{AccessConditions: s.accessConditions(ac), Metadata: metadata})
 		if !aids.IsError(err) { // Successfully uploaded the Tool Call blob
 			tc.ETag = (*svrcore.ETag)(response.ETag) // Update the passed-in ToolCall's ETag from the response ETag
 			blockClient := s.client.ServiceClient().NewContainerClient(containerName).NewBlockBlobClient(blobName)
@@ -112,5 +119,81 @@ func (s *store) Delete(ctx context.Context, tc *toolcall.Resource, ac svrcore.Ac
 	return nil
 }
 
+// statusMetadataKey is the blob metadata key holding the tool call's status
+const statusMetadataKey = "status"
+
+// List returns one page of the tenant's tool calls for the specified tool name matching the passed-in
+// ListOptions or a [svrcore.ServerError] if an error occurs. Filtering happens after the service returns
+// a page of blobs so a page may contain fewer than MaxResults tool calls even if more pages exist.
+func (s *store) List(ctx context.Context, tenant, toolName string, o toolcall.ListOptions) (*toolcall.ListPage, *svrcore.ServerError) {
+	maxResults := aids.Iif(o.MaxResults > 0, o.MaxResults, toolcall.DefaultListMaxResults)
+	pager := s.client.NewListBlobsFlatPager(tenant, &azblob.ListBlobsFlatOptions{
+		Include:    container.ListBlobsInclude{Metadata: true},
+		Marker:     o.ContinuationToken,
+		MaxResults: aids.New(int32(maxResults)),
+		Prefix:     aids.New(toolName + "/"),
+	})
+	page := &toolcall.ListPage{ToolCalls: []*toolcall.Resource{}}
+	response, err := pager.NextPage(ctx)
+	if aids.IsError(err) {
+		if bloberror.HasCode(err, bloberror.ContainerNotFound) {
+			return page, nil // No tool calls have ever been created for this tenant
+		}
+		if bloberror.HasCode(err, bloberror.InvalidQueryParameterValue, bloberror.OutOfRangeInput) {
+			return nil, svrcore.NewServerError(http.StatusBadRequest, "BadRequest", "Invalid continuation token")
+		}
+		return nil, svrcore.NewServerError(http.StatusInternalServerError, "", "failed to list tool calls")
+	}
+	for _, bi := range response.Segment.BlobItems {
+		tc := toolcall.New(tenant, toolName, strings.TrimPrefix(*bi.Name, toolName+"/"))
+		tc.Created, tc.Expiration, tc.Status = bi.Properties.CreationTime, bi.Properties.ExpiresOn, nil
+		if status, ok := bi.Metadata[statusMetadataKey]; ok && status != nil {
+			tc.Status = (*mcp.Status)(status)
+		}
+		if o.Status != nil && (tc.Status == nil || *tc.Status != *o.Status) {
+			continue
+		}
+		if o.CreatedAfter != nil && (tc.Created == nil || !tc.Created.After(*o.CreatedAfter)) {
+			continue
+		}
+		page.ToolCalls = append(page.ToolCalls, tc)
+	}
+	if response.NextMarker != nil && *response.NextMarker != "" {
+		page.ContinuationToken = response.NextMarker
+	}
+	return page, nil
+}
+
+// waitPollInterval is how often Wait re-polls a tool call's blob for a changed ETag
+const waitPollInterval = time.Second
+
+// Wait blocks until the specified tool call's ETag no longer matches etag and then retrieves it into the
+// passed-in ToolCall struct. Blob Storage has no change notifications so Wait polls the blob (if-none-match).
+func (s *store) Wait(ctx context.Context, tc *toolcall.Resource, etag svrcore.ETag) *svrcore.ServerError {
+	for {
+		se := s.Get(ctx, tc, svrcore.AccessConditions{IfNoneMatch: &etag})
+		switch {
+		case se == nil: // The tool call changed
+			return nil
+		case ctx.Err() != nil: // Get failed because ctx is done; the tool call didn't change in time
+			return svrcore.NewServerError(http.StatusNotModified, "NotModified", "Tool call not modified")
+		case se.StatusCode != http.StatusNotModified: // NotFound or some other error
+			return se
+		}
+		select {
+		case <-ctx.Done():
+			return se
+		case <-time.After(waitPollInterval):
+		}
+	}
+}
+
 // Blobs are cheap, fast (below link), simple, and offer features we need (like expiry)
 // https://learn.microsoft.com/en-us/azure/architecture/best-practices/data-partitioning-strategies
+
+// CheckHealth returns nil if the storage account's Blob service (holding each tenant's tool call container) is
+// reachable with the store's credentials; it implements the readiness probe's HealthChecker.
+func (s *store) CheckHealth(ctx context.Context) error {
+	_, err := s.client.NewListContainersPager(&azblob.ListContainersOptions{MaxResults: aids.New(int32(1))}).NextPage(ctx)
+	return err
+}
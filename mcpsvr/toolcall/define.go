@@ -0,0 +1,324 @@
+package toolcall
+
+import (
+	"bytes"
+	"context"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/mcpsvr/jsonschema"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+type (
+	// Definition declares a tool whose tool calls have requests of type Req & results of type Res. Define turns a
+	// Definition into the tool's standard operations: create, get, advance, cancel & phase processing.
+	Definition[Req, Res any] struct {
+		// Tool is the tool's metadata; if InputSchema/OutputSchema are empty, they're derived from Req's/Res's
+		// struct tags (see [jsonschema.For]).
+		Tool mcp.Tool
+
+		// Store returns the Store tool calls are persisted to; it's called for every operation so Store wrappers
+		// added after the tool is defined are used.
+		Store func() Store
+
+		// Scopes the caller must be granted to create, advance & cancel the tool's calls; if empty, they're derived
+		// from Tool.Annotations.
+		Scopes []string
+
+		// Ephemeral tool calls are never persisted; Phase must return a terminal status for phase 0.
+		Ephemeral bool
+
+		// ValidateResult optionally validates an Ephemeral tool call's result before it's returned to the client;
+		// persisted tool calls' results are validated by their Store.
+		ValidateResult func(tc *Resource) *svrcore.ServerError
+
+		// Phase processes a tool call's phase returning the tool call's next step.
+		Phase PhaseFunc[Req, Res]
+	}
+
+	// PhaseFunc processes a tool call's phase returning the tool call's next step. Phase 0 runs when the client
+	// creates the tool call; each subsequent phase runs via the PhaseMgr (if the previous step's status was
+	// running) or when the client advances the tool call with an elicitation/sampling result. A returned
+	// [svrcore.ServerError] is written to the client (or, for a phase run by the PhaseMgr, fails the tool call).
+	// A phase run by the PhaseMgr should return promptly when ctx is done (see [Sleep]); its step is discarded.
+	PhaseFunc[Req, Res any] func(ctx context.Context, in PhaseInput[Req, Res]) (Step[Res], *svrcore.ServerError)
+
+	// PhaseInput is the tool call state passed to a PhaseFunc
+	PhaseInput[Req, Res any] struct {
+		Phase             int                    // 0 when the tool call is created
+		Request           Req                    // The client's request
+		Result            Res                    // The previous step's (partial) result or Res's zero value
+		Internal          jsontext.Value         // The previous step's Internal state; nil if none
+		ElicitationResult *mcp.ElicitationResult // Set if the client advanced the tool call with an elicitation result
+		SamplingRequest   *mcp.SamplingRequest   // The previous step's SamplingRequest; nil if none
+		SamplingResult    *mcp.SamplingResult    // Set if the client advanced the tool call with a sampling result
+
+		tc *Resource
+		pp PhaseProcessor // nil if the phase isn't run by the PhaseMgr
+	}
+
+	// Step is a tool call's next state returned by a PhaseFunc
+	Step[Res any] struct {
+		// Status is running to have the PhaseMgr process the next phase, awaitingElicitationResult or
+		// awaitingSamplingResult to process the next phase when the client advances the tool call, or terminal.
+		Status             mcp.Status
+		Result             *Res                    // The (partial) result; nil if none
+		Progress           *mcp.Progress           // Optional progress returned to the client; nil keeps reported progress
+		Internal           any                     // Optional tool-specific state passed to the next phase; never returned to clients
+		ElicitationRequest *mcp.ElicitationRequest // Required if Status is awaitingElicitationResult
+		SamplingRequest    *mcp.SamplingRequest    // Required if Status is awaitingSamplingResult
+		Error              any                     // Optional error returned to the client if Status is failed
+	}
+
+	// DefinedTool implements a tool's operations from its Definition; its methods have the same signatures as
+	// mcpsvr's ToolInfo interface.
+	DefinedTool[Req, Res any] struct {
+		def  Definition[Req, Res]
+		tool mcp.Tool
+	}
+)
+
+// Define returns the DefinedTool implementing def's tool.
+func Define[Req, Res any](def Definition[Req, Res]) *DefinedTool[Req, Res] {
+	aids.Assert(def.Tool.Name != "" && def.Phase != nil, "toolcall.Define: Tool.Name & Phase are required")
+	aids.Assert(def.Ephemeral || def.Store != nil, fmt.Errorf("toolcall.Define: tool '%s' requires a Store", def.Tool.Name))
+	tool := def.Tool
+	if tool.InputSchema.Type == "" {
+		tool.InputSchema = toMCPSchema(jsonschema.For[Req]())
+	}
+	if tool.OutputSchema == nil {
+		tool.OutputSchema = aids.New(toMCPSchema(jsonschema.For[Res]()))
+	}
+	return &DefinedTool[Req, Res]{def: def, tool: tool}
+}
+
+// toMCPSchema converts a derived object schema to an mcp.JSONSchema
+func toMCPSchema(s map[string]any) mcp.JSONSchema {
+	schema := mcp.JSONSchema{Type: "object"}
+	if properties, ok := s["properties"].(map[string]any); ok && len(properties) > 0 {
+		schema.Properties = &properties
+	}
+	if required, ok := s["required"].([]any); ok {
+		for _, r := range required {
+			schema.Required = append(schema.Required, r.(string))
+		}
+	}
+	return schema
+}
+
+func (d *DefinedTool[Req, Res]) Tool() *mcp.Tool { return &d.tool }
+
+func (d *DefinedTool[Req, Res]) Scopes() []string { return d.def.Scopes }
+
+// createdHeader is the custom response header returned when a tool call is created
+type createdHeader struct {
+	NotificationSecret *string `json:"notification-secret"` // See NotificationSecretHeader
+}
+
+// Create unmarshals the request, processes phase 0 & persists the tool call (unless Ephemeral); if the tool call
+// is running, its next phase is started. The tool call's NotificationSecret is returned only if it's created.
+func (d *DefinedTool[Req, Res]) Create(ctx context.Context, tc *Resource, r *svrcore.ReqRes, pm PhaseMgr) bool {
+	body, err := io.ReadAll(r.R.Body)
+	r.R.Body.Close()
+	if aids.IsError(err) {
+		return r.WriteError(http.StatusBadRequest, nil, nil, "Unable to read full body", "%s", err.Error())
+	}
+	var req Req
+	if len(bytes.TrimSpace(body)) > 0 { // No body is the same as no arguments
+		if err := json.Unmarshal(body, &req); aids.IsError(err) {
+			return r.WriteError(http.StatusBadRequest, nil, nil, "Invalid JSON body", "%s", err.Error())
+		}
+	}
+	tc.Request = aids.MustMarshal(req)
+	step, se := d.def.Phase(ctx, PhaseInput[Req, Res]{Phase: 0, Request: req, tc: tc})
+	if se != nil {
+		return r.WriteServerError(se, nil, nil)
+	}
+	d.apply(tc, 0, step)
+
+	if d.def.Ephemeral {
+		aids.Assert(tc.Status.Terminated(), fmt.Errorf("ephemeral tool '%s' returned status %q", d.tool.Name, *tc.Status))
+		tc.Expiration = nil
+		if d.def.ValidateResult != nil {
+			if se := d.def.ValidateResult(tc); se != nil {
+				return r.WriteServerError(se, nil, nil)
+			}
+		}
+		return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{ETag: tc.ETag}, nil, tc.ToMCP())
+	}
+	if se := d.def.Store().Put(ctx, tc, svrcore.AccessConditions{IfNoneMatch: svrcore.ETagAnyPtr}); se != nil {
+		return r.WriteServerError(se, &svrcore.ResponseHeader{ETag: tc.ETag}, nil)
+	}
+	if tc.Status.Processing() {
+		if se := pm.StartPhase(ctx, tc); se != nil {
+			return r.WriteServerError(se, nil, nil)
+		}
+	}
+	return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{ETag: tc.ETag}, &createdHeader{NotificationSecret: tc.NotificationSecret}, tc.ToMCP())
+}
+
+func (d *DefinedTool[Req, Res]) Get(ctx context.Context, tc *Resource, r *svrcore.ReqRes) bool {
+	return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{ETag: tc.ETag}, nil, tc.ToMCP())
+}
+
+// Advance processes the tool call's next phase with the client's elicitation or sampling result. If-Match is
+// required for a sampling result so that a result for a stale turn (ex: a client retry after another client
+// advanced the tool call) is rejected.
+func (d *DefinedTool[Req, Res]) Advance(ctx context.Context, tc *Resource, r *svrcore.ReqRes, pm PhaseMgr) bool {
+	in := d.input(tc, nil)
+	switch *tc.Status {
+	case mcp.StatusAwaitingElicitationResult:
+		in.ElicitationResult = &mcp.ElicitationResult{}
+		if stop := r.UnmarshalBody(in.ElicitationResult); stop {
+			return stop
+		}
+	case mcp.StatusAwaitingSamplingResult:
+		if r.H.IfMatch == nil {
+			return r.WriteError(http.StatusPreconditionRequired, nil, nil, "PreconditionRequired", "If-Match header required when advancing with a sampling result")
+		}
+		in.SamplingResult = &mcp.SamplingResult{}
+		if stop := r.UnmarshalBody(in.SamplingResult); stop {
+			return stop
+		}
+	default:
+		return r.WriteError(http.StatusBadRequest, nil, nil, "BadRequest", "not expecting an elicitation or sampling result for call with status %q", *tc.Status)
+	}
+
+	step, se := d.def.Phase(ctx, in)
+	if se != nil {
+		return r.WriteServerError(se, nil, nil)
+	}
+	d.apply(tc, in.Phase, step)
+	if se := d.def.Store().Put(ctx, tc, svrcore.AccessConditions{IfMatch: r.H.IfMatch, IfNoneMatch: r.H.IfNoneMatch}); se != nil {
+		return r.WriteServerError(se, &svrcore.ResponseHeader{ETag: tc.ETag}, nil)
+	}
+	if tc.Status.Processing() {
+		if se := pm.StartPhase(ctx, tc); se != nil {
+			return r.WriteServerError(se, nil, nil)
+		}
+	}
+	return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{ETag: tc.ETag}, nil, tc.ToMCP())
+}
+
+// Cancel cancels the tool call & signals its running phase (if any) to stop; if the tool call has terminated, it
+// does nothing.
+func (d *DefinedTool[Req, Res]) Cancel(ctx context.Context, tc *Resource, r *svrcore.ReqRes, pm PhaseMgr) bool {
+	if tc.Status.Terminated() {
+		return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{ETag: tc.ETag}, nil, tc.ToMCP())
+	}
+	d.apply(tc, 0, Step[Res]{Status: mcp.StatusCanceled})
+	if se := d.def.Store().Put(ctx, tc, svrcore.AccessConditions{IfMatch: r.H.IfMatch, IfNoneMatch: r.H.IfNoneMatch}); se != nil {
+		return r.WriteServerError(se, &svrcore.ResponseHeader{ETag: tc.ETag}, nil)
+	}
+	pm.CancelPhase(ctx, tc)
+	return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{ETag: tc.ETag}, nil, tc.ToMCP())
+}
+
+// ProcessPhase processes the running tool call's next phase & persists the resulting step; a
+// [svrcore.ServerError] returned by the PhaseFunc fails the tool call. If ctx is canceled while the phase runs
+// (ex: the client canceled the tool call) or a client updated the tool call first, the step is discarded & tc is
+// refreshed from the Store.
+func (d *DefinedTool[Req, Res]) ProcessPhase(ctx context.Context, pp PhaseProcessor, tc *Resource) {
+	in := d.input(tc, pp)
+	step, se := d.def.Phase(ctx, in)
+	if ctx.Err() != nil {
+		d.refresh(ctx, tc)
+		return
+	}
+	if se != nil {
+		step = Step[Res]{Status: mcp.StatusFailed, Error: se}
+	}
+	d.apply(tc, in.Phase, step)
+	// ctx may be canceled now (ex: by a client's cancel or a shutdown); the step is still persisted & the If-Match
+	// decides whether a client updated the tool call first
+	se = d.def.Store().Put(context.WithoutCancel(ctx), tc, svrcore.AccessConditions{IfMatch: tc.ETag})
+	if se != nil && (se.StatusCode == http.StatusPreconditionFailed || ctx.Err() != nil) { // Lost the race to a client (ex: cancel)
+		d.refresh(ctx, tc)
+		return
+	}
+	aids.Assert(se == nil, fmt.Errorf("failed to put tool call resource: %w", se))
+}
+
+// refresh replaces tc with its stored state; if it's no longer stored (ex: expired), tc is marked canceled so
+// the PhaseMgr stops processing it.
+func (d *DefinedTool[Req, Res]) refresh(ctx context.Context, tc *Resource) {
+	stored := Resource{Identity: tc.Identity}
+	if se := d.def.Store().Get(context.WithoutCancel(ctx), &stored, svrcore.AccessConditions{}); se != nil {
+		tc.Status = aids.New(mcp.StatusCanceled)
+		return
+	}
+	*tc = stored
+}
+
+// Sleep pauses the current phase for d; it returns false if ctx is done first (ex: the tool call was canceled).
+func Sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// ReportProgress reports the running phase's progress to clients. If the PhaseMgr runs the phase, the progress
+// is persisted (throttled, see [ProgressThrottle]); otherwise, it's persisted with the phase's step.
+func (in PhaseInput[Req, Res]) ReportProgress(ctx context.Context, p mcp.Progress) *svrcore.ServerError {
+	if in.pp == nil {
+		in.tc.Progress = &p
+		return nil
+	}
+	return in.pp.ReportProgress(ctx, in.tc, p)
+}
+
+// input returns the PhaseInput for tc's next phase
+func (d *DefinedTool[Req, Res]) input(tc *Resource, pp PhaseProcessor) PhaseInput[Req, Res] {
+	in := PhaseInput[Req, Res]{Internal: tc.Internal, SamplingRequest: tc.SamplingRequest, tc: tc, pp: pp}
+	if tc.Phase != nil {
+		in.Phase = aids.Must(strconv.Atoi(*tc.Phase)) + 1
+	}
+	if len(tc.Request) > 0 {
+		in.Request = aids.MustUnmarshal[Req](tc.Request)
+	}
+	if len(tc.Result) > 0 {
+		in.Result = aids.MustUnmarshal[Res](tc.Result)
+	}
+	return in
+}
+
+// apply sets tc's state from the step returned by processing phase
+func (d *DefinedTool[Req, Res]) apply(tc *Resource, phase int, s Step[Res]) {
+	switch s.Status {
+	case mcp.StatusAwaitingElicitationResult:
+		aids.Assert(s.ElicitationRequest != nil, fmt.Errorf("tool '%s' awaits an elicitation result without an ElicitationRequest", d.tool.Name))
+	case mcp.StatusAwaitingSamplingResult:
+		aids.Assert(s.SamplingRequest != nil, fmt.Errorf("tool '%s' awaits a sampling result without a SamplingRequest", d.tool.Name))
+	}
+	tc.Status, tc.Phase = aids.New(s.Status), nil
+	if !s.Status.Terminated() {
+		tc.Phase = aids.New(strconv.Itoa(phase))
+	}
+	tc.ElicitationRequest, tc.SamplingRequest = s.ElicitationRequest, s.SamplingRequest
+	tc.Result, tc.Internal, tc.Error = nil, nil, nil
+	if s.Progress != nil || s.Status.Terminated() {
+		tc.Progress = s.Progress // Otherwise, progress reported by the phase is kept
+	}
+	if s.Result != nil {
+		tc.Result = aids.MustMarshal(s.Result)
+	}
+	if s.Internal != nil {
+		tc.Internal = aids.MustMarshal(s.Internal)
+	}
+	if s.Error != nil {
+		tc.Error = aids.MustMarshal(s.Error)
+	}
+}
@@ -0,0 +1,173 @@
+package toolcall
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+type (
+	defineTestRequest struct {
+		To int `json:"to" description:"The number to count to" minimum:"1"`
+	}
+
+	defineTestResult struct {
+		N int `json:"n"`
+	}
+
+	// putRecordingStore records the tool calls Put to it; if stored is set, Puts fail with 412 & Gets return it
+	putRecordingStore struct {
+		Store
+		puts   []Resource
+		stored *Resource
+	}
+)
+
+func (s *putRecordingStore) Put(_ context.Context, tc *Resource, _ svrcore.AccessConditions) *svrcore.ServerError {
+	if s.stored != nil {
+		return svrcore.NewServerError(http.StatusPreconditionFailed, "PreconditionFailed", "etag mismatch")
+	}
+	tc.ETag = aids.New(svrcore.ETag("etag"))
+	s.puts = append(s.puts, tc.Copy())
+	return nil
+}
+
+// cancelingStore cancels the phase's context as its Put starts (as if a client's cancel or a shutdown arrived
+// right after the phase returned); Puts honor their context or, if fail is set, fail anyway
+type cancelingStore struct {
+	putRecordingStore
+	cancel context.CancelFunc
+	fail   bool
+}
+
+func (s *cancelingStore) Put(ctx context.Context, tc *Resource, ac svrcore.AccessConditions) *svrcore.ServerError {
+	s.cancel()
+	if ctx.Err() != nil || s.fail {
+		return svrcore.NewServerError(http.StatusInternalServerError, "", "failed to upload blob")
+	}
+	return s.putRecordingStore.Put(ctx, tc, ac)
+}
+
+func (s *putRecordingStore) Get(_ context.Context, tc *Resource, _ svrcore.AccessConditions) *svrcore.ServerError {
+	*tc = s.stored.Copy()
+	return nil
+}
+
+func TestDefine(t *testing.T) {
+	store := &putRecordingStore{}
+	d := Define(Definition[defineTestRequest, defineTestResult]{
+		Tool:  mcp.Tool{BaseMetadata: mcp.BaseMetadata{Name: "counter"}},
+		Store: func() Store { return store },
+		Phase: func(_ context.Context, in PhaseInput[defineTestRequest, defineTestResult]) (Step[defineTestResult], *svrcore.ServerError) {
+			if in.Request.To > 2 {
+				return Step[defineTestResult]{}, svrcore.NewServerError(http.StatusBadRequest, "TooHigh", "can't count to %d", in.Request.To)
+			}
+			result := defineTestResult{N: in.Result.N + 1}
+			return Step[defineTestResult]{Status: aids.Iif(result.N == in.Request.To, mcp.StatusSuccess, mcp.StatusRunning), Result: &result, Progress: &mcp.Progress{Progress: float64(result.N)}}, nil
+		},
+	})
+
+	tool := d.Tool()
+	if tool.InputSchema.Type != "object" || !slices.Equal(tool.InputSchema.Required, []string{"to"}) ||
+		tool.OutputSchema == nil || !slices.Equal(tool.OutputSchema.Required, []string{"n"}) {
+		t.Fatalf("unexpected derived schemas: %s", aids.MustMarshal(tool))
+	}
+
+	tc := New("tenant", "counter", "1")
+	tc.Status, tc.Phase, tc.Request = aids.New(mcp.StatusRunning), aids.New("0"), aids.MustMarshal(defineTestRequest{To: 2})
+	for tc.Status.Processing() {
+		d.ProcessPhase(context.Background(), nil, tc)
+	}
+	if len(store.puts) != 2 || *store.puts[0].Phase != "1" || store.puts[0].Progress.Progress != 1 {
+		t.Fatalf("expected 2 puts with the 1st in phase 1, got %s", aids.MustMarshal(store.puts))
+	}
+	if *tc.Status != mcp.StatusSuccess || tc.Phase != nil || aids.MustUnmarshal[defineTestResult](tc.Result).N != 2 {
+		t.Fatalf("expected success with n=2, got %s", aids.MustMarshal(tc))
+	}
+
+	// A phase's error fails the tool call
+	tc = New("tenant", "counter", "2")
+	tc.Status, tc.Phase, tc.Request = aids.New(mcp.StatusRunning), aids.New("0"), aids.MustMarshal(defineTestRequest{To: 3})
+	d.ProcessPhase(context.Background(), nil, tc)
+	if se := aids.MustUnmarshal[svrcore.ServerError](tc.Error); *tc.Status != mcp.StatusFailed || se.ErrorCode != "TooHigh" {
+		t.Fatalf("expected failed with TooHigh error, got %s", aids.MustMarshal(tc))
+	}
+}
+
+func TestDefineProcessPhaseCanceled(t *testing.T) {
+	canceled := New("tenant", "sleeper", "1")
+	canceled.Status = aids.New(mcp.StatusCanceled)
+	store := &putRecordingStore{stored: canceled}
+	d := Define(Definition[struct{}, defineTestResult]{
+		Tool:  mcp.Tool{BaseMetadata: mcp.BaseMetadata{Name: "sleeper"}},
+		Store: func() Store { return store },
+		Phase: func(ctx context.Context, in PhaseInput[struct{}, defineTestResult]) (Step[defineTestResult], *svrcore.ServerError) {
+			if !Sleep(ctx, time.Hour) {
+				return Step[defineTestResult]{}, nil
+			}
+			return Step[defineTestResult]{Status: mcp.StatusSuccess, Result: &defineTestResult{N: 1}}, nil
+		},
+	})
+
+	// The phase's context is canceled: the step is discarded & tc is refreshed from the Store
+	tc := New("tenant", "sleeper", "1")
+	tc.Status, tc.Phase = aids.New(mcp.StatusRunning), aids.New("0")
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	d.ProcessPhase(ctx, nil, tc)
+	if *tc.Status != mcp.StatusCanceled || tc.Result != nil {
+		t.Fatalf("expected the stored canceled tool call, got %s", aids.MustMarshal(tc))
+	}
+
+	// The phase completes but a client updated the tool call first: the Put's 412 isn't a failure
+	d = Define(Definition[struct{}, defineTestResult]{
+		Tool:  mcp.Tool{BaseMetadata: mcp.BaseMetadata{Name: "sleeper"}},
+		Store: func() Store { return store },
+		Phase: func(context.Context, PhaseInput[struct{}, defineTestResult]) (Step[defineTestResult], *svrcore.ServerError) {
+			return Step[defineTestResult]{Status: mcp.StatusSuccess, Result: &defineTestResult{N: 1}}, nil
+		},
+	})
+	tc.Status, tc.Phase = aids.New(mcp.StatusRunning), aids.New("0")
+	d.ProcessPhase(context.Background(), nil, tc)
+	if *tc.Status != mcp.StatusCanceled || tc.Result != nil || len(store.puts) != 0 {
+		t.Fatalf("expected the stored canceled tool call, got %s", aids.MustMarshal(tc))
+	}
+}
+
+func TestDefineProcessPhaseCanceledDuringPut(t *testing.T) {
+	canceled := New("tenant", "quick", "1")
+	canceled.Status = aids.New(mcp.StatusCanceled)
+	store := &cancelingStore{}
+	d := Define(Definition[struct{}, defineTestResult]{
+		Tool:  mcp.Tool{BaseMetadata: mcp.BaseMetadata{Name: "quick"}},
+		Store: func() Store { return store },
+		Phase: func(context.Context, PhaseInput[struct{}, defineTestResult]) (Step[defineTestResult], *svrcore.ServerError) {
+			return Step[defineTestResult]{Status: mcp.StatusSuccess, Result: &defineTestResult{N: 1}}, nil
+		},
+	})
+
+	// The phase's step is persisted even though its context is canceled during the Put
+	ctx, cancel := context.WithCancel(context.Background())
+	store.cancel = cancel
+	tc := New("tenant", "quick", "1")
+	tc.Status, tc.Phase = aids.New(mcp.StatusRunning), aids.New("0")
+	d.ProcessPhase(ctx, nil, tc)
+	if *tc.Status != mcp.StatusSuccess || len(store.puts) != 1 {
+		t.Fatalf("expected the successful step to be persisted, got %s", aids.MustMarshal(tc))
+	}
+
+	// A Put failing once the context is canceled is a lost race (not a panic): tc is refreshed from the Store
+	ctx, cancel = context.WithCancel(context.Background())
+	store.cancel, store.fail, store.stored = cancel, true, canceled
+	tc.Status, tc.Phase = aids.New(mcp.StatusRunning), aids.New("0")
+	d.ProcessPhase(ctx, nil, tc)
+	if *tc.Status != mcp.StatusCanceled || tc.Result != nil {
+		t.Fatalf("expected the stored canceled tool call, got %s", aids.MustMarshal(tc))
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/JeffreyRichter/internal/aids"
 	"github.com/JeffreyRichter/mcpsvr/toolcall"
 	"github.com/JeffreyRichter/svrcore"
+	"github.com/JeffreyRichter/svrcore/tracing"
 )
 
 type PhaseMgrConfig struct {
@@ -19,40 +20,63 @@ type PhaseMgrConfig struct {
 
 	// ToolNameToProcessPhaseFunc converts a Tool Name to a function that processes its phases.
 	toolcall.ToolNameToProcessPhaseFunc
+
+	// Metrics receives the number of tool calls whose phases are running (the queue depth).
+	Metrics toolcall.PhaseMgrMetrics
 }
 
 type phaseMgr struct {
-	config PhaseMgrConfig
+	ctx     context.Context // Canceled when the server shuts down
+	tcs     toolcall.Store
+	config  PhaseMgrConfig
+	running toolcall.RunningPhases
 }
 
-// NewPhaseMgr creates a new Mgr.
-// queueUrl must look like: https://myaccount.queue.core.windows.net/<queuename>
-func NewPhaseMgr(ctx context.Context, o PhaseMgrConfig) toolcall.PhaseMgr {
-	return &phaseMgr{config: o}
+// NewPhaseMgr creates a new Mgr; canceling ctx cancels all running phases. tcs persists progress reports.
+func NewPhaseMgr(ctx context.Context, tcs toolcall.Store, o PhaseMgrConfig) toolcall.PhaseMgr {
+	return &phaseMgr{ctx: ctx, tcs: tcs, config: o}
 }
 
-// StartPhaseProcessing: enqueues a new tool call phase with tool name & tool call id.
+// StartPhaseProcessing: processes the tool call's phases in a new goroutine.
+// The phases run with a context that outlives ctx (the client's request) & is canceled by CancelPhase; it carries
+// ctx's trace context.
 func (pm *phaseMgr) StartPhase(ctx context.Context, tc *toolcall.Resource) *svrcore.ServerError {
+	cp := tc.Copy() // The caller continues to use tc (ex: to write its response)
+	tc = &cp
+	// The phases outlive ctx but continue its trace (ex: the PUT or advance request that started them)
+	phaseCtx, stop := pm.running.Start(tracing.ContextWithSpanContext(pm.ctx, tracing.SpanContextFromContext(ctx)), tc)
+	pm.config.Metrics.ReportQueueDepth(pm.running.Len())
+	pp := &phaseProcessor{ProgressThrottle: toolcall.ProgressThrottle{Store: pm.tcs}}
 	go func() { // Run each toolcall in its own goroutine to parallelize the work
+		defer func() { stop(); pm.config.Metrics.ReportQueueDepth(pm.running.Len()) }()
 		defer func() {
-			if r := recover(); r != nil {
-				if v := recover(); v != nil { // Panic: Capture error & stack trace
-					stack := &strings.Builder{}
-					stack.WriteString(fmt.Sprintf("Error: %v\n", v))
-					aids.WriteStack(stack, aids.ParseStack(2))
-					fmt.Fprint(os.Stderr, stack.String()) // Also write stack to stdout so it shows up in container logs
-					pm.config.ErrorLogger.LogAttrs(ctx, slog.LevelError, "StartPhase error", slog.String("stack", stack.String()))
-				}
+			if v := recover(); v != nil { // Panic: Capture error & stack trace
+				stack := &strings.Builder{}
+				stack.WriteString(fmt.Sprintf("Error: %v\n", v))
+				aids.WriteStack(stack, aids.ParseStack(2))
+				fmt.Fprint(os.Stderr, stack.String()) // Also write stack to stdout so it shows up in container logs
+				pm.config.ErrorLogger.LogAttrs(phaseCtx, slog.LevelError, "StartPhase error", slog.String("stack", stack.String()))
 			}
 		}()
 		// Lookup PhaseProcessor for this ToolName
 		tnpp := pm.config.ToolNameToProcessPhaseFunc(*tc.ToolName) // Error can't happen here because tool call was validated earlier
-		for (*tc.Status).Processing() {                            // Loop while tool call is server processing
-			tnpp(ctx, pm, tc) // Transition tool call from current phase to next phase
+		for (*tc.Status).Processing() && phaseCtx.Err() == nil {   // Loop while tool call is server processing & not canceled
+			tnpp(phaseCtx, pp, tc) // Transition tool call from current phase to next phase
 		}
 	}()
 	return nil
 }
 
-// ExtendTime extends the time a phase is allowed to run. This method allows *phaseMgr to implement toolcall.PhaseProcessor
-func (*phaseMgr) ExtendTime(_ context.Context, _ time.Duration) {}
+// CancelPhase cancels the context of the tool call's running phase (if any)
+func (pm *phaseMgr) CancelPhase(_ context.Context, tc *toolcall.Resource) { pm.running.Cancel(tc) }
+
+// Drain waits for the running phases to stop; they stop when the context passed to NewPhaseMgr is canceled.
+func (pm *phaseMgr) Drain(ctx context.Context) error { return pm.running.Wait(ctx) }
+
+// phaseProcessor implements toolcall.PhaseProcessor for one tool call's phases
+type phaseProcessor struct {
+	toolcall.ProgressThrottle
+}
+
+// ExtendTime extends the time a phase is allowed to run; local phases have no time limit.
+func (*phaseProcessor) ExtendTime(_ context.Context, _ time.Duration) {}
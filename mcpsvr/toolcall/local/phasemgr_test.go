@@ -0,0 +1,140 @@
+package local
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/mcpsvr/toolcall"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+func TestPhaseMgr_CancelPhase(t *testing.T) {
+	started, stopped := make(chan struct{}), make(chan error)
+	pm := NewPhaseMgr(t.Context(), NewToolCallStore(t.Context()), PhaseMgrConfig{
+		ErrorLogger: slog.Default(),
+		ToolNameToProcessPhaseFunc: func(string) toolcall.ProcessPhaseFunc {
+			return func(ctx context.Context, _ toolcall.PhaseProcessor, tc *toolcall.Resource) {
+				close(started)
+				<-ctx.Done()
+				tc.Status = aids.New(mcp.StatusCanceled) // As if refreshed from the Store
+				stopped <- ctx.Err()
+			}
+		},
+	})
+
+	tc := toolcall.New("tenant", "tool", "1")
+	tc.Status = aids.New(mcp.StatusRunning)
+	if se := pm.StartPhase(context.Background(), tc); se != nil {
+		t.Fatalf("StartPhase failed: %v", se)
+	}
+	<-started
+	pm.CancelPhase(context.Background(), tc)
+	select {
+	case err := <-stopped:
+		if err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("phase didn't observe the cancellation")
+	}
+	if *tc.Status != mcp.StatusRunning {
+		t.Fatal("StartPhase must process a copy of the caller's tool call")
+	}
+}
+
+func TestPhaseMgr_Drain(t *testing.T) {
+	ctx, shutdown := context.WithCancel(t.Context())
+	started, checkpoint := make(chan struct{}), make(chan struct{})
+	pm := NewPhaseMgr(ctx, NewToolCallStore(t.Context()), PhaseMgrConfig{
+		ErrorLogger: slog.Default(),
+		ToolNameToProcessPhaseFunc: func(string) toolcall.ProcessPhaseFunc {
+			return func(ctx context.Context, _ toolcall.PhaseProcessor, _ *toolcall.Resource) {
+				close(started)
+				<-ctx.Done()
+				<-checkpoint // Persisting the phase's state
+			}
+		},
+	})
+	if err := pm.Drain(t.Context()); err != nil {
+		t.Fatalf("expected Drain to return immediately with no running phases, got %v", err)
+	}
+
+	tc := toolcall.New("tenant", "tool", "1")
+	tc.Status = aids.New(mcp.StatusRunning)
+	if se := pm.StartPhase(context.Background(), tc); se != nil {
+		t.Fatalf("StartPhase failed: %v", se)
+	}
+	<-started
+	shutdown()
+	drainCtx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+	if err := pm.Drain(drainCtx); err != context.DeadlineExceeded {
+		t.Fatalf("expected Drain to wait for the checkpointing phase, got %v", err)
+	}
+	close(checkpoint)
+	if err := pm.Drain(t.Context()); err != nil {
+		t.Fatalf("expected Drain to return once the phase stopped, got %v", err)
+	}
+}
+
+// ctxStore fails Puts whose context is done like a real (network) store does; the in-memory store ignores ctx.
+// onPut, if not nil, is called as each Put starts.
+type ctxStore struct {
+	toolcall.Store
+	onPut func()
+}
+
+func (s *ctxStore) Put(ctx context.Context, tc *toolcall.Resource, ac svrcore.AccessConditions) *svrcore.ServerError {
+	if s.onPut != nil {
+		s.onPut()
+	}
+	if ctx.Err() != nil {
+		return svrcore.NewServerError(http.StatusInternalServerError, "", "%v", ctx.Err())
+	}
+	return s.Store.Put(ctx, tc, ac)
+}
+
+type drainTestResult struct{ Checkpoint string }
+
+func TestPhaseMgr_DrainPersistsPhase(t *testing.T) {
+	ctx, shutdown := context.WithCancel(t.Context())
+	store := &ctxStore{Store: NewToolCallStore(t.Context())}
+	d := toolcall.Define(toolcall.Definition[struct{}, drainTestResult]{
+		Tool:  mcp.Tool{BaseMetadata: mcp.BaseMetadata{Name: "tool"}},
+		Store: func() toolcall.Store { return store },
+		Phase: func(context.Context, toolcall.PhaseInput[struct{}, drainTestResult]) (toolcall.Step[drainTestResult], *svrcore.ServerError) {
+			store.onPut = shutdown // The shutdown starts as the phase's step is persisted
+			return toolcall.Step[drainTestResult]{Status: mcp.StatusRunning, Result: &drainTestResult{Checkpoint: "1"}}, nil
+		},
+	})
+	pm := NewPhaseMgr(ctx, store, PhaseMgrConfig{
+		ErrorLogger:                slog.Default(),
+		ToolNameToProcessPhaseFunc: func(string) toolcall.ProcessPhaseFunc { return d.ProcessPhase },
+	})
+
+	tc := toolcall.New("tenant", "tool", "1")
+	tc.Status, tc.Phase = aids.New(mcp.StatusRunning), aids.New("0")
+	if se := store.Put(t.Context(), tc, svrcore.AccessConditions{}); se != nil {
+		t.Fatalf("Put failed: %v", se)
+	}
+	if se := pm.StartPhase(context.Background(), tc); se != nil {
+		t.Fatalf("StartPhase failed: %v", se)
+	}
+	<-ctx.Done()
+	if err := pm.Drain(t.Context()); err != nil {
+		t.Fatalf("expected Drain to return once the phase stopped, got %v", err)
+	}
+
+	stored := toolcall.New("tenant", "tool", "1")
+	if se := store.Get(t.Context(), stored, svrcore.AccessConditions{}); se != nil {
+		t.Fatalf("Get failed: %v", se)
+	}
+	if *stored.Status != mcp.StatusRunning || stored.Phase == nil || *stored.Phase != "1" {
+		t.Fatalf("expected the phase's step to be persisted despite the shutdown, got %s", aids.MustMarshal(stored))
+	}
+}
@@ -2,7 +2,10 @@ package local
 
 import (
 	"context"
+	"encoding/base64"
 	"net/http"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,13 +16,14 @@ import (
 
 // LocalToolCallStore is an in-memory [ToolCallStore] having the same semantics as [AzureBlobToolCallStore]
 type localToolCallStore struct {
-	data map[string]*toolcall.Resource
-	mu   *sync.RWMutex
+	data    map[string]*toolcall.Resource
+	changed map[string]chan struct{} // Closed (& removed) when the key's tool call is Put or Deleted; used by Wait
+	mu      *sync.RWMutex
 }
 
 // NewToolCallStore creates a [toolcall.Store]; ctx is used to cancel the expiry goroutine
 func NewToolCallStore(ctx context.Context) toolcall.Store {
-	s := &localToolCallStore{data: map[string]*toolcall.Resource{}, mu: &sync.RWMutex{}}
+	s := &localToolCallStore{data: map[string]*toolcall.Resource{}, changed: map[string]chan struct{}{}, mu: &sync.RWMutex{}}
 	go s.expiry(ctx)
 	return s
 }
@@ -36,6 +40,7 @@ func (s *localToolCallStore) expiry(ctx context.Context) {
 			for k, v := range s.data {
 				if v.Expiration.Before(time.Now()) {
 					delete(s.data, k)
+					s.signalChanged(k)
 				}
 			}
 			s.mu.Unlock()
@@ -79,6 +84,7 @@ func (s *localToolCallStore) Put(_ context.Context, tc *toolcall.Resource, ac sv
 	cp := tc.Copy() // storing a copy prevents mutating the caller's data
 	cp.ETag = aids.New(svrcore.ETag(time.Now().Format("20060102150405.000000")))
 	s.data[key] = &cp
+	s.signalChanged(key)
 	*tc = cp // except we want the caller to have the actual ETag
 	return nil
 }
@@ -95,9 +101,88 @@ func (s *localToolCallStore) Delete(_ context.Context, tc *toolcall.Resource, ac
 		}
 	}
 	delete(s.data, key)
+	s.signalChanged(key)
 	return nil
 }
 
+func (s *localToolCallStore) List(_ context.Context, tenant, toolName string, o toolcall.ListOptions) (*toolcall.ListPage, *svrcore.ServerError) {
+	after := "" // The continuation token is the (encoded) key of the last tool call returned in the previous page
+	if o.ContinuationToken != nil {
+		b, err := base64.RawURLEncoding.DecodeString(*o.ContinuationToken)
+		if aids.IsError(err) {
+			return nil, svrcore.NewServerError(http.StatusBadRequest, "BadRequest", "Invalid continuation token")
+		}
+		after = string(b)
+	}
+	maxResults := aids.Iif(o.MaxResults > 0, o.MaxResults, toolcall.DefaultListMaxResults)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	prefix := s.key(tenant, toolName, "")
+	keys := []string{}
+	for k := range s.data {
+		if strings.HasPrefix(k, prefix) && k > after {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys) // Map iteration order is random; sort so that paging is stable
+
+	page := &toolcall.ListPage{ToolCalls: []*toolcall.Resource{}}
+	for _, k := range keys {
+		stored := s.data[k]
+		if o.Status != nil && (stored.Status == nil || *stored.Status != *o.Status) {
+			continue
+		}
+		if o.CreatedAfter != nil && (stored.Created == nil || !stored.Created.After(*o.CreatedAfter)) {
+			continue
+		}
+		if len(page.ToolCalls) == maxResults { // Another match exists; return a token so the caller can get it
+			page.ContinuationToken = aids.New(base64.RawURLEncoding.EncodeToString([]byte(s.key(tenant, toolName, *page.ToolCalls[maxResults-1].ID))))
+			break
+		}
+		cp := stored.Copy() // copying prevents the caller mutating stored data
+		page.ToolCalls = append(page.ToolCalls, &cp)
+	}
+	return page, nil
+}
+
+func (s *localToolCallStore) Wait(ctx context.Context, tc *toolcall.Resource, etag svrcore.ETag) *svrcore.ServerError {
+	key := s.key(*tc.Tenant, *tc.ToolName, *tc.ID)
+	for {
+		s.mu.Lock()
+		stored, ok := s.data[key]
+		if !ok {
+			s.mu.Unlock()
+			return svrcore.NewServerError(http.StatusNotFound, "NotFound", "Tool call not found")
+		}
+		if stored.ETag == nil || !stored.ETag.Equals(etag) {
+			*tc = stored.Copy() // copying prevents the caller mutating stored data
+			s.mu.Unlock()
+			return nil
+		}
+		changed, ok := s.changed[key]
+		if !ok {
+			changed = make(chan struct{})
+			s.changed[key] = changed
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return svrcore.NewServerError(http.StatusNotModified, "NotModified", "Tool call not modified")
+		case <-changed: // Tool call was Put or Deleted; loop around to get its new state
+		}
+	}
+}
+
+// signalChanged wakes all Wait callers for key; the caller must hold s.mu's write lock
+func (s *localToolCallStore) signalChanged(key string) {
+	if changed, ok := s.changed[key]; ok {
+		close(changed)
+		delete(s.changed, key)
+	}
+}
+
 func (*localToolCallStore) key(tenant, toolName, toolCallID string) string {
 	return tenant + "/" + toolName + "/" + toolCallID
 }
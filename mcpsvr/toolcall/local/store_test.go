@@ -3,6 +3,8 @@ package local
 import (
 	"context"
 	"encoding/json/jsontext"
+	"fmt"
+	"slices"
 	"testing"
 	"time"
 
@@ -386,3 +388,147 @@ func TestLocalToolCallStore_DataIsolation(t *testing.T) {
 		t.Errorf("Expected same ETags for put and get results, got put: %s, get: %s", *putResult.ETag, *getResult.ETag)
 	}
 }
+
+func TestLocalToolCallStore_List(t *testing.T) {
+	store := NewToolCallStore(ctx)
+	created := time.Now()
+	for i, status := range []mcp.Status{mcp.StatusRunning, mcp.StatusSuccess, mcp.StatusRunning, mcp.StatusFailed, mcp.StatusRunning} {
+		tc := toolcall.New("test-tenant", "test-tool", fmt.Sprintf("id-%d", i))
+		tc.Status, tc.Created = aids.New(status), aids.New(created.Add(time.Duration(i)*time.Hour))
+		if se := store.Put(ctx, tc, svrcore.AccessConditions{}); se != nil {
+			t.Fatalf("Put failed: %v", se)
+		}
+	}
+	// Tool calls for another tenant & another tool must never be listed
+	for _, tc := range []*toolcall.Resource{toolcall.New("different-tenant", "test-tool", "id-0"), toolcall.New("test-tenant", "different-tool", "id-0")} {
+		if se := store.Put(ctx, tc, svrcore.AccessConditions{}); se != nil {
+			t.Fatalf("Put failed: %v", se)
+		}
+	}
+
+	ids := func(page *toolcall.ListPage) []string {
+		ids := []string{}
+		for _, tc := range page.ToolCalls {
+			ids = append(ids, *tc.ID)
+		}
+		return ids
+	}
+
+	t.Run("All", func(t *testing.T) {
+		page, se := store.List(ctx, "test-tenant", "test-tool", toolcall.ListOptions{})
+		if se != nil {
+			t.Fatalf("List failed: %v", se)
+		}
+		if actual := ids(page); !slices.Equal(actual, []string{"id-0", "id-1", "id-2", "id-3", "id-4"}) {
+			t.Errorf("Unexpected tool calls: %v", actual)
+		}
+		if page.ContinuationToken != nil {
+			t.Errorf("Expected no continuation token, got %s", *page.ContinuationToken)
+		}
+	})
+
+	t.Run("Status", func(t *testing.T) {
+		page, se := store.List(ctx, "test-tenant", "test-tool", toolcall.ListOptions{Status: aids.New(mcp.StatusRunning)})
+		if se != nil {
+			t.Fatalf("List failed: %v", se)
+		}
+		if actual := ids(page); !slices.Equal(actual, []string{"id-0", "id-2", "id-4"}) {
+			t.Errorf("Unexpected tool calls: %v", actual)
+		}
+	})
+
+	t.Run("CreatedAfter", func(t *testing.T) {
+		page, se := store.List(ctx, "test-tenant", "test-tool", toolcall.ListOptions{CreatedAfter: aids.New(created.Add(2 * time.Hour))})
+		if se != nil {
+			t.Fatalf("List failed: %v", se)
+		}
+		if actual := ids(page); !slices.Equal(actual, []string{"id-3", "id-4"}) {
+			t.Errorf("Unexpected tool calls: %v", actual)
+		}
+	})
+
+	t.Run("Paging", func(t *testing.T) {
+		o, actual := toolcall.ListOptions{Status: aids.New(mcp.StatusRunning), MaxResults: 2}, []string{}
+		for pages := 1; ; pages++ {
+			page, se := store.List(ctx, "test-tenant", "test-tool", o)
+			if se != nil {
+				t.Fatalf("List failed: %v", se)
+			}
+			if len(page.ToolCalls) > o.MaxResults {
+				t.Fatalf("Expected at most %d tool calls per page, got %d", o.MaxResults, len(page.ToolCalls))
+			}
+			actual = append(actual, ids(page)...)
+			if page.ContinuationToken == nil {
+				if pages != 2 {
+					t.Errorf("Expected 2 pages, got %d", pages)
+				}
+				break
+			}
+			o.ContinuationToken = page.ContinuationToken
+		}
+		if !slices.Equal(actual, []string{"id-0", "id-2", "id-4"}) {
+			t.Errorf("Unexpected tool calls: %v", actual)
+		}
+	})
+
+	t.Run("InvalidContinuationToken", func(t *testing.T) {
+		_, se := store.List(ctx, "test-tenant", "test-tool", toolcall.ListOptions{ContinuationToken: aids.New("!not-a-token!")})
+		if se == nil || se.StatusCode != 400 {
+			t.Errorf("Expected status code 400, got %v", se)
+		}
+	})
+}
+
+func TestLocalToolCallStore_Wait(t *testing.T) {
+	store := NewToolCallStore(ctx)
+	tc := toolcall.New("test-tenant", "test-tool", "test-id")
+	if se := store.Put(ctx, tc, svrcore.AccessConditions{}); se != nil {
+		t.Fatalf("Put failed: %v", se)
+	}
+	etag := *tc.ETag
+
+	t.Run("Changed", func(t *testing.T) {
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			updated := toolcall.New("test-tenant", "test-tool", "test-id")
+			updated.Status = aids.New(mcp.StatusSuccess)
+			store.Put(ctx, updated, svrcore.AccessConditions{})
+		}()
+		waited := toolcall.New("test-tenant", "test-tool", "test-id")
+		waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if se := store.Wait(waitCtx, waited, etag); se != nil {
+			t.Fatalf("Wait failed: %v", se)
+		}
+		if waited.ETag.Equals(etag) || *waited.Status != mcp.StatusSuccess {
+			t.Fatalf("Expected the updated tool call, got ETag %s & status %s", *waited.ETag, *waited.Status)
+		}
+		etag = *waited.ETag
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		se := store.Wait(waitCtx, toolcall.New("test-tenant", "test-tool", "test-id"), etag)
+		if se == nil || se.StatusCode != 304 {
+			t.Fatalf("Expected 304, got %v", se)
+		}
+	})
+
+	t.Run("AlreadyChanged", func(t *testing.T) {
+		if se := store.Wait(ctx, toolcall.New("test-tenant", "test-tool", "test-id"), "stale-etag"); se != nil {
+			t.Fatalf("Wait failed: %v", se)
+		}
+	})
+
+	t.Run("Deleted", func(t *testing.T) {
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			store.Delete(ctx, toolcall.New("test-tenant", "test-tool", "test-id"), svrcore.AccessConditions{})
+		}()
+		se := store.Wait(ctx, toolcall.New("test-tenant", "test-tool", "test-id"), etag)
+		if se == nil || se.StatusCode != 404 {
+			t.Fatalf("Expected 404, got %v", se)
+		}
+	})
+}
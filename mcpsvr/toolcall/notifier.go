@@ -0,0 +1,247 @@
+package toolcall
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+const (
+	// NotificationSignatureHeader is the webhook request header containing "t=<unix seconds>,v1=<hex HMAC-SHA256>".
+	// The HMAC is computed over "<unix seconds>.<request body>" using the tool call's notification secret.
+	NotificationSignatureHeader = "Mcp-Signature"
+
+	// NotificationSecretHeader is the PUT response header returning the secret a new tool call's notifications are
+	// signed with; it's returned only by the PUT so only the client creating the tool call can verify them.
+	NotificationSecretHeader = "Notification-Secret"
+
+	// NotificationIDHeader is the webhook request header containing the tool call's ETag; it is the same for
+	// every retry of a single notification so clients can ignore duplicates.
+	NotificationIDHeader = "Mcp-Notification-Id"
+)
+
+type (
+	// NotifierConfig configures a Notifier.
+	NotifierConfig struct {
+		// Logger for logging notifications that could not be delivered
+		ErrorLogger *slog.Logger
+
+		// Client sends the webhook requests; nil uses a client with a 10-second timeout that doesn't follow
+		// redirects or use a proxy & refuses to connect to loopback, private & link-local addresses
+		Client *http.Client
+
+		// AllowedHosts, if not empty, are the only notification URL hosts (ex: "hooks.example.com") accepted
+		AllowedHosts []string
+
+		// MaxAttempts is the maximum number of times a notification is sent; <= 0 uses 5
+		MaxAttempts int
+
+		// InitialBackoff is the delay before the 1st retry & doubles after each retry; <= 0 uses 1 second
+		InitialBackoff time.Duration
+	}
+
+	// Notifier POSTs a tool call's public-facing MCP representation to the client's notification URL.
+	Notifier struct {
+		ctx    context.Context // Canceling this abandons any notifications still being retried
+		config NotifierConfig
+	}
+)
+
+// NewNotifier creates a Notifier; ctx is used to cancel any in-progress retries
+func NewNotifier(ctx context.Context, c NotifierConfig) *Notifier {
+	if c.Client == nil {
+		c.Client = newNotificationClient()
+	}
+	c.MaxAttempts = aids.Iif(c.MaxAttempts > 0, c.MaxAttempts, 5)
+	c.InitialBackoff = aids.Iif(c.InitialBackoff > 0, c.InitialBackoff, time.Second)
+	return &Notifier{ctx: ctx, config: c}
+}
+
+// ValidateURL returns an error if rawURL can't be a notification URL: it must be an absolute https URL whose host is
+// in AllowedHosts (if any) & isn't a non-public IP address. Since a host name can resolve (or later be rebound) to a
+// non-public address, the default Client also checks each address it connects to.
+func (n *Notifier) ValidateURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if aids.IsError(err) || u.Scheme != "https" || u.Host == "" {
+		return nil, errors.New("must be an absolute https URL")
+	}
+	if len(n.config.AllowedHosts) > 0 && !slices.ContainsFunc(n.config.AllowedHosts, func(h string) bool { return strings.EqualFold(h, u.Hostname()) }) {
+		return nil, fmt.Errorf("host '%s' isn't allowed", u.Hostname())
+	}
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && !publicAddr(addr) {
+		return nil, fmt.Errorf("address '%s' isn't public", u.Hostname())
+	}
+	return u, nil
+}
+
+// Notify sends tc, signed with secret, to url on its own goroutine, retrying with exponential backoff on network
+// errors, 408, 429 & 5xx responses. Notifications are not ordered; clients should use the tool call's ETag/status.
+func (n *Notifier) Notify(url, secret string, tc mcp.ToolCall) {
+	body := aids.MustMarshal(tc)
+	id := ""
+	if tc.ETag != nil {
+		id = strings.Trim(*tc.ETag, `\"`) // ToMCP quotes the ETag for JSON; the header wants the raw value
+	}
+	go func() {
+		backoff := n.config.InitialBackoff
+		for attempt := 1; ; attempt++ {
+			retry, err := n.send(url, secret, id, body)
+			if err == nil {
+				return
+			}
+			if !retry || attempt == n.config.MaxAttempts {
+				n.config.ErrorLogger.LogAttrs(n.ctx, slog.LevelError, "Tool call notification failed",
+					slog.String("url", url), slog.String("id", id), slog.Int("attempts", attempt), slog.String("error", err.Error()))
+				return
+			}
+			select {
+			case <-n.ctx.Done():
+				return
+			case <-time.After(backoff):
+				backoff *= 2
+			}
+		}
+	}()
+}
+
+// send POSTs one notification attempt; it returns whether a failed attempt should be retried
+func (n *Notifier) send(url, secret, id string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(n.ctx, http.MethodPost, url, bytes.NewReader(body))
+	if aids.IsError(err) {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(NotificationIDHeader, id)
+	req.Header.Set(NotificationSignatureHeader, SignNotification([]byte(secret), time.Now(), body))
+	resp, err := n.config.Client.Do(req)
+	if aids.IsError(err) {
+		return n.ctx.Err() == nil, err
+	}
+	resp.Body.Close()
+	switch sc := resp.StatusCode; {
+	case sc >= 200 && sc < 300:
+		return false, nil
+	case sc == http.StatusRequestTimeout, sc == http.StatusTooManyRequests, sc >= 500:
+		return true, fmt.Errorf("notification URL returned %d", sc)
+	default:
+		return false, fmt.Errorf("notification URL returned %d", sc)
+	}
+}
+
+// newNotificationClient creates the default NotifierConfig.Client. It has no Proxy so its dialer sees (& checks) the
+// webhook's address & it doesn't follow redirects (a 3xx response fails the notification).
+func newNotificationClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: dialPublicOnly}
+	return &http.Client{
+		Timeout:       10 * time.Second,
+		Transport:     &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 10 * time.Second, ForceAttemptHTTP2: true},
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+// dialPublicOnly is a net.Dialer Control func refusing to connect to non-public addresses; checking the address
+// being dialed (not the URL) stops a host name resolving to the server's network (ex: via DNS rebinding)
+func dialPublicOnly(_, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if aids.IsError(err) {
+		return err
+	}
+	if !publicAddr(ap.Addr()) {
+		return fmt.Errorf("notification URL address '%s' isn't public", ap.Addr())
+	}
+	return nil
+}
+
+// publicAddr returns false for loopback, private, link-local (ex: cloud metadata), multicast & unspecified addresses
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap() // ex: ::ffff:127.0.0.1
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !addr.IsLoopback() && !addr.IsLinkLocalUnicast()
+}
+
+// NewNotificationSecret returns a random secret for signing a tool call's notifications.
+func NewNotificationSecret() string { return rand.Text() }
+
+// SignNotification returns the NotificationSignatureHeader value for body sent at time t.
+func SignNotification(key []byte, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(notificationMAC(key, ts, body))
+}
+
+// VerifyNotification verifies the NotificationSignatureHeader value for body & that it was signed
+// within tolerance of now. Clients receiving notifications call this, passing the NotificationSecretHeader value
+// returned when they created the tool call as key, to authenticate the server.
+func VerifyNotification(key []byte, signature string, body []byte, tolerance time.Duration) error {
+	ts, mac := "", []byte(nil)
+	for part := range strings.SplitSeq(signature, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			mac, _ = hex.DecodeString(v)
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if aids.IsError(err) || mac == nil {
+		return errors.New("malformed notification signature")
+	}
+	if d := time.Since(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+		return errors.New("notification signature timestamp outside tolerance")
+	}
+	if !hmac.Equal(mac, notificationMAC(key, ts, body)) {
+		return errors.New("notification signature mismatch")
+	}
+	return nil
+}
+
+func notificationMAC(key []byte, ts string, body []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(ts + "."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// notifyingStore wraps a Store sending a notification after every Put that changes a tool call's status
+type notifyingStore struct {
+	Store
+	n *Notifier
+}
+
+// NewNotifyingStore wraps s so that every successful Put changing the status of a tool call with a
+// NotificationURL sends the tool call, signed with its NotificationSecret, to that URL via n.
+func NewNotifyingStore(s Store, n *Notifier) Store { return &notifyingStore{Store: s, n: n} }
+
+func (s *notifyingStore) Put(ctx context.Context, tc *Resource, ac svrcore.AccessConditions) *svrcore.ServerError {
+	notify := tc.NotificationURL != nil && tc.NotificationSecret != nil && tc.Status != nil && (tc.NotifiedStatus == nil || *tc.NotifiedStatus != *tc.Status)
+	notifiedStatus := tc.NotifiedStatus
+	if notify { // Persisting the notified status with the tool call means only the Put winning an ETag race notifies
+		tc.NotifiedStatus = aids.New(*tc.Status)
+	}
+	if se := s.Store.Put(ctx, tc, ac); se != nil {
+		tc.NotifiedStatus = notifiedStatus
+		return se
+	}
+	if notify {
+		s.n.Notify(*tc.NotificationURL, *tc.NotificationSecret, tc.ToMCP())
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package toolcall
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+func TestNotificationSignature(t *testing.T) {
+	key, body := []byte("key"), []byte(`{"id":"1"}`)
+	sig := SignNotification(key, time.Now(), body)
+	if err := VerifyNotification(key, sig, body, time.Minute); aids.IsError(err) {
+		t.Fatal(err)
+	}
+	for name, tc := range map[string]struct {
+		key, body []byte
+		sig       string
+	}{
+		"WrongKey":  {key: []byte("other"), body: body, sig: sig},
+		"Tampered":  {key: key, body: []byte(`{"id":"2"}`), sig: sig},
+		"Stale":     {key: key, body: body, sig: SignNotification(key, time.Now().Add(-time.Hour), body)},
+		"Malformed": {key: key, body: body, sig: "v1=abc"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := VerifyNotification(tc.key, tc.sig, tc.body, time.Minute); err == nil {
+				t.Fatal("expected verification to fail")
+			}
+		})
+	}
+}
+
+func TestNotifierRetries(t *testing.T) {
+	attempts, done := atomic.Int32{}, make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 { // Fail the 1st 2 attempts
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body := aids.Must(io.ReadAll(r.Body))
+		if err := VerifyNotification([]byte("key"), r.Header.Get(NotificationSignatureHeader), body, time.Minute); aids.IsError(err) {
+			t.Error(err)
+		}
+		if id := r.Header.Get(NotificationIDHeader); id != "etag1" {
+			t.Errorf("expected notification ID 'etag1', got %q", id)
+		}
+		done <- body
+	}))
+	defer srv.Close()
+
+	n := NewNotifier(context.Background(), NotifierConfig{ErrorLogger: slog.Default(), Client: srv.Client(), InitialBackoff: time.Millisecond})
+	tc := New("tenant", "tool", "1")
+	tc.ETag = aids.New(svrcore.ETag("etag1"))
+	n.Notify(srv.URL, "key", tc.ToMCP())
+	select {
+	case body := <-done:
+		if got := aids.MustUnmarshal[mcp.ToolCall](body); *got.ID != "1" {
+			t.Fatalf("expected tool call ID '1', got %q", *got.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("notification not delivered after %d attempts", attempts.Load())
+	}
+	if a := attempts.Load(); a != 3 {
+		t.Fatalf("expected 3 attempts, got %d", a)
+	}
+}
+
+func TestNotifierGivesUpOnClientError(t *testing.T) {
+	attempts := atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	n := NewNotifier(context.Background(), NotifierConfig{ErrorLogger: slog.Default(), Client: srv.Client(), InitialBackoff: time.Millisecond})
+	n.Notify(srv.URL, "key", New("tenant", "tool", "1").ToMCP())
+	time.Sleep(200 * time.Millisecond)
+	if a := attempts.Load(); a != 1 {
+		t.Fatalf("expected 1 attempt, got %d", a)
+	}
+}
+
+func TestNotifierValidateURL(t *testing.T) {
+	n := NewNotifier(context.Background(), NotifierConfig{ErrorLogger: slog.Default()})
+	allowed := NewNotifier(context.Background(), NotifierConfig{ErrorLogger: slog.Default(), AllowedHosts: []string{"hooks.example.com"}})
+	for name, tc := range map[string]struct {
+		n     *Notifier
+		url   string
+		valid bool
+	}{
+		"Https":          {n: n, url: "https://example.com/hook", valid: true},
+		"PublicIP":       {n: n, url: "https://203.0.113.1/hook", valid: true},
+		"Http":           {n: n, url: "http://example.com/hook"},
+		"Relative":       {n: n, url: "/hook"},
+		"Loopback":       {n: n, url: "https://127.0.0.1/hook"},
+		"LoopbackIPv6":   {n: n, url: "https://[::1]/hook"},
+		"MappedLoopback": {n: n, url: "https://[::ffff:127.0.0.1]/hook"},
+		"Private":        {n: n, url: "https://192.168.1.1/hook"},
+		"LinkLocal":      {n: n, url: "https://169.254.169.254/hook"},
+		"Unspecified":    {n: n, url: "https://0.0.0.0/hook"},
+		"AllowedHost":    {n: allowed, url: "https://HOOKS.example.com/hook", valid: true},
+		"DisallowedHost": {n: allowed, url: "https://example.com/hook"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := tc.n.ValidateURL(tc.url); (err == nil) != tc.valid {
+				t.Fatalf("expected valid=%t, got %v", tc.valid, err)
+			}
+		})
+	}
+}
+
+func TestNotifierRefusesNonPublicAddresses(t *testing.T) {
+	attempts := atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { attempts.Add(1) }))
+	defer srv.Close()
+
+	// The default Client checks the dialed address so a host name resolving to a loopback address is refused too
+	n := NewNotifier(context.Background(), NotifierConfig{ErrorLogger: slog.Default()})
+	for _, u := range []string{srv.URL, strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)} {
+		if _, err := n.send(u, "key", "", []byte("{}")); err == nil || !strings.Contains(err.Error(), "isn't public") {
+			t.Fatalf("expected %s to be refused, got %v", u, err)
+		}
+	}
+	if a := attempts.Load(); a != 0 {
+		t.Fatalf("expected no requests, got %d", a)
+	}
+}
@@ -0,0 +1,36 @@
+package toolcall
+
+import (
+	"context"
+	"time"
+
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+// DefaultProgressInterval is the default minimum time between persisting a tool call's progress reports
+const DefaultProgressInterval = time.Second
+
+// ProgressThrottle implements [PhaseProcessor.ReportProgress] for a PhaseProcessor processing one tool call
+// by persisting the tool call at most once per Interval; progress reporting completion is always persisted.
+type ProgressThrottle struct {
+	Store    Store
+	Interval time.Duration // 0 uses DefaultProgressInterval
+	last     time.Time     // When progress was last persisted
+}
+
+func (pt *ProgressThrottle) ReportProgress(ctx context.Context, tc *Resource, p mcp.Progress) *svrcore.ServerError {
+	tc.Progress = &p
+	interval := pt.Interval
+	if interval == 0 {
+		interval = DefaultProgressInterval
+	}
+	if time.Since(pt.last) < interval && !p.Done() {
+		return nil // Throttled; the progress is persisted with the next report or the phase's Put
+	}
+	if se := pt.Store.Put(ctx, tc, svrcore.AccessConditions{IfMatch: tc.ETag}); se != nil {
+		return se
+	}
+	pt.last = time.Now()
+	return nil
+}
@@ -0,0 +1,29 @@
+package toolcall
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+)
+
+func TestProgressThrottle(t *testing.T) {
+	store := &putRecordingStore{}
+	pt := &ProgressThrottle{Store: store, Interval: time.Hour}
+	tc := New("tenant", "tool", "1")
+	for i := range 5 {
+		if se := pt.ReportProgress(context.Background(), tc, mcp.Progress{Progress: float64(i), Total: aids.New(5.0)}); se != nil {
+			t.Fatalf("ReportProgress failed: %v", se)
+		}
+	}
+	if len(store.puts) != 1 || store.puts[0].Progress.Progress != 0 || tc.Progress.Progress != 4 {
+		t.Fatalf("expected only the 1st report persisted & the last report set, got %s", aids.MustMarshal(store.puts))
+	}
+
+	// Completion is always persisted
+	if se := pt.ReportProgress(context.Background(), tc, mcp.Progress{Progress: 5, Total: aids.New(5.0)}); se != nil || len(store.puts) != 2 {
+		t.Fatalf("expected completion persisted, got %v, %s", se, aids.MustMarshal(store.puts))
+	}
+}
@@ -0,0 +1,86 @@
+package toolcall
+
+import (
+	"context"
+	"sync"
+)
+
+// RunningPhases tracks the tool calls whose phases are running in this process so that a PhaseMgr can cancel
+// a tool call's running phase. The zero value is ready to use.
+type RunningPhases struct {
+	mu      sync.Mutex
+	running map[string]*runningPhase // Tool call Identity.Key -> its running phase
+	active  int                      // The number of Starts whose stop func hasn't been called (Cancel doesn't stop a phase)
+	idle    chan struct{}            // Closed when active drops to 0; nil while active is 0
+}
+
+type runningPhase struct{ cancel context.CancelFunc }
+
+// Start returns the context to run tc's phases with & a func that must be called when the phases stop running.
+// The context is canceled by Cancel or when parent is done.
+func (rp *RunningPhases) Start(parent context.Context, tc *Resource) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+	key, p := tc.Key(), &runningPhase{cancel: cancel}
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if rp.running == nil {
+		rp.running = map[string]*runningPhase{}
+	}
+	rp.running[key] = p
+	if rp.active++; rp.idle == nil {
+		rp.idle = make(chan struct{})
+	}
+	stopped := false
+	return ctx, func() {
+		cancel()
+		rp.mu.Lock()
+		defer rp.mu.Unlock()
+		if stopped { // The func may be called more than once; only the first call stops tracking the phase
+			return
+		}
+		stopped = true
+		if rp.running[key] == p { // A newer Start for the same tool call may have replaced p
+			delete(rp.running, key)
+		}
+		if rp.active--; rp.active == 0 {
+			close(rp.idle)
+			rp.idle = nil
+		}
+	}
+}
+
+// Cancel cancels the context of tc's running phase; it returns false if tc has no phase running in this process.
+func (rp *RunningPhases) Cancel(tc *Resource) bool {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	p, ok := rp.running[tc.Key()]
+	if ok {
+		p.cancel()
+		delete(rp.running, tc.Key())
+	}
+	return ok
+}
+
+// Len returns the number of tool calls whose phases are running in this process.
+func (rp *RunningPhases) Len() int {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	return len(rp.running)
+}
+
+// Wait waits until every Start's stop func has been called (ex: the phases checkpointed & returned after their
+// contexts were canceled by a shutdown); it returns ctx's error if ctx is done first.
+func (rp *RunningPhases) Wait(ctx context.Context) error {
+	rp.mu.Lock()
+	idle := rp.idle
+	rp.mu.Unlock()
+	if idle == nil {
+		return nil
+	}
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -5,180 +5,127 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"strings"
 	"time"
 
 	"github.com/JeffreyRichter/internal/aids"
 )
 
-func NewServerDataEncoder(encryptionKeyHex string) *ServerDataEncoder {
-	key := aids.Must(hex.DecodeString(encryptionKeyHex))
-	aids.Assert(len(key) == 256/8, "encryption key must be 32 bytes for AES-256")
-	return &ServerDataEncoder{
-		encryptionKey: key,
-		ivLength:      16,
-		dataTTLMs:     (time.Minute * 5).Milliseconds(),
-	}
-}
+// Errors returned by [ServerDataEncoder.Decode]; use errors.Is to test for them.
+var (
+	ErrServerDataExpired    = errors.New("server data expired")
+	ErrServerDataTampered   = errors.New("server data is malformed or was tampered with")
+	ErrServerDataUnknownKey = errors.New("server data was encrypted with an unknown key")
+)
+
+// serverDataVersion is the version of the encoded server data format:
+//
+//	version (1 byte) | key ID length (1 byte) | key ID | expiration (8-byte big-endian Unix ms) | nonce | ciphertext+tag
+//
+// Everything before the nonce is the header which is authenticated (but not encrypted) as AES-GCM additional data.
+const serverDataVersion = 1
 
 type (
-	ServerDataEncoder struct {
-		encryptionKey []byte
-		ivLength      int
-		dataTTLMs     int64 // Time-to-Live (milliseconds)
+	// ServerDataKey is an AES-256 key used to encrypt & authenticate serverData. Its ID is embedded in the
+	// encoded serverData so the key that encrypted it can be found after the keys are rotated.
+	ServerDataKey struct {
+		ID  string // 1-255 bytes
+		Key []byte // 32 bytes
 	}
 
-	secureServerData struct {
-		Data      []byte `json:"data"`
-		Timestamp int64  `json:"timestamp"`
-		Nonce     string `json:"nonce"`
+	// ServerDataEncoder encrypts & authenticates (AES-256-GCM) opaque state that's round-tripped via clients.
+	// Its keyring's first key encrypts; all keys decrypt so data encrypted with rotated-out keys remains valid
+	// until the keys are removed.
+	ServerDataEncoder struct {
+		keys   map[string]cipher.AEAD // Key ID to AEAD
+		newest string                 // ID of the key that encrypts
 	}
 )
 
-func (sdc *ServerDataEncoder) Encode(clearData []byte) string {
-	// Create cipher block
-	block := aids.Must(aes.NewCipher(sdc.encryptionKey))
-
-	// Generate IV
-	iv := make([]byte, sdc.ivLength)
-	aids.Must(io.ReadFull(rand.Reader, iv))
-
-	// Generate nonce
-	nonceBytes := make([]byte, 16)
-	aids.Must(rand.Read(nonceBytes))
-
-	ssd := secureServerData{
-		Data:      clearData,
-		Timestamp: time.Now().UnixMilli(),
-		Nonce:     hex.EncodeToString(nonceBytes),
+// ParseServerDataKeys parses a comma-separated list of "keyID:hexKey" pairs (newest key first) into ServerDataKeys.
+func ParseServerDataKeys(s string) ([]ServerDataKey, error) {
+	keys := []ServerDataKey{}
+	for pair := range strings.SplitSeq(s, ",") {
+		id, hexKey, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || id == "" || len(id) > 255 {
+			return nil, fmt.Errorf("server data key %q must look like keyID:hexKey with a 1-255 byte key ID", pair)
+		}
+		key, err := hex.DecodeString(hexKey)
+		if aids.IsError(err) || len(key) != 256/8 {
+			return nil, fmt.Errorf("server data key %q must be 64 hex digits (32 bytes for AES-256)", id)
+		}
+		for _, k := range keys {
+			if k.ID == id {
+				return nil, fmt.Errorf("server data key ID %q is duplicated", id)
+			}
+		}
+		keys = append(keys, ServerDataKey{ID: id, Key: key})
 	}
-
-	// Marshal ssd to JSON
-	payload := aids.MustMarshal(ssd)
-
-	// Apply PKCS7 padding
-	paddedPayload := pkcs7Pad(payload, aes.BlockSize)
-
-	// Encrypt
-	mode := cipher.NewCBCEncrypter(block, iv)
-	encrypted := make([]byte, len(paddedPayload))
-	mode.CryptBlocks(encrypted, paddedPayload)
-
-	// Concatenate IV + encrypted data
-	result := append(iv, encrypted...)
-	return base64.StdEncoding.EncodeToString(result)
+	return keys, nil
 }
 
-// PKCS7 padding functions
-func pkcs7Pad(data []byte, blockSize int) []byte {
-	padding := blockSize - (len(data) % blockSize)
-	padText := make([]byte, padding)
-	for i := range padText {
-		padText[i] = byte(padding)
-	}
-	return append(data, padText...)
+// NewServerDataEncoder creates a ServerDataEncoder whose keyring is keys; keys[0] encrypts & all keys decrypt.
+// It panics if no keys are passed, a key ID is duplicated, or any key isn't valid for AES-256.
+func NewServerDataEncoder(keys ...ServerDataKey) *ServerDataEncoder {
+	aids.Assert(len(keys) > 0, "at least 1 server data key is required")
+	sdc := &ServerDataEncoder{keys: map[string]cipher.AEAD{}, newest: keys[0].ID}
+	for _, k := range keys {
+		aids.Assert(k.ID != "" && len(k.ID) <= 255, "server data key ID must be 1-255 bytes")
+		aids.Assert(len(k.Key) == 256/8, "encryption key must be 32 bytes for AES-256")
+		_, dup := sdc.keys[k.ID]
+		aids.Assert(!dup, fmt.Errorf("server data key ID %q is duplicated", k.ID))
+		sdc.keys[k.ID] = aids.Must(cipher.NewGCM(aids.Must(aes.NewCipher(k.Key))))
+	}
+	return sdc
 }
 
-func (sdc *ServerDataEncoder) Decode(cipherText string) ([]byte, error) {
-	// Decode base64
-	buffer, err := base64.StdEncoding.DecodeString(cipherText)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode base64: %w", err)
-	}
-	if len(buffer) < sdc.ivLength {
-		return nil, errors.New("invalid data: too short")
-	}
-
-	// Extract IV and encrypted data
-	iv := buffer[:sdc.ivLength]
-	encrypted := buffer[sdc.ivLength:]
-
-	// Create cipher block
-	block := aids.Must(aes.NewCipher(sdc.encryptionKey))
-
-	// Decrypt
-	if len(encrypted)%aes.BlockSize != 0 {
-		return nil, errors.New("encrypted data is not a multiple of block size")
-	}
-
-	mode := cipher.NewCBCDecrypter(block, iv)
-	decrypted := make([]byte, len(encrypted))
-	mode.CryptBlocks(decrypted, encrypted)
-
-	// Remove PKCS7 padding
-	unpadded := aids.Must(pkcs7Unpad(decrypted))
+// Encode encrypts clearData with the newest key; the returned string can be decoded for ttl.
+func (sdc *ServerDataEncoder) Encode(clearData []byte, ttl time.Duration) string {
+	aead := sdc.keys[sdc.newest]
+	header := append([]byte{serverDataVersion, byte(len(sdc.newest))}, sdc.newest...)
+	header = binary.BigEndian.AppendUint64(header, uint64(time.Now().Add(ttl).UnixMilli()))
 
-	// Unmarshal JSON
-	var ssd secureServerData
-	if err := json.Unmarshal(unpadded, &ssd); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal context: %w", err)
-	}
-
-	// Validate timestamp
-	if time.Now().UnixMilli()-ssd.Timestamp > sdc.dataTTLMs {
-		return nil, errors.New("data expired")
-	}
-	return ssd.Data, nil
+	nonce := make([]byte, aead.NonceSize())
+	aids.Must(rand.Read(nonce))
+	b := append(append([]byte{}, header...), nonce...) // The additional data (header) must not overlap Seal's dst
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(b, nonce, clearData, header))
 }
 
-func pkcs7Unpad(data []byte) ([]byte, error) {
-	if len(data) == 0 {
-		return nil, errors.New("data is empty")
-	}
-	padding := int(data[len(data)-1])
-	if padding > len(data) || padding > aes.BlockSize {
-		return nil, errors.New("invalid padding")
-	}
-	for i := len(data) - padding; i < len(data); i++ {
-		if data[i] != byte(padding) {
-			return nil, errors.New("invalid padding")
-		}
+// Decode authenticates & decrypts cipherText returned from Encode. It returns an error wrapping
+// ErrServerDataTampered, ErrServerDataUnknownKey, or ErrServerDataExpired if the data was malformed or modified,
+// was encrypted with a key not in the keyring, or its TTL elapsed.
+func (sdc *ServerDataEncoder) Decode(cipherText string) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cipherText)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid base64", ErrServerDataTampered)
 	}
-	return data[:len(data)-padding], nil
-}
-
-/*
-type serverDataConverter struct {
-	secretKey []byte
-}
-
-func (sdc *serverDataConverter) Encode(data []byte) *string {
-	if data == nil {
-		return nil
+	if len(b) < 2 || b[0] != serverDataVersion {
+		return nil, fmt.Errorf("%w: unsupported version", ErrServerDataTampered)
 	}
-	h := hmac.New(sha256.New, sdc.secretKey) // Compute HMAC for data
-	h.Write(data)
-	mac := h.Sum(nil)
-	data = append(mac, data...)                              // Prepend HMAC to data
-	return aids.New(base64.StdEncoding.EncodeToString(data)) // Encode to base-64 string
-}
-
-func (sdc *serverDataConverter) Decode(serverData *string) (*Resource, error) {
-	if serverData == nil {
-		return nil, fmt.Errorf("serverData is nil")
+	headerLen := 2 + int(b[1]) + 8
+	if len(b) < headerLen {
+		return nil, fmt.Errorf("%w: too short", ErrServerDataTampered)
 	}
-	data, err := base64.StdEncoding.DecodeString(*serverData) // Decode from base-64 string
-	if aids.IsError(err) {
-		return nil, err
+	header, id := b[:headerLen], string(b[2:2+int(b[1])])
+	aead, ok := sdc.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: key ID %q", ErrServerDataUnknownKey, id)
 	}
-	// Split HMAC from data
-	h := hmac.New(sha256.New, sdc.secretKey)
-	if len(data) < h.Size() {
-		return nil, fmt.Errorf("serverData must be at least %v bytes (after base-64 decoding)", h.Size())
+	if len(b) < headerLen+aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("%w: too short", ErrServerDataTampered)
 	}
-	h.Write(data[h.Size():]) // Compute HMAC for data portion
-	mac := h.Sum(nil)
-	if !hmac.Equal(data[:h.Size()], mac) { // Compare HMACs
-		return nil, fmt.Errorf("serverData integrity check failed (after base-64 decoding)")
+	nonce, sealed := b[headerLen:headerLen+aead.NonceSize()], b[headerLen+aead.NonceSize():]
+	clearData, err := aead.Open(nil, nonce, sealed, header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: authentication failed", ErrServerDataTampered)
 	}
-	var resource Resource
-	if err := json.Unmarshal(data[h.Size():], &resource); err != nil {
-		return nil, err
+	// The expiration is checked only after it's authenticated
+	if expiration := time.UnixMilli(int64(binary.BigEndian.Uint64(header[headerLen-8:]))); time.Now().After(expiration) {
+		return nil, fmt.Errorf("%w at %s", ErrServerDataExpired, expiration.Format(time.RFC3339))
 	}
-	return &resource, nil
+	return clearData, nil
 }
-*/
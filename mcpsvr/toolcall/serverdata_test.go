@@ -1,24 +1,152 @@
 package toolcall
 
 import (
-	"fmt"
+	"bytes"
+	"encoding/base64"
+	"errors"
 	"testing"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+)
+
+const (
+	testKeyA = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	testKeyB = "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
 )
 
+func newTestServerDataEncoder(t testing.TB, keys string) *ServerDataEncoder {
+	t.Helper()
+	k, err := ParseServerDataKeys(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewServerDataEncoder(k...)
+}
+
 func TestServerDataEncoder(t *testing.T) {
 	// Generate a 32-byte (256-bit) key for AES-256
-	key := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
-	sdc := NewServerDataEncoder(key)
-	encoded := sdc.Encode([]byte("Jeffrey Richter"))
-	fmt.Println("Encoded:", encoded)
+	sdc := newTestServerDataEncoder(t, "k1:"+testKeyA)
+	encoded := sdc.Encode([]byte("Jeffrey Richter"), time.Minute)
 
 	// Decode state
 	decoded, err := sdc.Decode(encoded)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Println("Decoded: ", decoded)
 	if string(decoded) != "Jeffrey Richter" {
 		t.Fatal("Decoded value does not match original")
 	}
 }
+
+func TestServerDataEncoderExpired(t *testing.T) {
+	sdc := newTestServerDataEncoder(t, "k1:"+testKeyA)
+	if _, err := sdc.Decode(sdc.Encode([]byte("stale"), -time.Millisecond)); !errors.Is(err, ErrServerDataExpired) {
+		t.Fatalf("expected ErrServerDataExpired, got %v", err)
+	}
+}
+
+func TestServerDataEncoderKeyRotation(t *testing.T) {
+	encodedWithOld := newTestServerDataEncoder(t, "old:"+testKeyA).Encode([]byte("before rotation"), time.Minute)
+
+	sdc := newTestServerDataEncoder(t, "new:"+testKeyB+", old:"+testKeyA)
+	if decoded, err := sdc.Decode(encodedWithOld); err != nil || string(decoded) != "before rotation" {
+		t.Fatalf("expected data encrypted with the old key to decode after rotation, got %q, %v", decoded, err)
+	}
+	// The newest key encrypts so the data can't be decoded by an encoder knowing only the old key
+	if _, err := newTestServerDataEncoder(t, "old:"+testKeyA).Decode(sdc.Encode([]byte("after rotation"), time.Minute)); !errors.Is(err, ErrServerDataUnknownKey) {
+		t.Fatalf("expected the newest key to encrypt, got %v", err)
+	}
+
+	// Once the old key is retired, its data is rejected
+	if _, err := newTestServerDataEncoder(t, "new:"+testKeyB).Decode(encodedWithOld); !errors.Is(err, ErrServerDataUnknownKey) {
+		t.Fatalf("expected ErrServerDataUnknownKey for data encrypted with a retired key, got %v", err)
+	}
+}
+
+func TestServerDataEncoderTampered(t *testing.T) {
+	sdc := newTestServerDataEncoder(t, "a:"+testKeyA+",b:"+testKeyB)
+	raw := aids.Must(base64.RawURLEncoding.DecodeString(sdc.Encode([]byte("secret"), time.Minute)))
+
+	swappedKey := bytes.Clone(raw)
+	swappedKey[2] = 'b' // The key ID is authenticated so the data can't be attributed to another key
+	extended := bytes.Clone(raw)
+	extended[len(extended)-20]++ // The expiration is authenticated so it can't be extended
+	for name, tampered := range map[string][]byte{
+		"flipped byte": append(bytes.Clone(raw[:len(raw)-1]), raw[len(raw)-1]^1),
+		"swapped key":  swappedKey,
+		"version":      append([]byte{serverDataVersion + 1}, raw[1:]...),
+		"truncated":    raw[:10],
+		"empty":        {},
+	} {
+		if _, err := sdc.Decode(base64.RawURLEncoding.EncodeToString(tampered)); !errors.Is(err, ErrServerDataTampered) {
+			t.Errorf("%s: expected ErrServerDataTampered, got %v", name, err)
+		}
+	}
+	if _, err := sdc.Decode("not base64!"); !errors.Is(err, ErrServerDataTampered) {
+		t.Errorf("expected ErrServerDataTampered for invalid base64, got %v", err)
+	}
+}
+
+func TestParseServerDataKeys(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"nokey",
+		":" + testKeyA,
+		"k1:0123",
+		"k1:not-hex",
+		"k1:" + testKeyA + ",k1:" + testKeyB,
+	} {
+		if _, err := ParseServerDataKeys(s); err == nil {
+			t.Errorf("expected %q to fail parsing", s)
+		}
+	}
+}
+
+func FuzzServerDataEncoderRoundTrip(f *testing.F) {
+	sdc := newTestServerDataEncoder(f, "k1:"+testKeyA)
+	f.Add([]byte("Jeffrey Richter"))
+	f.Add([]byte{})
+	f.Add([]byte(`{"tenant":"t","toolname":"welcome","id":"1"}`))
+	f.Fuzz(func(t *testing.T, clearData []byte) {
+		decoded, err := sdc.Decode(sdc.Encode(clearData, time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, clearData) {
+			t.Fatalf("decoded %q, want %q", decoded, clearData)
+		}
+	})
+}
+
+func FuzzServerDataEncoderTampered(f *testing.F) {
+	sdc := newTestServerDataEncoder(f, "k1:"+testKeyA+",k2:"+testKeyB)
+	f.Add([]byte("Jeffrey Richter"), 0, byte(1))
+	f.Add([]byte{}, 3, byte(0x80))
+	f.Add([]byte("secret"), 50, byte(0xff))
+	f.Fuzz(func(t *testing.T, clearData []byte, pos int, mask byte) {
+		if mask == 0 {
+			return // Not a modification
+		}
+		raw := aids.Must(base64.RawURLEncoding.DecodeString(sdc.Encode(clearData, time.Minute)))
+		pos = ((pos % len(raw)) + len(raw)) % len(raw)
+		raw[pos] ^= mask
+		_, err := sdc.Decode(base64.RawURLEncoding.EncodeToString(raw))
+		if !errors.Is(err, ErrServerDataTampered) && !errors.Is(err, ErrServerDataUnknownKey) {
+			t.Fatalf("modifying byte %d with mask %#x: expected ErrServerDataTampered or ErrServerDataUnknownKey, got %v", pos, mask, err)
+		}
+	})
+}
+
+func FuzzServerDataEncoderDecode(f *testing.F) {
+	sdc := newTestServerDataEncoder(f, "k1:"+testKeyA)
+	f.Add(sdc.Encode([]byte("Jeffrey Richter"), time.Minute))
+	f.Add("")
+	f.Add("AQJrMQ")
+	f.Fuzz(func(t *testing.T, cipherText string) {
+		// Arbitrary input must never panic & must fail with one of the documented errors
+		if _, err := sdc.Decode(cipherText); err != nil && !errors.Is(err, ErrServerDataTampered) && !errors.Is(err, ErrServerDataUnknownKey) && !errors.Is(err, ErrServerDataExpired) {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+}
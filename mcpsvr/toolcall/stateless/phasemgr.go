@@ -0,0 +1,62 @@
+package stateless
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/JeffreyRichter/mcpsvr/toolcall"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+type PhaseMgrConfig struct {
+	// Logger for logging error events
+	ErrorLogger *slog.Logger
+
+	// ToolNameToProcessPhaseFunc converts a Tool Name to a function that processes its phases.
+	toolcall.ToolNameToProcessPhaseFunc
+
+	// Metrics receives the number of tool calls whose phases are running (the queue depth).
+	Metrics toolcall.PhaseMgrMetrics
+}
+
+type phaseMgr struct {
+	tcs     toolcall.Store
+	config  PhaseMgrConfig
+	running atomic.Int64 // The number of tool calls whose phases are running
+}
+
+// NewPhaseMgr creates a [toolcall.PhaseMgr] that processes phases inline; tcs persists progress reports.
+// Since nothing is stored, no other request can observe or resume a phase so the phases run before the
+// response is written & the response's serverData holds the tool call's resulting state.
+func NewPhaseMgr(tcs toolcall.Store, o PhaseMgrConfig) toolcall.PhaseMgr {
+	return &phaseMgr{tcs: tcs, config: o}
+}
+
+// StartPhase processes the tool call's phases (updating tc) until the tool call needs client input or terminates.
+// Phases run with ctx so they stop if the client goes away.
+func (pm *phaseMgr) StartPhase(ctx context.Context, tc *toolcall.Resource) *svrcore.ServerError {
+	pm.config.Metrics.ReportQueueDepth(int(pm.running.Add(1)))
+	defer func() { pm.config.Metrics.ReportQueueDepth(int(pm.running.Add(-1))) }()
+	pp := &phaseProcessor{ProgressThrottle: toolcall.ProgressThrottle{Store: pm.tcs}}
+	tnpp := pm.config.ToolNameToProcessPhaseFunc(*tc.ToolName) // Error can't happen here because tool call was validated earlier
+	for (*tc.Status).Processing() && ctx.Err() == nil {        // Loop while tool call is server processing & client is waiting
+		tnpp(ctx, pp, tc) // Transition tool call from current phase to next phase
+	}
+	return nil
+}
+
+// CancelPhase does nothing; a phase runs only during the request that started it.
+func (*phaseMgr) CancelPhase(_ context.Context, _ *toolcall.Resource) {}
+
+// Drain does nothing; phases run only during requests so waiting for in-flight requests waits for them.
+func (*phaseMgr) Drain(_ context.Context) error { return nil }
+
+// phaseProcessor implements toolcall.PhaseProcessor for one tool call's phases
+type phaseProcessor struct {
+	toolcall.ProgressThrottle
+}
+
+// ExtendTime extends the time a phase is allowed to run; stateless phases are limited by the request's lifetime.
+func (*phaseProcessor) ExtendTime(_ context.Context, _ time.Duration) {}
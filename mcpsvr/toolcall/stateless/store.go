@@ -0,0 +1,123 @@
+// Package stateless implements tool calls for deployments without durable storage: instead of being stored, each
+// tool call is encrypted into the serverData returned to the client which sends it back to advance or cancel the call.
+package stateless
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcpsvr/toolcall"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+// defaultTTL is how long the serverData of a tool call without an Expiration can be decoded
+const defaultTTL = 24 * time.Hour
+
+// statelessToolCallStore is a [toolcall.Store] that stores nothing; Put encrypts the tool call into its ServerData
+type statelessToolCallStore struct {
+	sde *toolcall.ServerDataEncoder
+
+	mu        sync.Mutex
+	created   map[string]time.Time // The keys of tool calls created by this process & when their serverData expires
+	nextSweep time.Time            // When expired keys are next removed from created
+}
+
+// NewToolCallStore creates a [toolcall.Store] that encodes tool calls into their ServerData using sde.
+// Get, Wait & Delete can't find tool calls; the caller reconstructs them from client-supplied serverData via Decode.
+// Since a retried PUT can't get the original tool call, creating a tool call ID this process already created (until its
+// serverData expires) fails with 409-Conflict instead of running the tool again.
+func NewToolCallStore(sde *toolcall.ServerDataEncoder) toolcall.Store {
+	return &statelessToolCallStore{sde: sde, created: map[string]time.Time{}}
+}
+
+// Put checks ac against tc's current ETag (nil for a new tool call), assigns a new ETag & sets tc.ServerData.
+// When creating a tool call (if-none-match: *), it returns 409-Conflict if this process already created the tool call ID.
+func (s *statelessToolCallStore) Put(_ context.Context, tc *toolcall.Resource, ac svrcore.AccessConditions) *svrcore.ServerError {
+	se := svrcore.CheckPreconditions(svrcore.ResourceValues{AllowedConditionals: svrcore.AllowedConditionalsMatch, ETag: tc.ETag}, http.MethodPut, ac)
+	if se != nil {
+		return se
+	}
+	ttl := defaultTTL
+	if tc.Expiration != nil {
+		ttl = time.Until(*tc.Expiration) // The serverData expires with the tool call
+	}
+	if ac.IfNoneMatch != nil && *ac.IfNoneMatch == svrcore.ETagAny {
+		if !s.create(tc.Key(), time.Now().Add(ttl)) {
+			return svrcore.NewServerError(http.StatusConflict, "Conflict",
+				"Tool call ID already exists; this server is stateless so the tool call can't be returned again, use a new tool call ID")
+		}
+	}
+	tc.ETag = aids.New(svrcore.ETag(time.Now().Format("20060102150405.000000")))
+	tc.ServerData = aids.New(s.sde.Encode(aids.MustMarshal(tc), ttl)) // ServerData itself isn't marshaled
+	return nil
+}
+
+// create records that the tool call identified by key was created & its serverData expires at expiration;
+// it returns false if the tool call was already created & its serverData hasn't expired.
+func (s *statelessToolCallStore) create(key string, expiration time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.After(s.nextSweep) { // Forget expired tool calls so created doesn't grow forever
+		for k, e := range s.created {
+			if now.After(e) {
+				delete(s.created, k)
+			}
+		}
+		s.nextSweep = now.Add(time.Minute)
+	}
+	if e, ok := s.created[key]; ok && !now.After(e) {
+		return false
+	}
+	s.created[key] = expiration
+	return true
+}
+
+func (*statelessToolCallStore) Get(_ context.Context, _ *toolcall.Resource, _ svrcore.AccessConditions) *svrcore.ServerError {
+	return notFound()
+}
+
+func (*statelessToolCallStore) Delete(_ context.Context, _ *toolcall.Resource, _ svrcore.AccessConditions) *svrcore.ServerError {
+	return nil // Nothing is stored
+}
+
+func (*statelessToolCallStore) List(_ context.Context, _, _ string, _ toolcall.ListOptions) (*toolcall.ListPage, *svrcore.ServerError) {
+	return &toolcall.ListPage{ToolCalls: []*toolcall.Resource{}}, nil
+}
+
+func (*statelessToolCallStore) Wait(_ context.Context, _ *toolcall.Resource, _ svrcore.ETag) *svrcore.ServerError {
+	return notFound()
+}
+
+func notFound() *svrcore.ServerError {
+	return svrcore.NewServerError(http.StatusNotFound, "NotFound", "Tool call not found; this server is stateless so tool calls are only available via serverData")
+}
+
+// Decode reconstructs the tool call identified by id from serverData returned by a stateless Store's Put.
+// It returns a 404 [svrcore.ServerError] if the tool call expired or a 400 [svrcore.ServerError] if serverData was
+// tampered with, was encrypted with a key that's been retired, or is for a different tool call.
+func Decode(sde *toolcall.ServerDataEncoder, id toolcall.Identity, serverData string) (*toolcall.Resource, *svrcore.ServerError) {
+	b, err := sde.Decode(serverData)
+	switch {
+	case errors.Is(err, toolcall.ErrServerDataExpired):
+		return nil, notFound()
+	case errors.Is(err, toolcall.ErrServerDataUnknownKey):
+		return nil, svrcore.NewServerError(http.StatusBadRequest, "InvalidServerData", "serverData's key has been retired; the tool call must be restarted")
+	case aids.IsError(err):
+		return nil, svrcore.NewServerError(http.StatusBadRequest, "InvalidServerData", "serverData is invalid: %s", err.Error())
+	}
+	tc := &toolcall.Resource{}
+	if err := json.Unmarshal(b, tc); aids.IsError(err) || tc.Tenant == nil || tc.ToolName == nil || tc.ID == nil || tc.Status == nil {
+		return nil, svrcore.NewServerError(http.StatusBadRequest, "InvalidServerData", "serverData doesn't contain a tool call")
+	}
+	if tc.Key() != id.Key() {
+		return nil, svrcore.NewServerError(http.StatusBadRequest, "InvalidServerData", "serverData is for a different tool call")
+	}
+	tc.ServerData = &serverData
+	return tc, nil
+}
@@ -0,0 +1,92 @@
+package stateless
+
+import (
+	"context"
+	"encoding/json/jsontext"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/mcpsvr/toolcall"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+var (
+	ctx = context.Background()
+	sde = toolcall.NewServerDataEncoder(aids.Must(toolcall.ParseServerDataKeys("k1:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"))...)
+)
+
+func TestStatelessToolCallStore_Put_and_Decode(t *testing.T) {
+	store := NewToolCallStore(sde)
+	tc := toolcall.New("test-tenant", "test-tool", "test-id")
+	tc.Status = aids.New(mcp.StatusAwaitingElicitationResult)
+	tc.Internal = jsontext.Value(`{"secret":"value"}`)
+
+	if se := store.Put(ctx, tc, svrcore.AccessConditions{IfNoneMatch: svrcore.ETagAnyPtr}); se != nil {
+		t.Fatalf("Put failed: %v", se)
+	}
+	if tc.ETag == nil || tc.ServerData == nil {
+		t.Fatal("Expected Put to set ETag & ServerData")
+	}
+	if se := store.Get(ctx, &toolcall.Resource{Identity: tc.Identity}, svrcore.AccessConditions{}); se == nil || se.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected Get to return 404, got %v", se)
+	}
+
+	decoded, se := Decode(sde, tc.Identity, *tc.ServerData)
+	if se != nil {
+		t.Fatalf("Decode failed: %v", se)
+	}
+	if !decoded.ETag.Equals(*tc.ETag) || *decoded.Status != *tc.Status || string(decoded.Internal) != string(tc.Internal) {
+		t.Errorf("Decoded tool call %+v doesn't match %+v", decoded, tc)
+	}
+
+	// Put checks preconditions against the decoded tool call's ETag
+	if se := store.Put(ctx, decoded, svrcore.AccessConditions{IfMatch: aids.New(svrcore.ETag("stale"))}); se == nil || se.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for a stale If-Match, got %v", se)
+	}
+	if se := store.Put(ctx, decoded, svrcore.AccessConditions{IfMatch: tc.ETag}); se != nil {
+		t.Errorf("Expected Put with a matching If-Match to succeed, got %v", se)
+	}
+}
+
+func TestStatelessDecode_Rejects(t *testing.T) {
+	store := NewToolCallStore(sde)
+	tc := toolcall.New("test-tenant", "test-tool", "test-id")
+	aids.Assert(store.Put(ctx, tc, svrcore.AccessConditions{}) == nil, "Put failed")
+
+	other := toolcall.New("other-tenant", "test-tool", "test-id")
+	if _, se := Decode(sde, other.Identity, *tc.ServerData); se == nil || se.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for another tenant's serverData, got %v", se)
+	}
+
+	expired := toolcall.New("test-tenant", "test-tool", "expired")
+	expired.Expiration = aids.New(time.Now().Add(-time.Minute))
+	aids.Assert(store.Put(ctx, expired, svrcore.AccessConditions{}) == nil, "Put failed")
+	if _, se := Decode(sde, expired.Identity, *expired.ServerData); se == nil || se.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for an expired tool call, got %v", se)
+	}
+}
+
+func TestStatelessToolCallStore_Put_RejectsRecreate(t *testing.T) {
+	store := NewToolCallStore(sde)
+	tc := toolcall.New("test-tenant", "test-tool", "test-id")
+	aids.Assert(store.Put(ctx, tc, svrcore.AccessConditions{IfNoneMatch: svrcore.ETagAnyPtr}) == nil, "Put failed")
+
+	again := toolcall.New("test-tenant", "test-tool", "test-id")
+	if se := store.Put(ctx, again, svrcore.AccessConditions{IfNoneMatch: svrcore.ETagAnyPtr}); se == nil || se.StatusCode != http.StatusConflict {
+		t.Errorf("Expected 409 recreating a tool call, got %v", se)
+	}
+	if se := store.Put(ctx, tc, svrcore.AccessConditions{IfMatch: tc.ETag}); se != nil {
+		t.Errorf("Expected updating the tool call to succeed, got %v", se)
+	}
+
+	expired := toolcall.New("test-tenant", "test-tool", "expired")
+	expired.Expiration = aids.New(time.Now().Add(-time.Minute))
+	aids.Assert(store.Put(ctx, expired, svrcore.AccessConditions{IfNoneMatch: svrcore.ETagAnyPtr}) == nil, "Put failed")
+	expired.ETag = nil
+	if se := store.Put(ctx, expired, svrcore.AccessConditions{IfNoneMatch: svrcore.ETagAnyPtr}); se != nil {
+		t.Errorf("Expected recreating an expired tool call to succeed, got %v", se)
+	}
+}
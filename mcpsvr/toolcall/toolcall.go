@@ -9,6 +9,7 @@ import (
 	"github.com/JeffreyRichter/internal/aids"
 	"github.com/JeffreyRichter/mcp"
 	"github.com/JeffreyRichter/svrcore"
+	"github.com/JeffreyRichter/svrcore/tracing"
 )
 
 type (
@@ -22,18 +23,26 @@ type (
 	// Resource is the data model for the version-agnostic tool call resource type.
 	Resource struct {
 		Identity           `json:",inline"`
+		Created            *time.Time              `json:"created,omitempty"`
 		Expiration         *time.Time              `json:"expiration,omitempty"`
-		IdempotencyKey     *string                 `json:"idempotencyKey,omitempty"` // Used for retried PUTs to determine if PUT of same Request should be considered OK
+		IdempotencyKey     *string                 `json:"idempotencyKey,omitempty"`     // Used for retried PUTs to determine if PUT of same Request should be considered OK
+		ClientID           *string                 `json:"clientId,omitempty"`           // Client (app) that created the tool call; its roots are available to every phase
+		NotificationURL    *string                 `json:"notificationUrl,omitempty"`    // Client's webhook URL POSTed to when Status changes
+		NotificationSecret *string                 `json:"notificationSecret,omitempty"` // Signs the notifications; returned only by the PUT creating the tool call
+		NotifiedStatus     *mcp.Status             `json:"notifiedStatus,omitempty"`     // Status last sent to NotificationURL
 		ETag               *svrcore.ETag           `json:"etag"`
 		Phase              *string                 `json:"phase,omitempty"`
 		Status             *mcp.Status             `json:"status,omitempty" enum:"running,awaitingSamplingResponse,awaitingElicitationResponse,success,failed,canceled"`
 		Request            jsontext.Value          `json:"request,omitempty"`
 		SamplingRequest    *mcp.SamplingRequest    `json:"samplingRequest,omitempty"`
 		ElicitationRequest *mcp.ElicitationRequest `json:"elicitationRequest,omitempty"`
-		Progress           jsontext.Value          `json:"progress,omitempty"`
+		Progress           *mcp.Progress           `json:"progress,omitempty"`
 		Result             jsontext.Value          `json:"result,omitempty"`
 		Error              jsontext.Value          `json:"error,omitempty"`
-		Internal           jsontext.Value          `json:"internal,omitempty"` // Tool-specific internal data, never returned to clients
+		Internal           jsontext.Value          `json:"internal,omitempty"`    // Tool-specific internal data, never returned to clients
+		TraceParent        *string                 `json:"traceparent,omitempty"` // W3C trace context of the request that created the tool call
+		TraceState         *string                 `json:"tracestate,omitempty"`
+		ServerData         *string                 `json:"-"` // Set by stateless Stores: the encrypted tool call clients round-trip
 	}
 
 	// Store manages persistent storage of ToolCalls
@@ -49,20 +58,44 @@ type (
 
 		// Delete deletes the specified tool call from storage or returns a [svrcore.ServerError] if an error occurs.
 		Delete(ctx context.Context, tc *Resource, ac svrcore.AccessConditions) *svrcore.ServerError
+
+		// List returns one page of the tenant's tool calls for the specified tool name matching the passed-in
+		// ListOptions or a [svrcore.ServerError] if an error occurs.
+		List(ctx context.Context, tenant, toolName string, o ListOptions) (*ListPage, *svrcore.ServerError)
+
+		// Wait blocks until the specified tool call's ETag no longer matches etag and then retrieves it into the
+		// passed-in ToolCall struct. Returns a 304 [svrcore.ServerError] if ctx is done before the tool call
+		// changes or another [svrcore.ServerError] (like 404) if an error occurs.
+		Wait(ctx context.Context, tc *Resource, etag svrcore.ETag) *svrcore.ServerError
+	}
+
+	// ListOptions filters & pages the tool calls returned by [Store.List]
+	ListOptions struct {
+		Status            *mcp.Status // If not nil, only tool calls with this status are returned
+		CreatedAfter      *time.Time  // If not nil, only tool calls created after this time are returned
+		MaxResults        int         // Maximum number of tool calls returned per page; <= 0 uses the store's default
+		ContinuationToken *string     // Opaque token from a previous ListPage; nil starts from the beginning
+	}
+
+	// ListPage is one page of tool calls returned by [Store.List]
+	ListPage struct {
+		ToolCalls         []*Resource // Only Identity, Created & Status are guaranteed to be set
+		ContinuationToken *string     // Pass in the next ListOptions to get the next page; nil if this is the last page
 	}
 )
 
-var sde = NewServerDataEncoder("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+// DefaultListMaxResults is the maximum number of tool calls a [Store.List] page returns if not specified
+const DefaultListMaxResults = 100
 
-// ToMCP convert the ToolCallResource to a public-facing MCP ToolCall returned to clients.
-// It omits internal fields: Tenant, IdempotencyKey, Phase, Internal
-func (tc *Resource) ToMCP() mcp.ToolCall { return tc.ToMCPWith(false) }
+// ToSummary converts the ToolCallResource to the public-facing MCP summary returned when listing tool calls.
+func (tc *Resource) ToSummary() mcp.ToolCallSummary {
+	return mcp.ToolCallSummary{ToolName: tc.ToolName, ID: tc.ID, Status: tc.Status}
+}
 
-func (tc *Resource) ToMCPWith(serverData bool) mcp.ToolCall {
-	sd := (*string)(nil)
-	if serverData {
-		sd = aids.New(sde.Encode(aids.MustMarshal(tc))) // Serialize the ToolCall Resource string
-	}
+// ToMCP convert the ToolCallResource to a public-facing MCP ToolCall returned to clients.
+// It omits internal fields: Tenant, IdempotencyKey, ClientID, NotificationURL, NotificationSecret, NotifiedStatus,
+// Phase, Internal, TraceParent, TraceState (stateless Stores return them encrypted in ServerData)
+func (tc *Resource) ToMCP() mcp.ToolCall {
 	etag := (*string)(nil)
 	if tc.ETag != nil {
 		etag = aids.New(`\"` + tc.ETag.String() + `\"`) // ex: "\"etagValue\""; json unmarhsal removes outer "s
@@ -76,17 +109,46 @@ func (tc *Resource) ToMCPWith(serverData bool) mcp.ToolCall {
 		Request:            tc.Request,
 		SamplingRequest:    tc.SamplingRequest,
 		ElicitationRequest: tc.ElicitationRequest,
-		ServerData:         sd,
+		ServerData:         tc.ServerData,
 		Progress:           tc.Progress,
 		Result:             tc.Result,
 		Error:              tc.Error,
 	}
 }
 
+// TraceContext returns the trace context of the request that created the tool call; it's invalid if there's none.
+func (tc *Resource) TraceContext() tracing.SpanContext {
+	if tc.TraceParent == nil {
+		return tracing.SpanContext{}
+	}
+	traceState := ""
+	if tc.TraceState != nil {
+		traceState = *tc.TraceState
+	}
+	sc, _ := tracing.ParseTraceParent(*tc.TraceParent, traceState)
+	return sc
+}
+
+// SetTraceContext sets the trace context of the request creating the tool call so the spans of its phases &
+// later requests can be related to it; an invalid sc clears it.
+func (tc *Resource) SetTraceContext(sc tracing.SpanContext) {
+	tc.TraceParent, tc.TraceState = nil, nil
+	if sc.IsValid() {
+		tc.TraceParent = aids.New(sc.TraceParent())
+		if sc.TraceState != "" {
+			tc.TraceState = aids.New(sc.TraceState)
+		}
+	}
+}
+
+// Key returns a string uniquely identifying the tool call across tenants & tools
+func (id Identity) Key() string { return *id.Tenant + "/" + *id.ToolName + "/" + *id.ID }
+
 // New creates a new ToolCall with the specified tenant, tool name, and tool call ID.
 func New(tenant, toolName, toolCallID string) *Resource {
 	return &Resource{
 		Identity:   Identity{Tenant: aids.New(tenant), ToolName: aids.New(toolName), ID: aids.New(toolCallID)},
+		Created:    aids.New(time.Now()),
 		Expiration: aids.New(time.Now().Add(24 * time.Hour)), // Default maximum time a tool call lives
 		Status:     aids.New(mcp.StatusSubmitted),
 	}
@@ -107,6 +169,15 @@ type (
 		// StartPhaseProcessing: enqueues a new tool call phase with tool name & tool call id.
 		// It must succeed or panic due to internal server error.
 		StartPhase(ctx context.Context, tc *Resource) *svrcore.ServerError
+
+		// CancelPhase signals the tool call's running phase (if any) to stop by canceling the phase's context.
+		// Phases running in other processes observe the cancellation when they next check the Store.
+		CancelPhase(ctx context.Context, tc *Resource)
+
+		// Drain waits for the phases running in this process to stop; they stop (after persisting their state)
+		// when the context passed to the PhaseMgr's constructor is canceled by a shutdown. It returns ctx's error
+		// if ctx is done first.
+		Drain(ctx context.Context) error
 	}
 
 	// PhaseProcessor processes the current phase of a tool call to its next phase.
@@ -114,9 +185,16 @@ type (
 		// ExtendTime extends the allowed execution time for the current phase.
 		// It must succeed or panic due to internal server error.
 		ExtendTime(ctx context.Context, phaseExecutionTime time.Duration)
+
+		// ReportProgress sets the tool call's progress & persists it if enough time passed since it was last
+		// persisted; otherwise, the progress is persisted with the phase's next Put. A [svrcore.ServerError] is
+		// returned if persisting fails (ex: 412 if a client canceled the tool call).
+		ReportProgress(ctx context.Context, tc *Resource, p mcp.Progress) *svrcore.ServerError
 	}
 
-	// ProcessPhaseFunc is the function signature for processing a tool call's current phase to its next phase.
+	// ProcessPhaseFunc is the function signature for processing a tool call's current phase to its next phase &
+	// persisting the tool call's new state. If ctx is canceled (ex: the client canceled the tool call) or a client
+	// updated the tool call first, the function must update the passed-in tool call to its stored state instead.
 	// It panics if phase processing fails.
 	ProcessPhaseFunc func(context.Context, PhaseProcessor, *Resource)
 
@@ -124,3 +202,26 @@ type (
 	// It must succeed or panic due to unrecognized tool name.
 	ToolNameToProcessPhaseFunc func(toolName string) ProcessPhaseFunc
 )
+
+// PhaseMgrMetrics receives a PhaseMgr's measurements (ex: to record them in a metrics registry); nil funcs are ignored.
+type PhaseMgrMetrics struct {
+	// QueueDepth reports the number of tool call phases waiting for or being processed by the PhaseMgr.
+	QueueDepth func(depth int)
+
+	// PoisonMessage reports that a tool call phase was abandoned because processing it failed repeatedly.
+	PoisonMessage func()
+}
+
+// ReportQueueDepth calls m.QueueDepth (if not nil).
+func (m PhaseMgrMetrics) ReportQueueDepth(depth int) {
+	if m.QueueDepth != nil {
+		m.QueueDepth(depth)
+	}
+}
+
+// ReportPoisonMessage calls m.PoisonMessage (if not nil).
+func (m PhaseMgrMetrics) ReportPoisonMessage() {
+	if m.PoisonMessage != nil {
+		m.PoisonMessage()
+	}
+}
@@ -3,10 +3,12 @@ package toolcall
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/JeffreyRichter/internal/aids"
 	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/svrcore/tracing"
 )
 
 func TestUnmarshalElicitationRequest(t *testing.T) {
@@ -294,3 +296,23 @@ func TestUnmarshalElicitationRequest(t *testing.T) {
 		}
 	})
 }
+
+func TestTraceContext(t *testing.T) {
+	tc := New("tenant", "tool", "id")
+	if tc.TraceContext().IsValid() {
+		t.Fatal("expected a new tool call to have no trace context")
+	}
+	sc := aids.Must(tracing.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "a=1"))
+	tc.SetTraceContext(sc)
+	cp := tc.Copy() // The trace context is persisted
+	if cp.TraceContext() != sc {
+		t.Fatalf("expected %+v, got %+v", sc, cp.TraceContext())
+	}
+	if b := aids.MustMarshal(tc.ToMCP()); strings.Contains(string(b), "traceparent") {
+		t.Fatalf("expected the trace context to be internal, got %s", b)
+	}
+	tc.SetTraceContext(tracing.SpanContext{})
+	if tc.TraceParent != nil || tc.TraceState != nil {
+		t.Fatal("expected an invalid trace context to clear it")
+	}
+}
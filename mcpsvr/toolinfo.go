@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"slices"
 
 	"github.com/JeffreyRichter/internal/aids"
 	"github.com/JeffreyRichter/mcp"
 	"github.com/JeffreyRichter/mcpsvr/toolcall"
 	"github.com/JeffreyRichter/svrcore"
+	"github.com/JeffreyRichter/svrcore/stages"
 )
 
 // ToolInfo defines the interface for tool-specific operations
@@ -16,18 +18,25 @@ type ToolInfo interface {
 	// Tool returns the tool metadata.
 	Tool() *mcp.Tool
 
+	// Scopes returns the scopes the caller must be granted to create, advance & cancel the tool's calls;
+	// if empty, they're derived from the tool's annotations (see requiredScopes).
+	Scopes() []string
+
 	// Create creates a brand new tool call ID resource (if-none-match: *),
-	// optionally starts phase processing, and writes success/error to the client.
+	// optionally starts phase processing, and writes success/error to the client. A successful response
+	// includes the tool call's NotificationSecret (if any) in the Notification-Secret header.
 	Create(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes, pm toolcall.PhaseMgr) bool
 
 	// Get retrieves the tool call ID resource and writes success/error to the client.
 	Get(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes) bool
 
-	// Advance advances the tool call to the next phase and writes success/error to the client.
-	Advance(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes) bool
+	// Advance advances the tool call to the next phase, optionally starts phase processing,
+	// and writes success/error to the client.
+	Advance(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes, pm toolcall.PhaseMgr) bool
 
-	// Cancel cancels the tool call and writes success/error to the client.
-	Cancel(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes) bool
+	// Cancel cancels the tool call, signals its running phase (if any) to stop,
+	// and writes success/error to the client.
+	Cancel(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes, pm toolcall.PhaseMgr) bool
 
 	// ProcessPhase processes the tool call resources's current phase; there is no client to write success/error to.
 	ProcessPhase(ctx context.Context, pp toolcall.PhaseProcessor, tc *toolcall.Resource)
@@ -36,17 +45,18 @@ type ToolInfo interface {
 // defaultToolInfo provides default implementations of ToolCaller methods that all return "NotAllowed" errors.
 type defaultToolInfo struct{}
 
-func (*defaultToolInfo) Tool() *mcp.Tool { return nil }
+func (*defaultToolInfo) Tool() *mcp.Tool  { return nil }
+func (*defaultToolInfo) Scopes() []string { return nil }
 func (*defaultToolInfo) Create(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes, pm toolcall.PhaseMgr) bool {
 	return r.WriteError(http.StatusMethodNotAllowed, nil, nil, "NotAllowed", "PUT not implemented for tool '%s'", *tc.ToolName)
 }
 func (*defaultToolInfo) Get(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes) bool {
 	return r.WriteError(http.StatusMethodNotAllowed, nil, nil, "NotAllowed", "GET not implemented for tool '%s'", *tc.ToolName)
 }
-func (*defaultToolInfo) Advance(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes) bool {
+func (*defaultToolInfo) Advance(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes, pm toolcall.PhaseMgr) bool {
 	return r.WriteError(http.StatusMethodNotAllowed, nil, nil, "NotAllowed", "POST /advance not implemented for tool '%s'", *tc.ToolName)
 }
-func (*defaultToolInfo) Cancel(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes) bool {
+func (*defaultToolInfo) Cancel(ctx context.Context, tc *toolcall.Resource, r *svrcore.ReqRes, pm toolcall.PhaseMgr) bool {
 	return r.WriteError(http.StatusMethodNotAllowed, nil, nil, "NotAllowed", "POST /cancel not implemented for tool '%s'", *tc.ToolName)
 }
 func (*defaultToolInfo) ProcessPhase(ctx context.Context, pp toolcall.PhaseProcessor, tc *toolcall.Resource) {
@@ -54,3 +64,56 @@ func (*defaultToolInfo) ProcessPhase(ctx context.Context, pp toolcall.PhaseProce
 }
 
 var _ ToolInfo = (*defaultToolInfo)(nil)
+
+// The scopes a tool requires by default; an authenticated caller must be granted all of a tool's scopes to use it
+const (
+	scopeToolsRead        = "tools:read"        // Tools that don't modify their environment
+	scopeToolsWrite       = "tools:write"       // Tools that may modify their environment
+	scopeToolsDestructive = "tools:destructive" // Tools that may also delete or overwrite data
+)
+
+// requiredScopes returns the scopes ti requires: its declared scopes or, if none, scopes derived from its
+// annotations. Per the MCP spec, a tool is assumed not read-only & (if not read-only) destructive unless its
+// annotations say otherwise so a tool without annotations requires the strictest scopes.
+func requiredScopes(ti ToolInfo) []string {
+	if scopes := ti.Scopes(); len(scopes) > 0 {
+		return scopes
+	}
+	a := ti.Tool().Annotations
+	if a == nil {
+		a = &mcp.ToolAnnotations{}
+	}
+	if a.ReadOnlyHint != nil && *a.ReadOnlyHint {
+		return []string{scopeToolsRead}
+	}
+	if a.DestructiveHint == nil || *a.DestructiveHint {
+		return []string{scopeToolsWrite, scopeToolsDestructive}
+	}
+	return []string{scopeToolsWrite}
+}
+
+// supportedScopes returns the sorted, distinct scopes required by toolInfos' tools
+func supportedScopes(toolInfos map[string]ToolInfo) []string {
+	scopes := []string{}
+	for _, ti := range toolInfos {
+		scopes = append(scopes, requiredScopes(ti)...)
+	}
+	slices.Sort(scopes)
+	return slices.Compact(scopes)
+}
+
+// missingScopes returns the scopes ti requires that the caller wasn't granted; it returns nil if the caller is
+// authorized to use ti or if authentication is disabled.
+func missingScopes(ctx context.Context, ti ToolInfo) []string {
+	principal, ok := stages.PrincipalFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	missing := []string(nil)
+	for _, s := range requiredScopes(ti) {
+		if !principal.HasScope(s) {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
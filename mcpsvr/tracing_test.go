@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JeffreyRichter/mcp"
+	"github.com/JeffreyRichter/svrcore/stages"
+	"github.com/JeffreyRichter/svrcore/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolCallTracing(t *testing.T) {
+	e := tracing.NewInMemoryExporter()
+	ops := newLocalMcpStages(context.Background(), slog.Default(), nil)
+	ops.tracer = tracing.NewTracer(e, nil)
+	client := &testClient{t: t, url: testServerFor(t, ops, stages.NewDistributedTracingStage(ops.tracer)).URL}
+
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	path := "/mcp/tools/count/calls/" + t.Name()
+	resp := client.Put(path, http.Header{
+		"Idempotency-Key": []string{time.Now().Format(time.RFC3339Nano)},
+		"Content-Type":    []string{"application/json"},
+		"Traceparent":     []string{traceParent},
+	}, strings.NewReader(`{"increments":2}`))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+	for status := mcp.StatusRunning; status != mcp.StatusSuccess; {
+		time.Sleep(50 * time.Millisecond)
+		resp := client.Get(path, http.Header{"Accept": []string{"application/json"}}) // A new trace
+		var tc mcp.ToolCall
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&tc))
+		resp.Body.Close()
+		status = *tc.Status
+	}
+
+	var put tracing.SpanData
+	phases, gets := []tracing.SpanData{}, []tracing.SpanData{}
+	require.Eventually(t, func() bool { // The last phase's span may end after the GET seeing its result
+		put, phases, gets = tracing.SpanData{}, phases[:0], gets[:0]
+		for _, s := range e.Spans() {
+			switch s.Name {
+			case "PUT /mcp/tools/{toolName}/calls/{toolCallID}":
+				put = s
+			case "ProcessPhase count":
+				phases = append(phases, s)
+			case "GET /mcp/tools/{toolName}/calls/{toolCallID}":
+				gets = append(gets, s)
+			}
+		}
+		return len(phases) == 2
+	}, 5*time.Second, 50*time.Millisecond)
+
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", put.TraceID.String(), "the PUT continues the caller's trace")
+	assert.Equal(t, "00f067aa0ba902b7", put.Parent.String())
+	for _, s := range phases {
+		assert.Equal(t, put.TraceID, s.TraceID, "phases continue the PUT's trace")
+		assert.Equal(t, put.SpanID, s.Parent)
+	}
+	require.NotEmpty(t, gets)
+	for _, s := range gets {
+		assert.NotEqual(t, put.TraceID, s.TraceID)
+		assert.Contains(t, s.Links, put.SpanContext, "GETs link to the tool call's creation")
+	}
+}
@@ -0,0 +1,175 @@
+// Package uritemplate expands RFC 6570 URI Templates (https://www.rfc-editor.org/rfc/rfc6570) with string
+// variable values. It supports all Level 3 operators plus Level 4's prefix modifier ({var:3}); since values are
+// strings (not lists or maps), the explode modifier ({var*}) is accepted but has no effect.
+package uritemplate
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// operator describes how an expression's variables are expanded (RFC 6570 Appendix A)
+type operator struct {
+	first    string // Prepended to the expansion if any variable is defined
+	sep      string // Separates each defined variable's expansion
+	named    bool   // Expansion is "name=value"
+	ifEmpty  string // Appended to the name for an empty value when named
+	reserved bool   // Reserved characters (& pct-encoded triplets) are not encoded
+}
+
+var operators = map[byte]operator{
+	'+': {first: "", sep: ",", reserved: true},
+	'#': {first: "#", sep: ",", reserved: true},
+	'.': {first: ".", sep: "."},
+	'/': {first: "/", sep: "/"},
+	';': {first: ";", sep: ";", named: true},
+	'?': {first: "?", sep: "&", named: true, ifEmpty: "="},
+	'&': {first: "&", sep: "&", named: true, ifEmpty: "="},
+}
+
+// varSpec is one variable in an expression with its optional prefix length (0 means no prefix modifier)
+type varSpec struct {
+	name   string
+	prefix int
+}
+
+// Expand expands template using values; variables without a value are undefined & omitted from the expansion.
+// Expand returns an error if template is malformed.
+func Expand(template string, values map[string]string) (string, error) {
+	sb := strings.Builder{}
+	err := parse(template, func(literal string) { sb.WriteString(encode(literal, true)) },
+		func(op operator, vars []varSpec) {
+			prefix := op.first // Precedes the 1st defined variable; op.sep precedes the rest
+			for _, v := range vars {
+				value, ok := values[v.name]
+				if !ok {
+					continue
+				}
+				sb.WriteString(prefix)
+				prefix = op.sep
+				if op.named {
+					sb.WriteString(encode(v.name, false))
+					if value == "" {
+						sb.WriteString(op.ifEmpty)
+						continue
+					}
+					sb.WriteString("=")
+				}
+				if v.prefix > 0 && utf8.RuneCountInString(value) > v.prefix {
+					value = string([]rune(value)[:v.prefix])
+				}
+				sb.WriteString(encode(value, op.reserved))
+			}
+		})
+	return sb.String(), err
+}
+
+// Variables returns the names of the variables in template (in order of 1st appearance) or an error if
+// template is malformed.
+func Variables(template string) ([]string, error) {
+	names := []string{}
+	err := parse(template, func(string) {}, func(_ operator, vars []varSpec) {
+		for _, v := range vars {
+			if !slices.Contains(names, v.name) {
+				names = append(names, v.name)
+			}
+		}
+	})
+	return names, err
+}
+
+// parse splits template into literals & expressions calling the corresponding callback for each
+func parse(template string, literal func(string), expression func(operator, []varSpec)) error {
+	for template != "" {
+		open := strings.IndexAny(template, "{}")
+		if open < 0 {
+			literal(template)
+			return nil
+		}
+		if template[open] == '}' {
+			return fmt.Errorf("uritemplate: unmatched '}' in %q", template)
+		}
+		literal(template[:open])
+		end := strings.IndexByte(template[open:], '}')
+		if end < 0 {
+			return fmt.Errorf("uritemplate: unterminated expression in %q", template)
+		}
+		expr := template[open+1 : open+end]
+		template = template[open+end+1:]
+
+		op := operator{sep: ","}
+		if expr != "" {
+			if o, ok := operators[expr[0]]; ok {
+				op, expr = o, expr[1:]
+			}
+		}
+		vars := []varSpec{}
+		for spec := range strings.SplitSeq(expr, ",") {
+			v := varSpec{name: strings.TrimSuffix(spec, "*")}
+			if name, prefix, found := strings.Cut(v.name, ":"); found {
+				n, err := strconv.Atoi(prefix)
+				if err != nil || n <= 0 || n >= 10000 {
+					return fmt.Errorf("uritemplate: invalid prefix modifier in %q", spec)
+				}
+				v.name, v.prefix = name, n
+			}
+			if !validName(v.name) {
+				return fmt.Errorf("uritemplate: invalid variable name %q", v.name)
+			}
+			vars = append(vars, v)
+		}
+		expression(op, vars)
+	}
+	return nil
+}
+
+// validName returns true if name is a valid varname: (ALPHA / DIGIT / "_" / pct-encoded) ["."] ...
+func validName(name string) bool {
+	if name == "" || name[0] == '.' || name[len(name)-1] == '.' || strings.Contains(name, "..") {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; {
+		case isAlphaNum(c), c == '_', c == '.':
+		case c == '%' && i+2 < len(name) && isHex(name[i+1]) && isHex(name[i+2]):
+			i += 2
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// encode pct-encodes every byte of s that isn't unreserved; if reserved is true, reserved characters &
+// pct-encoded triplets are also left as is.
+func encode(s string, reserved bool) string {
+	const hex = "0123456789ABCDEF"
+	sb := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case isAlphaNum(c), strings.IndexByte("-._~", c) >= 0:
+			sb.WriteByte(c)
+		case reserved && strings.IndexByte(":/?#[]@!$&'()*+,;=", c) >= 0:
+			sb.WriteByte(c)
+		case reserved && c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			sb.WriteString(s[i : i+3])
+			i += 2
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(hex[c>>4])
+			sb.WriteByte(hex[c&0xF])
+		}
+	}
+	return sb.String()
+}
+
+func isAlphaNum(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
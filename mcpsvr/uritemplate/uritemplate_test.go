@@ -0,0 +1,77 @@
+package uritemplate
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	// Examples from RFC 6570 section 3.2
+	values := map[string]string{
+		"var":   "value",
+		"hello": "Hello World!",
+		"path":  "/foo/bar",
+		"empty": "",
+		"x":     "1024",
+		"y":     "768",
+	}
+	for template, want := range map[string]string{
+		"{var}":               "value",
+		"{hello}":             "Hello%20World%21",
+		"{+hello}":            "Hello%20World!",
+		"{+path}/here":        "/foo/bar/here",
+		"here?ref={+path}":    "here?ref=/foo/bar",
+		"X{#var}":             "X#value",
+		"X{#hello}":           "X#Hello%20World!",
+		"map?{x,y}":           "map?1024,768",
+		"{x,hello,y}":         "1024,Hello%20World%21,768",
+		"{+x,hello,y}":        "1024,Hello%20World!,768",
+		"{+path,x}/here":      "/foo/bar,1024/here",
+		"{#x,hello,y}":        "#1024,Hello%20World!,768",
+		"X{.var}":             "X.value",
+		"X{.x,y}":             "X.1024.768",
+		"{/var}":              "/value",
+		"{/var,x}/here":       "/value/1024/here",
+		"{;x,y}":              ";x=1024;y=768",
+		"{;x,y,empty}":        ";x=1024;y=768;empty",
+		"{?x,y}":              "?x=1024&y=768",
+		"{?x,y,empty}":        "?x=1024&y=768&empty=",
+		"?fixed=yes{&x}":      "?fixed=yes&x=1024",
+		"{var:3}":             "val",
+		"{var:30}":            "value",
+		"{+path:6}/here":      "/foo/b/here",
+		"{undef}":             "",
+		"{?undef,x}":          "?x=1024",
+		"{/undef}/here":       "/here",
+		"file:///{var}.log":   "file:///value.log",
+		"{var*}":              "value",
+		"no expressions":      "no%20expressions",
+		"image://{x}x{y}.png": "image://1024x768.png",
+	} {
+		got, err := Expand(template, values)
+		if err != nil {
+			t.Fatalf("Expand(%q): %v", template, err)
+		}
+		if got != want {
+			t.Fatalf("Expand(%q): wanted %q, got %q", template, want, got)
+		}
+	}
+}
+
+func TestExpandMalformed(t *testing.T) {
+	for _, template := range []string{"{var", "var}", "{}", "{va r}", "{var:0}", "{var:x}", "{.}", "{a..b}"} {
+		if _, err := Expand(template, map[string]string{}); err == nil {
+			t.Fatalf("Expand(%q): expected an error", template)
+		}
+	}
+}
+
+func TestVariables(t *testing.T) {
+	got, err := Variables("image://gradient/{width}x{height}{?color,width}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"width", "height", "color"}; !slices.Equal(got, want) {
+		t.Fatalf("wanted %v, got %v", want, got)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"io"
 	"log/slog"
 	"net/http"
@@ -10,23 +11,40 @@ import (
 	"time"
 
 	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcpsvr/toolcall"
 	"github.com/JeffreyRichter/svrcore"
+	"github.com/JeffreyRichter/svrcore/metrics"
 	"github.com/JeffreyRichter/svrcore/stages"
 )
 
-var testSvr *mcpStages = newLocalMcpStages(context.Background(), slog.Default())
+// testNotifierConfig enables webhook notifications in the test servers; its Client trusts the test webhooks'
+// self-signed certificates & (unlike the default Client) connects to their loopback addresses
+var testNotifierConfig = &toolcall.NotifierConfig{
+	ErrorLogger: slog.Default(),
+	Client:      &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}},
+}
+
+var testSvr *mcpStages = func() *mcpStages {
+	ops := newLocalMcpStages(context.Background(), slog.Default(), testNotifierConfig)
+	ops.enableOutputValidation(OutputValidationStrict) // Tests fail if any tool's results don't match its OutputSchema
+	return ops
+}()
 
-func testServer(t *testing.T) *httptest.Server {
+func testServer(t *testing.T) *httptest.Server { return testServerFor(t, testSvr) }
+
+// testServerFor creates a test server serving ops' routes; extra stages run after the standard stages
+func testServerFor(t *testing.T, ops *mcpStages, extra ...svrcore.Stage) *httptest.Server {
 	logger := slog.Default()
 
 	stages := []svrcore.Stage{
-		stages.NewShutdownMgr(stages.ShutdownMgrConfig{ErrorLogger: logger, HealthProbeDelay: time.Second * 3, CancellationDelay: time.Second * 2}).NewStage(),
+		stages.NewManualShutdownMgr(stages.ShutdownMgrConfig{ErrorLogger: logger, HealthProbeDelay: time.Second * 3, CancellationDelay: time.Second * 2}).NewStage(),
 		stages.NewThrottlingStage(100),
 		stages.NewSharedKeyStage(""),
-		stages.NewMetricsStage(logger),
-		stages.NewDistributedTracingStage(),
+		stages.NewMetricsStage(metrics.NewRegistry()),
+		stages.NewDistributedTracingStage(nil),
 	}
-	avis := []*svrcore.ApiVersionInfo{{GetRoutes: testSvr.Routes20250808}}
+	stages = append(stages, extra...)
+	avis := []*svrcore.ApiVersionInfo{{GetRoutes: ops.Routes20250808}}
 	handler := svrcore.BuildHandler(
 		svrcore.BuildHandlerConfig{
 			Stages:                stages,
@@ -46,8 +64,11 @@ type testClient struct {
 	url string
 }
 
-func newTestClient(t *testing.T) *testClient {
-	srv := testServer(t)
+func newTestClient(t *testing.T) *testClient { return newTestClientFor(t, testSvr) }
+
+// newTestClientFor creates a test client for a test server serving ops' routes
+func newTestClientFor(t *testing.T, ops *mcpStages) *testClient {
+	srv := testServerFor(t, ops)
 	t.Cleanup(srv.Close)
 	return &testClient{t: t, url: srv.URL}
 }
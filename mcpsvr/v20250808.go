@@ -4,17 +4,18 @@ package main
 GET /mcp/tools
 GET /mcp/tools/{toolName}/calls
 PUT/GET /mcp/tools/{toolName}/calls/{toolCallID}
+GET /mcp/tools/{toolName}/calls/{toolCallID}/events
 POST /mcp/tools/{toolName}/calls/{toolCallID}/advance
 POST /mcp/tools/{toolName}/calls/{toolCallID}/cancel
 
 GET /mcp/resources
 GET /mcp/resources-templates
-POST /mcp/resources/{name}
+GET /mcp/resources/{name}
 
 GET /mcp/prompts
 POST /mcp/prompts/{name}
 
-PUT /mcp/roots
+PUT/GET /mcp/roots
 POST /mcp/complete
 */
 
@@ -29,6 +30,15 @@ func (p *mcpStages) Routes20250808(baseRoutes svrcore.ApiVersionRoutes) svrcore.
 	// To existing URL, add/overwrite HTTP method: baseRoutes["<ExistinUrl>"]["<ExistingOrNewHttpMethod>"] = postFoo
 	// To existing URL, remove HTTP method:        delete(baseRoutes["<ExistingUrl>"], "<ExisitngHttpMethod>")
 	// Remove existing URL entirely:               delete(baseRoutes, "<ExistingUrl>")
+	cancelHeader := &svrcore.ValidHeader{
+		MaxContentLength: int64(0), // No content expected for cancel
+	}
+	if p.serverData != nil { // Stateless tool calls are canceled by sending their serverData
+		cancelHeader = &svrcore.ValidHeader{
+			ContentTypes:     []string{"application/json"},
+			MaxContentLength: int64(64 * 1024),
+		}
+	}
 	return svrcore.ApiVersionRoutes{
 		// ***** TOOLS *****
 		"/mcp/tools": map[string]*svrcore.MethodInfo{
@@ -42,28 +52,29 @@ func (p *mcpStages) Routes20250808(baseRoutes svrcore.ApiVersionRoutes) svrcore.
 				Stage: p.putToolCallResource,
 				ValidHeader: &svrcore.ValidHeader{
 					ContentTypes:     []string{"application/json"},
-					MaxContentLength: int64(1024),
+					MaxContentLength: int64(64 * 1024),
 				},
 			},
 			"GET": {Stage: p.getToolCallResource},
 		},
+		"/mcp/tools/{toolName}/calls/{toolCallID}/events": map[string]*svrcore.MethodInfo{
+			"GET": {Stage: p.getToolCallEvents},
+		},
 
 		"/mcp/tools/{toolName}/calls/{toolCallID}/advance": map[string]*svrcore.MethodInfo{
 			"POST": {
 				Stage: p.postToolCallResourceAdvance,
 				ValidHeader: &svrcore.ValidHeader{
 					ContentTypes:     []string{"application/json"},
-					MaxContentLength: int64(1024),
+					MaxContentLength: int64(64 * 1024),
 				},
 			},
 		},
 
 		"/mcp/tools/{toolName}/calls/{toolCallID}/cancel": map[string]*svrcore.MethodInfo{
 			"POST": {
-				Stage: p.postToolCallCancelResource,
-				ValidHeader: &svrcore.ValidHeader{
-					MaxContentLength: int64(0), // No content expected for cancel
-				},
+				Stage:       p.postToolCallCancelResource,
+				ValidHeader: cancelHeader,
 			},
 		},
 
@@ -75,7 +86,7 @@ func (p *mcpStages) Routes20250808(baseRoutes svrcore.ApiVersionRoutes) svrcore.
 			"GET": {Stage: p.getResourcesTemplates},
 		},
 		"/mcp/resources/{name}": map[string]*svrcore.MethodInfo{
-			"POST": {Stage: p.getResource},
+			"GET": {Stage: p.getResource},
 		},
 
 		// ***** PROMPTS *****
@@ -83,15 +94,34 @@ func (p *mcpStages) Routes20250808(baseRoutes svrcore.ApiVersionRoutes) svrcore.
 			"GET": {Stage: p.getPrompts},
 		},
 		"/mcp/prompts/{name}": map[string]*svrcore.MethodInfo{
-			"POST": {Stage: p.getPrompt},
+			"POST": {
+				Stage: p.getPrompt,
+				ValidHeader: &svrcore.ValidHeader{
+					ContentTypes:     []string{"application/json"},
+					MaxContentLength: int64(64 * 1024),
+				},
+			},
 		},
 
 		// ***** ROOTS & COMPLETIONS *****
 		"/mcp/roots": map[string]*svrcore.MethodInfo{
-			"PUT": {Stage: p.putRoots},
+			"PUT": {
+				Stage: p.putRoots,
+				ValidHeader: &svrcore.ValidHeader{
+					ContentTypes:     []string{"application/json"},
+					MaxContentLength: int64(64 * 1024),
+				},
+			},
+			"GET": {Stage: p.getRoots},
 		},
 		"/mcp/complete": map[string]*svrcore.MethodInfo{
-			"POST": {Stage: p.postCompletion},
+			"POST": {
+				Stage: p.postCompletion,
+				ValidHeader: &svrcore.ValidHeader{
+					ContentTypes:     []string{"application/json"},
+					MaxContentLength: int64(64 * 1024),
+				},
+			},
 		},
 	}
 }
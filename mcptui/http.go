@@ -185,3 +185,104 @@ func (c *httpClient) advanceElicitation(callID string, approved bool) (*HTTPTran
 
 	return transaction, nil
 }
+
+// getPrompts fetches the list of available prompts from the server and returns an HTTPTransaction for UI display
+func (c *httpClient) getPrompts() ([]PromptInfo, *HTTPTransaction, error) {
+	txn, err := c.send(http.MethodGet, c.serverURL+"/mcp/prompts", nil)
+	if aids.IsError(err) {
+		return nil, txn, fmt.Errorf("failed to fetch prompts: %w", err)
+	}
+	if txn.StatusCode != http.StatusOK {
+		return nil, txn, fmt.Errorf("server returned status %d", txn.StatusCode)
+	}
+	var result struct {
+		Prompts []PromptInfo `json:"prompts"`
+	}
+	if err := json.Unmarshal([]byte(txn.ResponseBody), &result); aids.IsError(err) {
+		return nil, txn, fmt.Errorf("failed to decode prompts response: %w", err)
+	}
+	return result.Prompts, txn, nil
+}
+
+// getPrompt renders the named prompt with args and returns the HTTP transaction
+func (c *httpClient) getPrompt(promptName string, args map[string]string) (*HTTPTransaction, error) {
+	body, err := json.Marshal(map[string]any{"name": promptName, "arguments": args})
+	if aids.IsError(err) {
+		return nil, fmt.Errorf("failed to marshal arguments: %w", err)
+	}
+	txn, err := c.send(http.MethodPost, c.serverURL+"/mcp/prompts/"+promptName, body)
+	if aids.IsError(err) {
+		return txn, fmt.Errorf("request failed: %w", err)
+	}
+	return txn, nil
+}
+
+// complete returns the server's suggested values for a prompt argument given its partial value & the values of
+// the previously-entered arguments. The transaction isn't returned since completions are fetched per keystroke.
+func (c *httpClient) complete(promptName, argName, value string, args map[string]string) ([]string, error) {
+	body, err := json.Marshal(map[string]any{
+		"ref":      map[string]any{"type": "ref/prompt", "name": promptName},
+		"argument": map[string]any{"name": argName, "value": value},
+		"context":  map[string]any{"arguments": args},
+	})
+	if aids.IsError(err) {
+		return nil, fmt.Errorf("failed to marshal completion request: %w", err)
+	}
+	txn, err := c.send(http.MethodPost, c.serverURL+"/mcp/complete", body)
+	if aids.IsError(err) {
+		return nil, fmt.Errorf("completion failed: %w", err)
+	}
+	if txn.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned status %d", txn.StatusCode)
+	}
+	var result struct {
+		Completion struct {
+			Values []string `json:"values"`
+		} `json:"completion"`
+	}
+	if err := json.Unmarshal([]byte(txn.ResponseBody), &result); aids.IsError(err) {
+		return nil, fmt.Errorf("failed to decode completion response: %w", err)
+	}
+	return result.Completion.Values, nil
+}
+
+// send sends a request with an optional JSON body and returns the transaction including the response body
+func (c *httpClient) send(method, url string, body []byte) (*HTTPTransaction, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if aids.IsError(err) {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.authKey != "" {
+		req.Header.Set("Authorization", c.authKey)
+	}
+
+	start := time.Now()
+	resp, err := c.Do(req)
+	duration := time.Since(start)
+
+	txn := &HTTPTransaction{
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		RequestBody:    string(body),
+		RequestHeaders: req.Header.Clone(),
+		Timestamp:      start,
+		Duration:       duration,
+		Error:          err,
+	}
+	if aids.IsError(err) {
+		return txn, err
+	}
+	defer resp.Body.Close()
+	txn.StatusCode = resp.StatusCode
+	txn.ResponseHeaders = resp.Header.Clone()
+	if respBody, readErr := io.ReadAll(resp.Body); readErr == nil {
+		txn.ResponseBody = string(respBody)
+	}
+	return txn, nil
+}
@@ -57,7 +57,7 @@ func (m Model) handleHTTPResponse(msg httpResponseMsg) (Model, tea.Cmd) {
 	} else {
 		m.state = StateShowingResult
 	}
-	return m, nil
+	return m, m.scheduleToolCallPoll()
 }
 
 func (m Model) handleElicitation(msg elicitationMsg) (Model, tea.Cmd) {
@@ -16,6 +16,7 @@ type KeyMap struct {
 	Cancel    key.Binding
 	PathInput key.Binding
 	KillProc  key.Binding
+	Prompt    key.Binding
 }
 
 func defaultKeyMap() KeyMap {
@@ -30,5 +31,6 @@ func defaultKeyMap() KeyMap {
 		Cancel:    key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "cancel")),
 		PathInput: key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "path input")),
 		KillProc:  key.NewBinding(key.WithKeys("k"), key.WithHelp("k", "kill process")),
+		Prompt:    key.NewBinding(key.WithKeys("g"), key.WithHelp("g", "get prompt")),
 	}
 }
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/textinput"
@@ -51,6 +52,12 @@ type Model struct {
 	origAuthKey                  string
 	usingLocalServer             bool
 
+	// Prompt argument entry state
+	prompt promptEntry
+
+	// Progress of the running tool call in lastResponse; nil if none
+	progress *mcp.Progress
+
 	keys KeyMap
 
 	// UI state
@@ -99,6 +106,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Path input handled separately when state is StatePathInput and modal not reused
 			return m, nil
 		}
+		if m.state == StatePromptInput {
+			return m.handlePromptInputKeys(msg)
+		}
 		if m.state == StatePathInput {
 			// handle path input keystrokes directly
 			if msg.String() == "tab" { // toggle focus between path and storage inputs
@@ -161,6 +171,12 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.handleHTTPResponse(msg)
 	case elicitationMsg:
 		return m.handleElicitation(msg)
+	case promptsLoadedMsg:
+		return m.startPromptEntry(msg)
+	case completionMsg:
+		return m.handleCompletion(msg)
+	case toolCallPollMsg:
+		return m.pollToolCall(msg)
 	case modalDecisionMsg:
 		if msg.approved != nil {
 			// hide modal first
@@ -284,6 +300,11 @@ func (m Model) updateNormal(msg tea.KeyMsg) (Model, tea.Cmd) {
 		return m, nil
 	}
 
+	if m.activePanel == PanelTools && key.Matches(msg, m.keys.Prompt) && (m.state == StateToolList || m.state == StateShowingResult || m.state == StateError) {
+		m.state = StateLoading
+		return m, m.loadPrompts()
+	}
+
 	if m.activePanel == PanelServer && key.Matches(msg, m.keys.KillProc) {
 		if m.localServerCmd != nil && m.localServerCmd.Process != nil {
 			_ = m.localServerCmd.Process.Kill()
@@ -16,6 +16,7 @@ const (
 	StateShowingResult
 	StateElicitation
 	StatePathInput
+	StatePromptInput
 	StateError
 )
 
@@ -35,6 +36,20 @@ type ToolInfo struct {
 	Description string `json:"description"`
 }
 
+// PromptInfo represents information about an available prompt
+type PromptInfo struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Arguments   []PromptArgument `json:"arguments"`
+}
+
+// PromptArgument represents an argument a prompt accepts
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+}
+
 // HTTPTransaction represents a complete HTTP request/response cycle
 type HTTPTransaction struct {
 	Method          string
@@ -72,6 +87,20 @@ type elicitationMsg struct{ data ElicitationData }
 type modalDecisionMsg struct{ approved *bool }
 type errorMsg struct{ error error }
 
+// promptsLoadedMsg carries the prompts whose arguments the user can enter.
+type promptsLoadedMsg struct {
+	prompts     []PromptInfo
+	transaction *HTTPTransaction
+	err         error
+}
+
+// completionMsg carries the server's suggested values for an argument's partial value.
+type completionMsg struct {
+	argName string
+	value   string
+	values  []string
+}
+
 // pathInputSubmittedMsg emitted when user submits a path in path input modal.
 type pathInputSubmittedMsg struct {
 	path string
@@ -87,3 +116,6 @@ type pathExecResultMsg struct {
 
 // serverURLRefreshMsg triggers loading tools after a delay when switching servers.
 type serverURLRefreshMsg struct{}
+
+// toolCallPollMsg triggers getting a running tool call to refresh its progress.
+type toolCallPollMsg struct{ url string }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/mcp"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// toolCallPollInterval is how often a running tool call is refreshed to show its progress
+const toolCallPollInterval = 500 * time.Millisecond
+
+// scheduleToolCallPoll records the progress of the tool call in lastResponse & schedules refreshing it if it's
+// still running.
+func (m *Model) scheduleToolCallPoll() tea.Cmd {
+	m.progress = nil
+	var tc mcp.ToolCall
+	if err := json.Unmarshal([]byte(m.lastResponse.ResponseBody), &tc); aids.IsError(err) || tc.Status == nil || !tc.Status.Processing() {
+		return nil
+	}
+	m.progress = tc.Progress
+	url := m.lastResponse.URL
+	return tea.Tick(toolCallPollInterval, func(time.Time) tea.Msg { return toolCallPollMsg{url: url} })
+}
+
+// pollToolCall gets the running tool call unless the user has since made another request
+func (m Model) pollToolCall(msg toolCallPollMsg) (Model, tea.Cmd) {
+	if m.lastResponse == nil || m.lastResponse.URL != msg.url {
+		return m, nil
+	}
+	return m, func() tea.Msg {
+		transaction, err := m.client.send(http.MethodGet, msg.url, nil)
+		return httpResponseMsg{transaction: transaction, err: err}
+	}
+}
+
+// renderProgress renders the running tool call's progress as a bar width cells wide (or a count if its total
+// is unknown) followed by its message & ETA.
+func (m Model) renderProgress(width int) string {
+	p := *m.progress
+	s := fmt.Sprintf("%v", p.Progress)
+	if fraction, ok := p.Fraction(); ok {
+		filled := int(math.Round(fraction * float64(width)))
+		bar := strings.Repeat("█", filled)
+		if m.theme != nil {
+			bar = m.theme.StatusSuccess.Render(bar)
+		}
+		s = fmt.Sprintf("%s%s %3.0f%%", bar, strings.Repeat("░", width-filled), fraction*100)
+	}
+	if p.Message != nil {
+		s += " " + *p.Message
+	}
+	if p.ETA != nil {
+		s += fmt.Sprintf(" (ETA %v)", max(time.Until(*p.ETA).Round(time.Second), 0))
+	}
+	return s
+}
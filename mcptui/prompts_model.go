@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxShownSuggestions is the number of suggestions listed beneath the prompt argument input
+const maxShownSuggestions = 5
+
+// promptEntry is the state of entering a prompt's name & then each of its arguments. The prompt names and
+// the server's completions (POST /mcp/complete) are offered as inline suggestions; tab accepts one.
+type promptEntry struct {
+	prompts  []PromptInfo
+	prompt   *PromptInfo // nil while the prompt name is being entered
+	argIndex int
+	args     map[string]string
+	input    textinput.Model
+}
+
+// loadPrompts creates a command to load available prompts
+func (m Model) loadPrompts() tea.Cmd {
+	return func() tea.Msg {
+		prompts, txn, err := m.client.getPrompts()
+		return promptsLoadedMsg{prompts: prompts, transaction: txn, err: err}
+	}
+}
+
+// startPromptEntry begins entering a prompt's name once the prompts are loaded
+func (m Model) startPromptEntry(msg promptsLoadedMsg) (Model, tea.Cmd) {
+	if aids.IsError(msg.err) {
+		return m.handleHTTPResponse(httpResponseMsg{transaction: msg.transaction, err: msg.err})
+	}
+	names := make([]string, 0, len(msg.prompts))
+	for _, p := range msg.prompts {
+		names = append(names, p.Name)
+	}
+	m.prompt = promptEntry{prompts: msg.prompts, args: map[string]string{}, input: newPromptInput("prompt name")}
+	m.prompt.input.SetSuggestions(names)
+	m.state = StatePromptInput
+	return m, nil
+}
+
+func newPromptInput(placeholder string) textinput.Model {
+	ti := textinput.New()
+	ti.Placeholder = placeholder
+	ti.ShowSuggestions = true
+	ti.Focus()
+	return ti
+}
+
+// handlePromptInputKeys handles keystrokes while entering a prompt's name or arguments
+func (m Model) handlePromptInputKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.state = StateToolList
+		return m, nil
+	case tea.KeyEnter:
+		return m.submitPromptInput()
+	}
+	prev := m.prompt.input.Value()
+	var cmd tea.Cmd
+	m.prompt.input, cmd = m.prompt.input.Update(msg)
+	if m.prompt.prompt != nil && m.prompt.input.Value() != prev {
+		return m, tea.Batch(cmd, m.fetchCompletions())
+	}
+	return m, cmd
+}
+
+// submitPromptInput accepts the current input & advances to the next argument; after the last argument, the
+// prompt is requested from the server.
+func (m Model) submitPromptInput() (Model, tea.Cmd) {
+	value := m.prompt.input.Value()
+	if m.prompt.prompt == nil {
+		for i := range m.prompt.prompts {
+			if m.prompt.prompts[i].Name == value {
+				m.prompt.prompt = &m.prompt.prompts[i]
+			}
+		}
+		if m.prompt.prompt == nil { // ignore unknown prompt names
+			return m, nil
+		}
+	} else {
+		arg := m.prompt.prompt.Arguments[m.prompt.argIndex]
+		if value == "" && arg.Required { // ignore empty required arguments
+			return m, nil
+		}
+		if value != "" {
+			m.prompt.args[arg.Name] = value
+		}
+		m.prompt.argIndex++
+	}
+
+	if m.prompt.argIndex >= len(m.prompt.prompt.Arguments) {
+		m.state = StateExecuting
+		name, args := m.prompt.prompt.Name, m.prompt.args
+		return m, func() tea.Msg {
+			transaction, err := m.client.getPrompt(name, args)
+			return httpResponseMsg{transaction: transaction, err: err}
+		}
+	}
+	arg := m.prompt.prompt.Arguments[m.prompt.argIndex]
+	m.prompt.input = newPromptInput(arg.Description)
+	return m, m.fetchCompletions() // Suggest values before the user types anything
+}
+
+// fetchCompletions creates a command to get the server's suggestions for the current argument's value
+func (m Model) fetchCompletions() tea.Cmd {
+	name, argName, value := m.prompt.prompt.Name, m.prompt.prompt.Arguments[m.prompt.argIndex].Name, m.prompt.input.Value()
+	args := map[string]string{}
+	for k, v := range m.prompt.args {
+		args[k] = v
+	}
+	return func() tea.Msg {
+		values, err := m.client.complete(name, argName, value, args)
+		if aids.IsError(err) {
+			values = nil // suggestions are best-effort; the user can still type any value
+		}
+		return completionMsg{argName: argName, value: value, values: values}
+	}
+}
+
+// handleCompletion shows the server's suggestions if they're for the input's current value
+func (m Model) handleCompletion(msg completionMsg) (Model, tea.Cmd) {
+	if m.state != StatePromptInput || m.prompt.prompt == nil || m.prompt.input.Value() != msg.value ||
+		m.prompt.prompt.Arguments[m.prompt.argIndex].Name != msg.argName {
+		return m, nil // stale; the user moved on
+	}
+	m.prompt.input.SetSuggestions(msg.values)
+	return m, nil
+}
+
+// promptInputLabel returns the label for the current input
+func (m Model) promptInputLabel() string {
+	if m.prompt.prompt == nil {
+		return "Prompt:"
+	}
+	arg := m.prompt.prompt.Arguments[m.prompt.argIndex]
+	return fmt.Sprintf("%s (%d of %d)%s:", arg.Name, m.prompt.argIndex+1, len(m.prompt.prompt.Arguments), aids.Iif(arg.Required, " *", ""))
+}
@@ -37,6 +37,9 @@ func (m Model) View() string {
 	if m.state == StatePathInput {
 		return m.renderPathInputOverlay(base)
 	}
+	if m.state == StatePromptInput {
+		return m.renderPromptInputOverlay(base)
+	}
 	return base
 }
 
@@ -208,6 +211,36 @@ func (m Model) renderPathInputOverlay(base string) string {
 	return strings.Join(baseLines, "\n")
 }
 
+func (m Model) renderPromptInputOverlay(base string) string {
+	title := "Get Prompt"
+	if m.prompt.prompt != nil {
+		title += ": " + m.prompt.prompt.Name
+	}
+	suggestions := m.prompt.input.AvailableSuggestions()
+	if len(suggestions) > maxShownSuggestions {
+		suggestions = append(suggestions[:maxShownSuggestions], "...")
+	}
+	help := "Tab=Accept suggestion  Up/Down=Cycle  Enter=Next  Esc=Cancel"
+	content := title + "\n\n" + m.promptInputLabel() + "\n" + m.prompt.input.View() + "\n" + strings.Join(suggestions, "  ") + "\n\n" + help
+	if m.theme != nil {
+		content = m.theme.ModalBorder.Render(content)
+	}
+	mw := min(m.windowWidth-4, 70)
+	if mw < 30 {
+		mw = m.windowWidth - 2
+	}
+	box := lipgloss.NewStyle().Width(mw).Render(content)
+	placed := lipgloss.Place(m.windowWidth, m.windowHeight, lipgloss.Center, lipgloss.Center, box)
+	baseLines := strings.Split(base, "\n")
+	modalLines := strings.Split(placed, "\n")
+	for i := range baseLines {
+		if i < len(modalLines) && strings.TrimSpace(modalLines[i]) != "" {
+			baseLines[i] = modalLines[i]
+		}
+	}
+	return strings.Join(baseLines, "\n")
+}
+
 func (m Model) renderModal() string {
 	if !m.modal.Visible() {
 		return ""
@@ -235,6 +268,8 @@ func (m Model) renderStatusLine() string {
 		st = "Waiting for approval..."
 	case StatePathInput:
 		st = "Entering file path..."
+	case StatePromptInput:
+		st = "Entering prompt arguments..."
 	case StateError:
 		if aids.IsError(m.err) {
 			st = "Error: " + m.err.Error()
@@ -243,6 +278,9 @@ func (m Model) renderStatusLine() string {
 	if m.theme != nil && aids.IsError(m.err) {
 		st = m.theme.StatusError.Render(st)
 	}
+	if m.progress != nil && m.state == StateShowingResult {
+		st = "Running " + m.renderProgress(20)
+	}
 	line := "Status: " + st
 	lineWidth := lipgloss.Width(line)
 	if lineWidth < m.windowWidth {
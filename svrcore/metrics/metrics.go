@@ -0,0 +1,286 @@
+// Package metrics is a small metrics registry (counters, gauges & histograms with labels) whose metrics are
+// exposed in the Prometheus text exposition format (version 0.0.4) so Prometheus (or any OpenMetrics-compatible
+// collector) can scrape them.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/JeffreyRichter/internal/aids"
+)
+
+// TextContentType is the Content-Type of the Prometheus text exposition format written by Registry.WriteText.
+const TextContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the default upper bounds of a Histogram's buckets; they suit latencies in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var (
+	metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRegexp  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Registry holds metrics & writes them in the Prometheus text exposition format. Its methods are safe for
+// concurrent use. A Registry's ServeHTTP method makes it an http.Handler for a scrape endpoint.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric // In registration order
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry { return &Registry{} }
+
+// metric is a registered metric that can write itself in the text exposition format
+type metric interface {
+	name() string
+	writeText(w *bytes.Buffer)
+}
+
+// register adds m to the registry; it panics if m's name is invalid or already registered
+func (r *Registry) register(m metric) {
+	aids.Assert(metricNameRegexp.MatchString(m.name()), fmt.Errorf("invalid metric name %q", m.name()))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.metrics {
+		aids.Assert(existing.name() != m.name(), fmt.Errorf("metric %q is already registered", m.name()))
+	}
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText writes all of the registry's metrics to w in the Prometheus text exposition format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	metrics := slices.Clone(r.metrics)
+	r.mu.Unlock()
+	b := &bytes.Buffer{} // Metrics are formatted (while locked) in memory so w's I/O never blocks updates
+	for _, m := range metrics {
+		m.writeText(b)
+	}
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// ServeHTTP writes the registry's metrics in the Prometheus text exposition format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", TextContentType)
+	w.WriteHeader(http.StatusOK)
+	_ = r.WriteText(w) // The client went away; there's no one to report the error to
+}
+
+// desc describes a metric
+type desc struct {
+	metricName string
+	help       string
+	kind       string // counter, gauge or histogram
+	labelNames []string
+}
+
+func (d *desc) name() string { return d.metricName }
+
+// writeHeader writes the metric's HELP & TYPE lines
+func (d *desc) writeHeader(w *bytes.Buffer) {
+	help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.metricName, help, d.metricName, d.kind)
+}
+
+// vec holds a metric's series keyed by their label values
+type vec[T any] struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*series[T]
+}
+
+type series[T any] struct {
+	labelValues []string
+	value       T
+}
+
+func newVec[T any](name, help, kind string, labelNames []string) vec[T] {
+	for _, l := range labelNames {
+		aids.Assert(labelNameRegexp.MatchString(l) && !strings.HasPrefix(l, "__"), fmt.Errorf("metric %q has invalid label name %q", name, l))
+	}
+	return vec[T]{desc: desc{metricName: name, help: help, kind: kind, labelNames: labelNames}, series: map[string]*series[T]{}}
+}
+
+// update calls f with the value of the series identified by labelValues (creating it if necessary)
+func (v *vec[T]) update(labelValues []string, f func(value *T)) {
+	aids.Assert(len(labelValues) == len(v.labelNames), fmt.Errorf("metric %q requires %d label values; got %d", v.metricName, len(v.labelNames), len(labelValues)))
+	key := strings.Join(labelValues, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &series[T]{labelValues: slices.Clone(labelValues)}
+		v.series[key] = s
+	}
+	f(&s.value)
+}
+
+// each calls f (while locked) with each of the metric's series sorted by their label values so output is stable
+func (v *vec[T]) each(f func(labelValues []string, value *T)) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	all := slices.Collect(maps.Values(v.series))
+	slices.SortFunc(all, func(a, b *series[T]) int { return slices.Compare(a.labelValues, b.labelValues) })
+	for _, s := range all {
+		f(s.labelValues, &s.value)
+	}
+}
+
+// labels formats label names & values as {name="value",...}; extra is appended (ex: a histogram's le label)
+func labels(names, values []string, extra ...string) string {
+	if len(names)+len(extra) == 0 {
+		return ""
+	}
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	pairs := make([]string, 0, len(names)+len(extra)/2)
+	for i, n := range names {
+		pairs = append(pairs, n+`="`+escape.Replace(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escape.Replace(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatFloat formats v as the text exposition format requires
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Counter is a monotonically increasing value per combination of label values.
+type Counter struct{ vec[float64] }
+
+// NewCounter registers & returns a new Counter; by convention, a counter's name ends with "_total".
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{newVec[float64](name, help, "counter", labelNames)}
+	r.register(c)
+	return c
+}
+
+// Add adds delta (which must not be negative) to the series identified by labelValues.
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	aids.Assert(delta >= 0, fmt.Errorf("counter %q can't decrease", c.metricName))
+	c.update(labelValues, func(v *float64) { *v += delta })
+}
+
+// Inc adds 1 to the series identified by labelValues.
+func (c *Counter) Inc(labelValues ...string) { c.Add(1, labelValues...) }
+
+func (c *Counter) writeText(w *bytes.Buffer) {
+	c.writeHeader(w)
+	c.each(func(labelValues []string, value *float64) {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, labels(c.labelNames, labelValues), formatFloat(*value))
+	})
+}
+
+// Gauge is a value that can go up & down per combination of label values.
+type Gauge struct{ vec[float64] }
+
+// NewGauge registers & returns a new Gauge.
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{newVec[float64](name, help, "gauge", labelNames)}
+	r.register(g)
+	return g
+}
+
+// Set sets the series identified by labelValues to value.
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.update(labelValues, func(v *float64) { *v = value })
+}
+
+// Add adds delta (which may be negative) to the series identified by labelValues.
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.update(labelValues, func(v *float64) { *v += delta })
+}
+
+func (g *Gauge) writeText(w *bytes.Buffer) {
+	g.writeHeader(w)
+	g.each(func(labelValues []string, value *float64) {
+		fmt.Fprintf(w, "%s%s %s\n", g.metricName, labels(g.labelNames, labelValues), formatFloat(*value))
+	})
+}
+
+// gaugeFunc is an unlabeled gauge whose value is obtained when the metrics are written
+type gaugeFunc struct {
+	desc
+	f func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is returned by f each time the metrics are written (ex: the number
+// of goroutines); f must be safe for concurrent use.
+func (r *Registry) NewGaugeFunc(name, help string, f func() float64) {
+	r.register(&gaugeFunc{desc: desc{metricName: name, help: help, kind: "gauge"}, f: f})
+}
+
+func (g *gaugeFunc) writeText(w *bytes.Buffer) {
+	g.writeHeader(w)
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatFloat(g.f()))
+}
+
+// Histogram counts observations (ex: latencies) in buckets per combination of label values.
+type Histogram struct {
+	vec[histogramValue]
+	buckets []float64 // Sorted upper bounds; +Inf is implied
+}
+
+type histogramValue struct {
+	counts []uint64 // Per bucket (not cumulative); the last is the +Inf bucket
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram registers & returns a new Histogram whose buckets have the specified upper bounds (DefaultBuckets
+// if nil); by convention, a histogram's name ends with its unit (ex: "_seconds").
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	aids.Assert(!slices.Contains(labelNames, "le"), fmt.Errorf("histogram %q can't have an le label", name))
+	buckets = slices.Clone(aids.Iif(buckets == nil, DefaultBuckets, buckets))
+	slices.Sort(buckets)
+	h := &Histogram{vec: newVec[histogramValue](name, help, "histogram", labelNames), buckets: slices.Compact(buckets)}
+	r.register(h)
+	return h
+}
+
+// Observe adds value to the series identified by labelValues.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	i, _ := slices.BinarySearch(h.buckets, value) // The 1st bucket whose upper bound is >= value
+	h.update(labelValues, func(v *histogramValue) {
+		if v.counts == nil {
+			v.counts = make([]uint64, len(h.buckets)+1)
+		}
+		v.counts[i]++
+		v.sum += value
+		v.count++
+	})
+}
+
+func (h *Histogram) writeText(w *bytes.Buffer) {
+	h.writeHeader(w)
+	h.each(func(labelValues []string, value *histogramValue) {
+		cumulative := uint64(0)
+		for i, upperBound := range append(slices.Clone(h.buckets), math.Inf(1)) {
+			cumulative += value.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, labels(h.labelNames, labelValues, "le", formatFloat(upperBound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, labels(h.labelNames, labelValues), formatFloat(value.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, labels(h.labelNames, labelValues), value.count)
+	})
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("requests_total", "Requests.", "method", "path")
+	c.Inc("GET", `/a"b\`)
+	c.Add(2, "GET", `/a"b\`)
+	c.Inc("PUT", "/x")
+	g := r.NewGauge("queue_depth", "Queue depth\nin messages.")
+	g.Set(5)
+	g.Add(-2)
+	r.NewGaugeFunc("answer", "The answer.", func() float64 { return 42 })
+	h := r.NewHistogram("latency_seconds", "Latency.", []float64{1, 0.1}, "route")
+	for _, v := range []float64{0.05, 0.1, 0.5, 3} {
+		h.Observe(v, "/r")
+	}
+
+	rw := httptest.NewRecorder()
+	r.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rw.Code != http.StatusOK || rw.Header().Get("Content-Type") != TextContentType {
+		t.Fatalf("unexpected response %d %v", rw.Code, rw.Header())
+	}
+	expected := `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{method="GET",path="/a\"b\\"} 3
+requests_total{method="PUT",path="/x"} 1
+# HELP queue_depth Queue depth\nin messages.
+# TYPE queue_depth gauge
+queue_depth 3
+# HELP answer The answer.
+# TYPE answer gauge
+answer 42
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/r",le="0.1"} 2
+latency_seconds_bucket{route="/r",le="1"} 3
+latency_seconds_bucket{route="/r",le="+Inf"} 4
+latency_seconds_sum{route="/r"} 3.65
+latency_seconds_count{route="/r"} 4
+`
+	if actual := rw.Body.String(); actual != expected {
+		t.Fatalf("unexpected exposition:\n%s\nexpected:\n%s", actual, expected)
+	}
+}
+
+func TestRegistryPanics(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("a_total", "A.", "x")
+	for name, f := range map[string]func(){
+		"duplicate name":    func() { r.NewGauge("a_total", "A.") },
+		"invalid name":      func() { r.NewGauge("a-b", "A.") },
+		"invalid label":     func() { r.NewGauge("b", "B.", "__x") },
+		"le label":          func() { r.NewHistogram("c", "C.", nil, "le") },
+		"wrong label count": func() { r.NewCounter("d_total", "D.", "x").Inc() },
+		"negative counter":  func() { r.NewCounter("e_total", "E.").Add(-1) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+	b := &strings.Builder{}
+	if err := r.WriteText(b); err != nil || !strings.Contains(b.String(), "# TYPE a_total counter") {
+		t.Errorf("expected the registered counter to be written, got %v %q", err, b.String())
+	}
+}
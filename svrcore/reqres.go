@@ -2,8 +2,11 @@ package svrcore
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/json/jsontext"
 	"encoding/json/v2"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
@@ -19,9 +22,13 @@ import (
 
 // ReqRes encapsulates the incoming http.Requests and the outgoing http.ResponseWriter and is passed through the set of stages.
 type ReqRes struct {
-	// id is a unique ID for this ReqRes (useful for logging, etc.)
+	// id uniquely identifies this request (the client's X-Request-Id/Client-Request-Id or a new UUIDv7); it's
+	// returned in the Server-Request-Id response header & included in all of the request's logging
 	id string
 
+	// start is when the request's processing started
+	start time.Time
+
 	// R identifies the incoming HTTP request
 	R *http.Request
 
@@ -35,18 +42,21 @@ type ReqRes struct {
 	// s is the slice of stages to execute for this request
 	s stagescore.Stages[*ReqRes, bool]
 
-	// l is the logger for anything related to processing the request & its response
+	// l is the request-scoped logger for anything related to processing the request & its response; see Logger
 	l *slog.Logger
 
+	// apiVersion & route identify the api-version & route (URL pattern) processing the request; set once the request is routed
+	apiVersion, route string
+
 	_ struct{} // Forces use of field names in composite literals
 }
 
-// responseWriter is a custom http.responseWriter that captures the status code.
+// responseWriter is a custom http.responseWriter that captures the status code & the number of body bytes written.
 type responseWriter struct {
 	http.ResponseWriter
 	StatusCode          int
-	numWriteHeaderCalls int // When done request processing, this must be 1 or an error occurred
-	rr                  *ReqRes
+	numWriteHeaderCalls int      // When done request processing, this must be 1 or an error occurred
+	bytesWritten        int64    // The number of response body bytes written
 	_                   struct{} // Forces use of field names in composite literals
 }
 
@@ -55,37 +65,101 @@ func (rww *responseWriter) WriteHeader(statusCode int) {
 	rww.StatusCode = statusCode
 	rww.numWriteHeaderCalls++
 	rww.ResponseWriter.WriteHeader(statusCode)
-	rr := rww.rr
-	rr.l.LogAttrs(rr.R.Context(), slog.LevelInfo, "<-", slog.String("id", rr.id),
-		slog.String("method", rr.R.Method), slog.String("url", rr.R.URL.String()),
-		slog.Int("StatusCode", rww.StatusCode))
 }
 
+// Write overwrites http.ResponseWriter's Write method in order to count the body bytes written.
+func (rww *responseWriter) Write(b []byte) (int, error) {
+	n, err := rww.ResponseWriter.Write(b)
+	rww.bytesWritten += int64(n)
+	return n, err
+}
+
+// Unwrap returns the underlying http.ResponseWriter allowing http.ResponseController to flush, set deadlines, etc.
+func (rww *responseWriter) Unwrap() http.ResponseWriter { return rww.ResponseWriter }
+
 // newReqRes creates a new ReqRes with the specified stages, http.Request, & http.ResponseWriter.
+// If the returned bool is true, an error response was written but the returned ReqRes is still usable for logging.
 func newReqRes(s []Stage, l *slog.Logger, r *http.Request, rw http.ResponseWriter) (*ReqRes, bool) {
+	id := clientRequestID(r.Header)
+	if id == "" {
+		id = newRequestID()
+	}
 	rr := &ReqRes{
-		id: strconv.FormatInt(time.Now().Unix(), 10),
-		s:  s,
-		l:  l,
-		R:  r,
-		H:  &RequestHeader{},
-		RW: &responseWriter{ResponseWriter: rw},
-	}
-	rr.RW.rr = rr
+		id:    id,
+		start: time.Now(),
+		s:     s,
+		l:     l.With(slog.String("request_id", id)),
+		R:     r,
+		H:     &RequestHeader{},
+		RW:    &responseWriter{ResponseWriter: rw},
+	}
 	rw.Header().Set("Server-Request-Id", rr.id) // Set this header now guaranteeing its return to the client
 
-	rr.l.LogAttrs(rr.R.Context(), slog.LevelInfo, "->", slog.String("id", rr.id),
-		slog.String("method", rr.R.Method), slog.String("url", rr.R.URL.String()))
-
 	if err := unmarshalHeaderToStruct(r.Header, rr.H); aids.IsError(err) { // Deserialize standard HTTP request headers into this struct
-		return nil, rr.WriteError(http.StatusBadRequest, nil, nil, "UnparsableHeaders", "The request has some invalid headers: %s", err.Error())
+		return rr, rr.WriteError(http.StatusBadRequest, nil, nil, "UnparsableHeaders", "The request has some invalid headers: %s", err.Error())
 	}
 	return rr, false
 }
 
+// clientRequestID returns the request's X-Request-Id or Client-Request-Id header value so a client's ID correlates
+// its logs with the service's; "" if neither is present or valid. A valid ID is 1-128 printable, non-space ASCII
+// characters (it's logged & echoed in a response header so it mustn't contain anything else).
+func clientRequestID(h http.Header) string {
+	for _, name := range []string{"X-Request-Id", "Client-Request-Id"} {
+		id := h.Get(name)
+		if len(id) == 0 || len(id) > 128 || strings.ContainsFunc(id, func(r rune) bool { return r <= ' ' || r > '~' }) {
+			continue
+		}
+		return id
+	}
+	return ""
+}
+
+// newRequestID returns a new UUIDv7 (RFC 9562): a 48-bit Unix millisecond timestamp followed by 74 random bits so
+// IDs are unique & sort by creation time.
+func newRequestID() string {
+	var u [16]byte
+	_, _ = rand.Read(u[6:]) // guaranteed to return len(b), nil
+	ms := time.Now().UnixMilli()
+	for i := range 6 {
+		u[i] = byte(ms >> (40 - 8*i))
+	}
+	u[6] = u[6]&0x0f | 0x70 // Version 7
+	u[8] = u[8]&0x3f | 0x80 // Variant 10
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
 // Next sends the ReqRes to the next stage.
 func (r *ReqRes) Next(ctx context.Context) bool { return r.s.Next(ctx, r) }
 
+// ID returns the ID uniquely identifying the request; it's also returned in the Server-Request-Id response header.
+func (r *ReqRes) ID() string { return r.id }
+
+// Logger returns the request-scoped logger whose records include the request's ID, its route (once routed) & any
+// attributes added by AddLogAttrs (ex: the caller's tenant).
+func (r *ReqRes) Logger() *slog.Logger { return r.l }
+
+// AddLogAttrs adds attributes to the request-scoped logger's records (including the request's access log line);
+// stages call it as they learn more about the request (ex: an authentication stage adds the caller's tenant).
+// Like all ReqRes methods, it must not be called concurrently with other ReqRes methods.
+func (r *ReqRes) AddLogAttrs(attrs ...slog.Attr) { r.l = slog.New(r.l.Handler().WithAttrs(attrs)) }
+
+// logAccess logs the request's single access log line once its processing completes.
+func (r *ReqRes) logAccess() {
+	r.l.LogAttrs(r.R.Context(), slog.LevelInfo, "Request", slog.String("method", r.R.Method),
+		slog.String("url", r.R.URL.String()), slog.String("api_version", r.apiVersion),
+		slog.Int("status", aids.Iif(r.RW.StatusCode == 0, http.StatusOK, r.RW.StatusCode)), // 0 if the body was written without WriteHeader
+		slog.Int64("bytes", r.RW.bytesWritten), slog.Duration("latency", time.Since(r.start)))
+}
+
+// ApiVersion returns the api-version whose routes processed the request; it is "" until the request is routed
+// (so it's only useful to a stage after calling Next) or if the request was routed without an api-version.
+func (r *ReqRes) ApiVersion() string { return r.apiVersion }
+
+// Route returns the URL pattern (ex: "/items/{id}") of the route that processed the request; it is "" until the
+// request is routed (so it's only useful to a stage after calling Next) or if no route matched.
+func (r *ReqRes) Route() string { return r.route }
+
 // WriteError sets the HTTP response to the specified HTTP status code, response headers, custom headers
 // (a struct with fields/values or nil), errorCode, and message. rh and customHeader must be pointer-to-structures
 // which contain only the following field types:
@@ -106,7 +180,7 @@ func (r *ReqRes) WriteError(statusCode int, rh *ResponseHeader, customHeader any
 // For more control over a response, use ReqRes's RW (ResponseWriter) field directly instead of this method.
 func (r *ReqRes) WriteServerError(se *ServerError, rh *ResponseHeader, customHeader any) bool {
 	// Azure only: rh.XMSErrorCode = &se.ErrorCode
-	r.WriteSuccess(se.StatusCode, rh, customHeader, se.Error())
+	r.WriteSuccess(se.StatusCode, rh, customHeader, jsontext.Value(se.Error())) // Already JSON; don't marshal it as a string
 	return true
 }
 
@@ -151,7 +225,7 @@ func (r *ReqRes) WriteSuccess(statusCode int, rh *ResponseHeader, customHeader a
 				case reflect.Float32, reflect.Float64:
 					rwh.Set(jsonFieldName, strconv.FormatFloat(f.Float(), 'b', 4, 64)) // TODO:fix
 				case reflect.Struct:
-					switch v.Type() {
+					switch f.Type() {
 					case reflect.TypeFor[time.Time]():
 						rwh.Set(jsonFieldName, f.Interface().(time.Time).Format(http.TimeFormat))
 					case reflect.TypeFor[ETag]():
@@ -173,13 +247,99 @@ func (r *ReqRes) WriteSuccess(statusCode int, rh *ResponseHeader, customHeader a
 	fields2Header(r.RW.Header(), rh)
 	fields2Header(r.RW.Header(), customHeader)
 	r.RW.WriteHeader(statusCode)
-	if body != nil {
+	if len(body) > 0 { // Writing even 0 bytes fails for 1xx/204/304 responses with some ResponseWriters
 		_, err = r.RW.Write(body)
 		aids.Assert(!errors.Is(err, http.ErrBodyNotAllowed), "RFC 7230, section 3.3. statusCodes 1xx/204/304 must not have a body")
 	}
 	return false
 }
 
+// WriteContent completes an HTTP response using the passed-in response headers, custom headers (a struct with
+// fields/values or nil), and content's bytes (of type rh.ContentType) as the body (never JSON-marshaled) honoring
+// the request's Range & If-Range headers (RFC 9110 section 14). A satisfiable single byte range is written as
+// 206-Partial Content, an unsatisfiable range as 416-Range Not Satisfiable; a multi-range or malformed Range header
+// is ignored and all the content is written. Callers should check preconditions (CheckPreconditions) first.
+func (r *ReqRes) WriteContent(rh *ResponseHeader, customHeader any, content io.ReadSeeker) bool {
+	if rh == nil {
+		rh = &ResponseHeader{}
+	}
+	size := aids.Must(content.Seek(0, io.SeekEnd))
+	statusCode, start, length := http.StatusOK, int64(0), size
+	rh.AcceptRanges = aids.New("bytes")
+	if r.H.Range != nil && r.rangeApplies(rh) {
+		s, l, satisfiable, ok := parseByteRange(*r.H.Range, size)
+		switch {
+		case ok && !satisfiable:
+			rh.ContentRange = aids.New("bytes */" + strconv.FormatInt(size, 10))
+			return r.WriteError(http.StatusRequestedRangeNotSatisfiable, rh, customHeader, "RangeNotSatisfiable", "range %q not satisfiable for %d bytes", *r.H.Range, size)
+		case ok:
+			statusCode, start, length = http.StatusPartialContent, s, l
+			rh.ContentRange = aids.New("bytes " + strconv.FormatInt(start, 10) + "-" + strconv.FormatInt(start+length-1, 10) + "/" + strconv.FormatInt(size, 10))
+		}
+	}
+	rh.ContentLength = aids.New(int(length))
+	r.WriteSuccess(statusCode, rh, customHeader, nil)
+	if r.R.Method == http.MethodHead {
+		return false
+	}
+	aids.Must(content.Seek(start, io.SeekStart))
+	if _, err := io.CopyN(r.RW, content, length); aids.IsError(err) {
+		return true // Client likely disconnected; the status code has already been sent
+	}
+	return false
+}
+
+// rangeApplies returns true if the request has no If-Range header or its ETag/date matches the content's
+func (r *ReqRes) rangeApplies(rh *ResponseHeader) bool {
+	if r.H.IfRange == nil {
+		return true
+	}
+	if t, err := http.ParseTime(*r.H.IfRange); err == nil {
+		return rh.LastModified != nil && rh.LastModified.Truncate(time.Second).Equal(t)
+	}
+	return rh.ETag != nil && !rh.ETag.IsWeak() && ETag(*r.H.IfRange).Equals(*rh.ETag) // If-Range requires strong comparison
+}
+
+// parseByteRange parses a Range header value for content of size bytes returning the range's start & length.
+// ok is false if the header must be ignored (not a single "bytes" range or malformed); satisfiable is false if
+// the range starts at or beyond the end of the content.
+func parseByteRange(header string, size int64) (start, length int64, satisfiable, ok bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false, false
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false, false
+	}
+	if first == "" { // Suffix range: "-N" is the last N bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false, false
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, false, true
+		}
+		n = min(n, size)
+		return size - n, n, true, true
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false, false
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, 0, false, false
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return 0, 0, false, true
+	}
+	return start, end - start + 1, true, true
+}
+
 func (rr *ReqRes) numWriteHeaderCalls() int {
 	return rr.RW.numWriteHeaderCalls
 }
@@ -192,6 +352,10 @@ type RequestHeader struct { // HTTP/2 requires 'json' field names be lowercase
 	Authorization  *string    `json:"authorization"`
 	UserAgent      *string    `json:"user-agent"`
 	IdempotencyKey *string    `json:"idempotency-key"` // https://www.ietf.org/archive/id/draft-ietf-httpapi-idempotency-key-header-01.html
+	Prefer         []string   `json:"prefer"`          // https://www.rfc-editor.org/rfc/rfc7240
+	LastEventID    *string    `json:"last-event-id"`   // https://html.spec.whatwg.org/multipage/server-sent-events.html
+	Range          *string    `json:"range"`           // https://www.rfc-editor.org/rfc/rfc9110#section-14.2
+	IfRange        *string    `json:"if-range"`        // ETag or HTTP-date; https://www.rfc-editor.org/rfc/rfc9110#section-13.1.5
 
 	// Message Body Information
 	ContentLength   *int64  `json:"content-length"`
@@ -213,6 +377,21 @@ type RequestHeader struct { // HTTP/2 requires 'json' field names be lowercase
 	_              struct{} `json:"-"` // Forces use of field names in composite literals
 }
 
+// Preference returns the value of the named preference from the request's Prefer headers (RFC 7240) and
+// whether the preference was present. Preference parameters (after ';') are ignored.
+func (h *RequestHeader) Preference(name string) (string, bool) {
+	for _, prefer := range h.Prefer {
+		for pref := range strings.SplitSeq(prefer, ",") {
+			pref, _, _ = strings.Cut(pref, ";")
+			k, v, _ := strings.Cut(pref, "=")
+			if strings.EqualFold(strings.TrimSpace(k), name) {
+				return strings.Trim(strings.TrimSpace(v), `"`), true
+			}
+		}
+	}
+	return "", false
+}
+
 type ResponseHeader struct { // HTTP/2 requires 'json' field names be lowercase
 	// Versioning & Conditionals
 	ETag         *ETag      `json:"etag"`
@@ -224,12 +403,17 @@ type ResponseHeader struct { // HTTP/2 requires 'json' field names be lowercase
 	ContentEncoding    *string `json:"content-encoding"`
 	ContentRange       *string `json:"content-range"`
 	ContentDisposition *string `json:"content-disposition"`
+	AcceptRanges       *string `json:"accept-ranges"`
 
 	// Response Context
 	RetryAfter *int32 `json:"retry-after"` // Seconds
 
+	// Authentication
+	WWWAuthenticate *string `json:"www-authenticate"`
+
 	// Caching headers
-	Expires *time.Time `json:"expires" time:"RFC1123"`
+	CacheControl *string    `json:"cache-control"`
+	Expires      *time.Time `json:"expires" time:"RFC1123"`
 
 	/* CORS:
 	AccessControlAllowCredentials *string `json:"access-control-allow-credentials"`
@@ -350,7 +534,7 @@ func (r *ReqRes) UnmarshalQuery(s any) bool {
 	if err := unmarshalQueryToStruct(values, s); aids.IsError(err) {
 		return r.WriteError(http.StatusBadRequest, nil, nil, "Invalid query parameters", "%s", err.Error())
 	}
-	uf := reflect.ValueOf(s).FieldByName("Unknown").Interface().(Unknown)
+	uf := reflect.ValueOf(s).Elem().FieldByName("Unknown").Interface().(Unknown)
 	if len(uf) > 0 { // if any unrecognized query parameters, 400-BadRequest
 		return r.WriteError(http.StatusBadRequest, nil, nil, "", "Unrecognized query parameters: %s", strings.Join(uf, ", "))
 	}
@@ -1,10 +1,13 @@
 package svrcore
 
 import (
+	"bytes"
 	"context"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -21,6 +24,82 @@ func TestNewReqRes(t *testing.T) {
 	}
 }
 
+func TestRequestID(t *testing.T) {
+	newID := func(h http.Header) string {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header = h
+		rw := httptest.NewRecorder()
+		rr, _ := newReqRes(nil, slog.New(slog.DiscardHandler), r, rw)
+		if rw.Header().Get("Server-Request-Id") != rr.ID() {
+			t.Fatalf("expected Server-Request-Id %q, got %q", rr.ID(), rw.Header().Get("Server-Request-Id"))
+		}
+		return rr.ID()
+	}
+	uuidv7 := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	ids := map[string]bool{}
+	for range 1000 { // All created within the same second (& likely millisecond)
+		id := newID(http.Header{})
+		if !uuidv7.MatchString(id) || ids[id] {
+			t.Fatalf("expected a unique UUIDv7, got %q", id)
+		}
+		ids[id] = true
+	}
+	if id := newID(http.Header{"X-Request-Id": []string{"abc-123"}, "Client-Request-Id": []string{"def"}}); id != "abc-123" {
+		t.Fatalf("expected the X-Request-Id, got %q", id)
+	}
+	if id := newID(http.Header{"Client-Request-Id": []string{"def"}}); id != "def" {
+		t.Fatalf("expected the Client-Request-Id, got %q", id)
+	}
+	for _, invalid := range []string{"has space", "new\nline", strings.Repeat("x", 129)} {
+		if id := newID(http.Header{"X-Request-Id": []string{invalid}}); !uuidv7.MatchString(id) {
+			t.Fatalf("expected invalid %q to be replaced by a UUIDv7, got %q", invalid, id)
+		}
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	logs := &bytes.Buffer{}
+	handler := BuildHandler(BuildHandlerConfig{
+		Stages: []Stage{func(ctx context.Context, r *ReqRes) bool {
+			r.AddLogAttrs(slog.String("tenant", "contoso"))
+			return r.Next(ctx)
+		}},
+		ApiVersionInfos: []*ApiVersionInfo{{ApiVersion: "2025-01-01", GetRoutes: func(ApiVersionRoutes) ApiVersionRoutes {
+			return ApiVersionRoutes{"/items/{id}": {"GET": {Stage: func(ctx context.Context, r *ReqRes) bool {
+				r.Logger().Info("Getting item")
+				return r.WriteSuccess(http.StatusOK, nil, nil, map[string]string{"id": r.R.PathValue("id")})
+			}}}}
+		}}},
+		ApiVersionKeyName:     "Api-Version",
+		ApiVersionKeyLocation: ApiVersionKeyLocationHeader,
+		Logger:                slog.New(slog.NewJSONHandler(logs, nil)),
+	})
+	req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	req.Header.Set("Api-Version", "2025-01-01")
+	req.Header.Set("X-Request-Id", "req-1")
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+	if rw.Header().Get("Server-Request-Id") != "req-1" {
+		t.Fatalf("expected the X-Request-Id to be echoed, got %q", rw.Header().Get("Server-Request-Id"))
+	}
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected the route's log & 1 access log line, got:\n%s", logs)
+	}
+	for _, line := range lines {
+		record := aids.MustUnmarshal[map[string]any]([]byte(line))
+		if record["request_id"] != "req-1" || record["route"] != "/items/{id}" || record["tenant"] != "contoso" {
+			t.Fatalf("expected the request-scoped attributes, got %s", line)
+		}
+	}
+	access := aids.MustUnmarshal[map[string]any]([]byte(lines[1]))
+	if access["msg"] != "Request" || access["method"] != "GET" || access["url"] != "/items/1" || access["api_version"] != "2025-01-01" ||
+		access["status"] != float64(http.StatusOK) || access["bytes"] != float64(rw.Body.Len()) || access["latency"] == nil {
+		t.Fatalf("unexpected access log line %s", lines[1])
+	}
+}
+
 func TestUnmarshalRequestHeader(t *testing.T) {
 	rh := RequestHeader{}
 	err := unmarshalHeaderToStruct(http.Header{
@@ -44,6 +123,21 @@ func TestUnmarshalRequestHeader(t *testing.T) {
 	}
 }
 
+func TestRequestHeaderPreference(t *testing.T) {
+	rh := RequestHeader{}
+	if err := unmarshalHeaderToStruct(http.Header{"Prefer": []string{"respond-async, Wait=30", `handling="lenient"; x=1`}}, &rh); aids.IsError(err) {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"wait": "30", "respond-async": "", "handling": "lenient"} {
+		if v, ok := rh.Preference(name); !ok || v != want {
+			t.Fatalf("wanted preference %q to be %q, got %q (present=%t)", name, want, v, ok)
+		}
+	}
+	if _, ok := rh.Preference("return"); ok {
+		t.Fatal("expected 'return' preference to be absent")
+	}
+}
+
 func TestRequestHeaderVerifyStructFields(t *testing.T) {
 	tests := []struct {
 		name string
@@ -542,3 +636,60 @@ func TestValidatePreconditions(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteContent(t *testing.T) {
+	lastModified := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		headers      map[string]string
+		expectedCode int
+		contentRange string
+		body         string
+	}{
+		{name: "no range", expectedCode: http.StatusOK, body: "0123456789"},
+		{name: "range", headers: map[string]string{"Range": "bytes=2-5"}, expectedCode: http.StatusPartialContent, contentRange: "bytes 2-5/10", body: "2345"},
+		{name: "open-ended range", headers: map[string]string{"Range": "bytes=7-"}, expectedCode: http.StatusPartialContent, contentRange: "bytes 7-9/10", body: "789"},
+		{name: "suffix range", headers: map[string]string{"Range": "bytes=-3"}, expectedCode: http.StatusPartialContent, contentRange: "bytes 7-9/10", body: "789"},
+		{name: "range past end", headers: map[string]string{"Range": "bytes=8-100"}, expectedCode: http.StatusPartialContent, contentRange: "bytes 8-9/10", body: "89"},
+		{name: "unsatisfiable range", headers: map[string]string{"Range": "bytes=10-"}, expectedCode: http.StatusRequestedRangeNotSatisfiable, contentRange: "bytes */10"},
+		{name: "multiple ranges ignored", headers: map[string]string{"Range": "bytes=0-1,4-5"}, expectedCode: http.StatusOK, body: "0123456789"},
+		{name: "malformed range ignored", headers: map[string]string{"Range": "bytes=5-2"}, expectedCode: http.StatusOK, body: "0123456789"},
+		{name: "if-range etag matches", headers: map[string]string{"Range": "bytes=0-0", "If-Range": "123"}, expectedCode: http.StatusPartialContent, contentRange: "bytes 0-0/10", body: "0"},
+		{name: "if-range etag doesn't match", headers: map[string]string{"Range": "bytes=0-0", "If-Range": "456"}, expectedCode: http.StatusOK, body: "0123456789"},
+		{name: "if-range date matches", headers: map[string]string{"Range": "bytes=0-0", "If-Range": lastModified.Format(http.TimeFormat)}, expectedCode: http.StatusPartialContent, contentRange: "bytes 0-0/10", body: "0"},
+		{name: "if-range date doesn't match", headers: map[string]string{"Range": "bytes=0-0", "If-Range": lastModified.Add(-time.Hour).Format(http.TimeFormat)}, expectedCode: http.StatusOK, body: "0123456789"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://localhost", nil)
+			if aids.IsError(err) {
+				t.Fatal(err)
+			}
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rw := httptest.NewRecorder()
+			rr, stop := newReqRes(nil, slog.New(slog.DiscardHandler), req, rw)
+			if stop {
+				t.Fatal("newReqRes failed")
+			}
+			rr.WriteContent(&ResponseHeader{ETag: aids.New(ETag("123")), LastModified: aids.New(lastModified), ContentType: aids.New("text/plain")}, nil,
+				strings.NewReader("0123456789"))
+			if rw.Code != tt.expectedCode {
+				t.Fatalf("expected %q, got %q", http.StatusText(tt.expectedCode), http.StatusText(rw.Code))
+			}
+			if actual := rw.Header().Get("Content-Range"); actual != tt.contentRange {
+				t.Fatalf("expected Content-Range %q, got %q", tt.contentRange, actual)
+			}
+			if tt.expectedCode == http.StatusRequestedRangeNotSatisfiable {
+				return
+			}
+			if actual := rw.Body.String(); actual != tt.body {
+				t.Fatalf("expected body %q, got %q", tt.body, actual)
+			}
+			if actual := rw.Header().Get("Last-Modified"); actual != lastModified.Format(http.TimeFormat) {
+				t.Fatalf("expected Last-Modified %q, got %q", lastModified.Format(http.TimeFormat), actual)
+			}
+		})
+	}
+}
@@ -10,9 +10,11 @@ import (
 // ServerError represents a standard Service HTTP error response as documented here:
 // https://www.rfc-editor.org/rfc/rfc9457.html
 type ServerError struct {
-	StatusCode int    `json:"-"`
-	ErrorCode  string `json:"code"`
-	Message    string `json:"message,omitempty"`
+	StatusCode int            `json:"-"`
+	ErrorCode  string         `json:"code"`
+	Message    string         `json:"message,omitempty"`
+	Target     string         `json:"target,omitempty"`  // The error's source (ex: a JSON Pointer into the request body)
+	Details    []*ServerError `json:"details,omitempty"` // The specific errors that caused this error
 }
 
 func NewServerError(statusCode int, errorCode, messageFmt string, a ...any) *ServerError {
@@ -0,0 +1,340 @@
+package stages
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Tenant  string   // The tenant the caller belongs to; all of the caller's resources are scoped to it
+	Subject string   // The caller's identity within the tenant (the JWT's "sub" claim)
+	Scopes  []string // The operations the caller is allowed to perform
+}
+
+// HasScope returns true if the principal was granted scope
+func (p *Principal) HasScope(scope string) bool { return slices.Contains(p.Scopes, scope) }
+
+type principalKey struct{}
+
+// NewPrincipalContext returns a copy of ctx carrying p
+func NewPrincipalContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the Principal the authentication stage put on ctx or false if there is none
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// JWTKeys maps a key ID ("kid") to a key that verifies JWT signatures: *rsa.PublicKey (RS*/PS*),
+// *ecdsa.PublicKey (ES*), ed25519.PublicKey (EdDSA), or []byte (HS*).
+type JWTKeys map[string]any
+
+// LoadJWKS reads a JSON Web Key Set (RFC 7517) file into JWTKeys.
+func LoadJWKS(path string) (JWTKeys, error) {
+	b, err := os.ReadFile(path)
+	if aids.IsError(err) {
+		return nil, err
+	}
+	return ParseJWKS(b)
+}
+
+// ParseJWKS parses a JSON Web Key Set (RFC 7517) into JWTKeys; RSA, EC (P-256/384/521), OKP (Ed25519) & oct
+// keys are supported. Every key must have a unique "kid".
+func ParseJWKS(b []byte) (JWTKeys, error) {
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+			K   string `json:"k"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &jwks); aids.IsError(err) {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+	keys := JWTKeys{}
+	for _, k := range jwks.Keys {
+		if _, ok := keys[k.Kid]; ok || k.Kid == "" {
+			return nil, fmt.Errorf("invalid JWKS: every key must have a unique kid; %q", k.Kid)
+		}
+		key, err := any(nil), error(nil)
+		switch k.Kty {
+		case "RSA":
+			n, e := b64Int(k.N), b64Int(k.E)
+			if n == nil || e == nil || !e.IsInt64() {
+				return nil, fmt.Errorf("invalid JWKS: RSA key %q requires n & e", k.Kid)
+			}
+			key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			curve := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}[k.Crv]
+			x, y := b64Int(k.X), b64Int(k.Y)
+			if curve == nil || x == nil || y == nil {
+				return nil, fmt.Errorf("invalid JWKS: EC key %q requires a supported crv, x & y", k.Kid)
+			}
+			key = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		case "OKP":
+			x, _ := base64.RawURLEncoding.DecodeString(k.X)
+			if k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("invalid JWKS: OKP key %q must be an Ed25519 key", k.Kid)
+			}
+			key = ed25519.PublicKey(x)
+		case "oct":
+			key, err = base64.RawURLEncoding.DecodeString(k.K)
+			if aids.IsError(err) || len(key.([]byte)) == 0 {
+				return nil, fmt.Errorf("invalid JWKS: oct key %q requires k", k.Kid)
+			}
+		default:
+			return nil, fmt.Errorf("invalid JWKS: key %q has unsupported kty %q", k.Kid, k.Kty)
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+// b64Int decodes a base64url-encoded big-endian unsigned integer; it returns nil if s is invalid
+func b64Int(s string) *big.Int {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if aids.IsError(err) || len(b) == 0 {
+		return nil
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+// AuthConfig configures the stage created by NewAuthStage.
+type AuthConfig struct {
+	// Keys verify the JWTs' signatures; a JWT's "kid" header selects the key (or all keys are tried if it has none).
+	Keys JWTKeys
+
+	// Issuer, if not empty, must equal the JWT's "iss" claim.
+	Issuer string
+
+	// Audience, if not empty, must be in the JWT's "aud" claim.
+	Audience string
+
+	// TenantClaim is the name of the JWT claim holding the caller's tenant; the default is "tid".
+	TenantClaim string
+
+	// ClockSkew is the leeway allowed when checking the JWT's "exp" & "nbf" claims; the default is 1 minute.
+	ClockSkew time.Duration
+
+	// Anonymous, if not nil, returns true for requests allowed without a JWT (ex: health probes).
+	Anonymous func(r *svrcore.ReqRes) bool
+
+	// ProtectedResource, if not nil, makes 401 challenges carry a "resource_metadata" parameter with the URL of
+	// the resource's metadata (RFC 9728) so clients can discover where to get a token; the metadata itself is
+	// always allowed without a JWT.
+	ProtectedResource *ProtectedResourceConfig
+}
+
+// challenge returns a "WWW-Authenticate: Bearer" challenge (RFC 6750) with the optional error & (if configured)
+// the resource's metadata URL
+func (c *AuthConfig) challenge(r *svrcore.ReqRes, errorCode string) *string {
+	params := []string{}
+	if c.ProtectedResource != nil {
+		params = append(params, fmt.Sprintf(`resource_metadata="%s"`, c.ProtectedResource.MetadataURL(r.R)))
+	}
+	if errorCode != "" {
+		params = append(params, fmt.Sprintf(`error="%s"`, errorCode))
+	}
+	return aids.New(strings.TrimSpace("Bearer " + strings.Join(params, ", ")))
+}
+
+// NewAuthStage creates a stage that authenticates requests via an "Authorization: Bearer <JWT>" header & passes
+// the caller's Principal to the next stages via the context (see PrincipalFromContext). Requests with a missing,
+// invalid, or expired JWT get a 401-Unauthorized response with a WWW-Authenticate challenge (RFC 6750) that, if
+// c.ProtectedResource is set, points at the resource's metadata (RFC 9728).
+func NewAuthStage(c AuthConfig) svrcore.Stage {
+	c.TenantClaim = aids.Iif(c.TenantClaim == "", "tid", c.TenantClaim)
+	c.ClockSkew = aids.Iif(c.ClockSkew == 0, time.Minute, c.ClockSkew)
+	return func(ctx context.Context, r *svrcore.ReqRes) bool {
+		if (c.Anonymous != nil && c.Anonymous(r)) || (c.ProtectedResource != nil && isMetadataPath(r.R.URL.Path)) {
+			return r.Next(ctx)
+		}
+		token, ok := "", false
+		if r.H.Authorization != nil {
+			scheme, t, _ := strings.Cut(*r.H.Authorization, " ")
+			token, ok = strings.TrimSpace(t), strings.EqualFold(scheme, "Bearer")
+		}
+		if !ok || token == "" {
+			return r.WriteError(http.StatusUnauthorized, &svrcore.ResponseHeader{WWWAuthenticate: c.challenge(r, "")}, nil,
+				"Unauthorized", "Authorization: Bearer <token> header required")
+		}
+		p, err := c.verify(token, time.Now())
+		if aids.IsError(err) {
+			return r.WriteError(http.StatusUnauthorized, &svrcore.ResponseHeader{WWWAuthenticate: c.challenge(r, "invalid_token")}, nil,
+				"InvalidToken", "%s", err.Error())
+		}
+		r.AddLogAttrs(slog.String("tenant", p.Tenant))
+		return r.Next(NewPrincipalContext(ctx, p))
+	}
+}
+
+// jwtHeader is a JWT's JOSE header
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// verify checks token's signature & registered claims returning the Principal it identifies
+func (c *AuthConfig) verify(token string, now time.Time) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token must be a JWS compact serialization")
+	}
+	var h jwtHeader
+	if err := decodeSegment(parts[0], &h); aids.IsError(err) {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if aids.IsError(err) {
+		return nil, errors.New("invalid token signature encoding")
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	if h.Kid != "" {
+		key, ok := c.Keys[h.Kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", h.Kid)
+		}
+		verified = verifySignature(h.Alg, key, signed, sig)
+	} else {
+		for _, key := range c.Keys {
+			if verified = verifySignature(h.Alg, key, signed, sig); verified {
+				break
+			}
+		}
+	}
+	if !verified {
+		return nil, errors.New("token signature is invalid")
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); aids.IsError(err) {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("token must have an exp claim")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(c.ClockSkew)) {
+		return nil, errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(c.ClockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("token not valid yet")
+	}
+	if iss, _ := claims["iss"].(string); c.Issuer != "" && iss != c.Issuer {
+		return nil, fmt.Errorf("token issuer %q is not trusted", iss)
+	}
+	if c.Audience != "" && !slices.Contains(stringsClaim(claims["aud"]), c.Audience) {
+		return nil, errors.New("token audience doesn't include this service")
+	}
+	p := &Principal{Scopes: []string{}}
+	p.Subject, _ = claims["sub"].(string)
+	p.Tenant, _ = claims[c.TenantClaim].(string)
+	if p.Subject == "" || p.Tenant == "" {
+		return nil, fmt.Errorf("token must have sub & %s claims", c.TenantClaim)
+	}
+	// OAuth 2.0 puts scopes in a space-separated "scope" claim (RFC 8693); some issuers use "scp" (string or array)
+	for _, name := range []string{"scope", "scp"} {
+		for _, s := range stringsClaim(claims[name]) {
+			p.Scopes = append(p.Scopes, strings.Fields(s)...)
+		}
+	}
+	return p, nil
+}
+
+// decodeSegment base64url-decodes a JWT segment & unmarshals its JSON into v
+func decodeSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if aids.IsError(err) {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// stringsClaim returns a claim that's a string or an array of strings as a slice
+func stringsClaim(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		s := []string{}
+		for _, e := range v {
+			if e, ok := e.(string); ok {
+				s = append(s, e)
+			}
+		}
+		return s
+	}
+	return nil
+}
+
+// verifySignature returns true if sig is alg's signature of signed by key; the key's type must match alg's
+// family so, for example, an RSA public key can never be used as an HMAC secret.
+func verifySignature(alg string, key any, signed, sig []byte) bool {
+	hash := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}[alg[min(2, len(alg)):]]
+	if alg == "EdDSA" {
+		k, ok := key.(ed25519.PublicKey)
+		return ok && ed25519.Verify(k, signed, sig)
+	}
+	if hash == 0 {
+		return false // Includes "none"
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+	switch alg[:2] {
+	case "HS":
+		k, ok := key.([]byte)
+		if !ok {
+			return false
+		}
+		mac := hmac.New(hash.New, k)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), sig)
+	case "RS":
+		k, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(k, hash, digest, sig) == nil
+	case "PS":
+		k, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPSS(k, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+	case "ES":
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok || k.Params().BitSize != map[crypto.Hash]int{crypto.SHA256: 256, crypto.SHA384: 384, crypto.SHA512: 521}[hash] {
+			return false
+		}
+		size := (k.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	}
+	return false
+}
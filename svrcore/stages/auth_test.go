@@ -0,0 +1,152 @@
+package stages
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+)
+
+// signJWT returns a JWT with header & claims signed by key using alg
+func signJWT(alg string, key any, header map[string]any, claims map[string]any) string {
+	header["alg"] = alg
+	signed := b64JSON(header) + "." + b64JSON(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig := []byte(nil)
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		sig = aids.Must(rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]))
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		aids.Must0(err)
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(signed))
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func b64JSON(v any) string { return base64.RawURLEncoding.EncodeToString(aids.Must(json.Marshal(v))) }
+
+func TestAuthVerify(t *testing.T) {
+	rsaKey := aids.Must(rsa.GenerateKey(rand.Reader, 2048))
+	ecKey := aids.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	aids.Must0(err)
+	hmacKey := []byte("0123456789abcdef0123456789abcdef")
+	c := &AuthConfig{
+		Keys:        JWTKeys{"rsa": &rsaKey.PublicKey, "ec": &ecKey.PublicKey, "ed": edPub, "hmac": hmacKey},
+		Issuer:      "https://issuer.example",
+		Audience:    "api://mcpsvr",
+		TenantClaim: "tid",
+		ClockSkew:   time.Minute,
+	}
+	now := time.Now()
+	claims := func(changes map[string]any) map[string]any {
+		m := map[string]any{"iss": "https://issuer.example", "aud": []string{"other", "api://mcpsvr"}, "sub": "user1",
+			"tid": "tenant1", "exp": now.Add(time.Hour).Unix(), "scope": "tools.read tools.call"}
+		for k, v := range changes {
+			if v == nil {
+				delete(m, k)
+			} else {
+				m[k] = v
+			}
+		}
+		return m
+	}
+
+	for name, token := range map[string]string{
+		"RS256":    signJWT("RS256", rsaKey, map[string]any{"kid": "rsa"}, claims(nil)),
+		"ES256":    signJWT("ES256", ecKey, map[string]any{"kid": "ec"}, claims(nil)),
+		"EdDSA":    signJWT("EdDSA", edKey, map[string]any{"kid": "ed"}, claims(nil)),
+		"HS256":    signJWT("HS256", hmacKey, map[string]any{"kid": "hmac"}, claims(nil)),
+		"no kid":   signJWT("ES256", ecKey, map[string]any{}, claims(nil)),
+		"aud str":  signJWT("HS256", hmacKey, map[string]any{"kid": "hmac"}, claims(map[string]any{"aud": "api://mcpsvr"})),
+		"skew exp": signJWT("HS256", hmacKey, map[string]any{"kid": "hmac"}, claims(map[string]any{"exp": now.Add(-30 * time.Second).Unix()})),
+	} {
+		p, err := c.verify(token, now)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if p.Tenant != "tenant1" || p.Subject != "user1" || !slices.Equal(p.Scopes, []string{"tools.read", "tools.call"}) || !p.HasScope("tools.call") {
+			t.Errorf("%s: unexpected principal %+v", name, p)
+		}
+	}
+
+	rsaPub := rsaKey.N.Bytes() // Public key bytes an attacker could use as an HMAC secret
+	for name, token := range map[string]string{
+		"expired":       signJWT("HS256", hmacKey, map[string]any{"kid": "hmac"}, claims(map[string]any{"exp": now.Add(-2 * time.Minute).Unix()})),
+		"no exp":        signJWT("HS256", hmacKey, map[string]any{"kid": "hmac"}, claims(map[string]any{"exp": nil})),
+		"not yet valid": signJWT("HS256", hmacKey, map[string]any{"kid": "hmac"}, claims(map[string]any{"nbf": now.Add(time.Hour).Unix()})),
+		"wrong issuer":  signJWT("HS256", hmacKey, map[string]any{"kid": "hmac"}, claims(map[string]any{"iss": "https://evil.example"})),
+		"wrong aud":     signJWT("HS256", hmacKey, map[string]any{"kid": "hmac"}, claims(map[string]any{"aud": "api://other"})),
+		"no tenant":     signJWT("HS256", hmacKey, map[string]any{"kid": "hmac"}, claims(map[string]any{"tid": nil})),
+		"no subject":    signJWT("HS256", hmacKey, map[string]any{"kid": "hmac"}, claims(map[string]any{"sub": nil})),
+		"unknown kid":   signJWT("HS256", hmacKey, map[string]any{"kid": "other"}, claims(nil)),
+		"wrong key":     signJWT("HS256", []byte("wrong"), map[string]any{"kid": "hmac"}, claims(nil)),
+		"alg mismatch":  signJWT("HS256", rsaPub, map[string]any{"kid": "rsa"}, claims(nil)), // An RSA key must never be an HMAC secret
+		"alg none":      strings.TrimSuffix(signJWT("none", nil, map[string]any{"kid": "hmac"}, claims(nil)), "."),
+		"not a JWT":     "abc.def",
+	} {
+		if _, err := c.verify(token, now); err == nil {
+			t.Errorf("%s: expected verification to fail", name)
+		}
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaKey := aids.Must(rsa.GenerateKey(rand.Reader, 2048))
+	ecKey := aids.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks := fmt.Sprintf(`{"keys":[
+		{"kty":"RSA","kid":"rsa","n":%q,"e":"AQAB"},
+		{"kty":"EC","kid":"ec","crv":"P-256","x":%q,"y":%q},
+		{"kty":"oct","kid":"hmac","k":%q}]}`,
+		b64(rsaKey.N.Bytes()), b64(ecKey.X.FillBytes(make([]byte, 32))), b64(ecKey.Y.FillBytes(make([]byte, 32))), b64([]byte("secret")))
+	keys, err := ParseJWKS([]byte(jwks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &AuthConfig{Keys: keys, TenantClaim: "tid"}
+	claims := map[string]any{"sub": "user1", "tid": "tenant1", "exp": time.Now().Add(time.Hour).Unix()}
+	for _, token := range []string{
+		signJWT("RS256", rsaKey, map[string]any{"kid": "rsa"}, claims),
+		signJWT("ES256", ecKey, map[string]any{"kid": "ec"}, claims),
+		signJWT("HS256", []byte("secret"), map[string]any{"kid": "hmac"}, claims),
+	} {
+		if _, err := c.verify(token, time.Now()); err != nil {
+			t.Error(err)
+		}
+	}
+
+	for _, bad := range []string{
+		`not json`,
+		`{"keys":[{"kty":"RSA","n":"AQAB","e":"AQAB"}]}`,                                           // No kid
+		`{"keys":[{"kty":"oct","kid":"a","k":"c2VjcmV0"},{"kty":"oct","kid":"a","k":"c2VjcmV0"}]}`, // Duplicate kid
+		`{"keys":[{"kty":"EC","kid":"a","crv":"P-192","x":"AQAB","y":"AQAB"}]}`,                    // Unsupported curve
+		`{"keys":[{"kty":"OKP","kid":"a","crv":"Ed25519","x":"AQAB"}]}`,                            // Wrong key size
+		`{"keys":[{"kty":"XYZ","kid":"a"}]}`,
+	} {
+		if _, err := ParseJWKS([]byte(bad)); err == nil {
+			t.Errorf("expected %s to fail parsing", bad)
+		}
+	}
+}
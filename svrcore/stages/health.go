@@ -0,0 +1,139 @@
+package stages
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+// HealthChecker is implemented by a dependency (ex: a store or a queue processor) that the readiness probe checks.
+type HealthChecker interface {
+	// CheckHealth returns nil if the dependency can be used to process requests; else an error describing why not.
+	CheckHealth(ctx context.Context) error
+}
+
+// HealthCheckerFunc adapts an ordinary function to a HealthChecker.
+type HealthCheckerFunc func(ctx context.Context) error
+
+// CheckHealth calls f(ctx).
+func (f HealthCheckerFunc) CheckHealth(ctx context.Context) error { return f(ctx) }
+
+// HealthProbesConfig configures the probes created by NewHealthProbes.
+type HealthProbesConfig struct {
+	// Checkers are the dependencies checked by the readiness probe keyed by the names reported in its response.
+	Checkers map[string]HealthChecker
+
+	// ShuttingDown, if not nil, makes the readiness probe fail once it returns true (ex: ShutdownMgr.ShuttingDown).
+	ShuttingDown func() bool
+
+	// CacheDuration is how long the checkers' results are reused so frequent probes don't hammer the dependencies;
+	// the default is 5 seconds.
+	CacheDuration time.Duration
+
+	// Timeout is the longest each checker may take before it's reported unhealthy; the default is 2 seconds.
+	Timeout time.Duration
+}
+
+// HealthProbes provides liveness & readiness probe stages. A failing liveness probe means the process must be
+// restarted; a failing readiness probe means the process mustn't be sent requests (ex: a dependency is unreachable
+// or the process is shutting down).
+type HealthProbes struct {
+	config  HealthProbesConfig
+	mu      sync.Mutex // Serializes checks so concurrent probes share one check's results
+	checked time.Time  // When results were produced
+	results map[string]HealthCheckResult
+}
+
+// HealthCheckResult is one dependency's result in a readiness probe's response.
+type HealthCheckResult struct {
+	Status   HealthStatus `json:"status"`
+	Error    string       `json:"error,omitempty"`
+	Duration string       `json:"duration"` // How long the check took (ex: "1.5ms")
+}
+
+// HealthReport is a probe's JSON response body.
+type HealthReport struct {
+	Status    HealthStatus                 `json:"status"`
+	CheckedAt *time.Time                   `json:"checkedAt,omitempty"` // When the checks ran; they may be cached
+	Checks    map[string]HealthCheckResult `json:"checks,omitempty"`
+}
+
+// HealthStatus indicates whether a probe or dependency is healthy.
+type HealthStatus string
+
+const (
+	HealthStatusHealthy   HealthStatus = "healthy"
+	HealthStatusUnhealthy HealthStatus = "unhealthy"
+)
+
+// NewHealthProbes creates HealthProbes using the passed-in HealthProbesConfig.
+func NewHealthProbes(c HealthProbesConfig) *HealthProbes {
+	c.CacheDuration = aids.Iif(c.CacheDuration == 0, 5*time.Second, c.CacheDuration)
+	c.Timeout = aids.Iif(c.Timeout == 0, 2*time.Second, c.Timeout)
+	return &HealthProbes{config: c}
+}
+
+// LiveProbe can be called in response to an HTTP GET. It returns 200-OK if the process can respond to requests;
+// dependencies aren't checked since restarting the process doesn't fix them.
+func (hp *HealthProbes) LiveProbe(ctx context.Context, r *svrcore.ReqRes) bool {
+	return r.WriteSuccess(http.StatusOK, &svrcore.ResponseHeader{CacheControl: aids.New("no-store")}, nil, HealthReport{Status: HealthStatusHealthy})
+}
+
+// ReadyProbe can be called in response to an HTTP GET. It returns 200-OK if all the checkers are healthy & the
+// process isn't shutting down; else 503-ServiceUnavailable. The body is a HealthReport with each checker's result.
+func (hp *HealthProbes) ReadyProbe(ctx context.Context, r *svrcore.ReqRes) bool {
+	checkedAt, results := hp.check(ctx)
+	report := HealthReport{Status: HealthStatusHealthy, CheckedAt: &checkedAt, Checks: results}
+	if hp.config.ShuttingDown != nil && hp.config.ShuttingDown() {
+		report.Checks = maps.Clone(results)
+		report.Checks["shutdown"] = HealthCheckResult{Status: HealthStatusUnhealthy, Error: "shutting down", Duration: "0s"}
+	}
+	for _, result := range report.Checks {
+		if result.Status != HealthStatusHealthy {
+			report.Status = HealthStatusUnhealthy
+		}
+	}
+	rh := &svrcore.ResponseHeader{CacheControl: aids.New("no-store")}
+	return r.WriteSuccess(aids.Iif(report.Status == HealthStatusHealthy, http.StatusOK, http.StatusServiceUnavailable), rh, nil, report)
+}
+
+// check returns the checkers' (possibly cached) results & when they were produced; the checkers run concurrently
+func (hp *HealthProbes) check(ctx context.Context) (time.Time, map[string]HealthCheckResult) {
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+	if hp.results != nil && time.Since(hp.checked) < hp.config.CacheDuration {
+		return hp.checked, hp.results
+	}
+	// The results are shared with later probes so a probe's client going away mustn't cancel the checks
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), hp.config.Timeout)
+	defer cancel()
+	results, mu, wg := map[string]HealthCheckResult{}, sync.Mutex{}, sync.WaitGroup{}
+	for name, checker := range hp.config.Checkers {
+		wg.Go(func() {
+			start := time.Now()
+			err := make(chan error, 1) // The checker may ignore ctx; report it unhealthy when ctx is done
+			go func() { err <- checker.CheckHealth(ctx) }()
+			result := HealthCheckResult{Status: HealthStatusHealthy}
+			select {
+			case e := <-err:
+				if e != nil {
+					result = HealthCheckResult{Status: HealthStatusUnhealthy, Error: e.Error()}
+				}
+			case <-ctx.Done():
+				result = HealthCheckResult{Status: HealthStatusUnhealthy, Error: ctx.Err().Error()}
+			}
+			result.Duration = time.Since(start).String()
+			mu.Lock()
+			defer mu.Unlock()
+			results[name] = result
+		})
+	}
+	wg.Wait()
+	hp.checked, hp.results = time.Now(), results
+	return hp.checked, hp.results
+}
@@ -0,0 +1,70 @@
+package stages
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JeffreyRichter/svrcore"
+)
+
+func TestHealthProbes(t *testing.T) {
+	var calls atomic.Int32
+	queueErr, shuttingDown := error(nil), false
+	hp := NewHealthProbes(HealthProbesConfig{
+		Checkers: map[string]HealthChecker{
+			"store": HealthCheckerFunc(func(context.Context) error { calls.Add(1); return nil }),
+			"queue": HealthCheckerFunc(func(context.Context) error { return queueErr }),
+			"stuck": HealthCheckerFunc(func(ctx context.Context) error { <-ctx.Done(); return nil }),
+		},
+		ShuttingDown:  func() bool { return shuttingDown },
+		CacheDuration: time.Hour,
+		Timeout:       20 * time.Millisecond,
+	})
+	handler := svrcore.BuildHandler(svrcore.BuildHandlerConfig{
+		ApiVersionInfos: []*svrcore.ApiVersionInfo{{GetRoutes: func(svrcore.ApiVersionRoutes) svrcore.ApiVersionRoutes {
+			return svrcore.ApiVersionRoutes{
+				"/health/live":  {"GET": {Stage: hp.LiveProbe}},
+				"/health/ready": {"GET": {Stage: hp.ReadyProbe}},
+			}
+		}}},
+		ApiVersionKeyName:     "Api-Version",
+		ApiVersionKeyLocation: svrcore.ApiVersionKeyLocationHeader,
+		Logger:                slog.New(slog.DiscardHandler),
+	})
+	probe := func(path string) (int, HealthReport) {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, path, nil))
+		var report HealthReport
+		if err := json.Unmarshal(rw.Body.Bytes(), &report); err != nil {
+			t.Fatalf("unparsable %s response %q: %v", path, rw.Body.String(), err)
+		}
+		return rw.Code, report
+	}
+
+	if code, report := probe("/health/live"); code != http.StatusOK || report.Status != HealthStatusHealthy {
+		t.Fatalf("expected live, got %d %+v", code, report)
+	}
+	code, report := probe("/health/ready")
+	if code != http.StatusServiceUnavailable || report.Status != HealthStatusUnhealthy || report.CheckedAt == nil ||
+		report.Checks["store"].Status != HealthStatusHealthy || report.Checks["queue"].Status != HealthStatusHealthy ||
+		report.Checks["stuck"].Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("expected only the stuck checker to be unhealthy, got %d %+v", code, report)
+	}
+
+	// Results are cached so probes don't hammer the dependencies; shutting down isn't cached
+	queueErr, shuttingDown = errors.New("queue unreachable"), true
+	code, report = probe("/health/ready")
+	if calls.Load() != 1 || report.Checks["queue"].Status != HealthStatusHealthy || report.Checks["shutdown"].Status != HealthStatusUnhealthy {
+		t.Fatalf("expected cached results plus the shutdown check, got %d calls & %+v", calls.Load(), report)
+	}
+	if code, _ := probe("/health/live"); code != http.StatusOK {
+		t.Fatalf("expected liveness to ignore shutdown & dependencies, got %d", code)
+	}
+}
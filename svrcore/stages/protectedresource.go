@@ -0,0 +1,95 @@
+package stages
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+// ProtectedResourceMetadataPath is the well-known URL path of an OAuth 2.0 protected resource's metadata (RFC 9728).
+const ProtectedResourceMetadataPath = "/.well-known/oauth-protected-resource"
+
+// ProtectedResourceMetadata describes an OAuth 2.0 protected resource so clients can discover the authorization
+// servers that issue tokens for it (RFC 9728).
+type ProtectedResourceMetadata struct {
+	Resource               string   `json:"resource"`
+	AuthorizationServers   []string `json:"authorization_servers,omitempty"`
+	ScopesSupported        []string `json:"scopes_supported,omitempty"`
+	BearerMethodsSupported []string `json:"bearer_methods_supported,omitempty"`
+	ResourceName           string   `json:"resource_name,omitempty"`
+	ResourceDocumentation  string   `json:"resource_documentation,omitempty"`
+}
+
+// ProtectedResourceConfig configures the metadata published by NewProtectedResourceStage &
+// GetProtectedResourceMetadata.
+type ProtectedResourceConfig struct {
+	// Resource is the resource's canonical URI (ex: "https://mcp.example.com/mcp"); if empty, it's the request's
+	// scheme & host.
+	Resource string
+
+	// AuthorizationServers are the issuer URLs of the authorization servers that issue tokens for the resource.
+	AuthorizationServers []string
+
+	// ScopesSupported are the scopes clients can request to access the resource.
+	ScopesSupported []string
+
+	// ResourceName, if not empty, is a human-readable name for the resource.
+	ResourceName string
+}
+
+// resource returns the resource's canonical URI
+func (c *ProtectedResourceConfig) resource(r *http.Request) string {
+	if c.Resource != "" {
+		return c.Resource
+	}
+	return aids.Iif(r.TLS != nil, "https", "http") + "://" + r.Host
+}
+
+// MetadataURL returns the absolute URL of the resource's metadata: the well-known path inserted between the
+// resource's host & path (RFC 9728 §3.1).
+func (c *ProtectedResourceConfig) MetadataURL(r *http.Request) string {
+	u, err := url.Parse(c.resource(r))
+	if aids.IsError(err) || u.Host == "" {
+		return ProtectedResourceMetadataPath
+	}
+	return u.Scheme + "://" + u.Host + ProtectedResourceMetadataPath + strings.TrimSuffix(u.EscapedPath(), "/")
+}
+
+// isMetadataPath returns true if path is the well-known metadata path (optionally followed by the resource's path)
+func isMetadataPath(path string) bool {
+	return path == ProtectedResourceMetadataPath || strings.HasPrefix(path, ProtectedResourceMetadataPath+"/")
+}
+
+// GetProtectedResourceMetadata returns a route stage that writes the resource's metadata; mount it at
+// ProtectedResourceMetadataPath & allow anonymous access to it.
+func GetProtectedResourceMetadata(c ProtectedResourceConfig) svrcore.Stage {
+	return func(ctx context.Context, r *svrcore.ReqRes) bool {
+		return r.WriteSuccess(http.StatusOK, nil, nil, ProtectedResourceMetadata{
+			Resource:               c.resource(r.R),
+			AuthorizationServers:   c.AuthorizationServers,
+			ScopesSupported:        c.ScopesSupported,
+			BearerMethodsSupported: []string{"header"},
+			ResourceName:           c.ResourceName,
+		})
+	}
+}
+
+// NewProtectedResourceStage creates a stage that answers GET requests for the resource's metadata before any
+// later stage (authentication, api-version routing) sees them; all other requests pass to the next stage.
+// Pass the same config to AuthConfig.ProtectedResource so 401 challenges point clients at the metadata.
+func NewProtectedResourceStage(c ProtectedResourceConfig) svrcore.Stage {
+	getMetadata := GetProtectedResourceMetadata(c)
+	return func(ctx context.Context, r *svrcore.ReqRes) bool {
+		if !isMetadataPath(r.R.URL.Path) {
+			return r.Next(ctx)
+		}
+		if r.R.Method != http.MethodGet {
+			return r.WriteError(http.StatusMethodNotAllowed, nil, nil, "NotAllowed", "%s not allowed for %s", r.R.Method, r.R.URL.Path)
+		}
+		return getMetadata(ctx, r)
+	}
+}
@@ -0,0 +1,74 @@
+package stages
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/JeffreyRichter/svrcore"
+)
+
+func TestProtectedResource(t *testing.T) {
+	prc := ProtectedResourceConfig{
+		Resource:             "https://mcp.example.com/mcp",
+		AuthorizationServers: []string{"https://login.example.com"},
+		ScopesSupported:      []string{"tools:read", "tools:write"},
+	}
+	handler := svrcore.BuildHandler(svrcore.BuildHandlerConfig{
+		Stages: []svrcore.Stage{
+			NewProtectedResourceStage(prc),
+			NewAuthStage(AuthConfig{Keys: JWTKeys{"k": []byte("secret")}, ProtectedResource: &prc}),
+		},
+		ApiVersionInfos:       []*svrcore.ApiVersionInfo{{GetRoutes: func(svrcore.ApiVersionRoutes) svrcore.ApiVersionRoutes { return svrcore.ApiVersionRoutes{} }}},
+		ApiVersionKeyName:     "Api-Version",
+		ApiVersionKeyLocation: svrcore.ApiVersionKeyLocationHeader,
+		Logger:                slog.New(slog.DiscardHandler),
+	})
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest(method, path, nil))
+		return rw
+	}
+
+	// Unauthenticated requests are challenged with the metadata's URL
+	rw := serve(http.MethodGet, "/mcp/tools")
+	const expected = `Bearer resource_metadata="https://mcp.example.com/.well-known/oauth-protected-resource/mcp"`
+	if challenge := rw.Header().Get("WWW-Authenticate"); rw.Code != http.StatusUnauthorized || challenge != expected {
+		t.Fatalf("expected 401 with challenge %q, got %d %q", expected, rw.Code, challenge)
+	}
+
+	// The metadata is served without authentication
+	rw = serve(http.MethodGet, ProtectedResourceMetadataPath+"/mcp")
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected 200 for the metadata, got %d", rw.Code)
+	}
+	var md ProtectedResourceMetadata
+	if err := json.Unmarshal(rw.Body.Bytes(), &md); err != nil {
+		t.Fatal(err)
+	}
+	if md.Resource != prc.Resource || !slices.Equal(md.AuthorizationServers, prc.AuthorizationServers) ||
+		!slices.Equal(md.ScopesSupported, prc.ScopesSupported) || !slices.Equal(md.BearerMethodsSupported, []string{"header"}) {
+		t.Fatalf("unexpected metadata %+v", md)
+	}
+
+	if rw = serve(http.MethodPost, ProtectedResourceMetadataPath); rw.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for POST, got %d", rw.Code)
+	}
+}
+
+func TestProtectedResourceMetadataURL(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/mcp/tools", nil)
+	for resource, expected := range map[string]string{
+		"":                           "http://localhost:8080" + ProtectedResourceMetadataPath,
+		"https://mcp.example.com":    "https://mcp.example.com" + ProtectedResourceMetadataPath,
+		"https://mcp.example.com/":   "https://mcp.example.com" + ProtectedResourceMetadataPath,
+		"https://mcp.example.com/v1": "https://mcp.example.com" + ProtectedResourceMetadataPath + "/v1",
+	} {
+		if actual := (&ProtectedResourceConfig{Resource: resource}).MetadataURL(r); actual != expected {
+			t.Errorf("%q: expected %q, got %q", resource, expected, actual)
+		}
+	}
+}
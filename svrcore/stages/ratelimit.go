@@ -0,0 +1,154 @@
+package stages
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+	"github.com/JeffreyRichter/svrcore"
+)
+
+// RateLimit is a token bucket's budget: the bucket holds up to Limit tokens (requests) & is refilled evenly so
+// Limit requests are allowed per Window.
+type RateLimit struct {
+	Limit  int
+	Window time.Duration
+}
+
+// ParseRateLimit parses "limit/window" (ex: "100/1m") into a RateLimit; window is a time.ParseDuration string.
+func ParseRateLimit(s string) (RateLimit, error) {
+	limit, window, ok := strings.Cut(s, "/")
+	l := RateLimit{}
+	l.Limit, _ = strconv.Atoi(strings.TrimSpace(limit))
+	l.Window, _ = time.ParseDuration(strings.TrimSpace(window))
+	if !ok || l.Limit <= 0 || l.Window <= 0 {
+		return RateLimit{}, fmt.Errorf("rate limit %q must look like limit/window (ex: 100/1m)", s)
+	}
+	return l, nil
+}
+
+// RateLimitResult is the outcome of taking a token from a bucket.
+type RateLimitResult struct {
+	Allowed    bool          // True if a token was taken
+	Remaining  int           // The tokens left in the bucket
+	Reset      time.Duration // The time until the bucket is full again
+	RetryAfter time.Duration // If not Allowed, the time until a token is available
+}
+
+// Take takes a token from a bucket whose state is tat (the time at which the bucket will be full again; zero
+// for a new bucket) returning the bucket's new state & the result. A bucket is a single time so backends only
+// need to store & atomically replace 1 value per key (this is the generic cell rate algorithm which behaves
+// exactly like a token bucket).
+func (l RateLimit) Take(tat time.Time, now time.Time) (time.Time, RateLimitResult) {
+	interval := l.Window / time.Duration(l.Limit) // The time to refill 1 token
+	tat = aids.Iif(tat.Before(now), now, tat)
+	if newTat := tat.Add(interval); newTat.Sub(now) <= l.Window {
+		return newTat, RateLimitResult{Allowed: true, Remaining: int((l.Window - newTat.Sub(now)) / interval), Reset: newTat.Sub(now)}
+	}
+	return tat, RateLimitResult{Remaining: 0, Reset: tat.Sub(now), RetryAfter: tat.Add(interval).Sub(now) - l.Window}
+}
+
+// RateLimitBackend stores token buckets. NewMemoryRateLimitBackend keeps them in this process; implement the
+// interface over a shared store (ex: Redis) with RateLimit.Take so a cluster's servers share budgets.
+type RateLimitBackend interface {
+	// Take takes a token (if available) from key's bucket which is refilled per limit.
+	Take(ctx context.Context, key string, limit RateLimit, now time.Time) (RateLimitResult, error)
+}
+
+// NewMemoryRateLimitBackend creates a RateLimitBackend storing buckets in this process's memory.
+func NewMemoryRateLimitBackend() RateLimitBackend {
+	return &memoryRateLimitBackend{tats: map[string]time.Time{}, lastSweep: time.Now()}
+}
+
+type memoryRateLimitBackend struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time // Key to the time at which its bucket will be full again
+	lastSweep time.Time
+}
+
+func (b *memoryRateLimitBackend) Take(_ context.Context, key string, limit RateLimit, now time.Time) (RateLimitResult, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.Sub(b.lastSweep) > time.Minute { // Full buckets are the same as missing buckets; remove them to bound memory
+		for k, tat := range b.tats {
+			if tat.Before(now) {
+				delete(b.tats, k)
+			}
+		}
+		b.lastSweep = now
+	}
+	tat, result := limit.Take(b.tats[key], now)
+	b.tats[key] = tat
+	return result, nil
+}
+
+// RateLimitConfig configures the stage created by NewRateLimitStage.
+type RateLimitConfig struct {
+	// Limits maps an HTTP method to the budget of each caller's requests with that method; each method has its
+	// own budget (ex: PUTs creating work vs. GETs polling). Requests with other methods aren't limited.
+	Limits map[string]RateLimit
+
+	// Key, if not nil, returns the identity whose budget a request consumes. The default is the authenticated
+	// caller's tenant & subject (see PrincipalFromContext) or else the client's remote IP address.
+	Key func(ctx context.Context, r *svrcore.ReqRes) string
+
+	// Backend stores the budgets; the default is NewMemoryRateLimitBackend().
+	Backend RateLimitBackend
+
+	// ErrorLogger logs Backend errors; requests are allowed if the Backend fails.
+	ErrorLogger *slog.Logger
+}
+
+// NewRateLimitStage creates a stage that limits each caller's request rate per c.Limits. Limited responses
+// include RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset & RateLimit-Policy headers (IETF httpapi draft);
+// requests exceeding their budget get a 429-Too Many Requests response with a Retry-After header.
+// Place it after the authentication stage so callers are identified by their principal.
+func NewRateLimitStage(c RateLimitConfig) svrcore.Stage {
+	for method, l := range c.Limits {
+		aids.Assert(l.Limit > 0 && l.Window > 0, fmt.Errorf("rate limit for %s must have a positive Limit & Window", method))
+	}
+	if c.Key == nil {
+		c.Key = func(ctx context.Context, r *svrcore.ReqRes) string {
+			if p, ok := PrincipalFromContext(ctx); ok {
+				return "principal:" + p.Tenant + "/" + p.Subject
+			}
+			host, _, err := net.SplitHostPort(r.R.RemoteAddr)
+			return "addr:" + aids.Iif(aids.IsError(err), r.R.RemoteAddr, host)
+		}
+	}
+	if c.Backend == nil {
+		c.Backend = NewMemoryRateLimitBackend()
+	}
+	if c.ErrorLogger == nil {
+		c.ErrorLogger = slog.Default()
+	}
+	seconds := func(d time.Duration) int { return int((d + time.Second - 1) / time.Second) } // Rounded up
+	return func(ctx context.Context, r *svrcore.ReqRes) bool {
+		limit, ok := c.Limits[r.R.Method]
+		if !ok {
+			return r.Next(ctx)
+		}
+		result, err := c.Backend.Take(ctx, r.R.Method+" "+c.Key(ctx, r), limit, time.Now())
+		if aids.IsError(err) {
+			c.ErrorLogger.LogAttrs(ctx, slog.LevelError, "Rate limit backend failed; allowing request", slog.String("error", err.Error()))
+			return r.Next(ctx)
+		}
+		h := r.RW.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(limit.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+		h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Limit, seconds(limit.Window)))
+		if !result.Allowed {
+			return r.WriteError(http.StatusTooManyRequests, &svrcore.ResponseHeader{RetryAfter: aids.New(int32(seconds(result.RetryAfter)))}, nil,
+				"TooManyRequests", "Too many %s requests; retry after %s", r.R.Method, result.RetryAfter.Round(time.Millisecond))
+		}
+		return r.Next(ctx)
+	}
+}
@@ -0,0 +1,110 @@
+package stages
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JeffreyRichter/svrcore"
+)
+
+func TestRateLimitTake(t *testing.T) {
+	l, now := RateLimit{Limit: 3, Window: 3 * time.Second}, time.Now()
+	tat := time.Time{}
+	for i := range 3 { // A new bucket is full so a burst of Limit requests is allowed
+		var r RateLimitResult
+		if tat, r = l.Take(tat, now); !r.Allowed || r.Remaining != 2-i || r.Reset != time.Duration(i+1)*time.Second {
+			t.Fatalf("request %d: unexpected result %+v", i, r)
+		}
+	}
+	if _, r := l.Take(tat, now); r.Allowed || r.RetryAfter != time.Second || r.Remaining != 0 {
+		t.Fatalf("expected an empty bucket to deny the request for 1s, got %+v", r)
+	}
+	// 1 token is refilled per Window/Limit
+	if _, r := l.Take(tat, now.Add(time.Second)); !r.Allowed || r.Remaining != 0 {
+		t.Fatalf("expected a refilled token to be allowed, got %+v", r)
+	}
+	if _, r := l.Take(tat, now.Add(time.Hour)); !r.Allowed || r.Remaining != 2 {
+		t.Fatalf("expected an idle bucket to be full, got %+v", r)
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	if l, err := ParseRateLimit("100/1m"); err != nil || l != (RateLimit{Limit: 100, Window: time.Minute}) {
+		t.Fatalf("unexpected %+v, %v", l, err)
+	}
+	for _, s := range []string{"", "100", "0/1m", "100/0s", "x/1m", "100/x"} {
+		if _, err := ParseRateLimit(s); err == nil {
+			t.Errorf("expected %q to fail parsing", s)
+		}
+	}
+}
+
+type failingRateLimitBackend struct{}
+
+func (failingRateLimitBackend) Take(context.Context, string, RateLimit, time.Time) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("backend unavailable")
+}
+
+func TestRateLimitStage(t *testing.T) {
+	newHandler := func(c RateLimitConfig) http.Handler {
+		ok := func(ctx context.Context, r *svrcore.ReqRes) bool { return r.WriteSuccess(http.StatusOK, nil, nil, nil) }
+		return svrcore.BuildHandler(svrcore.BuildHandlerConfig{
+			Stages: []svrcore.Stage{NewRateLimitStage(c)},
+			ApiVersionInfos: []*svrcore.ApiVersionInfo{{GetRoutes: func(svrcore.ApiVersionRoutes) svrcore.ApiVersionRoutes {
+				return svrcore.ApiVersionRoutes{"/r": {"GET": {Stage: ok}, "PUT": {Stage: ok}, "DELETE": {Stage: ok}}}
+			}}},
+			ApiVersionKeyName:     "Api-Version",
+			ApiVersionKeyLocation: svrcore.ApiVersionKeyLocationHeader,
+			Logger:                slog.New(slog.DiscardHandler),
+		})
+	}
+	handler := newHandler(RateLimitConfig{Limits: map[string]RateLimit{
+		http.MethodPut: {Limit: 1, Window: time.Minute},
+		http.MethodGet: {Limit: 2, Window: time.Minute},
+	}})
+	serve := func(h http.Handler, method, remoteAddr string) *httptest.ResponseRecorder {
+		rw, req := httptest.NewRecorder(), httptest.NewRequest(method, "/r", nil)
+		req.RemoteAddr = remoteAddr
+		h.ServeHTTP(rw, req)
+		return rw
+	}
+
+	if rw := serve(handler, http.MethodPut, "192.0.2.1:1000"); rw.Code != http.StatusOK || rw.Header().Get("RateLimit-Remaining") != "0" ||
+		rw.Header().Get("RateLimit-Limit") != "1" || rw.Header().Get("RateLimit-Policy") != "1;w=60" {
+		t.Fatalf("expected the 1st PUT to be allowed with RateLimit headers, got %d %v", rw.Code, rw.Header())
+	}
+	rw := serve(handler, http.MethodPut, "192.0.2.1:2000") // Same client, different port
+	if rw.Code != http.StatusTooManyRequests || rw.Header().Get("Retry-After") != "60" || rw.Header().Get("RateLimit-Reset") != "60" {
+		t.Fatalf("expected 429 with Retry-After, got %d %v", rw.Code, rw.Header())
+	}
+
+	// GETs have their own budget & other clients & methods aren't affected
+	for _, c := range []struct {
+		method, remoteAddr string
+		status             int
+	}{
+		{http.MethodGet, "192.0.2.1:1000", http.StatusOK},
+		{http.MethodGet, "192.0.2.1:1000", http.StatusOK},
+		{http.MethodGet, "192.0.2.1:1000", http.StatusTooManyRequests},
+		{http.MethodPut, "192.0.2.2:1000", http.StatusOK},
+		{http.MethodDelete, "192.0.2.1:1000", http.StatusOK},
+	} {
+		if rw := serve(handler, c.method, c.remoteAddr); rw.Code != c.status {
+			t.Errorf("%s from %s: expected %d, got %d", c.method, c.remoteAddr, c.status, rw.Code)
+		}
+	}
+
+	// Requests are allowed if the backend fails
+	handler = newHandler(RateLimitConfig{Limits: map[string]RateLimit{http.MethodGet: {Limit: 1, Window: time.Minute}},
+		Backend: failingRateLimitBackend{}, ErrorLogger: slog.New(slog.DiscardHandler)})
+	for range 2 {
+		if rw := serve(handler, http.MethodGet, "192.0.2.1:1000"); rw.Code != http.StatusOK {
+			t.Fatalf("expected requests to be allowed when the backend fails, got %d", rw.Code)
+		}
+	}
+}
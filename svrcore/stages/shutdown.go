@@ -1,15 +1,9 @@
-// TODO: Here's what we want...
-// 1. When process receives SIGINT/SIGTERM/(SIGQUIT: Ctrl+\), set flag indicating shutdown started.
-// 		HealthProbe() returns 503-ServiceUnavailable if shutdown has been requested; else 200-OK.
-// 2. Delay Xxx for load balancer to stop sending traffic.
-// 3. After delay, create context (derived from Background) and pass to http.Server's Shutdown method
-// 4. Immediately cancel http.Server's BaseContext
-
 package stages
 
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
@@ -19,21 +13,39 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/JeffreyRichter/internal/aids"
 	"github.com/JeffreyRichter/svrcore"
 )
 
-// ShutdownMgr provides a stage that returns 503-ServiceUnavailable if the service is shutting down.
-// It also provides a context that is canceled after a delay when shutdown is requested.
-// This context can be used as the BaseContext for http.Server to cancel all in-flight requests.
+// ShutdownMgr gracefully shuts down the service:
+//  1. When shutdown starts (SIGINT/SIGTERM or a call to Shutdown), ShuttingDown starts returning true so the health
+//     probe returns 503-ServiceUnavailable & the stage rejects new requests with 503-ServiceUnavailable.
+//  2. After HealthProbeDelay (giving the load balancer time to stop sending traffic), Context is canceled (ending
+//     long-running requests & running phases) & the drain funcs registered with OnShutdown (ex: http.Server's
+//     Shutdown & the phase managers' Drain) are called.
+//  3. The in-flight requests & drain funcs are waited for up to CancellationDelay.
+//
+// Context can be used as the BaseContext for http.Server to cancel all in-flight requests.
 type ShutdownMgr struct {
 	// Context canceled after Config.HealthProbeDelay notifies load balancer to remove node
 	context.Context
+	config           ShutdownMgrConfig
+	mu               sync.RWMutex // Guarantees no request is added to inflightRequests once shuttingDown is set
 	shuttingDown     atomic.Bool
-	inflightRequests sync.WaitGroup          // We could Wait() to block until no more inflight requests but we can't hang forever if a request is in a infinite loop
+	inflightRequests sync.WaitGroup          // Waited for (up to CancellationDelay) since a request in an infinite loop mustn't hang shutdown
 	ctxCancel        context.CancelCauseFunc // Cancels Context
+	drains           []shutdownDrain         // Called when Context is canceled
+	once             sync.Once               // Shutdown runs once
+	err              error                   // Shutdown's result
+}
+
+// shutdownDrain is a func registered with OnShutdown
+type shutdownDrain struct {
+	name  string
+	drain func(ctx context.Context) error
 }
 
-// ShuttingDown returns true after this processes receives a signal to shutdown.
+// ShuttingDown returns true after shutdown starts.
 func (sm *ShutdownMgr) ShuttingDown() bool { return sm.shuttingDown.Load() }
 
 // HealthProbe can be called in response to an HTTP GET. It returns 503-ServiceUnavailable if
@@ -52,50 +64,119 @@ type ShutdownMgrConfig struct {
 	// HealthProbeDelay indicates the time the load balancer takes to stop sending traffic to the process.
 	// After this delay, all operations using ShutdownMgr are canceled.
 	HealthProbeDelay time.Duration
-	// CancellationDelay indicates the time to wait after ShutdownMgr is canceled before forcefully terminating the process
+	// CancellationDelay is the longest to wait, after ShutdownMgr is canceled, for in-flight requests & drain funcs
+	// to complete before forcefully terminating the process
 	CancellationDelay time.Duration
 }
 
-// NewShutdownMgr creates a new ShutdownMgr using the passed-in ShutdownConfig.
+// NewShutdownMgr creates a new ShutdownMgr using the passed-in ShutdownConfig that shuts down when the process
+// receives SIGINT or SIGTERM & then exits the process: with 0 if everything drained; else with 1.
 // You can set http.Serve's BaseContext to `func(_ net.Listener) context.Context { return shutdownCtx }`.
 func NewShutdownMgr(c ShutdownMgrConfig) *ShutdownMgr {
-	sm := &ShutdownMgr{shuttingDown: atomic.Bool{}, inflightRequests: sync.WaitGroup{}}
-	sm.Context, sm.ctxCancel = context.WithCancelCause(context.Background())
-
+	sm := NewManualShutdownMgr(c)
 	go func() {
 		// Listen for shutdown signals (e.g., SIGINT, SIGTERM)
 		sigs := make(chan os.Signal, 1)
 		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM) // Register the signals we want the channel to receive
-		switch sig := <-sigs; sig {                          // Block until signal is received
-		case syscall.SIGINT, syscall.SIGTERM: // SIGINT is Ctrl-C, SIGTERM is default termination signal
-			c.ErrorLogger.LogAttrs(sm.Context, slog.LevelInfo,
-				"Server shutdown start", slog.String("signal", sig.String()))
-			// 1. Set flag indicating that shutdown has been requested (health probe uses this to notify load balancer to take node out of rotation)
-			sm.shuttingDown.Store(true) // All future requests immedidately return http.StatusServiceUnavailable via this stage
-
-			// 2. Give some time for health probe/load balancer to stop sending traffic to this node
-			time.Sleep(c.HealthProbeDelay)
-
-			// 3. Give some time to cancel any remaining in-flight requests
-			sm.ctxCancel(errors.New("shutdown requested")) // Cancel the after-inflight-requests context
-			time.Sleep(c.CancellationDelay)
-
-			// 4. No more time given, force node shutdown
-			c.ErrorLogger.LogAttrs(sm.Context, slog.LevelInfo, "Server shutdown complete")
-			os.Exit(1) // Kill this service instance
-		}
+		sig := <-sigs                                        // Block until signal is received; SIGINT is Ctrl-C, SIGTERM is default termination signal
+		sm.config.ErrorLogger.LogAttrs(sm.Context, slog.LevelInfo, "Server shutdown requested", slog.String("signal", sig.String()))
+		os.Exit(aids.Iif(sm.Shutdown(context.Background()) == nil, 0, 1)) // Kill this service instance
 	}()
 	return sm
 }
 
+// NewManualShutdownMgr creates a new ShutdownMgr using the passed-in ShutdownConfig that shuts down only when
+// Shutdown is called; it installs no signal handlers & never exits the process so it's useful for tests.
+func NewManualShutdownMgr(c ShutdownMgrConfig) *ShutdownMgr {
+	if c.ErrorLogger == nil {
+		c.ErrorLogger = slog.Default()
+	}
+	sm := &ShutdownMgr{config: c}
+	sm.Context, sm.ctxCancel = context.WithCancelCause(context.Background())
+	return sm
+}
+
+// OnShutdown registers drain to be called (concurrently with other drain funcs) when Context is canceled; drain must
+// return once its work completes or its ctx (whose deadline is CancellationDelay away) is done. For example, pass an
+// http.Server's Shutdown method to stop accepting connections & wait for in-flight requests.
+func (sm *ShutdownMgr) OnShutdown(name string, drain func(ctx context.Context) error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.drains = append(sm.drains, shutdownDrain{name: name, drain: drain})
+}
+
+// Shutdown gracefully shuts down the service (see ShutdownMgr) returning nil if all in-flight requests & drain funcs
+// completed within CancellationDelay; else an error describing what didn't. It returns ctx's error if ctx is done
+// first. Calls after the 1st wait for & return the 1st call's result.
+func (sm *ShutdownMgr) Shutdown(ctx context.Context) error {
+	sm.once.Do(func() { sm.err = sm.shutdown(ctx) })
+	return sm.err
+}
+
+func (sm *ShutdownMgr) shutdown(ctx context.Context) error {
+	sm.config.ErrorLogger.LogAttrs(ctx, slog.LevelInfo, "Server shutdown start")
+	// 1. Set flag indicating that shutdown has been requested (health probe uses this to notify load balancer to take node out of rotation)
+	sm.mu.Lock()
+	sm.shuttingDown.Store(true) // All future requests immediately return http.StatusServiceUnavailable via this stage
+	drains := sm.drains
+	sm.mu.Unlock()
+
+	// 2. Give some time for health probe/load balancer to stop sending traffic to this node
+	select {
+	case <-time.After(sm.config.HealthProbeDelay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	// 3. Cancel long-running operations & give some time for in-flight requests & drain funcs to complete
+	sm.ctxCancel(errors.New("shutdown requested"))
+	drainCtx, cancel := context.WithTimeout(ctx, sm.config.CancellationDelay)
+	defer cancel()
+	errs := make(chan error, len(drains)+1)
+	go func() {
+		requestsDone := make(chan struct{})
+		go func() { sm.inflightRequests.Wait(); close(requestsDone) }()
+		select {
+		case <-requestsDone:
+			errs <- nil
+		case <-drainCtx.Done():
+			errs <- fmt.Errorf("in-flight requests: %w", drainCtx.Err())
+		}
+	}()
+	for _, d := range drains {
+		go func() {
+			if err := d.drain(drainCtx); err != nil {
+				errs <- fmt.Errorf("%s: %w", d.name, err)
+				return
+			}
+			errs <- nil
+		}()
+	}
+	var err error
+	for range len(drains) + 1 {
+		err = errors.Join(err, <-errs)
+	}
+
+	// 4. No more time given
+	if err != nil {
+		sm.config.ErrorLogger.LogAttrs(ctx, slog.LevelError, "Server shutdown incomplete", slog.String("error", err.Error()))
+		return err
+	}
+	sm.config.ErrorLogger.LogAttrs(ctx, slog.LevelInfo, "Server shutdown complete")
+	return nil
+}
+
 // NewStage creates a new shutdown stage using ShutdownMgr.
 // This stage returns a 503-ServiceUnavailable if the service is shutting down; else the request is processed normally.
 func (sm *ShutdownMgr) NewStage() svrcore.Stage {
 	return func(ctx context.Context, r *svrcore.ReqRes) bool {
+		sm.mu.RLock()
 		if sm.ShuttingDown() {
+			sm.mu.RUnlock()
 			return r.WriteError(http.StatusServiceUnavailable, nil, nil, "Server unavailable", "This server instance is shutting down. Please try again.")
 		}
 		sm.inflightRequests.Add(1) // Add 1 to wait group whenever a new request comes into the service
+		sm.mu.RUnlock()
 		defer sm.inflightRequests.Done()
 		return r.Next(ctx)
 	}
@@ -0,0 +1,74 @@
+package stages
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JeffreyRichter/svrcore"
+)
+
+func TestShutdownMgr(t *testing.T) {
+	sm := NewManualShutdownMgr(ShutdownMgrConfig{ErrorLogger: slog.New(slog.DiscardHandler), HealthProbeDelay: 10 * time.Millisecond, CancellationDelay: time.Second})
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := svrcore.BuildHandler(svrcore.BuildHandlerConfig{
+		Stages: []svrcore.Stage{sm.NewStage()},
+		ApiVersionInfos: []*svrcore.ApiVersionInfo{{GetRoutes: func(svrcore.ApiVersionRoutes) svrcore.ApiVersionRoutes {
+			return svrcore.ApiVersionRoutes{"/slow": {"GET": {Stage: func(ctx context.Context, r *svrcore.ReqRes) bool {
+				close(started)
+				<-release // An in-flight request completing after shutdown starts
+				return r.WriteSuccess(http.StatusOK, nil, nil, nil)
+			}}}}
+		}}},
+		ApiVersionKeyName:     "Api-Version",
+		ApiVersionKeyLocation: svrcore.ApiVersionKeyLocationHeader,
+		Logger:                slog.New(slog.DiscardHandler),
+	})
+	slow := httptest.NewRecorder()
+	go handler.ServeHTTP(slow, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	<-started
+
+	drained := false
+	sm.OnShutdown("drain", func(ctx context.Context) error {
+		if sm.Err() == nil {
+			return errors.New("expected Context to be canceled before drains are called")
+		}
+		drained = true
+		return nil
+	})
+	shutdown := make(chan error)
+	go func() { shutdown <- sm.Shutdown(context.Background()) }()
+	for !sm.ShuttingDown() {
+		time.Sleep(time.Millisecond)
+	}
+	rejected := httptest.NewRecorder()
+	handler.ServeHTTP(rejected, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if rejected.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected new requests to be rejected, got %d", rejected.Code)
+	}
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned %v before the in-flight request completed", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-shutdown; err != nil || !drained || slow.Code != http.StatusOK {
+		t.Fatalf("expected a clean drain, got %v (drained=%t, status=%d)", err, drained, slow.Code)
+	}
+	if err := sm.Shutdown(context.Background()); err != nil {
+		t.Fatalf("expected later calls to return the 1st call's result, got %v", err)
+	}
+}
+
+func TestShutdownMgrTimeout(t *testing.T) {
+	sm := NewManualShutdownMgr(ShutdownMgrConfig{ErrorLogger: slog.New(slog.DiscardHandler), CancellationDelay: 20 * time.Millisecond})
+	sm.OnShutdown("stuck", func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() })
+	sm.OnShutdown("quick", func(context.Context) error { return nil })
+	if err := sm.Shutdown(context.Background()); !errors.Is(err, context.DeadlineExceeded) || err.Error() != "stuck: context deadline exceeded" {
+		t.Fatalf("expected the stuck drain to time out, got %v", err)
+	}
+}
@@ -5,9 +5,13 @@ import (
 	"log/slog"
 	"net/http"
 	"runtime"
+	"strconv"
 	"time"
 
+	"github.com/JeffreyRichter/internal/aids"
 	"github.com/JeffreyRichter/svrcore"
+	"github.com/JeffreyRichter/svrcore/metrics"
+	"github.com/JeffreyRichter/svrcore/tracing"
 )
 
 func NewSharedKeyStage(sharedKey string) svrcore.Stage {
@@ -19,54 +23,76 @@ func NewSharedKeyStage(sharedKey string) svrcore.Stage {
 	}
 }
 
+// NewThrottlingStage creates a stage limiting all callers' requests to maxRequestsPerSecond (a fixed 1-second
+// window); use NewRateLimitStage to give each caller its own budget.
 func NewThrottlingStage(maxRequestsPerSecond int) svrcore.Stage {
 	requestPerSecond := newRateCounter(time.Second)
 	return func(ctx context.Context, r *svrcore.ReqRes) bool {
 		if requestPerSecond.Rate() >= maxRequestsPerSecond {
-			return r.WriteError(http.StatusTooManyRequests, nil, nil, "TooManyRequests", "Too many requests")
+			return r.WriteError(http.StatusTooManyRequests, &svrcore.ResponseHeader{RetryAfter: aids.New(int32(1))}, nil, "TooManyRequests", "Too many requests")
 		}
+		requestPerSecond.Add(1)
 		return r.Next(ctx)
 	}
 }
 
-func NewDistributedTracingStage() svrcore.Stage {
-	return func(ctx context.Context, r *svrcore.ReqRes) bool { return r.Next(ctx) }
+// NewDistributedTracingStage creates a stage that starts a server span per request whose parent is the caller's
+// W3C trace context (traceparent & tracestate headers) if any; later stages get the span from ctx (see
+// tracing.SpanFromContext) to link it to other spans or propagate it (see tracing.Inject). The span is named by
+// the request's method & matched route pattern (ex: "GET /items/{id}") & t exports it when the response is written.
+// A nil t still propagates the caller's trace context without starting spans.
+func NewDistributedTracingStage(t *tracing.Tracer) svrcore.Stage {
+	return func(ctx context.Context, r *svrcore.ReqRes) bool {
+		ctx, span := t.Start(tracing.Extract(ctx, r.R.Header), r.R.Method, tracing.SpanKindServer)
+		defer func() {
+			status := aids.Iif(r.RW.StatusCode == 0, http.StatusOK, r.RW.StatusCode) // 0 if the body was written without WriteHeader
+			span.SetAttributes(
+				slog.String("http.request.method", r.R.Method),
+				slog.String("url.path", r.R.URL.Path),
+				slog.Int("http.response.status_code", status))
+			if route := r.Route(); route != "" {
+				span.SetName(r.R.Method + " " + route)
+				span.SetAttributes(slog.String("http.route", route))
+			}
+			if r.ApiVersion() != "" {
+				span.SetAttributes(slog.String("api_version", r.ApiVersion()))
+			}
+			if status >= 500 {
+				span.SetError(http.StatusText(status))
+			}
+			span.End()
+		}()
+		return r.Next(ctx)
+	}
 }
 
-func NewMetricsStage(logger *slog.Logger) svrcore.Stage {
-	requestCountPerMinute := newRateCounter(time.Minute)
-	requestLatencyPerMinute := newRateCounter(time.Minute)
-	requestServiceFailuresPerMinute := newRateCounter(time.Minute)
-	lastUpdate := time.Now()
+// NewMetricsStage creates a stage that records each request's count & latency in reg labeled by api_version,
+// route (the matched URL pattern), method & status; it also registers the process's
+// goroutine count & heap size. Create 1 metrics stage per Registry.
+func NewMetricsStage(reg *metrics.Registry) svrcore.Stage {
+	// Add support for https://shopify.engineering/building-resilient-payment-systems (See "4. Add Monitoring and Alerting")
+	// Google’s site reliability engineering (SRE) book lists four golden signals a user-facing system should be monitored for:
+	labelNames := []string{"api_version", "route", "method", "status"}
+	// Traffic & Errors: the rate in which new work comes into the system & the rate of unexpected things (5xx) happening.
+	requests := reg.NewCounter("http_requests_total", "HTTP requests processed.", labelNames...)
+	// Latency: the amount of time it takes to process a unit of work, broken down between success and failures.
+	latency := reg.NewHistogram("http_request_duration_seconds", "HTTP request latency in seconds.", nil, labelNames...)
+	// Saturation: how much load the system is under, relative to its total capacity.
+	reg.NewGaugeFunc("go_goroutines", "Number of goroutines that currently exist.", func() float64 { return float64(runtime.NumGoroutine()) })
+	reg.NewGaugeFunc("go_memstats_heap_alloc_bytes", "Number of heap bytes allocated and still in use.", func() float64 {
+		var memStats runtime.MemStats
+		runtime.ReadMemStats(&memStats)
+		return float64(memStats.HeapAlloc)
+	})
 
 	return func(ctx context.Context, r *svrcore.ReqRes) bool {
-		// Add support for https://shopify.engineering/building-resilient-payment-systems (See "4. Add Monitoring and Alerting")
-		// Google’s site reliability engineering (SRE) book lists four golden signals a user-facing system should be monitored for:
-		requestCountPerMinute.Add(1) // Traffic: the rate in which new work comes into the system, typically expressed in requests per minute.
 		start := time.Now()
 		defer func() {
-			duration := time.Since(start) // Latency: the amount of time it takes to process a unit of work, broken down between success and failures.
-			requestLatencyPerMinute.Add(int(duration.Milliseconds()))
-			if r.RW.StatusCode >= 500 && r.RW.StatusCode < 600 {
-				requestServiceFailuresPerMinute.Add(1) // Errors: the rate of unexpected service things (5xx) happening.
-			}
-
-			// Saturation: how much load the system is under, relative to its total capacity.
-			// This could be the amount of memory used versus available or a thread pool’s
-			// active threads versus total number of threads available, in any layer of
-			// the system.
-			if time.Since(lastUpdate) > 1*time.Minute {
-				lastUpdate = time.Now()
-				var memStats runtime.MemStats
-				runtime.ReadMemStats(&memStats)
-				logger.LogAttrs(ctx, slog.LevelInfo, "Metrics",
-					slog.Int("req/min", requestCountPerMinute.Rate()),
-					slog.Int("req ms/min", requestLatencyPerMinute.Rate()),
-					slog.Int("5xx/min", requestServiceFailuresPerMinute.Rate()),
-					slog.Int("HeapMem", int(memStats.Alloc)),
-					slog.Int("GCs", int(memStats.NumGC)),
-					slog.Int("Goroutines", runtime.NumGoroutine()))
-			}
+			route := aids.Iif(r.Route() == "", "unmatched", r.Route())                             // A route's pattern keeps the label's cardinality bounded
+			status := strconv.Itoa(aids.Iif(r.RW.StatusCode == 0, http.StatusOK, r.RW.StatusCode)) // 0 if the body was written without WriteHeader
+			labelValues := []string{r.ApiVersion(), route, r.R.Method, status}
+			requests.Inc(labelValues...)
+			latency.Observe(time.Since(start).Seconds(), labelValues...)
 		}()
 		return r.Next(ctx)
 	}
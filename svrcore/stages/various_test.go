@@ -0,0 +1,93 @@
+package stages
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JeffreyRichter/svrcore"
+	"github.com/JeffreyRichter/svrcore/metrics"
+	"github.com/JeffreyRichter/svrcore/tracing"
+)
+
+func TestMetricsStage(t *testing.T) {
+	reg := metrics.NewRegistry()
+	handler := svrcore.BuildHandler(svrcore.BuildHandlerConfig{
+		Stages: []svrcore.Stage{NewMetricsStage(reg)},
+		ApiVersionInfos: []*svrcore.ApiVersionInfo{{ApiVersion: "2025-01-01", GetRoutes: func(svrcore.ApiVersionRoutes) svrcore.ApiVersionRoutes {
+			return svrcore.ApiVersionRoutes{
+				"/items/{id}": {"GET": {Stage: func(ctx context.Context, r *svrcore.ReqRes) bool {
+					if r.R.PathValue("id") == "missing" {
+						return r.WriteError(http.StatusNotFound, nil, nil, "NotFound", "No such item")
+					}
+					_, _ = r.RW.Write([]byte("{}")) // An implicit 200
+					return false
+				}}},
+			}
+		}}},
+		ApiVersionKeyName:     "Api-Version",
+		ApiVersionKeyLocation: svrcore.ApiVersionKeyLocationHeader,
+		Logger:                slog.New(slog.DiscardHandler),
+	})
+	for _, path := range []string{"/items/1", "/items/2", "/items/missing", "/nowhere"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Api-Version", "2025-01-01")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	b := &strings.Builder{}
+	if err := reg.WriteText(b); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`http_requests_total{api_version="2025-01-01",route="/items/{id}",method="GET",status="200"} 2`,
+		`http_requests_total{api_version="2025-01-01",route="/items/{id}",method="GET",status="404"} 1`,
+		`http_requests_total{api_version="2025-01-01",route="unmatched",method="GET",status="404"} 1`,
+		`http_request_duration_seconds_count{api_version="2025-01-01",route="/items/{id}",method="GET",status="200"} 2`,
+		`# TYPE go_goroutines gauge`,
+	} {
+		if !strings.Contains(b.String(), expected) {
+			t.Errorf("expected %q in:\n%s", expected, b.String())
+		}
+	}
+}
+
+func TestDistributedTracingStage(t *testing.T) {
+	e := tracing.NewInMemoryExporter()
+	var routeSpan tracing.SpanContext
+	handler := svrcore.BuildHandler(svrcore.BuildHandlerConfig{
+		Stages: []svrcore.Stage{NewDistributedTracingStage(tracing.NewTracer(e, nil))},
+		ApiVersionInfos: []*svrcore.ApiVersionInfo{{GetRoutes: func(svrcore.ApiVersionRoutes) svrcore.ApiVersionRoutes {
+			return svrcore.ApiVersionRoutes{"/items/{id}": {"GET": {Stage: func(ctx context.Context, r *svrcore.ReqRes) bool {
+				routeSpan = tracing.SpanContextFromContext(ctx)
+				return r.WriteError(http.StatusInternalServerError, nil, nil, "InternalServerError", "Failed")
+			}}}}
+		}}},
+		ApiVersionKeyName:     "Api-Version",
+		ApiVersionKeyLocation: svrcore.ApiVersionKeyLocationHeader,
+		Logger:                slog.New(slog.DiscardHandler),
+	})
+	req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := e.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %+v", spans)
+	}
+	s := spans[0]
+	if s.Name != "GET /items/{id}" || s.Kind != tracing.SpanKindServer || s.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+		s.Parent.String() != "00f067aa0ba902b7" || !s.Error || s.SpanContext.SpanID != routeSpan.SpanID {
+		t.Fatalf("unexpected span %+v", s)
+	}
+	attrs := map[string]string{}
+	for _, a := range s.Attributes {
+		attrs[a.Key] = a.Value.String()
+	}
+	if attrs["http.route"] != "/items/{id}" || attrs["http.response.status_code"] != "500" || attrs["url.path"] != "/items/1" {
+		t.Fatalf("unexpected attributes %v", attrs)
+	}
+}
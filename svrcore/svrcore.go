@@ -99,10 +99,12 @@ func BuildHandler(c BuildHandlerConfig) http.Handler {
 				aids.WriteStack(stack, aids.ParseStack(2))
 				fmt.Fprint(os.Stderr, stack.String()) // Also write stack to stdout so it shows up in container logs
 			}
+			defer rr.logAccess() // Log the request's access log line last so it has the final status
+
 			if stack.Len() == 0 && rr.numWriteHeaderCalls() == 1 {
 				return // No panic & exactly 1 response sent to the client; all went as expected
 			}
-			c.Logger.LogAttrs(rr.R.Context(), slog.LevelError, "Request error", slog.String("id", rr.id),
+			rr.l.LogAttrs(rr.R.Context(), slog.LevelError, "Request error",
 				slog.String("method", rr.R.Method), slog.String("url", rr.R.URL.String()),
 				slog.Int("numWriteHeaderCalls", rr.numWriteHeaderCalls()),
 				slog.String("stack", aids.Iif(stack.Len() == 0, "(no panic)", stack.String())))
@@ -174,6 +176,8 @@ func newApiVersionToServeMuxStage(avis apiVersionInfos, apiVersionKeyName string
 					s := w.(*smuggler)
 					hackPostActionForServeHTTP(r, false)
 					s.r.R = r // Replace old R with new 'r' which has PathValues set
+					s.r.route = url
+					s.r.AddLogAttrs(slog.String("route", url))
 					s.stop = s.r.validateRequestHeader(stageInfo.ValidHeader)
 					if !s.stop {
 						s.stop = stageInfo.Stage(s.ctx, s.r) // Smuggle the continue/stop flag  back to our caller
@@ -247,6 +251,7 @@ func (p *apiVersionToServeMuxStage) next(ctx context.Context, r *ReqRes) bool {
 		// TODO: Add support for recommending a newer api-version?
 	}
 
+	r.apiVersion = avi.ApiVersion
 	hackPostActionForServeHTTP(r.R, true)
 	handler, pattern := avi.serveMux.Handler(r.R) // Gets api-version's ServeMux
 	if pattern == "" {                            // No pattern: handler writes 404 or 405
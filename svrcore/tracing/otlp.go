@@ -0,0 +1,280 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JeffreyRichter/internal/aids"
+)
+
+// OTLPConfig configures the exporter created by NewOTLPExporter.
+type OTLPConfig struct {
+	// Endpoint is the collector's base URL (ex: "http://localhost:4318"); spans are POSTed to Endpoint + "/v1/traces".
+	Endpoint string
+
+	// Headers are added to each export request (ex: an API key).
+	Headers map[string]string
+
+	// ServiceName is the service.name resource attribute identifying this service's spans.
+	ServiceName string
+
+	// Client sends the export requests; the default has a 10-second timeout.
+	Client *http.Client
+
+	// BatchSize is the most spans sent per export request; the default is 512.
+	BatchSize int
+
+	// FlushInterval is the longest a span waits to be sent; the default is 5 seconds.
+	FlushInterval time.Duration
+
+	// MaxQueueSize is the most spans waiting to be sent; spans exported when the queue is full are dropped (& logged).
+	// The default is 4096.
+	MaxQueueSize int
+
+	// ErrorLogger logs failed export requests & dropped spans.
+	ErrorLogger *slog.Logger
+}
+
+// OTLPExporter is an Exporter sending spans in batches to an OpenTelemetry collector using OTLP/HTTP with JSON
+// encoding (https://opentelemetry.io/docs/specs/otlp/).
+type OTLPExporter struct {
+	config  OTLPConfig
+	mu      sync.Mutex
+	queue   []SpanData
+	flushCh chan chan struct{} // Requests an immediate flush; the sent chan is closed when it completes
+}
+
+// NewOTLPExporter creates an OTLPExporter whose background goroutine sends batches until ctx is done, at which point
+// it sends the queued spans.
+func NewOTLPExporter(ctx context.Context, c OTLPConfig) *OTLPExporter {
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 512
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = 5 * time.Second
+	}
+	if c.MaxQueueSize <= 0 {
+		c.MaxQueueSize = 4096
+	}
+	if c.ErrorLogger == nil {
+		c.ErrorLogger = slog.Default()
+	}
+	c.Endpoint = strings.TrimSuffix(c.Endpoint, "/") + "/v1/traces"
+	e := &OTLPExporter{config: c, flushCh: make(chan chan struct{})}
+	go e.run(ctx)
+	return e
+}
+
+// Export queues spans to be sent by the exporter's background goroutine.
+func (e *OTLPExporter) Export(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if dropped := len(e.queue) + len(spans) - e.config.MaxQueueSize; dropped > 0 {
+		spans = spans[:max(len(spans)-dropped, 0)]
+		e.config.ErrorLogger.Error("OTLP export queue full; spans dropped", slog.Int("dropped", dropped))
+	}
+	e.queue = append(e.queue, spans...)
+	return nil
+}
+
+// Flush sends all queued spans; it returns ctx's error if ctx is done first.
+func (e *OTLPExporter) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case e.flushCh <- done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run sends batches every FlushInterval (or when Flush is called) until ctx is done
+func (e *OTLPExporter) run(ctx context.Context) {
+	ticker := time.NewTicker(e.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		var done chan struct{}
+		select {
+		case <-ctx.Done():
+			e.sendAll(context.WithoutCancel(ctx))
+			return
+		case <-ticker.C:
+		case done = <-e.flushCh:
+		}
+		e.sendAll(ctx)
+		if done != nil {
+			close(done)
+		}
+	}
+}
+
+// sendAll sends the queued spans in batches of up to BatchSize
+func (e *OTLPExporter) sendAll(ctx context.Context) {
+	for {
+		e.mu.Lock()
+		batch := e.queue[:min(len(e.queue), e.config.BatchSize)]
+		e.queue = e.queue[len(batch):]
+		e.mu.Unlock()
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(ctx, batch); err != nil {
+			e.config.ErrorLogger.LogAttrs(ctx, slog.LevelError, "OTLP export failed",
+				slog.String("endpoint", e.config.Endpoint), slog.Int("spans", len(batch)), slog.String("error", err.Error()))
+		}
+	}
+}
+
+// send POSTs one batch of spans
+func (e *OTLPExporter) send(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.config.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("collector returned %s: %s", resp.Status, b)
+	}
+	return nil
+}
+
+// The OTLP/HTTP JSON request body (ExportTraceServiceRequest); IDs are hex & 64-bit integers are strings.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		TraceState        string         `json:"traceState,omitempty"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Flags             uint32         `json:"flags"`
+		Name              string         `json:"name"`
+		Kind              SpanKind       `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Links             []otlpLink     `json:"links,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpLink struct {
+		TraceID    string `json:"traceId"`
+		SpanID     string `json:"spanId"`
+		TraceState string `json:"traceState,omitempty"`
+		Flags      uint32 `json:"flags"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"` // 0: unset, 1: ok, 2: error
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+func (e *OTLPExporter) request(spans []SpanData) otlpRequest {
+	ss := otlpScopeSpans{Scope: otlpScope{Name: "github.com/JeffreyRichter/svrcore/tracing"}}
+	for _, s := range spans {
+		o := otlpSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.SpanID.String(),
+			TraceState:        s.TraceState,
+			Flags:             uint32(s.Flags),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attributes),
+		}
+		if s.Parent.IsValid() {
+			o.ParentSpanID = s.Parent.String()
+		}
+		for _, l := range s.Links {
+			o.Links = append(o.Links, otlpLink{TraceID: l.TraceID.String(), SpanID: l.SpanID.String(), TraceState: l.TraceState, Flags: uint32(l.Flags)})
+		}
+		if s.Error {
+			o.Status = otlpStatus{Code: 2, Message: s.StatusMessage}
+		}
+		ss.Spans = append(ss.Spans, o)
+	}
+	resource := otlpResource{Attributes: otlpAttributes([]slog.Attr{slog.String("service.name", e.config.ServiceName)})}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{Resource: resource, ScopeSpans: []otlpScopeSpans{ss}}}}
+}
+
+// otlpAttributes converts slog attributes to OTLP attributes; groups are flattened with dotted keys
+func otlpAttributes(attrs []slog.Attr) []otlpKeyValue {
+	kvs := []otlpKeyValue{}
+	for _, a := range attrs {
+		v := a.Value.Resolve()
+		switch v.Kind() {
+		case slog.KindGroup:
+			for _, kv := range otlpAttributes(v.Group()) {
+				kv.Key = a.Key + "." + kv.Key
+				kvs = append(kvs, kv)
+			}
+			continue
+		case slog.KindBool:
+			kvs = append(kvs, otlpKeyValue{a.Key, otlpAnyValue{BoolValue: aids.New(v.Bool())}})
+		case slog.KindInt64:
+			kvs = append(kvs, otlpKeyValue{a.Key, otlpAnyValue{IntValue: aids.New(strconv.FormatInt(v.Int64(), 10))}})
+		case slog.KindUint64:
+			kvs = append(kvs, otlpKeyValue{a.Key, otlpAnyValue{IntValue: aids.New(strconv.FormatUint(v.Uint64(), 10))}})
+		case slog.KindFloat64:
+			kvs = append(kvs, otlpKeyValue{a.Key, otlpAnyValue{DoubleValue: aids.New(v.Float64())}})
+		default:
+			kvs = append(kvs, otlpKeyValue{a.Key, otlpAnyValue{StringValue: aids.New(v.String())}})
+		}
+	}
+	return kvs
+}
@@ -0,0 +1,315 @@
+// Package tracing implements W3C Trace Context (https://www.w3.org/TR/trace-context/) propagation & spans that
+// are sent to a pluggable Exporter (ex: an OTLP/HTTP collector).
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// W3C Trace Context HTTP headers
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// IsValid returns true if id isn't all zeros.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// IsValid returns true if id isn't all zeros.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// TraceFlags are a trace context's trace-flags.
+type TraceFlags byte
+
+// FlagsSampled indicates the caller may have recorded the trace.
+const FlagsSampled TraceFlags = 0x01
+
+// SpanContext is the part of a span propagated across processes: a traceparent's IDs & flags plus the vendor-specific
+// tracestate.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      TraceFlags
+	TraceState string
+}
+
+// IsValid returns true if sc's TraceID & SpanID are valid.
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// Sampled returns true if sc's spans should be exported.
+func (sc SpanContext) Sampled() bool { return sc.Flags&FlagsSampled != 0 }
+
+// TraceParent returns sc as a traceparent header value (ex: "00-<trace-id>-<parent-id>-01"); "" if sc is invalid.
+func (sc SpanContext) TraceParent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, byte(sc.Flags))
+}
+
+var traceParentRegexp = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})(-.*)?$`)
+
+// ParseTraceParent parses traceparent & tracestate header values into a SpanContext. Per the W3C spec, a version
+// 00 traceparent must have exactly 4 fields while later versions may append fields; tracestate is kept as is.
+func ParseTraceParent(traceParent, traceState string) (SpanContext, error) {
+	m := traceParentRegexp.FindStringSubmatch(strings.TrimSpace(traceParent))
+	if m == nil || m[1] == "ff" || (m[1] == "00" && m[5] != "") {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", traceParent)
+	}
+	sc := SpanContext{TraceState: strings.TrimSpace(traceState)}
+	_, _ = hex.Decode(sc.TraceID[:], []byte(m[2])) // The regexp guarantees valid hex
+	_, _ = hex.Decode(sc.SpanID[:], []byte(m[3]))
+	flags, _ := hex.DecodeString(m[4])
+	sc.Flags = TraceFlags(flags[0])
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q: all-zero trace-id or parent-id", traceParent)
+	}
+	return sc, nil
+}
+
+// Extract returns ctx with the remote SpanContext from h's traceparent & tracestate headers (if valid) so spans
+// started with the returned context are its children.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	sc, err := ParseTraceParent(h.Get(TraceParentHeader), strings.Join(h.Values(TraceStateHeader), ","))
+	if err != nil {
+		return ctx
+	}
+	return ContextWithSpanContext(ctx, sc)
+}
+
+// Inject sets h's traceparent & tracestate headers from ctx's SpanContext (if any) to propagate it to another service.
+func Inject(ctx context.Context, h http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(TraceParentHeader, sc.TraceParent())
+	if sc.TraceState != "" {
+		h.Set(TraceStateHeader, sc.TraceState)
+	}
+}
+
+type (
+	spanKey        struct{}
+	spanContextKey struct{}
+)
+
+// ContextWithSpanContext returns ctx with a remote (ex: from another process) SpanContext that becomes the parent
+// of spans started with the returned context.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(context.WithValue(ctx, spanKey{}, (*Span)(nil)), spanContextKey{}, sc)
+}
+
+// SpanFromContext returns ctx's current span; nil if ctx has no span started in this process.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// SpanContextFromContext returns the SpanContext of ctx's current span or remote parent; it's invalid if neither.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if s := SpanFromContext(ctx); s != nil {
+		return s.SpanContext()
+	}
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+// SpanKind describes a span's relationship to its parent & children; the values match OTLP's.
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1 // An operation within this process
+	SpanKindServer   SpanKind = 2 // Processing a remote client's request
+	SpanKindClient   SpanKind = 3 // A request to a remote service
+)
+
+// SpanData is a read-only snapshot of an ended span passed to an Exporter.
+type SpanData struct {
+	SpanContext
+	Parent        SpanID // Invalid if the span is a trace's root
+	Name          string
+	Kind          SpanKind
+	Start, End    time.Time
+	Attributes    []slog.Attr
+	Links         []SpanContext // Spans in this or other traces that are related to this span
+	Error         bool          // True if the span's operation failed
+	StatusMessage string        // Describes the error if Error is true
+}
+
+// Exporter sends ended spans to a tracing backend.
+type Exporter interface {
+	// Export exports the spans; it must not block for long since it's called when a span ends.
+	Export(ctx context.Context, spans []SpanData) error
+}
+
+// Tracer starts spans & sends them to its Exporter when they end. A nil *Tracer is valid & starts no spans (its
+// spans are nil whose methods do nothing) so tracing can be disabled without changing callers.
+type Tracer struct {
+	exporter    Exporter
+	errorLogger *slog.Logger
+}
+
+// NewTracer creates a Tracer exporting sampled spans to exporter; errorLogger (slog.Default() if nil) logs Export errors.
+func NewTracer(exporter Exporter, errorLogger *slog.Logger) *Tracer {
+	if errorLogger == nil {
+		errorLogger = slog.Default()
+	}
+	return &Tracer{exporter: exporter, errorLogger: errorLogger}
+}
+
+// Start starts a span that's a child of ctx's SpanContext (or the root of a new, sampled trace) returning it & a
+// context with it as the current span. The span must be ended by calling End.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind, links ...SpanContext) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	parent := SpanContextFromContext(ctx)
+	s := &Span{tracer: t, data: SpanData{Name: name, Kind: kind, Start: time.Now()}}
+	if parent.IsValid() {
+		s.data.SpanContext, s.data.Parent = parent, parent.SpanID
+	} else {
+		_, _ = rand.Read(s.data.TraceID[:]) // guaranteed to return len(b), nil
+		s.data.Flags = FlagsSampled
+	}
+	_, _ = rand.Read(s.data.SpanID[:])
+	for _, l := range links {
+		s.AddLink(l)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Span is an operation within a trace. Its methods are safe for concurrent use & do nothing if the span is nil.
+type Span struct {
+	tracer *Tracer
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+// SpanContext returns the span's SpanContext; it's invalid if s is nil.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext // Immutable after Start
+}
+
+// SetName replaces the span's name (ex: once a request's route is known).
+func (s *Span) SetName(name string) {
+	s.update(func(d *SpanData) { d.Name = name })
+}
+
+// SetAttributes adds attributes to the span replacing any with the same keys.
+func (s *Span) SetAttributes(attrs ...slog.Attr) {
+	s.update(func(d *SpanData) {
+		for _, a := range attrs {
+			if i := slices.IndexFunc(d.Attributes, func(e slog.Attr) bool { return e.Key == a.Key }); i >= 0 {
+				d.Attributes[i] = a
+			} else {
+				d.Attributes = append(d.Attributes, a)
+			}
+		}
+	})
+}
+
+// AddLink links the span to a related span (ex: the span that created a resource the request uses); invalid span
+// contexts & the span's own parent are ignored.
+func (s *Span) AddLink(sc SpanContext) {
+	s.update(func(d *SpanData) {
+		if sc.IsValid() && !(sc.TraceID == d.TraceID && sc.SpanID == d.Parent) && !slices.Contains(d.Links, sc) {
+			d.Links = append(d.Links, sc)
+		}
+	})
+}
+
+// SetError marks the span's operation as failed.
+func (s *Span) SetError(message string) {
+	s.update(func(d *SpanData) { d.Error, d.StatusMessage = true, message })
+}
+
+// End ends the span & exports it if it's sampled; calls after the 1st do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended, s.data.End = true, time.Now()
+	d := s.data
+	s.mu.Unlock()
+	if !d.Sampled() || s.tracer.exporter == nil {
+		return
+	}
+	if err := s.tracer.exporter.Export(context.Background(), []SpanData{d}); err != nil {
+		s.tracer.errorLogger.Error("Span export failed", slog.String("span", d.Name), slog.String("error", err.Error()))
+	}
+}
+
+// update calls f with the span's data unless the span is nil or ended
+func (s *Span) update(f func(d *SpanData)) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		f(&s.data)
+	}
+}
+
+// InMemoryExporter is an Exporter keeping spans in memory; it's useful for tests.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// NewInMemoryExporter creates an empty InMemoryExporter.
+func NewInMemoryExporter() *InMemoryExporter { return &InMemoryExporter{} }
+
+// Export appends spans to the exporter's spans.
+func (e *InMemoryExporter) Export(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// Spans returns the exported spans in the order they ended.
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Clone(e.spans)
+}
+
+// Reset removes all exported spans.
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
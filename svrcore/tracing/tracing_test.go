@@ -0,0 +1,149 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceParent(traceParent, " congo=t61rcWkgMzE ")
+	if err != nil || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" ||
+		!sc.Sampled() || sc.TraceState != "congo=t61rcWkgMzE" || sc.TraceParent() != traceParent {
+		t.Fatalf("unexpected %+v, %v", sc, err)
+	}
+	if sc, err := ParseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future", ""); err != nil || sc.Sampled() {
+		t.Fatalf("expected a later version's extra fields to be ignored, got %+v, %v", sc, err)
+	}
+	for _, s := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", // Version 00 has exactly 4 fields
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",       // Version ff is invalid
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",       // Uppercase hex is invalid
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+	} {
+		if _, err := ParseTraceParent(s, ""); err == nil {
+			t.Errorf("expected %q to fail parsing", s)
+		}
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	in := http.Header{"Traceparent": []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, "Tracestate": []string{"a=1", "b=2"}}
+	ctx := Extract(context.Background(), in)
+	out := http.Header{}
+	Inject(ctx, out)
+	if out.Get(TraceParentHeader) != in.Get(TraceParentHeader) || out.Get(TraceStateHeader) != "a=1,b=2" {
+		t.Fatalf("expected the extracted trace context to be injected, got %v", out)
+	}
+	out = http.Header{}
+	Inject(Extract(context.Background(), http.Header{"Traceparent": []string{"garbage"}}), out)
+	if len(out) != 0 {
+		t.Fatalf("expected an invalid traceparent to be ignored, got %v", out)
+	}
+}
+
+func TestTracer(t *testing.T) {
+	e := NewInMemoryExporter()
+	tracer := NewTracer(e, nil)
+	ctx, root := tracer.Start(context.Background(), "root", SpanKindServer)
+	if !root.SpanContext().IsValid() || !root.SpanContext().Sampled() {
+		t.Fatalf("expected a new sampled trace, got %+v", root.SpanContext())
+	}
+	link := SpanContext{TraceID: TraceID{1}, SpanID: SpanID{2}}
+	_, child := tracer.Start(ctx, "child", SpanKindInternal, link, root.SpanContext())
+	child.SetAttributes(slog.String("k", "v1"), slog.Int("n", 1))
+	child.SetAttributes(slog.String("k", "v2"))
+	child.SetError("failed")
+	child.End()
+	child.SetName("ignored after End")
+	root.End()
+	root.End()
+
+	spans := e.Spans()
+	if len(spans) != 2 || spans[0].Name != "child" || spans[1].Name != "root" {
+		t.Fatalf("expected child & root to be exported once each, got %+v", spans)
+	}
+	c := spans[0]
+	if c.TraceID != root.SpanContext().TraceID || c.Parent != root.SpanContext().SpanID || c.Kind != SpanKindInternal ||
+		!c.Error || c.StatusMessage != "failed" || len(c.Attributes) != 2 || c.Attributes[0].Value.String() != "v2" {
+		t.Fatalf("unexpected child %+v", c)
+	}
+	if len(c.Links) != 1 || c.Links[0] != link { // A link to the parent is redundant
+		t.Fatalf("unexpected links %+v", c.Links)
+	}
+
+	// An unsampled parent's children are propagated but not exported
+	e.Reset()
+	remote := SpanContext{TraceID: TraceID{3}, SpanID: SpanID{4}}
+	ctx, span := tracer.Start(ContextWithSpanContext(context.Background(), remote), "unsampled", SpanKindServer)
+	span.End()
+	if len(e.Spans()) != 0 || SpanContextFromContext(ctx).TraceID != remote.TraceID {
+		t.Fatalf("expected the unsampled span to continue the trace without being exported, got %+v", e.Spans())
+	}
+
+	// A nil Tracer starts nil spans whose methods do nothing
+	ctx, span = (*Tracer)(nil).Start(context.Background(), "nil", SpanKindServer)
+	span.SetName("x")
+	span.End()
+	if span != nil || SpanFromContext(ctx) != nil || span.SpanContext().IsValid() {
+		t.Fatal("expected a nil span")
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	requests := make(chan map[string]any, 10)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Api-Key") != "secret" ||
+			json.NewDecoder(r.Body).Decode(&body) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		requests <- body
+	}))
+	defer collector.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e := NewOTLPExporter(ctx, OTLPConfig{Endpoint: collector.URL + "/", Headers: map[string]string{"Api-Key": "secret"},
+		ServiceName: "test", BatchSize: 1, FlushInterval: time.Hour})
+	tracer := NewTracer(e, nil)
+	ctx, parent := tracer.Start(ctx, "parent", SpanKindServer)
+	_, child := tracer.Start(ctx, "child", SpanKindInternal)
+	child.SetAttributes(slog.Bool("b", true), slog.Int("i", 7), slog.Float64("f", 1.5), slog.Group("g", slog.String("s", "x")))
+	child.SetError("oops")
+	child.End()
+	parent.End()
+	if err := e.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 { // BatchSize is 1
+		t.Fatalf("expected 2 export requests, got %d", len(requests))
+	}
+
+	rs := (<-requests)["resourceSpans"].([]any)[0].(map[string]any)
+	if v := rs["resource"].(map[string]any)["attributes"].([]any)[0]; v.(map[string]any)["key"] != "service.name" {
+		t.Fatalf("expected the service.name resource attribute, got %v", v)
+	}
+	span := rs["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)[0].(map[string]any)
+	if span["name"] != "child" || span["kind"] != float64(SpanKindInternal) || span["traceId"] != parent.SpanContext().TraceID.String() ||
+		span["parentSpanId"] != parent.SpanContext().SpanID.String() || span["status"].(map[string]any)["code"] != float64(2) {
+		t.Fatalf("unexpected span %v", span)
+	}
+	attrs := map[string]any{}
+	for _, a := range span["attributes"].([]any) {
+		attrs[a.(map[string]any)["key"].(string)] = a.(map[string]any)["value"]
+	}
+	for k, expected := range map[string]string{"b": "boolValue", "i": "intValue", "f": "doubleValue", "g.s": "stringValue"} {
+		if v, ok := attrs[k].(map[string]any); !ok || v[expected] == nil {
+			t.Errorf("expected attribute %s to be a %s, got %v", k, expected, attrs[k])
+		}
+	}
+}